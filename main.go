@@ -27,32 +27,26 @@ func main() {
 
 	// --- 2. Logic Setup ---
 	client := iso7816.NewClient(card)
-	cls, _ := iso7816.NewClass(0x00)
 
 	// --- 3. Execution Flow ---
 
-	// Step 1: Try to find the Payment System Environment (PSE)
-	sfi, err := step1SelectPSE(client, cls)
-	if err != nil {
-		log.Printf("Step 1 Warning: %v", err)
-		// We continue, because sometimes we might want to try manual selection later
-		// even if PSE fails (though in this demo, Step 2 depends on Step 1).
-	}
-
-	// Step 2: If we found a directory (SFI), read it to find Applications (AIDs)
-	var candidateAIDs [][]byte
-	if sfi > 0 {
-		candidateAIDs = step2ReadDirectory(client, cls, sfi)
-	} else {
-		fmt.Println("\n>> Step 2 Skipped: No Valid SFI found in Step 1.")
-	}
+	// Step 1: Build the candidate list (PSE, then list of AIDs as a fallback)
+	candidates := step1SelectApplications(client)
 
-	// Step 3: Select every application found
-	step3SelectCandidates(client, cls, candidateAIDs)
+	// Step 2: Describe every candidate application
+	step2DescribeCandidates(candidates)
 
 	fmt.Println("\n>> Demo Finished Successfully")
 }
 
+// terminalAIDs lists the applications supported by this demo terminal.
+var terminalAIDs = []emv.TerminalAID{
+	{AID: []byte{0xA0, 0x00, 0x00, 0x00, 0x03}, PartialSelection: true},       // Visa
+	{AID: []byte{0xA0, 0x00, 0x00, 0x00, 0x04}, PartialSelection: true},       // Mastercard
+	{AID: []byte{0xA0, 0x00, 0x00, 0x00, 0x42}, PartialSelection: true},       // CB
+	{AID: []byte{0xA0, 0x00, 0x00, 0x00, 0x25, 0x01}, PartialSelection: true}, // American Express
+}
+
 // =========================================================================
 // Helper Functions
 // =========================================================================
@@ -86,135 +80,38 @@ func connectToCard() (*scard.Context, *scard.Card) {
 	return ctx, card
 }
 
-// step1SelectPSE selects the Contact/Contactless PSE and tries to extract the SFI.
-func step1SelectPSE(client *iso7816.Client, cls iso7816.Class) (byte, error) {
+// step1SelectApplications runs the EMV application selection and returns the ranked candidates.
+func step1SelectApplications(client *iso7816.Client) []emv.Candidate {
 	fmt.Println("\n=============================================")
-	fmt.Println(" Step 1: SELECT PSE (1PAY.SYS.DDF01)")
+	fmt.Println(" Step 1: APPLICATION SELECTION (PSE / List of AIDs)")
 	fmt.Println("=============================================")
 
-	pseCmd := iso7816.SelectByAID(cls, []byte("1PAY.SYS.DDF01"))
-	pseTrace, err := client.Send(pseCmd)
-	if err != nil {
-		return 0, fmt.Errorf("transmission failed: %w", err)
-	}
-
-	pseRes, err := iso7816.NewSelectResult(pseTrace)
+	list, err := emv.NewSelector(client, terminalAIDs).BuildCandidateList()
 	if err != nil {
-		return 0, fmt.Errorf("result creation failed: %w", err)
-	}
-
-	fmt.Println(pseRes.Describe())
-
-	if !pseRes.IsSuccess() {
-		return 0, fmt.Errorf("PSE selection failed with status: %s", pseRes.Last().Response.Status.Verbose())
-	}
-
-	// Parse EMV Data
-	rawData := pseRes.Last().Response.Data
-	fciEmv, err := emv.ParseFCI(rawData)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse PSE FCI: %w", err)
-	}
-
-	fmt.Println(fciEmv.Describe())
-
-	// Extract SFI
-	if len(fciEmv.ProprietaryTemplate.SFI) > 0 {
-		return fciEmv.ProprietaryTemplate.SFI[0], nil
-	}
-
-	return 0, nil
-}
-
-// step2ReadDirectory iterates over records in the SFI to find Application IDs (AIDs).
-func step2ReadDirectory(client *iso7816.Client, cls iso7816.Class, sfi byte) [][]byte {
-	fmt.Println("\n=============================================")
-	fmt.Printf(" Step 2: EXPLORING DIRECTORY (SFI %d)\n", sfi)
-	fmt.Println(" Counting records until 'Record Not Found'...")
-	fmt.Println("=============================================")
-
-	var collectedAIDs [][]byte
-
-	// Loop strictly from 1 to 30 (max records in a file)
-	for recNum := byte(1); recNum <= 30; recNum++ {
-		fmt.Printf("\n[Record #%d] Querying target SFI %d...\n", recNum, sfi)
-
-		readCmd := iso7816.ReadRecord(cls, sfi, recNum)
-		readTrace, err := client.Send(readCmd)
-		if err != nil {
-			log.Printf("(!) Communication broken: %v", err)
-			break
-		}
-
-		// Stop if we hit the end of the file (Status 6A83)
-		if readTrace.Last().Response.Status == 0x6A83 {
-			fmt.Printf(">> Status 6A83 received: End of Directory reached.\n")
-			break
-		}
-
-		// Display Technical Report
-		readRes, _ := iso7816.NewReadRecordResult(readTrace)
-		fmt.Println(readRes.Describe())
-
-		if readRes.IsSuccess() {
-			// Parse EMV Business Data
-			rawData := readTrace.Last().Response.Data
-			fmt.Printf("   -> Found record entry (%d bytes). Parsing EMV content...\n", len(rawData))
-
-			if record, err := emv.ParseDirectoryRecord(rawData); err == nil {
-				fmt.Println(record.Describe())
-
-				// Collect AIDs found in this record
-				for _, app := range record.Applications {
-					if len(app.AID) > 0 {
-						fmt.Printf("      [+] Adding Candidate AID: %X (%s)\n", app.AID, app.ApplicationLabel)
-						collectedAIDs = append(collectedAIDs, app.AID)
-					}
-				}
-			} else {
-				fmt.Printf("   (!) Failed to parse EMV Directory Record: %v\n", err)
-			}
-		}
+		log.Printf("Step 1 Warning: %v", err)
 	}
 
-	return collectedAIDs
+	fmt.Println(list.Describe())
+	return list.Candidates
 }
 
-// step3SelectCandidates iterates through the list of found AIDs and selects them one by one.
-func step3SelectCandidates(client *iso7816.Client, cls iso7816.Class, aids [][]byte) {
+// step2DescribeCandidates displays the FCI and the PDOL of every candidate application.
+func step2DescribeCandidates(candidates []emv.Candidate) {
 	fmt.Println("\n=============================================")
-	fmt.Printf(" Step 3: SELECTING CANDIDATE APPLICATIONS (%d found)\n", len(aids))
+	fmt.Printf(" Step 2: CANDIDATE APPLICATIONS (%d found)\n", len(candidates))
 	fmt.Println("=============================================")
 
-	if len(aids) == 0 {
-		fmt.Println(">> No Applications found to select.")
-		return
-	}
-
-	for i, aid := range aids {
+	for i, c := range candidates {
 		fmt.Printf("\n------------------------------------------------------------\n")
-		fmt.Printf(" [App %d/%d] Selecting AID: %X\n", i+1, len(aids), aid)
+		fmt.Printf(" [App %d/%d] AID: %X (%s)\n", i+1, len(candidates), c.AID, c.Label)
 		fmt.Printf("------------------------------------------------------------\n")
 
-		selectCmd := iso7816.SelectByAID(cls, aid)
-		trace, err := client.Send(selectCmd)
-		if err != nil {
-			log.Printf("Transmission failed for AID %X: %v", aid, err)
-			continue
-		}
+		fmt.Println(c.FCI.Describe())
 
-		res, _ := iso7816.NewSelectResult(trace)
-		if res.IsSuccess() {
-			// Try to parse the response as an EMV FCI
-			rawData := res.Last().Response.Data
-			if fciEmv, err := emv.ParseFCI(rawData); err == nil {
-				fmt.Println(fciEmv.Describe())
-			} else {
-				// Fallback to generic ISO description if EMV parsing fails
-				fmt.Println(res.Describe())
-			}
-		} else {
-			fmt.Printf("Selection Failed: %s\n", res.Last().Response.Status.Verbose())
+		if pdol, err := c.FCI.ProprietaryTemplate.ParsePDOL(); err != nil {
+			fmt.Printf("   (!) %v\n", err)
+		} else if len(pdol) > 0 {
+			fmt.Println(pdol.Describe())
 		}
 	}
 }
@@ -0,0 +1,188 @@
+package emv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// TERMINAL ACTION ANALYSIS Logic according to EMV Book 3, section 10.7.
+// The terminal compares the TVR with the Issuer Action Codes (IAC, read from the card)
+// and the Terminal Action Codes (TAC, set by the acquirer). Action codes have the layout
+// of the TVR: a bit set both in a code and in the TVR triggers the action of the code.
+//
+// 1. Denial (IAC '9F0E', TAC-Denial): a match declines the transaction offline (AAC).
+// 2. Online (IAC '9F0F', TAC-Online), online capable terminals only: a match requests an
+//    online authorisation (ARQC), otherwise the transaction is approved offline (TC).
+// 3. Default (IAC '9F0D', TAC-Default), for offline-only terminals, and for online capable
+//    terminals unable to go online: a match declines (AAC), otherwise approve (TC).
+//
+// When the card does not provide them, the IAC-Denial defaults to '0000000000', and the
+// IAC-Online and IAC-Default to 'FFFFFFFFFF'.
+
+// ActionCodes are the Denial, Online and Default action codes of the issuer or the terminal.
+type ActionCodes struct {
+	Denial  TVR
+	Online  TVR
+	Default TVR
+}
+
+// IssuerActionCodes reads the Issuer Action Codes from the card data, applying the
+// default values of absent codes.
+func IssuerActionCodes(src DataSource) (ActionCodes, error) {
+	all := TVR{0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	codes := ActionCodes{Online: all, Default: all}
+
+	for _, c := range []struct {
+		tag  string
+		code *TVR
+	}{
+		{"9F0E", &codes.Denial},
+		{"9F0F", &codes.Online},
+		{"9F0D", &codes.Default},
+	} {
+		value, ok := src.Lookup(c.tag)
+		if !ok {
+			continue
+		}
+		code, err := NewTVR(value)
+		if err != nil {
+			return codes, fmt.Errorf("%s: %w", tagLabel(c.tag), err)
+		}
+		*c.code = code
+	}
+
+	return codes, nil
+}
+
+// ActionMatch lists the TVR bits that match an action code.
+type ActionMatch struct {
+	Code string // e.g. IAC-Denial or TAC-Online
+	Bits []TVRBit
+}
+
+// ActionDecision is the outcome of terminal action analysis.
+type ActionDecision struct {
+	Cryptogram CryptogramType // Requested in the first GENERATE AC
+	Phase      string         // Denial, Online or Default: the phase that took the decision
+
+	// Matches lists the bits that drove the decision. It is empty when the
+	// transaction is approved offline.
+	Matches []ActionMatch
+
+	Steps []string
+}
+
+// ActionAnalysis holds the inputs of terminal action analysis.
+type ActionAnalysis struct {
+	TVR           TVR
+	Issuer        ActionCodes
+	Terminal      ActionCodes
+	OnlineCapable bool
+}
+
+// Decide performs terminal action analysis before the first GENERATE AC.
+func (a ActionAnalysis) Decide() *ActionDecision {
+	d := &ActionDecision{}
+
+	if a.compare(d, "Denial", a.Issuer.Denial, a.Terminal.Denial) {
+		d.Cryptogram = CryptogramAAC
+		return d
+	}
+
+	if !a.OnlineCapable {
+		d.Steps = append(d.Steps, "Online: skipped, the terminal is offline-only")
+		return a.decideDefault(d)
+	}
+
+	if a.compare(d, "Online", a.Issuer.Online, a.Terminal.Online) {
+		d.Cryptogram = CryptogramARQC
+	} else {
+		d.Cryptogram = CryptogramTC
+	}
+	return d
+}
+
+// DecideUnableToGoOnline performs the default action analysis of an online capable terminal
+// that requested an ARQC but could not reach the issuer.
+func (a ActionAnalysis) DecideUnableToGoOnline() *ActionDecision {
+	d := &ActionDecision{Steps: []string{"Online: the terminal is unable to go online"}}
+	return a.decideDefault(d)
+}
+
+func (a ActionAnalysis) decideDefault(d *ActionDecision) *ActionDecision {
+	if a.compare(d, "Default", a.Issuer.Default, a.Terminal.Default) {
+		d.Cryptogram = CryptogramAAC
+	} else {
+		d.Cryptogram = CryptogramTC
+	}
+	return d
+}
+
+// compare checks the TVR against the IAC and the TAC of a phase and records the matches.
+func (a ActionAnalysis) compare(d *ActionDecision, phase string, iac, tac TVR) bool {
+	d.Phase = phase
+	matched := false
+
+	for _, c := range []struct {
+		name string
+		code TVR
+	}{
+		{"IAC-" + phase, iac},
+		{"TAC-" + phase, tac},
+	} {
+		var common TVR
+		for i := range common {
+			common[i] = a.TVR[i] & c.code[i]
+		}
+
+		set := common.SetBits()
+		if len(set) == 0 {
+			d.Steps = append(d.Steps, fmt.Sprintf("%s: %s %X, no match", phase, c.name, c.code[:]))
+			continue
+		}
+
+		matched = true
+		d.Matches = append(d.Matches, ActionMatch{Code: c.name, Bits: set})
+		d.Steps = append(d.Steps, fmt.Sprintf("%s: %s %X matches %s", phase, c.name, c.code[:], bitNames(set)))
+	}
+
+	return matched
+}
+
+func bitNames(set []TVRBit) string {
+	names := make([]string, len(set))
+	for i, b := range set {
+		names[i] = b.String()
+	}
+	return strings.Join(names, "; ")
+}
+
+// Report builds the structured report of the decision.
+func (d *ActionDecision) Report() *report.Report {
+	rep := report.New("EMV TERMINAL ACTION ANALYSIS")
+
+	steps := rep.AddSection("[1] Analysis:")
+	for _, s := range d.Steps {
+		steps.Note("", s)
+	}
+
+	decision := rep.AddSection("[=] DECISION:")
+	decision.Note("Cryptogram", fmt.Sprintf("%s (%s phase)", d.Cryptogram, d.Phase))
+	if len(d.Matches) == 0 {
+		decision.Note("Reason", "no TVR bit matches the action codes")
+	}
+	for _, m := range d.Matches {
+		for _, b := range m.Bits {
+			decision.Note(m.Code, b.String())
+		}
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable explanation of the decision.
+func (d *ActionDecision) Describe() string {
+	return report.Text(d.Report())
+}
@@ -0,0 +1,179 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestIssuerActionCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     TerminalData
+		expected ActionCodes
+		wantErr  bool
+	}{
+		{
+			name: "Absent",
+			data: TerminalData{},
+			expected: ActionCodes{
+				Online:  TVR{0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+				Default: TVR{0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			},
+		},
+		{
+			name: "Present",
+			data: TerminalData{
+				"9F0E": tlv.Hex("0010000000"),
+				"9F0F": tlv.Hex("DC4004F800"),
+				"9F0D": tlv.Hex("DC4000A800"),
+			},
+			expected: ActionCodes{
+				Denial:  TVR{0x00, 0x10, 0x00, 0x00, 0x00},
+				Online:  TVR{0xDC, 0x40, 0x04, 0xF8, 0x00},
+				Default: TVR{0xDC, 0x40, 0x00, 0xA8, 0x00},
+			},
+		},
+		{
+			name:    "Invalid Length",
+			data:    TerminalData{"9F0E": tlv.Hex("0010")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes, err := IssuerActionCodes(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IssuerActionCodes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, codes); diff != "" {
+				t.Errorf("Action codes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestActionAnalysisDecide(t *testing.T) {
+	issuer := ActionCodes{
+		Denial:  TVR{0x00, 0x10, 0x00, 0x00, 0x00}, // Service not allowed
+		Online:  TVR{0xDC, 0x40, 0x04, 0xF8, 0x00},
+		Default: TVR{0xDC, 0x40, 0x00, 0xA8, 0x00},
+	}
+	terminal := ActionCodes{
+		Denial:  TVR{0x00, 0x00, 0x00, 0x00, 0x00},
+		Online:  TVR{0x00, 0x00, 0x00, 0x00, 0x00},
+		Default: TVR{0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+
+	tests := []struct {
+		name          string
+		tvr           TVR
+		onlineCapable bool
+		cryptogram    CryptogramType
+		phase         string
+		matches       []ActionMatch
+	}{
+		{
+			name:          "Clean TVR",
+			onlineCapable: true,
+			cryptogram:    CryptogramTC,
+			phase:         "Online",
+		},
+		{
+			name:          "Service Not Allowed",
+			tvr:           TVR{0x00, 0x10, 0x00, 0x00, 0x00},
+			onlineCapable: true,
+			cryptogram:    CryptogramAAC,
+			phase:         "Denial",
+			matches:       []ActionMatch{{Code: "IAC-Denial", Bits: []TVRBit{TVRServiceNotAllowed}}},
+		},
+		{
+			name:          "Floor Limit Online",
+			tvr:           TVR{0x00, 0x00, 0x00, 0x80, 0x00},
+			onlineCapable: true,
+			cryptogram:    CryptogramARQC,
+			phase:         "Online",
+			matches:       []ActionMatch{{Code: "IAC-Online", Bits: []TVRBit{TVRExceedsFloorLimit}}},
+		},
+		{
+			name:       "Floor Limit Offline Only",
+			tvr:        TVR{0x00, 0x00, 0x00, 0x80, 0x00},
+			cryptogram: CryptogramAAC,
+			phase:      "Default",
+			matches:    []ActionMatch{{Code: "IAC-Default", Bits: []TVRBit{TVRExceedsFloorLimit}}},
+		},
+		{
+			name:       "Online PIN Offline Only",
+			tvr:        TVR{0x00, 0x00, 0x04, 0x00, 0x00},
+			cryptogram: CryptogramTC,
+			phase:      "Default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := ActionAnalysis{TVR: tt.tvr, Issuer: issuer, Terminal: terminal, OnlineCapable: tt.onlineCapable}
+			d := a.Decide()
+
+			if d.Cryptogram != tt.cryptogram || d.Phase != tt.phase {
+				t.Errorf("Decide() = %s (%s), want %s (%s)\n%s", d.Cryptogram, d.Phase, tt.cryptogram, tt.phase, d.Describe())
+			}
+			if diff := cmp.Diff(tt.matches, d.Matches); diff != "" {
+				t.Errorf("Matches mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestActionAnalysisUnableToGoOnline(t *testing.T) {
+	a := ActionAnalysis{
+		TVR:           TVR{0x80, 0x00, 0x00, 0x80, 0x00},
+		Issuer:        ActionCodes{Online: TVR{0x00, 0x00, 0x00, 0x80, 0x00}, Default: TVR{0x00, 0x00, 0x00, 0x00, 0x00}},
+		Terminal:      ActionCodes{Default: TVR{0x80, 0x00, 0x00, 0x00, 0x00}},
+		OnlineCapable: true,
+	}
+
+	if d := a.Decide(); d.Cryptogram != CryptogramARQC {
+		t.Fatalf("Decide() = %s, want ARQC", d.Cryptogram)
+	}
+
+	d := a.DecideUnableToGoOnline()
+	if d.Cryptogram != CryptogramAAC || d.Phase != "Default" {
+		t.Errorf("DecideUnableToGoOnline() = %s (%s), want AAC (Default)", d.Cryptogram, d.Phase)
+	}
+	expected := []ActionMatch{{Code: "TAC-Default", Bits: []TVRBit{TVROfflineDataAuthenticationNotPerformed}}}
+	if diff := cmp.Diff(expected, d.Matches); diff != "" {
+		t.Errorf("Matches mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestActionDecisionDescribe(t *testing.T) {
+	a := ActionAnalysis{
+		TVR:           TVR{0x00, 0x00, 0x00, 0x80, 0x00},
+		Issuer:        ActionCodes{Online: TVR{0x00, 0x00, 0x00, 0x80, 0x00}},
+		Terminal:      ActionCodes{Online: TVR{0x00, 0x00, 0x00, 0x80, 0x00}},
+		OnlineCapable: true,
+	}
+
+	expected := []string{
+		"=== EMV TERMINAL ACTION ANALYSIS ===",
+		"    - Denial: IAC-Denial 0000000000, no match",
+		"    - Denial: TAC-Denial 0000000000, no match",
+		"    - Online: IAC-Online 0000008000 matches Byte 4 Bit 8: Transaction exceeds floor limit",
+		"    - Cryptogram: ARQC (Online phase)",
+		"    - IAC-Online: Byte 4 Bit 8: Transaction exceeds floor limit",
+		"    - TAC-Online: Byte 4 Bit 8: Transaction exceeds floor limit",
+	}
+	desc := a.Decide().Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// APPLICATION FILE LOCATOR (AFL) Logic according to EMV Book 3, section 10.2.
+// The AFL (Tag '94') is a list of 4-byte entries indicating the records the
+// terminal has to read:
+//
+// - Byte 1: Bits 8-4 = SFI, bits 3-1 = 000.
+// - Byte 2: First record number to read (never 0).
+// - Byte 3: Last record number to read (>= first record).
+// - Byte 4: Number of records, starting with the first one, involved in offline data authentication.
+
+// AFLEntry designates a range of records of one file.
+type AFLEntry struct {
+	SFI            byte
+	FirstRecord    byte
+	LastRecord     byte
+	OfflineRecords byte
+}
+
+// Records returns the record numbers to read, in order.
+func (e AFLEntry) Records() []byte {
+	records := make([]byte, 0, int(e.LastRecord)-int(e.FirstRecord)+1)
+	for r := int(e.FirstRecord); r <= int(e.LastRecord); r++ {
+		records = append(records, byte(r))
+	}
+	return records
+}
+
+// IsOfflineRecord reports whether a record of this entry is involved in offline data authentication.
+func (e AFLEntry) IsOfflineRecord(record byte) bool {
+	return record >= e.FirstRecord && int(record) < int(e.FirstRecord)+int(e.OfflineRecords)
+}
+
+// AFL is the Application File Locator.
+type AFL []AFLEntry
+
+// ParseAFL decodes and checks the entries of an AFL.
+func ParseAFL(data []byte) (AFL, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("AFL length %d is not a multiple of 4", len(data))
+	}
+
+	afl := make(AFL, 0, len(data)/4)
+	for i := 0; i < len(data); i += 4 {
+		entry := AFLEntry{
+			SFI:            data[i] >> 3,
+			FirstRecord:    data[i+1],
+			LastRecord:     data[i+2],
+			OfflineRecords: data[i+3],
+		}
+
+		switch {
+		case data[i]&0x07 != 0 || entry.SFI == 0 || entry.SFI > 30:
+			return nil, fmt.Errorf("AFL entry %d: invalid SFI byte %02X", i/4+1, data[i])
+		case entry.FirstRecord == 0:
+			return nil, fmt.Errorf("AFL entry %d: first record cannot be 0", i/4+1)
+		case entry.LastRecord < entry.FirstRecord:
+			return nil, fmt.Errorf("AFL entry %d: last record %d is lower than first record %d", i/4+1, entry.LastRecord, entry.FirstRecord)
+		case int(entry.OfflineRecords) > int(entry.LastRecord)-int(entry.FirstRecord)+1:
+			return nil, fmt.Errorf("AFL entry %d: %d offline records exceed the range %d-%d", i/4+1, entry.OfflineRecords, entry.FirstRecord, entry.LastRecord)
+		}
+
+		afl = append(afl, entry)
+	}
+
+	return afl, nil
+}
+
+// Report builds the structured report of the AFL.
+func (a AFL) Report() *report.Report {
+	rep := report.New("EMV APPLICATION FILE LOCATOR")
+	section := rep.AddSection(fmt.Sprintf("AFL (94): %d entries", len(a)))
+
+	for _, e := range a {
+		section.Note(fmt.Sprintf("SFI %02X (%d)", e.SFI, e.SFI),
+			fmt.Sprintf("Records %d-%d, %d for offline authentication", e.FirstRecord, e.LastRecord, e.OfflineRecords))
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable list of the files to read.
+func (a AFL) Describe() string {
+	return report.Text(a.Report())
+}
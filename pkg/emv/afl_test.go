@@ -0,0 +1,65 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestParseAFL(t *testing.T) {
+	afl, err := ParseAFL(tlv.Hex("08 01 01 00", "10 01 03 02", "18 02 02 01"))
+	if err != nil {
+		t.Fatalf("ParseAFL failed: %v", err)
+	}
+
+	expected := AFL{
+		{SFI: 1, FirstRecord: 1, LastRecord: 1, OfflineRecords: 0},
+		{SFI: 2, FirstRecord: 1, LastRecord: 3, OfflineRecords: 2},
+		{SFI: 3, FirstRecord: 2, LastRecord: 2, OfflineRecords: 1},
+	}
+	if diff := cmp.Diff(expected, afl); diff != "" {
+		t.Errorf("AFL mismatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]byte{1, 2, 3}, afl[1].Records()); diff != "" {
+		t.Errorf("Records mismatch (-want +got):\n%s", diff)
+	}
+	if !afl[1].IsOfflineRecord(2) || afl[1].IsOfflineRecord(3) {
+		t.Error("records 1-2 of SFI 2 only should be involved in offline authentication")
+	}
+
+	expectedLines := []string{
+		"=== EMV APPLICATION FILE LOCATOR ===",
+		"AFL (94): 3 entries",
+		"    - SFI 01 (1): Records 1-1, 0 for offline authentication",
+		"    - SFI 02 (2): Records 1-3, 2 for offline authentication",
+		"    - SFI 03 (3): Records 2-2, 1 for offline authentication",
+	}
+	if diff := cmp.Diff(expectedLines, strings.Split(afl.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseAFL_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Truncated", tlv.Hex("08 01 01")},
+		{"SFI Zero", tlv.Hex("00 01 01 00")},
+		{"Low Bits Set", tlv.Hex("09 01 01 00")},
+		{"First Record Zero", tlv.Hex("08 00 01 00")},
+		{"Last Before First", tlv.Hex("08 03 02 00")},
+		{"Too Many Offline Records", tlv.Hex("08 01 02 03")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAFL(tt.data); err == nil {
+				t.Errorf("ParseAFL(%X) should fail", tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/bits"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// APPLICATION INTERCHANGE PROFILE (AIP) Logic according to EMV Book 3, Annex C1.
+// The AIP (Tag '82', 2 bytes) lists the functions supported by the card application.
+//
+// Byte 1:
+// - Bit 8: RFU
+// - Bit 7: SDA supported
+// - Bit 6: DDA supported
+// - Bit 5: Cardholder verification is supported
+// - Bit 4: Terminal risk management is to be performed
+// - Bit 3: Issuer authentication is supported
+// - Bit 2: On device cardholder verification supported (contactless)
+// - Bit 1: CDA supported
+//
+// Byte 2:
+// - Bit 8: EMV mode supported (contactless)
+// - Bits 7-1: RFU
+
+// bitMeaning names a single bit of a multi-byte bit field (byte index starts at 0).
+type bitMeaning struct {
+	byteIndex int
+	bit       uint
+	name      string
+}
+
+// AIP is the Application Interchange Profile.
+type AIP [2]byte
+
+var aipBits = []bitMeaning{
+	{0, 7, "SDA supported"},
+	{0, 6, "DDA supported"},
+	{0, 5, "Cardholder verification is supported"},
+	{0, 4, "Terminal risk management is to be performed"},
+	{0, 3, "Issuer authentication is supported"},
+	{0, 2, "On device cardholder verification supported"},
+	{0, 1, "CDA supported"},
+	{1, 8, "EMV mode supported"},
+}
+
+// NewAIP creates an AIP from the value of Tag '82'.
+func NewAIP(value []byte) (AIP, error) {
+	if len(value) != 2 {
+		return AIP{}, fmt.Errorf("AIP must be 2 bytes long (got %d)", len(value))
+	}
+	return AIP{value[0], value[1]}, nil
+}
+
+// SupportsSDA reports whether Static Data Authentication is supported.
+func (a AIP) SupportsSDA() bool { return bits.IsSet(a[0], 7) }
+
+// SupportsDDA reports whether Dynamic Data Authentication is supported.
+func (a AIP) SupportsDDA() bool { return bits.IsSet(a[0], 6) }
+
+// SupportsCardholderVerification reports whether the CVM List must be processed.
+func (a AIP) SupportsCardholderVerification() bool { return bits.IsSet(a[0], 5) }
+
+// RequiresTerminalRiskManagement reports whether terminal risk management is to be performed.
+func (a AIP) RequiresTerminalRiskManagement() bool { return bits.IsSet(a[0], 4) }
+
+// SupportsIssuerAuthentication reports whether the card supports EXTERNAL AUTHENTICATE.
+func (a AIP) SupportsIssuerAuthentication() bool { return bits.IsSet(a[0], 3) }
+
+// SupportsOnDeviceCVM reports whether on device cardholder verification is supported (contactless).
+func (a AIP) SupportsOnDeviceCVM() bool { return bits.IsSet(a[0], 2) }
+
+// SupportsCDA reports whether Combined DDA / Application Cryptogram Generation is supported.
+func (a AIP) SupportsCDA() bool { return bits.IsSet(a[0], 1) }
+
+// SupportsEMVMode reports whether the EMV mode is supported (contactless).
+func (a AIP) SupportsEMVMode() bool { return bits.IsSet(a[1], 8) }
+
+// Features lists the names of the bits set in the AIP.
+func (a AIP) Features() []string {
+	return setBits(a[:], aipBits)
+}
+
+// Report builds the structured report of the AIP.
+func (a AIP) Report() *report.Report {
+	rep := report.New("EMV APPLICATION INTERCHANGE PROFILE")
+	describeBits(rep.AddSection(fmt.Sprintf("AIP (82): %X", a[:])), a[:], aipBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the AIP capabilities.
+func (a AIP) Describe() string {
+	return report.Text(a.Report())
+}
+
+// setBits returns the names of the bits set in value.
+func setBits(value []byte, meanings []bitMeaning) []string {
+	var names []string
+	for _, m := range meanings {
+		if m.byteIndex < len(value) && bits.IsSet(value[m.byteIndex], m.bit) {
+			names = append(names, m.name)
+		}
+	}
+	return names
+}
+
+// describeBits lists every known bit of value with its state ("[x]" when set).
+func describeBits(section *report.Section, value []byte, meanings []bitMeaning) {
+	for _, m := range meanings {
+		state := "[ ]"
+		if m.byteIndex < len(value) && bits.IsSet(value[m.byteIndex], m.bit) {
+			state = "[x]"
+		}
+		section.Note(fmt.Sprintf("Byte %d Bit %d", m.byteIndex+1, m.bit), fmt.Sprintf("%s %s", state, m.name))
+	}
+}
@@ -0,0 +1,70 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAIP(t *testing.T) {
+	aip, err := NewAIP([]byte{0x59, 0x80})
+	if err != nil {
+		t.Fatalf("NewAIP failed: %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"SDA", aip.SupportsSDA(), true},
+		{"DDA", aip.SupportsDDA(), false},
+		{"CVM", aip.SupportsCardholderVerification(), true},
+		{"TRM", aip.RequiresTerminalRiskManagement(), true},
+		{"IssuerAuth", aip.SupportsIssuerAuthentication(), false},
+		{"OnDeviceCVM", aip.SupportsOnDeviceCVM(), false},
+		{"CDA", aip.SupportsCDA(), true},
+		{"EMVMode", aip.SupportsEMVMode(), true},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	expectedFeatures := []string{
+		"SDA supported",
+		"Cardholder verification is supported",
+		"Terminal risk management is to be performed",
+		"CDA supported",
+		"EMV mode supported",
+	}
+	if diff := cmp.Diff(expectedFeatures, aip.Features()); diff != "" {
+		t.Errorf("Features mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewAIP([]byte{0x59}); err == nil {
+		t.Error("NewAIP should reject a 1-byte value")
+	}
+}
+
+func TestAIPDescribe(t *testing.T) {
+	expected := []string{
+		"=== EMV APPLICATION INTERCHANGE PROFILE ===",
+		"AIP (82): 3900",
+		"    - Byte 1 Bit 7: [ ] SDA supported",
+		"    - Byte 1 Bit 6: [x] DDA supported",
+		"    - Byte 1 Bit 5: [x] Cardholder verification is supported",
+		"    - Byte 1 Bit 4: [x] Terminal risk management is to be performed",
+		"    - Byte 1 Bit 3: [ ] Issuer authentication is supported",
+		"    - Byte 1 Bit 2: [ ] On device cardholder verification supported",
+		"    - Byte 1 Bit 1: [x] CDA supported",
+		"    - Byte 2 Bit 8: [ ] EMV mode supported",
+	}
+
+	got := strings.Split(AIP{0x39, 0x00}.Describe(), "\n")
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
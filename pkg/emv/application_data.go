@@ -0,0 +1,201 @@
+package emv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// READ APPLICATION DATA Logic according to EMV Book 3, section 10.2.
+// The terminal reads every record designated by the AFL with READ RECORD.
+// Records of files with SFI 1 to 10 must be coded as a Record Template (Tag '70').
+//
+// OFFLINE DATA AUTHENTICATION INPUT (EMV Book 3, section 10.3):
+// The records flagged in the AFL feed the static data to be authenticated:
+// - SFI 1 to 10: only the value field of the '70' template (tag and length excluded).
+// - SFI 11 to 30: the whole record, including its tag and length.
+
+// ErrDuplicateTag is returned when a primitive data object is read more than once.
+// EMV requires the terminal to terminate the transaction in that case.
+var ErrDuplicateTag = errors.New("duplicate data object")
+
+// RecordData is a record read while following the AFL.
+type RecordData struct {
+	SFI     byte
+	Record  byte
+	Data    []byte // Response data, as returned by the card
+	Offline bool   // Involved in offline data authentication
+}
+
+// ApplicationData stores the data objects read from the application records.
+// It implements DataSource, so that card data can feed a DOL.
+type ApplicationData struct {
+	Records []RecordData
+
+	// OfflineAuthenticationData is the exact input for offline data authentication.
+	OfflineAuthenticationData []byte
+
+	// Duplicates lists the tags found more than once, in reading order.
+	Duplicates []string
+
+	// Trace keeps every READ RECORD exchange.
+	Trace iso7816.Trace
+
+	objects map[string][]byte
+	order   []string
+}
+
+// NewApplicationData creates an empty data store.
+func NewApplicationData() *ApplicationData {
+	return &ApplicationData{objects: make(map[string][]byte)}
+}
+
+// Lookup implements DataSource.
+func (a *ApplicationData) Lookup(tag string) ([]byte, bool) {
+	value, ok := a.objects[strings.ToUpper(tag)]
+	return value, ok
+}
+
+// Tags returns the tags of the stored data objects, in reading order.
+func (a *ApplicationData) Tags() []string {
+	return append([]string{}, a.order...)
+}
+
+// Set stores a data object. A tag that is already present is recorded as a
+// duplicate and its first value is kept.
+func (a *ApplicationData) Set(tag string, value []byte) {
+	tag = strings.ToUpper(tag)
+	if _, exists := a.objects[tag]; exists {
+		a.Duplicates = append(a.Duplicates, tag)
+		return
+	}
+	a.objects[tag] = value
+	a.order = append(a.order, tag)
+}
+
+// Merge stores every primitive data object of the elements, descending into templates.
+func (a *ApplicationData) Merge(elements []tlv.Element) {
+	for _, e := range elements {
+		switch {
+		case e.IsPadding():
+			continue
+		case e.Constructed:
+			a.Merge(e.Children)
+		default:
+			a.Set(e.Tag, e.Value)
+		}
+	}
+}
+
+// AddRecord validates a record, merges its data objects and, when needed, appends it
+// to the offline data authentication input.
+func (a *ApplicationData) AddRecord(sfi, record byte, data []byte, offline bool) error {
+	a.Records = append(a.Records, RecordData{SFI: sfi, Record: record, Data: data, Offline: offline})
+
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		if sfi <= 10 {
+			return fmt.Errorf("SFI %d record %d: BER-TLV decode failed: %w", sfi, record, err)
+		}
+		// Files 11 to 30 may hold data that is not BER-TLV coded.
+		elements = nil
+	}
+
+	template, found := recordTemplate(elements)
+	if !found && sfi <= 10 {
+		return fmt.Errorf("SFI %d record %d: missing Record Template (Tag 70)", sfi, record)
+	}
+	if found {
+		a.Merge(template.Children)
+	}
+
+	if offline {
+		if sfi <= 10 {
+			a.OfflineAuthenticationData = append(a.OfflineAuthenticationData, template.Value...)
+		} else {
+			a.OfflineAuthenticationData = append(a.OfflineAuthenticationData, data...)
+		}
+	}
+
+	return nil
+}
+
+// recordTemplate returns the '70' template when it is the only data object of the record.
+func recordTemplate(elements []tlv.Element) (tlv.Element, bool) {
+	var template tlv.Element
+	count := 0
+	for _, e := range elements {
+		if e.IsPadding() {
+			continue
+		}
+		template = e
+		count++
+	}
+	return template, count == 1 && template.Tag == "70"
+}
+
+// ReadApplicationData reads every record designated by the AFL.
+// When records hold duplicate data objects, the data is returned together with an
+// error wrapping ErrDuplicateTag.
+func ReadApplicationData(client *iso7816.Client, cla iso7816.Class, afl AFL) (*ApplicationData, error) {
+	data := NewApplicationData()
+
+	for _, entry := range afl {
+		for _, record := range entry.Records() {
+			trace, err := client.Send(iso7816.ReadRecord(cla, entry.SFI, record))
+			data.Trace = append(data.Trace, trace...)
+			if err != nil {
+				return data, fmt.Errorf("SFI %d record %d: %w", entry.SFI, record, err)
+			}
+			if !trace.IsSuccess() {
+				return data, fmt.Errorf("SFI %d record %d: read failed with status: %s",
+					entry.SFI, record, trace.Last().Response.Status.Verbose())
+			}
+
+			if err := data.AddRecord(entry.SFI, record, trace.Last().Response.Data, entry.IsOfflineRecord(record)); err != nil {
+				return data, err
+			}
+		}
+	}
+
+	if len(data.Duplicates) > 0 {
+		return data, fmt.Errorf("%w: %s", ErrDuplicateTag, strings.Join(data.Duplicates, ", "))
+	}
+
+	return data, nil
+}
+
+// Report builds the structured report of the application data.
+func (a *ApplicationData) Report() *report.Report {
+	rep := report.New("EMV APPLICATION DATA")
+
+	records := rep.AddSection(fmt.Sprintf("Records: %d read", len(a.Records)))
+	for _, r := range a.Records {
+		detail := fmt.Sprintf("%d bytes", len(r.Data))
+		if r.Offline {
+			detail += " (offline authentication)"
+		}
+		records.Note(fmt.Sprintf("SFI %02X Record %d", r.SFI, r.Record), detail)
+	}
+	records.Note("Offline Authentication Data", fmt.Sprintf("%d bytes", len(a.OfflineAuthenticationData)))
+
+	objects := rep.AddSection(fmt.Sprintf("Data Objects: %d", len(a.order)))
+	for _, tag := range a.order {
+		objects.Note(tagLabel(tag), fmt.Sprintf("%X", a.objects[tag]))
+	}
+
+	if len(a.Duplicates) > 0 {
+		rep.AddSection("[!!] Duplicate Data Objects:").Note("", strings.Join(a.Duplicates, ", "))
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the application data.
+func (a *ApplicationData) Describe() string {
+	return report.Text(a.Report())
+}
@@ -0,0 +1,158 @@
+package emv
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// mockCard simulates a card answering fixed responses, keyed by the upper-case hex command.
+// Responses queued in sequences are consumed first, for commands sent several times.
+// Commands that are not mocked get '6A82' (File or application not found).
+type mockCard struct {
+	responses map[string]string
+	sequences map[string][]string
+	sent      []string
+}
+
+func (m *mockCard) Transmit(cmd []byte) ([]byte, error) {
+	key := strings.ToUpper(hex.EncodeToString(cmd))
+	m.sent = append(m.sent, key)
+	if queue := m.sequences[key]; len(queue) > 0 {
+		m.sequences[key] = queue[1:]
+		return tlv.Hex(queue[0]), nil
+	}
+	if resp, ok := m.responses[key]; ok {
+		return tlv.Hex(resp), nil
+	}
+	return tlv.Hex("6A82"), nil
+}
+
+// tlvHex encodes a primitive or constructed data object from hex strings.
+func tlvHex(tag string, value ...string) string {
+	content := strings.ReplaceAll(strings.Join(value, ""), " ", "")
+	length := fmt.Sprintf("%02X", len(content)/2)
+	if len(content)/2 > 0x7F {
+		length = "81" + length
+	}
+	return tag + length + content
+}
+
+func TestReadApplicationData(t *testing.T) {
+	card := &mockCard{responses: map[string]string{
+		"00B2010C00": "70 08 5A06 476173900010 9000",
+		"00B2020C00": "70 0B 5F24 03 251231 9F07 02 FF00 9000",
+		"00B2015C00": "70 06 8E04 00000000 9000",
+		"00B2025C00": "70 06 5F25 03 200101 9000",
+	}}
+
+	afl := AFL{
+		{SFI: 1, FirstRecord: 1, LastRecord: 2, OfflineRecords: 1},
+		{SFI: 11, FirstRecord: 1, LastRecord: 2, OfflineRecords: 2},
+	}
+
+	data, err := ReadApplicationData(iso7816.NewClient(card), iso7816.Class{}, afl)
+	if err != nil {
+		t.Fatalf("ReadApplicationData failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"5A", "5F24", "9F07", "8E", "5F25"}, data.Tags()); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+
+	pan, found := data.Lookup("5a")
+	if !found || hex.EncodeToString(pan) != "476173900010" {
+		t.Errorf("Lookup(5A) = %X, %v", pan, found)
+	}
+
+	// SFI 1: value of '70' only. SFI 11: whole records.
+	expectedODA := tlv.Hex("5A06476173900010", "70068E0400000000", "70065F2503200101")
+	if diff := cmp.Diff(expectedODA, data.OfflineAuthenticationData); diff != "" {
+		t.Errorf("Offline data mismatch (-want +got):\n%s", diff)
+	}
+
+	if len(data.Records) != 4 || len(data.Trace) != 4 {
+		t.Errorf("expected 4 records and 4 transactions, got %d and %d", len(data.Records), len(data.Trace))
+	}
+}
+
+func TestReadApplicationData_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		responses  map[string]string
+		afl        AFL
+		isDup      bool
+		errContain string
+	}{
+		{
+			name: "Duplicate Tag",
+			responses: map[string]string{
+				"00B2011400": "70 03 5A01 11 9000",
+				"00B2021400": "70 03 5A01 22 9000",
+			},
+			afl:        AFL{{SFI: 2, FirstRecord: 1, LastRecord: 2}},
+			isDup:      true,
+			errContain: "duplicate data object: 5A",
+		},
+		{
+			name:       "Missing Record Template",
+			responses:  map[string]string{"00B2011C00": "5A01 11 9000"},
+			afl:        AFL{{SFI: 3, FirstRecord: 1, LastRecord: 1}},
+			errContain: "missing Record Template (Tag 70)",
+		},
+		{
+			name:       "Record Not Found",
+			responses:  map[string]string{},
+			afl:        AFL{{SFI: 3, FirstRecord: 1, LastRecord: 1}},
+			errContain: "read failed with status",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ReadApplicationData(iso7816.NewClient(&mockCard{responses: tt.responses}), iso7816.Class{}, tt.afl)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.errContain) {
+				t.Errorf("error %q should contain %q", err, tt.errContain)
+			}
+			if errors.Is(err, ErrDuplicateTag) != tt.isDup {
+				t.Errorf("errors.Is(ErrDuplicateTag) = %v, want %v", !tt.isDup, tt.isDup)
+			}
+			if data == nil {
+				t.Error("data read so far should be returned")
+			}
+		})
+	}
+}
+
+func TestApplicationDataDescribe(t *testing.T) {
+	data := NewApplicationData()
+	if err := data.AddRecord(1, 1, tlv.Hex("70 07 5F24 03 251231 00"), true); err != nil {
+		t.Fatalf("AddRecord failed: %v", err)
+	}
+	data.Set("5F24", []byte{0x99})
+
+	expected := []string{
+		"=== EMV APPLICATION DATA ===",
+		"Records: 1 read",
+		"    - SFI 01 Record 1: 9 bytes (offline authentication)",
+		"    - Offline Authentication Data: 7 bytes",
+		"",
+		"Data Objects: 1",
+		"    - Application Expiration Date (5F24): 251231",
+		"",
+		"[!!] Duplicate Data Objects:",
+		"    - 5F24",
+	}
+	if diff := cmp.Diff(expected, strings.Split(data.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,88 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// COMBINED DDA/APPLICATION CRYPTOGRAM GENERATION (CDA) Logic according to EMV Book 2, section 6.6.
+// With CDA, the card signs the response to GENERATE AC (format 2, Tag '77') instead of
+// returning the Application Cryptogram in clear. The Signed Dynamic Application Data
+// ('9F4B') signs the Unpredictable Number of the terminal, and its ICC Dynamic Data holds:
+//
+//	ICC Dynamic Number Length (1) | ICC Dynamic Number | CID (1) | Application Cryptogram (8) |
+//	Transaction Data Hash Code (20)
+//
+// The Transaction Data Hash Code is the SHA-1 hash of, in order:
+// 1. The values of the data objects requested by the PDOL (the GPO data without the '83' template).
+// 2. The values of the data objects requested by CDOL1 (and CDOL2 for the second GENERATE AC).
+// 3. The data objects of the GENERATE AC response, except the '9F4B', as returned by the card.
+
+// CDAInput holds the transaction data signed by the card.
+type CDAInput struct {
+	PDOLData []byte
+	// CDOLData is the CDOL1 data, followed by the CDOL2 data for the second GENERATE AC.
+	CDOLData []byte
+	// UnpredictableNumber is the value of '9F37' sent with GENERATE AC.
+	UnpredictableNumber []byte
+	// Response is the data field of the GENERATE AC response.
+	Response []byte
+}
+
+// transactionData returns the input of the Transaction Data Hash Code.
+func (c CDAInput) transactionData() ([]byte, error) {
+	elements, err := tlv.DecodeElements(c.Response)
+	if err != nil {
+		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
+	}
+	template, found := tlv.FindElement(elements, "77")
+	if !found {
+		return nil, fmt.Errorf("CDA requires a format 2 response (Tag 77)")
+	}
+
+	data := concat(c.PDOLData, c.CDOLData)
+	for _, e := range template.Children {
+		if e.Tag != "9F4B" {
+			data = append(data, e.Bytes()...)
+		}
+	}
+	return data, nil
+}
+
+// VerifyCDA verifies the signature of a GENERATE AC response and extracts the Application
+// Cryptogram it carries.
+func VerifyCDA(in ODAInput, cda CDAInput) *AuthenticationResult {
+	r := &AuthenticationResult{Method: "CDA"}
+	if !authenticateICC(in, r) {
+		return r
+	}
+
+	cryptogram, err := ParseCryptogram(cda.Response)
+	if !r.check("GENERATE AC Response", err == nil, "%d bytes%s", len(cda.Response), errorSuffix(err)) {
+		return r
+	}
+	transactionData, err := cda.transactionData()
+	if !r.check("Transaction Data", err == nil, "%d bytes%s", len(transactionData), errorSuffix(err)) {
+		return r
+	}
+	if !verifyDynamicSignature(r, cryptogram.SignedDynamicApplicationData, cda.UnpredictableNumber) {
+		return r
+	}
+
+	dd := r.DynamicData.ICCDynamicData
+	offset := 1 + len(r.DynamicData.ICCDynamicNumber)
+	if !r.check("CDA Dynamic Data", len(dd) >= offset+29, "%d bytes (at least %d)", len(dd), offset+29) {
+		return r
+	}
+	r.DynamicData.CID = CID(dd[offset])
+	r.DynamicData.ApplicationCryptogram = dd[offset+1 : offset+9]
+	r.DynamicData.TransactionDataHashCode = dd[offset+9 : offset+29]
+
+	if !r.check("Cryptogram Information Data", r.DynamicData.CID == cryptogram.CID,
+		"%02X signed, %02X returned", byte(r.DynamicData.CID), byte(cryptogram.CID)) {
+		return r
+	}
+	checkHash(r, "Transaction Data Hash", transactionData, r.DynamicData.TransactionDataHashCode)
+	return r
+}
@@ -0,0 +1,135 @@
+package emv
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const (
+	cdaPDOLData = "E0B8C8 0978"
+	cdaCDOLData = "000000001500 0978 11223344"
+	cdaUN       = "11223344"
+	cdaAC       = "1122334455667788"
+)
+
+// cdaResponse builds a format 2 GENERATE AC response signed with CDA. The CID signed by
+// the card may differ from the returned one.
+func cdaResponse(p testPKI, returnedCID, signedCID string) []byte {
+	objects := tlvHex("9F27", returnedCID) + tlvHex("9F36", "0001") + tlvHex("9F10", "06010A03A00000")
+	hash := sha1.Sum(tlv.Hex(cdaPDOLData, cdaCDOLData, objects))
+
+	iccDynamicData := tlv.Hex("04 A1B2C3D4", signedCID, cdaAC, hex.EncodeToString(hash[:]))
+	sdad := p.signedDynamicData(iccDynamicData, tlv.Hex(cdaUN))
+	return tlv.Hex(tlvHex("77", objects, tlvHex("9F4B", hex.EncodeToString(sdad))))
+}
+
+func TestVerifyCDA(t *testing.T) {
+	p := loadTestPKI(t)
+	valid := CDAInput{
+		PDOLData:            tlv.Hex(cdaPDOLData),
+		CDOLData:            tlv.Hex(cdaCDOLData),
+		UnpredictableNumber: tlv.Hex(cdaUN),
+		Response:            cdaResponse(p, "40", "40"),
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(c *CDAInput)
+		passed     bool
+		failedStep string
+	}{
+		{name: "Valid", passed: true},
+		{
+			name:       "CID Mismatch",
+			modify:     func(c *CDAInput) { c.Response = cdaResponse(p, "80", "40") },
+			failedStep: "Cryptogram Information Data",
+		},
+		{
+			name:       "Modified CDOL Data",
+			modify:     func(c *CDAInput) { c.CDOLData = tlv.Hex("000000009900 0978 11223344") },
+			failedStep: "Transaction Data Hash",
+		},
+		{
+			name:       "Other Unpredictable Number",
+			modify:     func(c *CDAInput) { c.UnpredictableNumber = tlv.Hex("55667788") },
+			failedStep: "Signed Dynamic Data Hash",
+		},
+		{
+			name:       "Format 1 Response",
+			modify:     func(c *CDAInput) { c.Response = tlv.Hex("80 0B 40 0001", cdaAC) },
+			failedStep: "Transaction Data",
+		},
+		{
+			name:       "Invalid Response",
+			modify:     func(c *CDAInput) { c.Response = tlv.Hex("77 03 9F3601") },
+			failedStep: "GENERATE AC Response",
+		},
+		{
+			name:       "Cryptogram Without Signature",
+			modify:     func(c *CDAInput) { c.Response = tlv.Hex("77 14 9F2701 40 9F3602 0001 9F2608", cdaAC) },
+			failedStep: "Signed Dynamic Application Data",
+		},
+		{
+			name: "DDA Signature",
+			modify: func(c *CDAInput) {
+				sdad := p.signedDynamicData(tlv.Hex("04 A1B2C3D4"), tlv.Hex(cdaUN))
+				c.Response = tlv.Hex(tlvHex("77", "9F270140 9F36020001", tlvHex("9F4B", hex.EncodeToString(sdad))))
+			},
+			failedStep: "CDA Dynamic Data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cda := valid
+			if tt.modify != nil {
+				tt.modify(&cda)
+			}
+
+			result := VerifyCDA(odaInput(t, nil), cda)
+			if result.Passed() != tt.passed {
+				t.Fatalf("Passed() = %v, want %v\n%s", result.Passed(), tt.passed, result.Describe())
+			}
+			if tt.passed {
+				if diff := cmp.Diff(tlv.Hex(cdaAC), result.DynamicData.ApplicationCryptogram); diff != "" {
+					t.Errorf("Application Cryptogram mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			last := result.Steps[len(result.Steps)-1]
+			if last.Passed || last.Name != tt.failedStep {
+				t.Errorf("last step = %+v, want failed %q", last, tt.failedStep)
+			}
+		})
+	}
+}
+
+func TestVerifyCDADescribe(t *testing.T) {
+	p := loadTestPKI(t)
+	result := VerifyCDA(odaInput(t, nil), CDAInput{
+		PDOLData:            tlv.Hex(cdaPDOLData),
+		CDOLData:            tlv.Hex(cdaCDOLData),
+		UnpredictableNumber: tlv.Hex(cdaUN),
+		Response:            cdaResponse(p, "40", "40"),
+	})
+	desc := result.Describe()
+
+	for _, want := range []string{
+		"Method: CDA",
+		"Cryptogram Information Data: [OK] 40 signed, 40 returned",
+		"CID: 40 -> TC, No information given",
+		"Application Cryptogram: " + cdaAC,
+		"Transaction Data Hash Code:",
+		"CDA succeeded",
+	} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("Describe() missing %q:\n%s", want, desc)
+		}
+	}
+}
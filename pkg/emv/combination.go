@@ -0,0 +1,158 @@
+package emv
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// COMBINATION SELECTION Logic according to EMV Book B, section 3.3.
+// The terminal (Entry Point) is configured with a list of Combinations: an AID and
+// the kernel able to process it. Each PPSE Directory Entry is checked against every
+// Combination:
+//
+// 1. ADF Name: the ADF Name of the entry must start with the AID of the Combination.
+// 2. Requested Kernel ID, from the Kernel Identifier (Tag '9F2A') of the entry:
+//    - Absent, empty, or Short Kernel ID 0: the default kernel of the payment system
+//      (derived from the RID of the ADF Name).
+//    - Byte 1 bits 8-7 = 00b or 01b (international kernel): byte 1.
+//    - Byte 1 bits 8-7 = 10b or 11b (domestic kernel): bytes 1 to 3.
+// 3. The Requested Kernel ID must be the kernel of the Combination.
+//
+// Matching entries are ranked by Application Priority Indicator. The application is
+// finally selected with its ADF Name, followed by its Extended Selection (Tag '9F29')
+// when the Combination supports it.
+
+// defaultKernels maps a RID to the kernel of its payment system (EMV Book B, Table 3-6).
+var defaultKernels = map[string]byte{
+	"A000000004": 0x02, // Mastercard
+	"A000000003": 0x03, // Visa
+	"A000000025": 0x04, // American Express
+	"A000000065": 0x05, // JCB
+	"A000000152": 0x06, // Discover
+	"A000000333": 0x07, // UnionPay
+}
+
+// KernelCombination is an AID/kernel pair supported by the terminal.
+type KernelCombination struct {
+	AID      []byte
+	KernelID []byte // e.g. {0x02} for Kernel 2
+
+	// ExtendedSelection allows the Extended Selection of the card to be appended
+	// to the ADF Name for the final selection.
+	ExtendedSelection bool
+}
+
+// CombinationCandidate is a directory entry matched against a combination.
+type CombinationCandidate struct {
+	ADFName           []byte
+	Label             string
+	Priority          byte // 1 (highest) to 15, 0 when no priority is given
+	KernelID          []byte
+	ExtendedSelection []byte // Only kept when supported by the combination
+}
+
+// SelectionName returns the DF Name to use in the final SELECT command.
+func (c CombinationCandidate) SelectionName() []byte {
+	return append(append([]byte{}, c.ADFName...), c.ExtendedSelection...)
+}
+
+// CombinationList is the candidate list built by the Entry Point.
+type CombinationList struct {
+	Candidates []CombinationCandidate
+}
+
+// RequestedKernelID returns the kernel requested by a directory entry, or nil when it
+// cannot be determined (unknown payment system without Kernel Identifier, or
+// malformed domestic Kernel Identifier).
+func (e DirectoryEntry) RequestedKernelID() []byte {
+	kid := e.KernelIdentifier
+
+	if len(kid) > 0 {
+		switch {
+		case kid[0]&0xC0 >= 0x80:
+			// Domestic kernel: 3 bytes identify the kernel.
+			if len(kid) < 3 {
+				return nil
+			}
+			return kid[:3]
+		case kid[0]&0x3F != 0:
+			return kid[:1]
+		}
+	}
+
+	if len(e.ADFName) < 5 {
+		return nil
+	}
+	if kernel, ok := defaultKernels[fmt.Sprintf("%X", e.ADFName[:5])]; ok {
+		return []byte{kernel}
+	}
+	return nil
+}
+
+// SelectCombinations matches the PPSE directory entries against the terminal combinations
+// and returns the candidates ranked by priority.
+func SelectCombinations(ppse *PPSE, combinations []KernelCombination) *CombinationList {
+	list := &CombinationList{}
+
+	for _, entry := range ppse.Entries() {
+		kernel := entry.RequestedKernelID()
+		if kernel == nil {
+			continue
+		}
+
+		for _, combination := range combinations {
+			if !bytes.HasPrefix(entry.ADFName, combination.AID) || !bytes.Equal(kernel, combination.KernelID) {
+				continue
+			}
+
+			candidate := CombinationCandidate{
+				ADFName:  entry.ADFName,
+				Label:    string(entry.ApplicationLabel),
+				KernelID: kernel,
+			}
+			if len(entry.ApplicationPriorityIndicator) > 0 {
+				candidate.Priority = entry.ApplicationPriorityIndicator[0] & 0x0F
+			}
+			if combination.ExtendedSelection {
+				candidate.ExtendedSelection = entry.ExtendedSelection
+			}
+
+			list.Candidates = append(list.Candidates, candidate)
+			break
+		}
+	}
+
+	sort.SliceStable(list.Candidates, func(i, j int) bool {
+		return priorityRank(list.Candidates[i].Priority) < priorityRank(list.Candidates[j].Priority)
+	})
+
+	return list
+}
+
+// Report builds the structured report of the combination candidates.
+func (l *CombinationList) Report() *report.Report {
+	rep := report.New("EMV ENTRY POINT COMBINATIONS")
+	section := rep.AddSection(fmt.Sprintf("[=] CANDIDATE LIST (%d combinations):", len(l.Candidates)))
+
+	if len(l.Candidates) == 0 {
+		section.Note("", "No matching combination.")
+	}
+	for i, c := range l.Candidates {
+		priority := "none"
+		if c.Priority > 0 {
+			priority = fmt.Sprintf("%d", c.Priority)
+		}
+		section.Note(fmt.Sprintf("#%d", i+1),
+			fmt.Sprintf("%X %q (Kernel: %X, Priority: %s)", c.SelectionName(), c.Label, c.KernelID, priority))
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the combination candidates.
+func (l *CombinationList) Describe() string {
+	return report.Text(l.Report())
+}
@@ -0,0 +1,69 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestRequestedKernelID(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    DirectoryEntry
+		expected []byte
+	}{
+		{"Default Kernel From RID", DirectoryEntry{ADFName: tlv.Hex(aidVisa)}, []byte{0x03}},
+		{"Short Kernel ID Zero", DirectoryEntry{ADFName: tlv.Hex(aidMC), KernelIdentifier: []byte{0x00}}, []byte{0x02}},
+		{"International Kernel", DirectoryEntry{ADFName: tlv.Hex(aidMC), KernelIdentifier: tlv.Hex("0203")}, []byte{0x02}},
+		{"Domestic Kernel", DirectoryEntry{ADFName: tlv.Hex(aidMC), KernelIdentifier: tlv.Hex("C10203AA")}, tlv.Hex("C10203")},
+		{"Truncated Domestic Kernel", DirectoryEntry{ADFName: tlv.Hex(aidMC), KernelIdentifier: tlv.Hex("8001")}, nil},
+		{"Unknown Payment System", DirectoryEntry{ADFName: tlv.Hex("A0000009991010")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expected, tt.entry.RequestedKernelID()); diff != "" {
+				t.Errorf("Mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectCombinations(t *testing.T) {
+	ppse, err := ParsePPSE(testPPSE(
+		tlvHex("61", tlvHex("4F", aidMC), tlvHex("50", labelMC), "870102", "9F2A0102"),
+		tlvHex("61", tlvHex("4F", aidVisa), tlvHex("50", labelVisa), "870101", "9F2A0103", "9F29021234"),
+		tlvHex("61", tlvHex("4F", "A0000000999999"), "9F2A0105"),
+		tlvHex("61", tlvHex("4F", "A0000000042203"), tlvHex("50", "4C4F43414C"), "9F2A03C10203", "9F290199"),
+		tlvHex("61", tlvHex("4F", "A0000000043060"), "9F2A0103"), // Mastercard AID requesting Kernel 3
+	))
+	if err != nil {
+		t.Fatalf("ParsePPSE failed: %v", err)
+	}
+
+	combinations := []KernelCombination{
+		{AID: tlv.Hex(aidMC), KernelID: []byte{0x02}},
+		{AID: tlv.Hex("A000000003"), KernelID: []byte{0x03}, ExtendedSelection: true},
+		{AID: tlv.Hex("A000000004"), KernelID: tlv.Hex("C10203")},
+	}
+
+	list := SelectCombinations(ppse, combinations)
+
+	expected := []string{
+		"=== EMV ENTRY POINT COMBINATIONS ===",
+		"[=] CANDIDATE LIST (3 combinations):",
+		`    - #1: A00000000310101234 "VISA" (Kernel: 03, Priority: 1)`,
+		`    - #2: A0000000041010 "MASTERCARD" (Kernel: 02, Priority: 2)`,
+		`    - #3: A0000000042203 "LOCAL" (Kernel: C10203, Priority: none)`,
+	}
+	if diff := cmp.Diff(expected, strings.Split(list.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+
+	// Extended Selection is dropped when the combination does not support it.
+	if list.Candidates[2].ExtendedSelection != nil {
+		t.Errorf("Extended Selection should be ignored, got %X", list.Candidates[2].ExtendedSelection)
+	}
+}
@@ -0,0 +1,452 @@
+package emv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// CARDHOLDER VERIFICATION Logic according to EMV Book 3, section 10.5 and Annex C3.
+// The CVM List (Tag '8E') is coded as:
+//
+//	Amount X (4, binary) | Amount Y (4, binary) | CV Rule 1 (2) | CV Rule 2 (2) | ...
+//
+// Each CV Rule is:
+// - Byte 1 (CVM Code): bit 8 RFU; bit 7 = 0: fail cardholder verification if this CVM is
+//   unsuccessful, 1: apply the succeeding CV Rule; bits 6-1: the method.
+// - Byte 2 (CVM Condition Code): when the rule applies. Amounts X and Y are expressed in
+//   the application currency ('9F42') and only compared to transactions in that currency.
+//
+// The terminal walks the rules in order:
+// 1. Rules whose condition is not understood or not satisfied are skipped.
+// 2. The method is performed when it is recognised and supported by the terminal.
+// 3. On success, cardholder verification is complete.
+// 4. On failure, the next rule is applied if bit 7 allows it, otherwise verification fails.
+//
+// The outcome is stored in the CVM Results (Tag '9F34'):
+// CVM Performed (1) | CVM Condition (1) | CVM Result (1: '00' unknown, '01' failed, '02' successful).
+
+// CVMethod is the method of a CV Rule (bits 6-1 of the CVM Code).
+type CVMethod byte
+
+const (
+	CVMFail                      CVMethod = 0x00
+	CVMPlaintextPIN              CVMethod = 0x01 // Plaintext PIN verification performed by ICC
+	CVMOnlinePIN                 CVMethod = 0x02 // Enciphered PIN verified online
+	CVMPlaintextPINAndSignature  CVMethod = 0x03
+	CVMEncipheredPIN             CVMethod = 0x04 // Enciphered PIN verification performed by ICC
+	CVMEncipheredPINAndSignature CVMethod = 0x05
+	CVMSignature                 CVMethod = 0x1E
+	CVMNoCVM                     CVMethod = 0x1F
+	CVMNotPerformed              CVMethod = 0x3F // Only used in the CVM Results
+)
+
+func (m CVMethod) String() string {
+	switch m {
+	case CVMFail:
+		return "Fail CVM processing"
+	case CVMPlaintextPIN:
+		return "Plaintext PIN verification performed by ICC"
+	case CVMOnlinePIN:
+		return "Enciphered PIN verified online"
+	case CVMPlaintextPINAndSignature:
+		return "Plaintext PIN verification performed by ICC and signature"
+	case CVMEncipheredPIN:
+		return "Enciphered PIN verification performed by ICC"
+	case CVMEncipheredPINAndSignature:
+		return "Enciphered PIN verification performed by ICC and signature"
+	case CVMSignature:
+		return "Signature (paper)"
+	case CVMNoCVM:
+		return "No CVM required"
+	case CVMNotPerformed:
+		return "No CVM performed"
+	}
+
+	switch {
+	case m >= 0x20 && m <= 0x2F:
+		return fmt.Sprintf("Payment system method (%02X)", byte(m))
+	case m >= 0x30 && m <= 0x3E:
+		return fmt.Sprintf("Issuer method (%02X)", byte(m))
+	default:
+		return fmt.Sprintf("RFU (%02X)", byte(m))
+	}
+}
+
+// Recognised reports whether the method is defined by EMV.
+func (m CVMethod) Recognised() bool {
+	return m <= CVMEncipheredPINAndSignature || m == CVMSignature || m == CVMNoCVM
+}
+
+// RequiresPIN reports whether the method needs a PIN entry.
+func (m CVMethod) RequiresPIN() bool {
+	return m >= CVMPlaintextPIN && m <= CVMEncipheredPINAndSignature
+}
+
+// CVMCondition is the CVM Condition Code of a CV Rule.
+type CVMCondition byte
+
+const (
+	CVMAlways           CVMCondition = 0x00
+	CVMIfUnattendedCash CVMCondition = 0x01
+	CVMIfNotCash        CVMCondition = 0x02 // Not unattended cash, not manual cash and not purchase with cashback
+	CVMIfSupported      CVMCondition = 0x03
+	CVMIfManualCash     CVMCondition = 0x04
+	CVMIfCashback       CVMCondition = 0x05
+	CVMIfUnderX         CVMCondition = 0x06
+	CVMIfOverX          CVMCondition = 0x07
+	CVMIfUnderY         CVMCondition = 0x08
+	CVMIfOverY          CVMCondition = 0x09
+)
+
+var cvmConditionNames = map[CVMCondition]string{
+	CVMAlways:           "Always",
+	CVMIfUnattendedCash: "If unattended cash",
+	CVMIfNotCash:        "If not unattended cash and not manual cash and not purchase with cashback",
+	CVMIfSupported:      "If terminal supports the CVM",
+	CVMIfManualCash:     "If manual cash",
+	CVMIfCashback:       "If purchase with cashback",
+	CVMIfUnderX:         "If transaction is in the application currency and is under X value",
+	CVMIfOverX:          "If transaction is in the application currency and is over X value",
+	CVMIfUnderY:         "If transaction is in the application currency and is under Y value",
+	CVMIfOverY:          "If transaction is in the application currency and is over Y value",
+}
+
+func (c CVMCondition) String() string {
+	if name, ok := cvmConditionNames[c]; ok {
+		return name
+	}
+	if c >= 0x80 {
+		return fmt.Sprintf("Payment system condition (%02X)", byte(c))
+	}
+	return fmt.Sprintf("RFU (%02X)", byte(c))
+}
+
+// CVRule is a Cardholder Verification Rule.
+type CVRule struct {
+	Method                  CVMethod
+	ApplyNextIfUnsuccessful bool
+	Condition               CVMCondition
+}
+
+// Code returns the CVM Code (byte 1 of the rule).
+func (r CVRule) Code() byte {
+	code := byte(r.Method) & 0x3F
+	if r.ApplyNextIfUnsuccessful {
+		code |= 0x40
+	}
+	return code
+}
+
+func (r CVRule) String() string {
+	next := "fail if unsuccessful"
+	if r.ApplyNextIfUnsuccessful {
+		next = "apply next if unsuccessful"
+	}
+	return fmt.Sprintf("%02X%02X: %s, %s (%s)", r.Code(), byte(r.Condition), r.Method, r.Condition, next)
+}
+
+// CVMList is the Cardholder Verification Method List.
+type CVMList struct {
+	AmountX uint32
+	AmountY uint32
+	Rules   []CVRule
+}
+
+// ParseCVMList decodes the value of Tag '8E'.
+func ParseCVMList(data []byte) (*CVMList, error) {
+	if len(data) < 8 || len(data)%2 != 0 {
+		return nil, fmt.Errorf("CVM List must hold amounts X and Y followed by 2-byte rules (got %d bytes)", len(data))
+	}
+
+	list := &CVMList{
+		AmountX: binary.BigEndian.Uint32(data[0:4]),
+		AmountY: binary.BigEndian.Uint32(data[4:8]),
+	}
+	for i := 8; i < len(data); i += 2 {
+		list.Rules = append(list.Rules, CVRule{
+			Method:                  CVMethod(data[i] & 0x3F),
+			ApplyNextIfUnsuccessful: data[i]&0x40 != 0,
+			Condition:               CVMCondition(data[i+1]),
+		})
+	}
+	return list, nil
+}
+
+// Report builds the structured report of the CVM List.
+func (l *CVMList) Report() *report.Report {
+	rep := report.New("EMV CVM LIST")
+
+	amounts := rep.AddSection("Amounts:")
+	amounts.Note("Amount X", fmt.Sprintf("%d", l.AmountX))
+	amounts.Note("Amount Y", fmt.Sprintf("%d", l.AmountY))
+
+	rules := rep.AddSection(fmt.Sprintf("CV Rules: %d", len(l.Rules)))
+	for i, r := range l.Rules {
+		rules.Note(fmt.Sprintf("#%d", i+1), r.String())
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable list of the CV Rules.
+func (l *CVMList) Describe() string {
+	return report.Text(l.Report())
+}
+
+// CVMOutcome is the result of a CVM (byte 3 of the CVM Results).
+type CVMOutcome byte
+
+const (
+	CVMResultUnknown    CVMOutcome = 0x00 // e.g. signature or online PIN
+	CVMResultFailed     CVMOutcome = 0x01
+	CVMResultSuccessful CVMOutcome = 0x02
+)
+
+func (o CVMOutcome) String() string {
+	switch o {
+	case CVMResultUnknown:
+		return "Unknown"
+	case CVMResultFailed:
+		return "Failed"
+	case CVMResultSuccessful:
+		return "Successful"
+	default:
+		return fmt.Sprintf("RFU (%02X)", byte(o))
+	}
+}
+
+// CVMResults is the value of Tag '9F34'.
+type CVMResults [3]byte
+
+func (r CVMResults) String() string {
+	return fmt.Sprintf("%X: %s, %s, %s", r[:], CVMethod(r[0]&0x3F), CVMCondition(r[1]), CVMOutcome(r[2]))
+}
+
+// CVMPerformer performs a CVM supported by the terminal (e.g. an offline PIN verification)
+// and returns its outcome. It may set the related TVR bits (e.g. PIN Try Limit exceeded).
+type CVMPerformer func(rule CVRule, tvr *TVR) CVMOutcome
+
+// defaultCVMOutcome assumes that the cardholder completes the CVM: offline checks succeed,
+// while signature and online PIN remain unknown to the terminal.
+func defaultCVMOutcome(rule CVRule, _ *TVR) CVMOutcome {
+	switch rule.Method {
+	case CVMFail:
+		return CVMResultFailed
+	case CVMPlaintextPIN, CVMEncipheredPIN, CVMNoCVM:
+		return CVMResultSuccessful
+	default:
+		return CVMResultUnknown
+	}
+}
+
+// CVMTransaction describes the terminal and the transaction for cardholder verification.
+type CVMTransaction struct {
+	Capabilities TerminalCapabilities
+	Unattended   bool
+	Type         TransactionType
+
+	// Amount is the Amount, Authorised ('9F02') in the minor unit of the currency.
+	Amount              uint64
+	Currency            []byte // Transaction Currency Code ('5F2A')
+	ApplicationCurrency []byte // Application Currency Code ('9F42')
+
+	// Perform performs the selected CVM. Nil means defaultCVMOutcome.
+	Perform CVMPerformer
+}
+
+// supports reports whether the terminal supports a method.
+func (tx CVMTransaction) supports(m CVMethod) bool {
+	c := tx.Capabilities
+	switch m {
+	case CVMFail:
+		return true
+	case CVMPlaintextPIN:
+		return c.SupportsPlaintextPIN()
+	case CVMOnlinePIN:
+		return c.SupportsOnlinePIN()
+	case CVMPlaintextPINAndSignature:
+		return c.SupportsPlaintextPIN() && c.SupportsSignature()
+	case CVMEncipheredPIN:
+		return c.SupportsEncipheredPIN()
+	case CVMEncipheredPINAndSignature:
+		return c.SupportsEncipheredPIN() && c.SupportsSignature()
+	case CVMSignature:
+		return c.SupportsSignature()
+	case CVMNoCVM:
+		return c.SupportsNoCVM()
+	default:
+		return false
+	}
+}
+
+// conditionSatisfied checks the condition of a rule. understood is false for RFU and
+// payment system specific conditions.
+func (tx CVMTransaction) conditionSatisfied(rule CVRule, list *CVMList) (satisfied, understood bool) {
+	cash := tx.Type == TransactionCash
+
+	switch rule.Condition {
+	case CVMAlways:
+		return true, true
+	case CVMIfUnattendedCash:
+		return cash && tx.Unattended, true
+	case CVMIfNotCash:
+		return !cash && tx.Type != TransactionCashback, true
+	case CVMIfSupported:
+		return tx.supports(rule.Method), true
+	case CVMIfManualCash:
+		return cash && !tx.Unattended, true
+	case CVMIfCashback:
+		return tx.Type == TransactionCashback, true
+	case CVMIfUnderX, CVMIfOverX, CVMIfUnderY, CVMIfOverY:
+		return tx.amountCondition(rule.Condition, list), true
+	default:
+		return false, false
+	}
+}
+
+// amountCondition compares the amount with X or Y, for transactions in the application currency.
+func (tx CVMTransaction) amountCondition(condition CVMCondition, list *CVMList) bool {
+	if len(tx.Currency) == 0 || !bytes.Equal(tx.Currency, tx.ApplicationCurrency) {
+		return false
+	}
+
+	switch condition {
+	case CVMIfUnderX:
+		return tx.Amount < uint64(list.AmountX)
+	case CVMIfOverX:
+		return tx.Amount > uint64(list.AmountX)
+	case CVMIfUnderY:
+		return tx.Amount < uint64(list.AmountY)
+	default:
+		return tx.Amount > uint64(list.AmountY)
+	}
+}
+
+// CVMStep explains how a CV Rule was processed.
+type CVMStep struct {
+	Rule   string
+	Detail string
+}
+
+// CVMEvaluation is the outcome of cardholder verification.
+type CVMEvaluation struct {
+	Results    CVMResults
+	TVR        TVR // Bits set by cardholder verification
+	Successful bool
+	Steps      []CVMStep
+}
+
+func (e *CVMEvaluation) step(rule, format string, args ...interface{}) {
+	e.Steps = append(e.Steps, CVMStep{Rule: rule, Detail: fmt.Sprintf(format, args...)})
+}
+
+// EvaluateCVM walks the CVM List and returns the CVM Results and the TVR bits to set.
+// A nil or empty list means that the card provided no CVM List.
+func EvaluateCVM(list *CVMList, tx CVMTransaction) *CVMEvaluation {
+	e := &CVMEvaluation{}
+
+	if list == nil || len(list.Rules) == 0 {
+		e.TVR.Set(TVRICCDataMissing)
+		e.Results = CVMResults{byte(CVMNotPerformed), 0x00, byte(CVMResultUnknown)}
+		e.step("CVM List", "missing or empty, no CVM performed")
+		return e
+	}
+
+	var attempted *CVRule
+	for i := range list.Rules {
+		rule := list.Rules[i]
+		label := fmt.Sprintf("#%d %02X%02X", i+1, rule.Code(), byte(rule.Condition))
+
+		satisfied, understood := tx.conditionSatisfied(rule, list)
+		if !understood {
+			e.step(label, "condition not understood (%s), rule skipped", rule.Condition)
+			continue
+		}
+		if !satisfied {
+			e.step(label, "condition not satisfied (%s)", rule.Condition)
+			continue
+		}
+
+		attempted = &rule
+		outcome := e.apply(label, rule, tx)
+		if outcome != CVMResultFailed {
+			e.Successful = true
+			e.Results = CVMResults{rule.Code(), byte(rule.Condition), byte(outcome)}
+			return e
+		}
+		if !rule.ApplyNextIfUnsuccessful {
+			e.step(label, "rule does not allow the next one, cardholder verification failed")
+			break
+		}
+	}
+
+	e.TVR.Set(TVRCardholderVerificationNotSuccessful)
+	if attempted == nil {
+		e.step("CVM List", "no applicable rule, cardholder verification failed")
+		e.Results = CVMResults{byte(CVMNotPerformed), 0x00, byte(CVMResultFailed)}
+	} else {
+		e.Results = CVMResults{attempted.Code(), byte(attempted.Condition), byte(CVMResultFailed)}
+	}
+	return e
+}
+
+// apply performs the method of a rule whose condition is satisfied.
+func (e *CVMEvaluation) apply(label string, rule CVRule, tx CVMTransaction) CVMOutcome {
+	if !rule.Method.Recognised() {
+		e.TVR.Set(TVRUnrecognisedCVM)
+		e.step(label, "unrecognised CVM (%s)", rule.Method)
+		return CVMResultFailed
+	}
+	if !tx.supports(rule.Method) {
+		if rule.Method.RequiresPIN() {
+			e.TVR.Set(TVRPINPadNotPresent)
+		}
+		e.step(label, "%s not supported by the terminal", rule.Method)
+		return CVMResultFailed
+	}
+
+	perform := tx.Perform
+	if perform == nil {
+		perform = defaultCVMOutcome
+	}
+	outcome := perform(rule, &e.TVR)
+	if outcome != CVMResultFailed && rule.Method == CVMOnlinePIN {
+		e.TVR.Set(TVROnlinePINEntered)
+	}
+	e.step(label, "%s performed: %s", rule.Method, outcome)
+	return outcome
+}
+
+// Report builds the structured report of the cardholder verification.
+func (e *CVMEvaluation) Report() *report.Report {
+	rep := report.New("EMV CARDHOLDER VERIFICATION")
+
+	steps := rep.AddSection("[1] CV Rules Processing:")
+	for _, s := range e.Steps {
+		steps.Note(s.Rule, s.Detail)
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	if e.Successful {
+		outcome.Note("", "Cardholder verification successful")
+	} else {
+		outcome.Note("", "Cardholder verification failed")
+	}
+	outcome.Note("CVM Results (9F34)", e.Results.String())
+	names := e.TVR.Names()
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	outcome.Note(fmt.Sprintf("TVR (95) %X", e.TVR[:]), strings.Join(names, ", "))
+
+	return rep
+}
+
+// Describe generates a human-readable report of the cardholder verification.
+func (e *CVMEvaluation) Describe() string {
+	return report.Text(e.Report())
+}
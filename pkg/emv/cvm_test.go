@@ -0,0 +1,215 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestParseCVMList(t *testing.T) {
+	list, err := ParseCVMList(tlv.Hex("00001388 00000000", "4203", "1E03", "0203", "1F00"))
+	if err != nil {
+		t.Fatalf("ParseCVMList failed: %v", err)
+	}
+
+	expected := &CVMList{
+		AmountX: 5000,
+		Rules: []CVRule{
+			{Method: CVMOnlinePIN, ApplyNextIfUnsuccessful: true, Condition: CVMIfSupported},
+			{Method: CVMSignature, Condition: CVMIfSupported},
+			{Method: CVMOnlinePIN, Condition: CVMIfSupported},
+			{Method: CVMNoCVM, Condition: CVMAlways},
+		},
+	}
+	if diff := cmp.Diff(expected, list); diff != "" {
+		t.Errorf("CVM List mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, data := range [][]byte{tlv.Hex("00000000"), tlv.Hex("00000000 00000000 42")} {
+		if _, err := ParseCVMList(data); err == nil {
+			t.Errorf("ParseCVMList(%X) should fail", data)
+		}
+	}
+}
+
+func TestCVMListDescribe(t *testing.T) {
+	list, err := ParseCVMList(tlv.Hex("00001388 00002710", "4206", "2501"))
+	if err != nil {
+		t.Fatalf("ParseCVMList failed: %v", err)
+	}
+
+	expected := []string{
+		"=== EMV CVM LIST ===",
+		"    - Amount X: 5000",
+		"    - Amount Y: 10000",
+		"CV Rules: 2",
+		"    - #1: 4206: Enciphered PIN verified online, If transaction is in the application currency and is under X value (apply next if unsuccessful)",
+		"    - #2: 2501: Payment system method (25), If unattended cash (fail if unsuccessful)",
+	}
+	desc := list.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
+
+func TestEvaluateCVM(t *testing.T) {
+	eur := tlv.Hex("0978")
+	attended := CVMTransaction{
+		Capabilities:        TerminalCapabilities{0xE0, 0xF8, 0xC8}, // Every CVM
+		Type:                TransactionPurchase,
+		Amount:              2500,
+		Currency:            eur,
+		ApplicationCurrency: eur,
+	}
+	noPINPad := attended
+	noPINPad.Capabilities = TerminalCapabilities{0xE0, 0x20, 0xC8} // Signature only
+	unattended := attended
+	unattended.Capabilities = TerminalCapabilities{0xE0, 0x48, 0xC8} // Online PIN and No CVM
+	unattended.Unattended = true
+	unattended.Type = TransactionCash
+	foreign := attended
+	foreign.Currency = tlv.Hex("0840")
+	failingPIN := attended
+	failingPIN.Perform = func(rule CVRule, tvr *TVR) CVMOutcome {
+		if rule.Method == CVMPlaintextPIN {
+			tvr.Set(TVRPINTryLimitExceeded)
+			return CVMResultFailed
+		}
+		return defaultCVMOutcome(rule, tvr)
+	}
+
+	list := "00000BB8 00002710" // X = 3000, Y = 10000
+
+	tests := []struct {
+		name       string
+		list       []byte
+		tx         CVMTransaction
+		results    CVMResults
+		tvr        TVR
+		successful bool
+	}{
+		{
+			name:    "Missing CVM List",
+			tx:      attended,
+			results: CVMResults{0x3F, 0x00, 0x00},
+			tvr:     TVR{0x20, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:       "Online PIN",
+			list:       tlv.Hex(list, "4203", "1E03", "1F00"),
+			tx:         attended,
+			results:    CVMResults{0x42, 0x03, 0x00},
+			tvr:        TVR{0x00, 0x00, 0x04, 0x00, 0x00},
+			successful: true,
+		},
+		{
+			name:       "No PIN Pad Falls Back To Signature",
+			list:       tlv.Hex(list, "4200", "1E00"),
+			tx:         noPINPad,
+			results:    CVMResults{0x1E, 0x00, 0x00},
+			tvr:        TVR{0x00, 0x00, 0x10, 0x00, 0x00},
+			successful: true,
+		},
+		{
+			name:    "No PIN Pad Without Fallback",
+			list:    tlv.Hex(list, "0200", "1E00"),
+			tx:      noPINPad,
+			results: CVMResults{0x02, 0x00, 0x01},
+			tvr:     TVR{0x00, 0x00, 0x90, 0x00, 0x00},
+		},
+		{
+			name:       "Under X",
+			list:       tlv.Hex(list, "1F06", "4203"),
+			tx:         attended,
+			results:    CVMResults{0x1F, 0x06, 0x02},
+			successful: true,
+		},
+		{
+			name:       "Foreign Currency Skips Amount Rules",
+			list:       tlv.Hex(list, "1F06", "1E03"),
+			tx:         foreign,
+			results:    CVMResults{0x1E, 0x03, 0x00},
+			successful: true,
+		},
+		{
+			name:       "Unattended Cash",
+			list:       tlv.Hex(list, "1E02", "4201", "1F00"),
+			tx:         unattended,
+			results:    CVMResults{0x42, 0x01, 0x00},
+			tvr:        TVR{0x00, 0x00, 0x04, 0x00, 0x00},
+			successful: true,
+		},
+		{
+			name:       "Unrecognised CVM",
+			list:       tlv.Hex(list, "6600", "5F00"),
+			tx:         attended,
+			results:    CVMResults{0x5F, 0x00, 0x02},
+			tvr:        TVR{0x00, 0x00, 0x40, 0x00, 0x00},
+			successful: true,
+		},
+		{
+			name:    "Offline PIN Failed",
+			list:    tlv.Hex(list, "4100", "0000"),
+			tx:      failingPIN,
+			results: CVMResults{0x00, 0x00, 0x01},
+			tvr:     TVR{0x00, 0x00, 0xA0, 0x00, 0x00},
+		},
+		{
+			name:    "No Applicable Rule",
+			list:    tlv.Hex(list, "1E05", "1F04", "1F80"),
+			tx:      attended,
+			results: CVMResults{0x3F, 0x00, 0x01},
+			tvr:     TVR{0x00, 0x00, 0x80, 0x00, 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list *CVMList
+			if tt.list != nil {
+				var err error
+				if list, err = ParseCVMList(tt.list); err != nil {
+					t.Fatalf("ParseCVMList failed: %v", err)
+				}
+			}
+
+			e := EvaluateCVM(list, tt.tx)
+			if e.Successful != tt.successful {
+				t.Errorf("Successful = %v, want %v\n%s", e.Successful, tt.successful, e.Describe())
+			}
+			if diff := cmp.Diff(tt.results, e.Results); diff != "" {
+				t.Errorf("CVM Results mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.tvr, e.TVR); diff != "" {
+				t.Errorf("TVR mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCVMEvaluationDescribe(t *testing.T) {
+	list, err := ParseCVMList(tlv.Hex("00000000 00000000", "4200", "1E00"))
+	if err != nil {
+		t.Fatalf("ParseCVMList failed: %v", err)
+	}
+	e := EvaluateCVM(list, CVMTransaction{Capabilities: TerminalCapabilities{0xE0, 0x20, 0xC8}})
+
+	expected := []string{
+		"=== EMV CARDHOLDER VERIFICATION ===",
+		"    - #1 4200: Enciphered PIN verified online not supported by the terminal",
+		"    - #2 1E00: Signature (paper) performed: Unknown",
+		"    - Cardholder verification successful",
+		"    - CVM Results (9F34): 1E0000: Signature (paper), Always, Unknown",
+		"    - TVR (95) 0000100000: PIN entry required and PIN pad not present or not working",
+	}
+	desc := e.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package emv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// DYNAMIC DATA AUTHENTICATION (DDA) Logic according to EMV Book 2, section 6.
+// 1. The issuer public key is recovered with the CA public key (see recoverIssuerKey).
+// 2. The ICC public key is recovered with the issuer public key (see recoverICCKey).
+// 3. The card signs terminal dynamic data with its private key. The Signed Dynamic
+//    Application Data (Tag '9F4B') is recovered with the ICC public key:
+//
+//	6A | 05 | Hash Algo (1) | ICC Dynamic Data Length (1) | ICC Dynamic Data | Pad 'BB' | Hash (20) | BC
+//
+// 4. The hash covers the recovered data from the format to the pad pattern, followed
+//    by the terminal dynamic data.
+//
+// The terminal dynamic data depends on the method:
+// - DDA: the data requested by the DDOL and sent with INTERNAL AUTHENTICATE.
+// - fDDA (contactless, EMV Book C-3): the card signs during GET PROCESSING OPTIONS.
+//   The data is the Unpredictable Number ('9F37'), followed, when the Card Authentication
+//   Related Data ('9F69') announces fDDA version '01', by the Amount, Authorised ('9F02'),
+//   the Transaction Currency Code ('5F2A') and the Card Authentication Related Data.
+// - CDA: the Unpredictable Number (see VerifyCDA).
+//
+// INTERNAL AUTHENTICATE (EMV Book 3, section 6.5.9): CLA '00', INS '88', data = DDOL data.
+// The card answers with the Signed Dynamic Application Data, either as the value of a
+// format 1 template (Tag '80'), or as a '9F4B' object of a format 2 template (Tag '77').
+
+// DefaultDDOL is the terminal DDOL, used when the card has no DDOL (Tag '9F49').
+// It must contain the Unpredictable Number.
+var DefaultDDOL = DOL{{Tag: "9F37", Length: 4}}
+
+// ddaRequiredTags are the data objects the card must provide for DDA, fDDA and CDA.
+var ddaRequiredTags = []string{"8F", "90", "9F32", "9F46", "9F47"}
+
+// InternalAuthenticate creates an INTERNAL AUTHENTICATE command from already built DDOL data.
+func InternalAuthenticate(ddolData []byte) *iso7816.CommandAPDU {
+	ins, _ := iso7816.NewInstruction(iso7816.INS_INTERNAL_AUTHENTICATE)
+	return iso7816.NewCommandAPDU(iso7816.Class{}, ins, 0x00, 0x00, ddolData, iso7816.MaxShortLe)
+}
+
+// InternalAuthenticateWithDDOL builds the DDOL data from the terminal data and creates the
+// INTERNAL AUTHENTICATE command. The tags that the terminal could not provide are returned in missing.
+func InternalAuthenticateWithDDOL(ddol DOL, src DataSource) (cmd *iso7816.CommandAPDU, missing []string) {
+	data, missing := ddol.Build(src)
+	return InternalAuthenticate(data), missing
+}
+
+// ParseInternalAuthenticate extracts the Signed Dynamic Application Data from the response
+// data of an INTERNAL AUTHENTICATE command.
+func ParseInternalAuthenticate(data []byte) ([]byte, error) {
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
+	}
+
+	if template, found := tlv.FindElement(elements, "80"); found {
+		return template.Value, nil
+	}
+	if template, found := tlv.FindElement(elements, "77"); found {
+		if sdad, found := tlv.FindElement(template.Children, "9F4B"); found {
+			return sdad.Value, nil
+		}
+		return nil, fmt.Errorf("invalid format 2 response: missing Signed Dynamic Application Data (9F4B)")
+	}
+
+	return nil, fmt.Errorf("missing response template (Tag 80 or 77)")
+}
+
+// SignedDynamicData holds the fields recovered from the Signed Dynamic Application Data.
+//
+// The ICC Dynamic Data starts with the ICC Dynamic Number Length (1) and the ICC Dynamic
+// Number (2-8 bytes). With CDA, it goes on with the CID (1), the Application Cryptogram (8)
+// and the Transaction Data Hash Code (20).
+type SignedDynamicData struct {
+	Format           byte
+	HashAlgorithm    byte
+	ICCDynamicData   []byte
+	ICCDynamicNumber []byte
+
+	// CDA only.
+	CID                     CID
+	ApplicationCryptogram   []byte
+	TransactionDataHashCode []byte
+
+	Hash []byte
+}
+
+func (d *SignedDynamicData) describe(section *report.Section) {
+	section.Note("Format", fmt.Sprintf("%02X", d.Format))
+	section.Note("Hash Algorithm", fmt.Sprintf("%02X", d.HashAlgorithm))
+	section.Note("ICC Dynamic Data", fmt.Sprintf("%X", d.ICCDynamicData))
+	if len(d.ICCDynamicNumber) > 0 {
+		section.Note("ICC Dynamic Number", fmt.Sprintf("%X", d.ICCDynamicNumber))
+	}
+	if len(d.ApplicationCryptogram) > 0 {
+		section.Note("CID", d.CID.String())
+		section.Note("Application Cryptogram", fmt.Sprintf("%X", d.ApplicationCryptogram))
+		section.Note("Transaction Data Hash Code", fmt.Sprintf("%X", d.TransactionDataHashCode))
+	}
+	section.Note("Hash", fmt.Sprintf("%X", d.Hash))
+}
+
+// authenticateICC checks the data of the card and recovers the issuer and ICC public keys.
+func authenticateICC(in ODAInput, r *AuthenticationResult) bool {
+	return r.require(in, ddaRequiredTags...) && recoverIssuerKey(in, r) && recoverICCKey(in, r)
+}
+
+// verifyDynamicSignature recovers the Signed Dynamic Application Data with the ICC public key
+// and verifies its hash over the terminal dynamic data.
+func verifyDynamicSignature(r *AuthenticationResult, sdad, terminalData []byte) bool {
+	if !r.check("Signed Dynamic Application Data", len(sdad) > 0, "%d bytes", len(sdad)) {
+		return false
+	}
+	recovered, err := r.ICCPublicKey.recover(sdad)
+	if !r.check("Signed Dynamic Data Recovery", err == nil, "%d bytes%s", len(sdad), errorSuffix(err)) {
+		return false
+	}
+	if !r.check("Signed Dynamic Data Length", len(recovered) >= 28, "%d bytes (at least 28)", len(recovered)) {
+		return false
+	}
+	if !checkFrame(r, "Signed Dynamic Data", recovered, 0x05) {
+		return false
+	}
+
+	n, ldd := len(recovered), int(recovered[3])
+	if !r.check("ICC Dynamic Data Length", ldd >= 3 && ldd <= n-25, "%d bytes (at most %d)", ldd, n-25) {
+		return false
+	}
+
+	r.DynamicData = &SignedDynamicData{
+		Format:         recovered[1],
+		HashAlgorithm:  recovered[2],
+		ICCDynamicData: recovered[4 : 4+ldd],
+		Hash:           recovered[n-21 : n-1],
+	}
+	if !checkHash(r, "Signed Dynamic Data Hash", concat(recovered[1:n-21], terminalData), r.DynamicData.Hash) {
+		return false
+	}
+
+	ldn := int(r.DynamicData.ICCDynamicData[0])
+	if !r.check("ICC Dynamic Number", ldn >= 2 && ldn <= 8 && ldn < ldd, "%d bytes", ldn) {
+		return false
+	}
+	r.DynamicData.ICCDynamicNumber = r.DynamicData.ICCDynamicData[1 : 1+ldn]
+	return true
+}
+
+// VerifyDDA performs Dynamic Data Authentication. sdad is the Signed Dynamic Application Data
+// returned by INTERNAL AUTHENTICATE, and ddolData the data field sent in the command.
+func VerifyDDA(in ODAInput, sdad, ddolData []byte) *AuthenticationResult {
+	r := &AuthenticationResult{Method: "DDA"}
+	if authenticateICC(in, r) {
+		verifyDynamicSignature(r, sdad, ddolData)
+	}
+	return r
+}
+
+// VerifyFDDA performs fast Dynamic Data Authentication. sdad is the Signed Dynamic Application
+// Data returned during GET PROCESSING OPTIONS, and terminal provides the transaction data
+// ('9F37', '9F02', '5F2A') sent to the card.
+func VerifyFDDA(in ODAInput, sdad []byte, terminal DataSource) *AuthenticationResult {
+	r := &AuthenticationResult{Method: "fDDA"}
+	if !authenticateICC(in, r) {
+		return r
+	}
+
+	terminalData, err := fddaTerminalData(in, terminal)
+	if !r.check("Terminal Dynamic Data", err == nil, "%X%s", terminalData, errorSuffix(err)) {
+		return r
+	}
+	verifyDynamicSignature(r, sdad, terminalData)
+	return r
+}
+
+// fddaTerminalData builds the terminal dynamic data signed with fDDA.
+func fddaTerminalData(in ODAInput, terminal DataSource) ([]byte, error) {
+	tags := []string{"9F37"}
+	cad := in.lookup("9F69")
+	if len(cad) > 0 && cad[0] == 0x01 {
+		tags = append(tags, "9F02", "5F2A")
+	}
+
+	var data []byte
+	var missing []string
+	for _, tag := range tags {
+		value, ok := terminal.Lookup(tag)
+		if !ok {
+			missing = append(missing, tag)
+		}
+		data = append(data, value...)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing terminal data %s", strings.Join(missing, ", "))
+	}
+
+	if len(tags) > 1 {
+		data = append(data, cad...)
+	}
+	return data, nil
+}
@@ -0,0 +1,250 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestInternalAuthenticate(t *testing.T) {
+	cmd, missing := InternalAuthenticateWithDDOL(DefaultDDOL, TerminalData{"9F37": tlv.Hex("11223344")})
+	if len(missing) > 0 {
+		t.Errorf("unexpected missing tags: %v", missing)
+	}
+
+	raw, err := cmd.Bytes()
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("00 88 00 00 04", "11223344", "00"), raw); diff != "" {
+		t.Errorf("APDU mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseInternalAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []byte
+		wantErr  bool
+	}{
+		{"Format 1", tlv.Hex("80 04 11223344"), tlv.Hex("11223344"), false},
+		{"Format 2", tlv.Hex("77 07 9F4B04 11223344"), tlv.Hex("11223344"), false},
+		{"Format 2 Without SDAD", tlv.Hex("77 05 9F4C02 1122"), nil, true},
+		{"Unknown Template", tlv.Hex("70 02 5A00"), nil, true},
+		{"Malformed", tlv.Hex("80 05 1122"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInternalAuthenticate(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInternalAuthenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("SDAD mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVerifyDDA(t *testing.T) {
+	p := loadTestPKI(t)
+	ddolData := tlv.Hex("11223344")
+	sdad := p.signedDynamicData(tlv.Hex("04 A1B2C3D4"), ddolData)
+
+	tests := []struct {
+		name        string
+		modify      func(data *ApplicationData)
+		sdad        []byte
+		ddolData    []byte
+		passed      bool
+		failedStep  string
+		dataMissing bool
+	}{
+		{name: "Valid", sdad: sdad, ddolData: ddolData, passed: true},
+		{
+			name:        "Missing ICC Certificate",
+			modify:      func(d *ApplicationData) { delete(d.objects, "9F46") },
+			sdad:        sdad,
+			ddolData:    ddolData,
+			failedStep:  "Required Data",
+			dataMissing: true,
+		},
+		{
+			name:       "Modified Record",
+			modify:     func(d *ApplicationData) { d.OfflineAuthenticationData[0] = 0x57 },
+			sdad:       sdad,
+			ddolData:   ddolData,
+			failedStep: "ICC Certificate Hash",
+		},
+		{
+			name:       "Wrong ICC Remainder",
+			modify:     func(d *ApplicationData) { replace(d, "9F48", tlv.Hex("00")) },
+			sdad:       sdad,
+			ddolData:   ddolData,
+			failedStep: "ICC Certificate Hash",
+		},
+		{
+			name: "PAN Mismatch",
+			modify: func(d *ApplicationData) {
+				staticData := concat(d.OfflineAuthenticationData, tlv.Hex("6100"))
+				cert, _ := p.iccCertificate("4761739000100099FFFF", "0627", staticData)
+				replace(d, "9F46", cert)
+			},
+			sdad:       sdad,
+			ddolData:   ddolData,
+			failedStep: "Application PAN",
+		},
+		{
+			name: "Expired ICC Certificate",
+			modify: func(d *ApplicationData) {
+				staticData := concat(d.OfflineAuthenticationData, tlv.Hex("6100"))
+				cert, _ := p.iccCertificate("4761739000100010FFFF", "0526", staticData)
+				replace(d, "9F46", cert)
+			},
+			sdad:       sdad,
+			ddolData:   ddolData,
+			failedStep: "ICC Certificate Expiry",
+		},
+		{name: "No Signature", ddolData: ddolData, failedStep: "Signed Dynamic Application Data"},
+		{
+			name:       "Signed By Issuer",
+			sdad:       p.signedStaticData("DAC1", nil),
+			ddolData:   ddolData,
+			failedStep: "Signed Dynamic Data Recovery",
+		},
+		{name: "Other Unpredictable Number", sdad: sdad, ddolData: tlv.Hex("55667788"), failedStep: "Signed Dynamic Data Hash"},
+		{
+			name:       "Invalid ICC Dynamic Number",
+			sdad:       p.signedDynamicData(tlv.Hex("09 A1B2C3D4"), ddolData),
+			ddolData:   ddolData,
+			failedStep: "ICC Dynamic Number",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyDDA(odaInput(t, tt.modify), tt.sdad, tt.ddolData)
+			if result.Passed() != tt.passed {
+				t.Fatalf("Passed() = %v, want %v\n%s", result.Passed(), tt.passed, result.Describe())
+			}
+			if result.DataMissing != tt.dataMissing {
+				t.Errorf("DataMissing = %v, want %v", result.DataMissing, tt.dataMissing)
+			}
+			if tt.passed {
+				if diff := cmp.Diff(tlv.Hex("A1B2C3D4"), result.DynamicData.ICCDynamicNumber); diff != "" {
+					t.Errorf("ICC Dynamic Number mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			last := result.Steps[len(result.Steps)-1]
+			if last.Passed || last.Name != tt.failedStep {
+				t.Errorf("last step = %+v, want failed %q", last, tt.failedStep)
+			}
+		})
+	}
+}
+
+func TestVerifyFDDA(t *testing.T) {
+	p := loadTestPKI(t)
+	terminal := TerminalData{
+		"9F37": tlv.Hex("11223344"),
+		"9F02": tlv.Hex("000000001500"),
+		"5F2A": tlv.Hex("0978"),
+	}
+	cad := "01 A1B2C3D4 0000"
+
+	tests := []struct {
+		name       string
+		cad        string
+		signed     []byte
+		terminal   TerminalData
+		passed     bool
+		failedStep string
+	}{
+		{
+			name:     "Version 00",
+			signed:   tlv.Hex("11223344"),
+			terminal: terminal,
+			passed:   true,
+		},
+		{
+			name:     "Version 01",
+			cad:      cad,
+			signed:   tlv.Hex("11223344", "000000001500", "0978", cad),
+			terminal: terminal,
+			passed:   true,
+		},
+		{
+			name:       "Version 01 Signing Only Unpredictable Number",
+			cad:        cad,
+			signed:     tlv.Hex("11223344"),
+			terminal:   terminal,
+			failedStep: "Signed Dynamic Data Hash",
+		},
+		{
+			name:       "Missing Amount",
+			cad:        cad,
+			signed:     tlv.Hex("11223344"),
+			terminal:   TerminalData{"9F37": tlv.Hex("11223344"), "5F2A": tlv.Hex("0978")},
+			failedStep: "Terminal Dynamic Data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := odaInput(t, func(d *ApplicationData) {
+				if tt.cad != "" {
+					d.Set("9F69", tlv.Hex(tt.cad))
+				}
+			})
+
+			sdad := p.signedDynamicData(tlv.Hex("04 A1B2C3D4"), tt.signed)
+			result := VerifyFDDA(in, sdad, tt.terminal)
+			if result.Passed() != tt.passed {
+				t.Fatalf("Passed() = %v, want %v\n%s", result.Passed(), tt.passed, result.Describe())
+			}
+			if tt.passed {
+				return
+			}
+
+			last := result.Steps[len(result.Steps)-1]
+			if last.Passed || last.Name != tt.failedStep {
+				t.Errorf("last step = %+v, want failed %q", last, tt.failedStep)
+			}
+		})
+	}
+}
+
+func TestVerifyDDADescribe(t *testing.T) {
+	p := loadTestPKI(t)
+	ddolData := tlv.Hex("11223344")
+	result := VerifyDDA(odaInput(t, nil), p.signedDynamicData(tlv.Hex("04 A1B2C3D4"), ddolData), ddolData)
+	desc := result.Describe()
+
+	for _, want := range []string{
+		"Method: DDA",
+		"[OK] 04 (expected 04)",
+		"[2] Issuer Public Key Certificate:",
+		"Issuer Identifier: 476173",
+		"[3] ICC Public Key Certificate:",
+		"Application PAN: 4761739000100010",
+		"Expiration Date: 06/27",
+		"Serial Number: 000001",
+		"Public Key Length: 128 bytes",
+		"[4] Signed Dynamic Application Data:",
+		"Format: 05",
+		"ICC Dynamic Data: 04A1B2C3D4",
+		"ICC Dynamic Number: A1B2C3D4",
+		"DDA succeeded",
+		"ICC Public Key: 1024 bits",
+	} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("Describe() missing %q:\n%s", want, desc)
+		}
+	}
+}
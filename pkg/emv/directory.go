@@ -1,9 +1,10 @@
 package emv
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/gregLibert/smart-card/pkg/tlv"
 	"github.com/moov-io/bertlv"
 )
@@ -22,15 +23,21 @@ type DirectoryDiscretionaryTemplate struct {
 	Unknown []bertlv.TLV `tlv:",unknown"`
 }
 
+// ParseLogEntry decodes the Log Entry (Tag '9F4D') locating the transaction log.
+// A nil entry is returned when the card does not keep a log.
+func (d *DirectoryDiscretionaryTemplate) ParseLogEntry() (*LogEntry, error) {
+	return parseOptionalLogEntry(d.LogEntry)
+}
+
 // ApplicationTemplate (Tag '61') represents an entry in the Payment System Directory.
 // It contains the necessary information to select a specific application.
 type ApplicationTemplate struct {
-	AID                          []byte                         `tlv:"4F"`             // Mandatory
-	ApplicationLabel             []byte                         `tlv:"50" fmt:"ascii"` // Mandatory
-	ApplicationPriorityIndicator []byte                         `tlv:"87" fmt:"int"`
-	DirectoryDiscretionaryData   DirectoryDiscretionaryTemplate `tlv:"73"`
-	ApplicationPreferredName     []byte                         `tlv:"9F12" fmt:"ascii"`
-	DDFName                      []byte                         `tlv:"9D" fmt:"ascii"`
+	AID                          []byte                         `tlv:"4F,mandatory,len=5-16"`
+	ApplicationLabel             []byte                         `tlv:"50,mandatory,len=1-16" fmt:"ans"`
+	ApplicationPriorityIndicator []byte                         `tlv:"87,len=1" fmt:"int"`
+	DirectoryDiscretionaryData   DirectoryDiscretionaryTemplate `tlv:"73" report:"Discretionary"`
+	ApplicationPreferredName     []byte                         `tlv:"9F12,len=1-16" fmt:"ascii"`
+	DDFName                      []byte                         `tlv:"9D,len=5-16" fmt:"ascii"`
 
 	Unknown []bertlv.TLV `tlv:",unknown"`
 }
@@ -39,51 +46,53 @@ type ApplicationTemplate struct {
 // It is wrapped in a Record Template (Tag '70').
 type DirectoryRecord struct {
 	// A record can technically contain multiple application templates
-	Applications []ApplicationTemplate `tlv:"61"`
+	Applications []ApplicationTemplate `tlv:"61,mandatory" report:"App"`
+
+	// Raw keeps the data objects of the record exactly as returned by the card.
+	Raw []tlv.Element `tlv:",raw"`
 
 	Unknown []bertlv.TLV `tlv:",unknown"`
 }
 
 // ParseDirectoryRecord interprets raw bytes from a READ RECORD command as EMV directory data.
+// When the record is readable but violates EMV constraints (e.g. an entry without AID),
+// the record is returned together with an error wrapping a *tlv.ValidationError.
 func ParseDirectoryRecord(data []byte) (*DirectoryRecord, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty record data")
 	}
 
-	packets, err := bertlv.Decode(data)
+	elements, err := tlv.DecodeElements(data)
 	if err != nil {
 		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
 	}
 
 	// The record must be wrapped in Tag '70'
-	var processingPackets []bertlv.TLV
-	if len(packets) > 0 && strings.EqualFold(packets[0].Tag, "70") {
-		processingPackets = packets[0].TLVs
-	} else {
+	template, found := tlv.FindElement(elements, "70")
+	if !found {
 		return nil, fmt.Errorf("missing mandatory Record Template (Tag 70)")
 	}
 
 	record := &DirectoryRecord{}
-	if err := tlv.UnmarshalFromPackets(processingPackets, record); err != nil {
+	if err := tlv.UnmarshalElements(template.Children, record); err != nil {
+		var verr *tlv.ValidationError
+		if errors.As(err, &verr) {
+			return record, fmt.Errorf("invalid directory record: %w", err)
+		}
 		return nil, fmt.Errorf("failed to map directory record: %w", err)
 	}
 
 	return record, nil
 }
 
+// Report builds the structured report of all applications found in the record.
+func (r *DirectoryRecord) Report() *report.Report {
+	rep := report.New("EMV DIRECTORY RECORD")
+	rep.AddSection("").AddNode(tlv.BuildNode("Record", r))
+	return rep
+}
+
 // Describe generates a report for all applications found in the record.
 func (r *DirectoryRecord) Describe() string {
-	var sb strings.Builder
-	sb.WriteString("=== EMV DIRECTORY RECORD ===")
-
-	tlv.WriteStructFields(&sb, "Record", r)
-
-	for i, app := range r.Applications {
-		prefix := fmt.Sprintf("App[%d]", i+1)
-		tlv.WriteStructFields(&sb, prefix, app)
-
-		tlv.WriteStructFields(&sb, prefix+".Discretionary", app.DirectoryDiscretionaryData)
-	}
-
-	return strings.TrimRight(sb.String(), "\n")
+	return report.Text(r.Report())
 }
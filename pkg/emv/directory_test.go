@@ -1,6 +1,7 @@
 package emv
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -46,3 +47,40 @@ func TestParseDirectoryRecord_WithUnknowns(t *testing.T) {
 		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestParseDirectoryRecord_ConstraintViolations(t *testing.T) {
+	rawData := tlv.Hex(
+		"70 13",
+		"61 09", // Valid entry
+		"4F 05 A000000042",
+		"50 00", // Empty label
+		"61 06", // Entry without label and with a truncated AID
+		"4F 04 A0000000",
+	)
+
+	record, err := ParseDirectoryRecord(rawData)
+
+	var verr *tlv.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	if record == nil || len(record.Applications) != 2 {
+		t.Fatalf("Record should still be returned with both entries, got %+v", record)
+	}
+
+	var got []string
+	for _, fe := range verr.Errors {
+		got = append(got, fe.Path+": "+fe.Message)
+	}
+
+	want := []string{
+		"61[1]/50: length 0, expected 1-16",
+		"61[2]/4F: length 4, expected 5-16",
+		"61[2]/50: mandatory tag missing",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Violations mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,187 @@
+package emv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// DATA OBJECT LIST (DOL) Logic according to EMV Book 3, section 5.4.
+// A DOL (PDOL '9F38', CDOL1 '8C', CDOL2 '8D', DDOL '9F49', TDOL '97') is a list of
+// tag/length pairs by which the card requests terminal data. The terminal answers
+// with the concatenation of the requested values, without tags nor lengths.
+
+// DOLEntry is a single data object requested by a DOL.
+type DOLEntry struct {
+	Tag    string // Upper-case hex tag, e.g. "9F02"
+	Length int    // Number of bytes expected by the card
+}
+
+// Constructed reports whether the requested tag is a constructed data object.
+// The terminal always answers such entries with zeros.
+func (e DOLEntry) Constructed() bool {
+	first, err := hex.DecodeString(e.Tag[:2])
+	return err == nil && first[0]&0x20 != 0
+}
+
+// DOL is an ordered Data Object List.
+type DOL []DOLEntry
+
+// ParseDOL decodes the tag/length pairs of a DOL.
+func ParseDOL(data []byte) (DOL, error) {
+	var dol DOL
+
+	for offset := 0; offset < len(data); {
+		tagLen := tagLength(data, offset)
+		if offset+tagLen >= len(data) {
+			return nil, fmt.Errorf("offset %d: DOL entry is incomplete", offset)
+		}
+
+		dol = append(dol, DOLEntry{
+			Tag:    strings.ToUpper(hex.EncodeToString(data[offset : offset+tagLen])),
+			Length: int(data[offset+tagLen]),
+		})
+		offset += tagLen + 1
+	}
+
+	return dol, nil
+}
+
+// tagLength returns the length of the BER-TLV tag starting at data[offset]: subsequent
+// bytes follow a first byte ending with '1F' while their bit 8 is set.
+func tagLength(data []byte, offset int) int {
+	tagLen := 1
+	if data[offset]&0x1F == 0x1F {
+		for offset+tagLen < len(data) && data[offset+tagLen]&0x80 != 0 {
+			tagLen++
+		}
+		tagLen++
+	}
+	return tagLen
+}
+
+// Length returns the total length of the data built from the DOL.
+func (d DOL) Length() int {
+	total := 0
+	for _, e := range d {
+		total += e.Length
+	}
+	return total
+}
+
+// Bytes re-encodes the DOL as tag/length pairs.
+func (d DOL) Bytes() []byte {
+	var out []byte
+	for _, e := range d {
+		tag, _ := hex.DecodeString(e.Tag)
+		out = append(out, tag...)
+		out = append(out, byte(e.Length))
+	}
+	return out
+}
+
+// Split is the reverse of Build: it cuts data built from the DOL into its values.
+func (d DOL) Split(data []byte) (TerminalData, error) {
+	if len(data) != d.Length() {
+		return nil, fmt.Errorf("data is %d bytes long, the DOL requires %d", len(data), d.Length())
+	}
+
+	values := TerminalData{}
+	offset := 0
+	for _, e := range d {
+		values[e.Tag] = data[offset : offset+e.Length]
+		offset += e.Length
+	}
+	return values, nil
+}
+
+// DataSource provides the terminal data requested by a DOL.
+type DataSource interface {
+	// Lookup returns the value of a data element, tag being upper-case hex ("9F02").
+	Lookup(tag string) ([]byte, bool)
+}
+
+// TerminalData is a DataSource backed by a map of values keyed by upper-case hex tag.
+type TerminalData map[string][]byte
+
+// Lookup implements DataSource.
+func (t TerminalData) Lookup(tag string) ([]byte, bool) {
+	value, ok := t[strings.ToUpper(tag)]
+	return value, ok
+}
+
+// Build concatenates the values requested by the DOL (EMV Book 3, section 5.4):
+//
+//   - Unknown tags and constructed tags are filled with hexadecimal zeros.
+//   - Values that are too long are truncated: numeric (n) values keep their
+//     rightmost bytes, all other formats keep their leftmost bytes.
+//   - Values that are too short are padded: numeric (n) values with leading zeros,
+//     compressed numeric (cn) values with trailing 'FF', others with trailing zeros.
+//
+// The tags that the data source could not provide are returned in missing.
+func (d DOL) Build(src DataSource) (data []byte, missing []string) {
+	data = make([]byte, 0, d.Length())
+
+	for _, e := range d {
+		value, found := src.Lookup(e.Tag)
+		if !found || e.Constructed() {
+			if !found {
+				missing = append(missing, e.Tag)
+			}
+			data = append(data, make([]byte, e.Length)...)
+			continue
+		}
+
+		format := FormatB
+		if info, ok := LookupTag(e.Tag); ok {
+			format = info.Format
+		}
+		data = append(data, fitValue(value, e.Length, format)...)
+	}
+
+	return data, missing
+}
+
+// fitValue truncates or pads a value to the requested length according to its format.
+func fitValue(value []byte, length int, format string) []byte {
+	if len(value) >= length {
+		if format == FormatN {
+			return value[len(value)-length:]
+		}
+		return value[:length]
+	}
+
+	padLen := length - len(value)
+	switch format {
+	case FormatN:
+		return append(make([]byte, padLen), value...)
+	case FormatCN:
+		return append(append([]byte{}, value...), bytes.Repeat([]byte{0xFF}, padLen)...)
+	default:
+		return append(append([]byte{}, value...), make([]byte, padLen)...)
+	}
+}
+
+// Report builds the structured report of the requested data elements.
+func (d DOL) Report() *report.Report {
+	rep := report.New("EMV DATA OBJECT LIST")
+	section := rep.AddSection(fmt.Sprintf("%d entries, %d bytes requested", len(d), d.Length()))
+
+	for _, e := range d {
+		detail := fmt.Sprintf("%d bytes, %s", e.Length, TagName(e.Tag))
+		if info, ok := LookupTag(e.Tag); ok {
+			detail = fmt.Sprintf("%d bytes, %s (%s)", e.Length, info.Name, info.Format)
+		}
+		section.Note(e.Tag, detail)
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable list of the requested data elements.
+func (d DOL) Describe() string {
+	return report.Text(d.Report())
+}
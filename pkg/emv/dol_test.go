@@ -0,0 +1,143 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestParseDOL(t *testing.T) {
+	raw := tlv.Hex("9F66 04", "9F02 06", "5F2A 02", "9A 03", "BF0C 05")
+
+	dol, err := ParseDOL(raw)
+	if err != nil {
+		t.Fatalf("ParseDOL failed: %v", err)
+	}
+
+	expected := DOL{
+		{Tag: "9F66", Length: 4},
+		{Tag: "9F02", Length: 6},
+		{Tag: "5F2A", Length: 2},
+		{Tag: "9A", Length: 3},
+		{Tag: "BF0C", Length: 5},
+	}
+	if diff := cmp.Diff(expected, dol); diff != "" {
+		t.Errorf("DOL mismatch (-want +got):\n%s", diff)
+	}
+	if dol.Length() != 20 {
+		t.Errorf("Length() = %d, want 20", dol.Length())
+	}
+	if diff := cmp.Diff(raw, dol.Bytes()); diff != "" {
+		t.Errorf("Bytes() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDOL_Incomplete(t *testing.T) {
+	for _, raw := range [][]byte{tlv.Hex("9F02"), tlv.Hex("9F02 06 9A"), tlv.Hex("9F")} {
+		if _, err := ParseDOL(raw); err == nil {
+			t.Errorf("ParseDOL(%X) should fail", raw)
+		}
+	}
+}
+
+func TestDOLBuild(t *testing.T) {
+	tests := []struct {
+		name            string
+		dol             DOL
+		data            TerminalData
+		expected        []byte
+		expectedMissing []string
+	}{
+		{
+			name:     "Exact Lengths",
+			dol:      DOL{{"9F02", 6}, {"5F2A", 2}},
+			data:     TerminalData{"9F02": tlv.Hex("000000001500"), "5F2A": tlv.Hex("0978")},
+			expected: tlv.Hex("000000001500 0978"),
+		},
+		{
+			name:     "Numeric Padded Left And Truncated Left",
+			dol:      DOL{{"9F02", 8}, {"9A", 2}},
+			data:     TerminalData{"9F02": tlv.Hex("000000001500"), "9A": tlv.Hex("240131")},
+			expected: tlv.Hex("0000000000001500 0131"),
+		},
+		{
+			name:     "Compressed Numeric Padded With FF",
+			dol:      DOL{{"5A", 10}},
+			data:     TerminalData{"5A": tlv.Hex("4111111111111111")},
+			expected: tlv.Hex("4111111111111111 FFFF"),
+		},
+		{
+			name:     "Binary Padded Right And Truncated Right",
+			dol:      DOL{{"9F66", 6}, {"9F37", 2}},
+			data:     TerminalData{"9F66": tlv.Hex("36004000"), "9F37": tlv.Hex("11223344")},
+			expected: tlv.Hex("360040000000 1122"),
+		},
+		{
+			name:            "Missing And Constructed Tags Are Zero Filled",
+			dol:             DOL{{"9F1A", 2}, {"BF0C", 3}, {"DF01", 1}},
+			data:            TerminalData{"BF0C": tlv.Hex("AABBCC")},
+			expected:        tlv.Hex("0000 000000 00"),
+			expectedMissing: []string{"9F1A", "DF01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := tt.dol.Build(tt.data)
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("Data mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedMissing, missing); diff != "" {
+				t.Errorf("Missing mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDOLSplit(t *testing.T) {
+	dol := DOL{{"9F02", 6}, {"5F2A", 2}, {"9F37", 4}}
+	got, err := dol.Split(tlv.Hex("000000001500 0978 11223344"))
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	expected := TerminalData{"9F02": tlv.Hex("000000001500"), "5F2A": tlv.Hex("0978"), "9F37": tlv.Hex("11223344")}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Values mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := dol.Split(tlv.Hex("000000001500 0978")); err == nil {
+		t.Error("Split should reject data shorter than the DOL")
+	}
+}
+
+func TestDOLDescribe(t *testing.T) {
+	dol := DOL{{"9F02", 6}, {"DF01", 1}}
+
+	expected := []string{
+		"=== EMV DATA OBJECT LIST ===",
+		"2 entries, 7 bytes requested",
+		"    - 9F02: 6 bytes, Amount, Authorised (Numeric) (n)",
+		"    - DF01: 1 bytes, Unknown Tag DF01",
+	}
+	if diff := cmp.Diff(expected, strings.Split(dol.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFCIParsePDOL(t *testing.T) {
+	p := &FCIProprietaryTemplate{PDOL: tlv.Hex("9F66 04 9F02 06")}
+
+	dol, err := p.ParsePDOL()
+	if err != nil {
+		t.Fatalf("ParsePDOL failed: %v", err)
+	}
+	if diff := cmp.Diff(DOL{{"9F66", 4}, {"9F02", 6}}, dol); diff != "" {
+		t.Errorf("PDOL mismatch (-want +got):\n%s", diff)
+	}
+
+	if dol, err := (&FCIProprietaryTemplate{}).ParsePDOL(); dol != nil || err != nil {
+		t.Errorf("empty PDOL should give nil, nil; got %v, %v", dol, err)
+	}
+}
@@ -1,9 +1,10 @@
 package emv
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/gregLibert/smart-card/pkg/tlv"
 	"github.com/moov-io/bertlv"
 )
@@ -12,27 +13,44 @@ import (
 
 // FCI represents the EMV-specific File Control Information returned in response to a SELECT command.
 type FCI struct {
-	DFName              []byte                 `tlv:"84" fmt:"ascii"`
-	ProprietaryTemplate FCIProprietaryTemplate `tlv:"A5"`
+	DFName              []byte                 `tlv:"84,mandatory,len=5-16" fmt:"ascii"`
+	ProprietaryTemplate FCIProprietaryTemplate `tlv:"A5,mandatory" report:"Proprietary"`
+
+	// Raw keeps the data objects of the template exactly as returned by the card.
+	// tlv.EncodeElements(Raw) reproduces them byte for byte.
+	Raw []tlv.Element `tlv:",raw"`
 }
 
 // FCIProprietaryTemplate contains the issuer-specific data found in tag 'A5'.
 type FCIProprietaryTemplate struct {
-	ApplicationLabel []byte `tlv:"50" fmt:"ascii"`
+	ApplicationLabel []byte `tlv:"50,len=1-16" fmt:"ans"`
 
 	// Optional EMV fields
-	ApplicationPriorityIndicator []byte `tlv:"87" fmt:"int"`
-	SFI                          []byte `tlv:"88"`
+	ApplicationPriorityIndicator []byte `tlv:"87,len=1" fmt:"int"`
+	SFI                          []byte `tlv:"88,len=1"`
 	PDOL                         []byte `tlv:"9F38"`
-	LanguagePreference           []byte `tlv:"5F2D" fmt:"ascii"`
-	IssuerCodeTableIndex         []byte `tlv:"9F11" fmt:"int"`
-	ApplicationPreferredName     []byte `tlv:"9F12" fmt:"ascii"`
+	LanguagePreference           []byte `tlv:"5F2D,len=2-8" fmt:"an"`
+	IssuerCodeTableIndex         []byte `tlv:"9F11,len=1" fmt:"int"`
+	ApplicationPreferredName     []byte `tlv:"9F12,len=1-16" fmt:"ascii"`
 
-	IssuerDiscretionaryData *FCIIssuerDiscretionaryData `tlv:"BF0C"`
+	IssuerDiscretionaryData *FCIIssuerDiscretionaryData `tlv:"BF0C" report:"Discretionary"`
 
 	Unknown []bertlv.TLV `tlv:",unknown"`
 }
 
+// ParsePDOL decodes the Processing Options Data Object List (Tag '9F38').
+// A nil DOL is returned when the card does not request any terminal data.
+func (p *FCIProprietaryTemplate) ParsePDOL() (DOL, error) {
+	if len(p.PDOL) == 0 {
+		return nil, nil
+	}
+	dol, err := ParseDOL(p.PDOL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDOL: %w", err)
+	}
+	return dol, nil
+}
+
 // FCIIssuerDiscretionaryData represents the discretionary data (Tag 'BF0C') which often contains specific bank or country information.
 type FCIIssuerDiscretionaryData struct {
 	LogEntry                           []byte `tlv:"9F4D"`
@@ -47,45 +65,50 @@ type FCIIssuerDiscretionaryData struct {
 	Unknown []bertlv.TLV `tlv:",unknown"`
 }
 
+// ParseLogEntry decodes the Log Entry (Tag '9F4D') locating the transaction log.
+// A nil entry is returned when the card does not keep a log.
+func (d *FCIIssuerDiscretionaryData) ParseLogEntry() (*LogEntry, error) {
+	return parseOptionalLogEntry(d.LogEntry)
+}
+
 // ParseFCI interprets raw byte data as an EMV FCI structure.
+// When the FCI is readable but violates EMV constraints (e.g. missing DF Name),
+// the FCI is returned together with an error wrapping a *tlv.ValidationError.
 func ParseFCI(data []byte) (*FCI, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty data cannot be parsed")
 	}
 
-	packets, err := bertlv.Decode(data)
+	elements, err := tlv.DecodeElements(data)
 	if err != nil {
 		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
 	}
 
-	var processingPackets []bertlv.TLV
-
-	if len(packets) > 0 && strings.EqualFold(packets[0].Tag, "6F") {
-		processingPackets = packets[0].TLVs
-	} else {
-		processingPackets = packets
+	processingElements := elements
+	if template, found := tlv.FindElement(elements, "6F"); found {
+		processingElements = template.Children
 	}
 
 	fci := &FCI{}
-	if err := tlv.UnmarshalFromPackets(processingPackets, fci); err != nil {
+	if err := tlv.UnmarshalElements(processingElements, fci); err != nil {
+		var verr *tlv.ValidationError
+		if errors.As(err, &verr) {
+			return fci, fmt.Errorf("invalid FCI: %w", err)
+		}
 		return nil, fmt.Errorf("failed to map structure: %w", err)
 	}
 
 	return fci, nil
 }
 
+// Report builds the structured report of the FCI content, nested templates included.
+func (f *FCI) Report() *report.Report {
+	r := report.New("EMV FCI TEMPLATE")
+	r.AddSection("").AddNode(tlv.BuildNode("FCI", f))
+	return r
+}
+
 // Describe generates a detailed, standardized report of the FCI content.
 func (f *FCI) Describe() string {
-	var sb strings.Builder
-	sb.WriteString("=== EMV FCI TEMPLATE ===")
-
-	tlv.WriteStructFields(&sb, "FCI", f)
-
-	tlv.WriteStructFields(&sb, "Proprietary", f.ProprietaryTemplate)
-
-	if f.ProprietaryTemplate.IssuerDiscretionaryData != nil {
-		tlv.WriteStructFields(&sb, "Discretionary", f.ProprietaryTemplate.IssuerDiscretionaryData)
-	}
-
-	return strings.TrimRight(sb.String(), "\n")
+	return report.Text(f.Report())
 }
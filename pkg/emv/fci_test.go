@@ -1,6 +1,7 @@
 package emv
 
 import (
+	"bytes"
 	"encoding/hex"
 	"strings"
 	"testing"
@@ -117,3 +118,25 @@ func TestFCI_Describe(t *testing.T) {
 		t.Errorf("Report mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestParseFCI_PreservesRawEncoding(t *testing.T) {
+	// Non-minimal length for the proprietary template (81 06 instead of 06)
+	template := tlv.Hex(
+		"84 07 A0000000031010",
+		"A5 81 06",
+		"50 04 56495341",
+	)
+	rawData := append(tlv.Hex("6F 12"), template...)
+
+	fci, err := ParseFCI(rawData)
+	if err != nil {
+		t.Fatalf("ParseFCI failed: %v", err)
+	}
+
+	if got := tlv.EncodeElements(fci.Raw); !bytes.Equal(got, template) {
+		t.Errorf("Raw encoding lost:\nGot:  %X\nWant: %X", got, template)
+	}
+	if string(fci.ProprietaryTemplate.ApplicationLabel) != "VISA" {
+		t.Errorf("Label mismatch: %q", fci.ProprietaryTemplate.ApplicationLabel)
+	}
+}
@@ -0,0 +1,216 @@
+package emv
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+	"github.com/moov-io/bertlv"
+)
+
+// GENERATE APPLICATION CRYPTOGRAM Logic according to EMV Book 3, section 6.5.5.
+// The GENERATE AC command (CLA '80', INS 'AE') asks the card for an Application
+// Cryptogram. It is issued once with the data requested by CDOL1 (first GENERATE AC),
+// and possibly a second time, after an online authorisation, with CDOL2.
+//
+// P1 (Reference Control Parameter):
+// - Bits 8-7: Requested cryptogram: 00 = AAC (decline), 01 = TC (approve), 10 = ARQC (go online).
+// - Bit 5:    CDA signature requested.
+//
+// The card answers in one of two formats:
+// - Format 1 (Tag '80'): CID (1) || ATC (2) || Application Cryptogram (8) || Issuer Application Data.
+// - Format 2 (Tag '77'): BER-TLV objects '9F27', '9F36', '9F26', '9F10', and '9F4B' with CDA.
+
+// CryptogramType is the type of an Application Cryptogram (bits 8-7 of P1 and of the CID).
+type CryptogramType byte
+
+const (
+	CryptogramAAC  CryptogramType = 0x00 // Application Authentication Cryptogram: decline
+	CryptogramTC   CryptogramType = 0x40 // Transaction Certificate: approve
+	CryptogramARQC CryptogramType = 0x80 // Authorisation Request Cryptogram: go online
+	CryptogramRFU  CryptogramType = 0xC0
+)
+
+func (c CryptogramType) String() string {
+	switch c {
+	case CryptogramAAC:
+		return "AAC"
+	case CryptogramTC:
+		return "TC"
+	case CryptogramARQC:
+		return "ARQC"
+	default:
+		return "RFU"
+	}
+}
+
+// cdaRequested is bit 5 of the reference control parameter.
+const cdaRequested = 0x10
+
+// GenerateAC creates a GENERATE AC command from already built CDOL data.
+func GenerateAC(cryptogram CryptogramType, cda bool, cdolData []byte) *iso7816.CommandAPDU {
+	p1 := byte(cryptogram)
+	if cda {
+		p1 |= cdaRequested
+	}
+	return newEMVCommand(INS_GENERATE_AC, p1, 0x00, cdolData, iso7816.MaxShortLe)
+}
+
+// GenerateACWithCDOL builds the CDOL1 or CDOL2 data from the terminal data and creates
+// the GENERATE AC command. The tags that the terminal could not provide are returned in missing.
+func GenerateACWithCDOL(cryptogram CryptogramType, cda bool, cdol DOL, src DataSource) (cmd *iso7816.CommandAPDU, missing []string) {
+	data, missing := cdol.Build(src)
+	return GenerateAC(cryptogram, cda, data), missing
+}
+
+// CID is the Cryptogram Information Data (Tag '9F27').
+//
+// - Bits 8-7: Cryptogram type (AAC, TC, ARQC).
+// - Bits 6-5: Payment system-specific cryptogram.
+// - Bit 4:    Advice required.
+// - Bits 3-1: Reason/advice code.
+type CID byte
+
+// Type returns the type of the cryptogram returned by the card.
+func (c CID) Type() CryptogramType {
+	return CryptogramType(c & 0xC0)
+}
+
+// AdviceRequired reports whether the card asks for an advice message.
+func (c CID) AdviceRequired() bool {
+	return c&0x08 != 0
+}
+
+// Reason returns the meaning of the reason/advice code.
+func (c CID) Reason() string {
+	switch c & 0x07 {
+	case 0b000:
+		return "No information given"
+	case 0b001:
+		return "Service not allowed"
+	case 0b010:
+		return "PIN Try Limit exceeded"
+	case 0b011:
+		return "Issuer authentication failed"
+	default:
+		return fmt.Sprintf("RFU (%d)", byte(c&0x07))
+	}
+}
+
+func (c CID) String() string {
+	s := fmt.Sprintf("%02X -> %s, %s", byte(c), c.Type(), c.Reason())
+	if c.AdviceRequired() {
+		s += ", Advice required"
+	}
+	return s
+}
+
+// Cryptogram is the response to GENERATE AC.
+type Cryptogram struct {
+	// Format is the tag of the response template: 0x80 (format 1) or 0x77 (format 2).
+	Format                byte
+	CID                   CID
+	ATC                   uint16
+	ApplicationCryptogram []byte
+	IssuerApplicationData []byte
+
+	// SignedDynamicApplicationData is returned instead of the cryptogram when CDA was requested (format 2).
+	SignedDynamicApplicationData []byte
+
+	// Additional holds the other data objects of a format 2 response.
+	Additional []bertlv.TLV
+}
+
+// responseTemplateAC is the content of a format 2 GENERATE AC response (Tag '77').
+type responseTemplateAC struct {
+	CID                          []byte       `tlv:"9F27,mandatory,len=1"`
+	ATC                          []byte       `tlv:"9F36,mandatory,len=2"`
+	ApplicationCryptogram        []byte       `tlv:"9F26,len=8"`
+	IssuerApplicationData        []byte       `tlv:"9F10,len=1-32"`
+	SignedDynamicApplicationData []byte       `tlv:"9F4B"`
+	Unknown                      []bertlv.TLV `tlv:",unknown"`
+}
+
+// ParseCryptogram interprets the response data of a GENERATE AC command.
+func ParseCryptogram(data []byte) (*Cryptogram, error) {
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
+	}
+
+	if template, found := tlv.FindElement(elements, "80"); found {
+		value := template.Value
+		if len(value) < 11 || len(value) > 43 {
+			return nil, fmt.Errorf("format 1 response must be 11 to 43 bytes long (got %d)", len(value))
+		}
+		return &Cryptogram{
+			Format:                0x80,
+			CID:                   CID(value[0]),
+			ATC:                   binary.BigEndian.Uint16(value[1:3]),
+			ApplicationCryptogram: value[3:11],
+			IssuerApplicationData: value[11:],
+		}, nil
+	}
+
+	if template, found := tlv.FindElement(elements, "77"); found {
+		t := &responseTemplateAC{}
+		if err := tlv.UnmarshalElements(template.Children, t); err != nil {
+			return nil, fmt.Errorf("invalid format 2 response: %w", err)
+		}
+		if len(t.ApplicationCryptogram) == 0 && len(t.SignedDynamicApplicationData) == 0 {
+			return nil, fmt.Errorf("invalid format 2 response: missing Application Cryptogram (9F26) or Signed Dynamic Application Data (9F4B)")
+		}
+		return &Cryptogram{
+			Format:                       0x77,
+			CID:                          CID(t.CID[0]),
+			ATC:                          binary.BigEndian.Uint16(t.ATC),
+			ApplicationCryptogram:        t.ApplicationCryptogram,
+			IssuerApplicationData:        t.IssuerApplicationData,
+			SignedDynamicApplicationData: t.SignedDynamicApplicationData,
+			Additional:                   t.Unknown,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("missing response template (Tag 80 or 77)")
+}
+
+// Report builds the structured report of the cryptogram.
+func (c *Cryptogram) Report() *report.Report {
+	rep := report.New("EMV GENERATE AC")
+
+	format := "Format 1 (Tag 80)"
+	if c.Format == 0x77 {
+		format = "Format 2 (Tag 77)"
+	}
+
+	section := rep.AddSection("[=] RESPONSE:")
+	section.LabelWidth = 10
+	section.Note("Format", format)
+	section.Note("CID", c.CID.String())
+	section.Note("ATC", fmt.Sprintf("%04X (%d)", c.ATC, c.ATC))
+	if len(c.ApplicationCryptogram) > 0 {
+		section.Note("AC", fmt.Sprintf("%X", c.ApplicationCryptogram))
+	}
+	if len(c.IssuerApplicationData) > 0 {
+		section.Note("IAD", fmt.Sprintf("%X", c.IssuerApplicationData))
+	}
+	if len(c.SignedDynamicApplicationData) > 0 {
+		section.Note("SDAD", fmt.Sprintf("%d bytes", len(c.SignedDynamicApplicationData)))
+	}
+
+	if len(c.Additional) > 0 {
+		additional := rep.AddSection("Additional Data Objects:")
+		for _, obj := range c.Additional {
+			additional.Note(tagLabel(obj.Tag), fmt.Sprintf("%X", obj.Value))
+		}
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the cryptogram.
+func (c *Cryptogram) Describe() string {
+	return report.Text(c.Report())
+}
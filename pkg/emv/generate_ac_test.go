@@ -0,0 +1,159 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+	"github.com/moov-io/bertlv"
+)
+
+func TestGenerateAC(t *testing.T) {
+	cdol1 := DOL{{"9F02", 6}, {"9F37", 4}, {"95", 5}}
+	src := TerminalData{"9F02": tlv.Hex("000000002500"), "9F37": tlv.Hex("CAFEBABE")}
+
+	tests := []struct {
+		name       string
+		cryptogram CryptogramType
+		cda        bool
+		expected   []byte
+	}{
+		{"ARQC", CryptogramARQC, false, tlv.Hex("80 AE 80 00 0F", "000000002500 CAFEBABE 0000000000", "00")},
+		{"TC With CDA", CryptogramTC, true, tlv.Hex("80 AE 50 00 0F", "000000002500 CAFEBABE 0000000000", "00")},
+		{"AAC", CryptogramAAC, false, tlv.Hex("80 AE 00 00 0F", "000000002500 CAFEBABE 0000000000", "00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, missing := GenerateACWithCDOL(tt.cryptogram, tt.cda, cdol1, src)
+			if diff := cmp.Diff([]string{"95"}, missing); diff != "" {
+				t.Errorf("Missing mismatch (-want +got):\n%s", diff)
+			}
+
+			raw, err := cmd.Bytes()
+			if err != nil {
+				t.Fatalf("encoding failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, raw); diff != "" {
+				t.Errorf("APDU mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCID(t *testing.T) {
+	tests := []struct {
+		cid      CID
+		expected string
+	}{
+		{0x80, "80 -> ARQC, No information given"},
+		{0x40, "40 -> TC, No information given"},
+		{0x0A, "0A -> AAC, PIN Try Limit exceeded, Advice required"},
+		{0x03, "03 -> AAC, Issuer authentication failed"},
+		{0xC5, "C5 -> RFU, RFU (5)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cid.String(); got != tt.expected {
+			t.Errorf("CID(%02X).String() = %q, want %q", byte(tt.cid), got, tt.expected)
+		}
+	}
+}
+
+func TestParseCryptogram(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected *Cryptogram
+	}{
+		{
+			name: "Format 1",
+			data: tlv.Hex("80 12", "80", "0042", "1122334455667788", "06010A03A00000"),
+			expected: &Cryptogram{
+				Format:                0x80,
+				CID:                   0x80,
+				ATC:                   0x42,
+				ApplicationCryptogram: tlv.Hex("1122334455667788"),
+				IssuerApplicationData: tlv.Hex("06010A03A00000"),
+			},
+		},
+		{
+			name: "Format 2",
+			data: tlv.Hex("77 23", "9F2701 40", "9F3602 0102", "9F2608 1122334455667788", "9F1007 06010A03A00000", "9F4C02 ABCD"),
+			expected: &Cryptogram{
+				Format:                0x77,
+				CID:                   0x40,
+				ATC:                   0x0102,
+				ApplicationCryptogram: tlv.Hex("1122334455667788"),
+				IssuerApplicationData: tlv.Hex("06010A03A00000"),
+				Additional:            []bertlv.TLV{{Tag: "9F4C", Value: tlv.Hex("ABCD")}},
+			},
+		},
+		{
+			name: "Format 2 With CDA",
+			data: tlv.Hex("77 0F", "9F2701 40", "9F3602 0102", "9F4B03 6A0102"),
+			expected: &Cryptogram{
+				Format:                       0x77,
+				CID:                          0x40,
+				ATC:                          0x0102,
+				SignedDynamicApplicationData: tlv.Hex("6A0102"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCryptogram(tt.data)
+			if err != nil {
+				t.Fatalf("ParseCryptogram failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("Mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseCryptogram_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Unknown Template", tlv.Hex("70 01 00")},
+		{"Format 1 Too Short", tlv.Hex("80 0A 80 0042 11223344556677")},
+		{"Format 2 Without CID", tlv.Hex("77 0F 9F3602 0102 9F2608 1122334455667788")},
+		{"Format 2 Without Cryptogram", tlv.Hex("77 08 9F2701 80 9F3602 0102")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCryptogram(tt.data); err == nil {
+				t.Errorf("ParseCryptogram(%X) should fail", tt.data)
+			}
+		})
+	}
+}
+
+func TestCryptogramDescribe(t *testing.T) {
+	c := &Cryptogram{
+		Format:                0x80,
+		CID:                   0x80,
+		ATC:                   0x42,
+		ApplicationCryptogram: tlv.Hex("1122334455667788"),
+		IssuerApplicationData: tlv.Hex("06010A03A00000"),
+	}
+
+	expected := []string{
+		"=== EMV GENERATE AC ===",
+		"[=] RESPONSE:",
+		"    - Format:   Format 1 (Tag 80)",
+		"    - CID:      80 -> ARQC, No information given",
+		"    - ATC:      0042 (66)",
+		"    - AC:       1122334455667788",
+		"    - IAD:      06010A03A00000",
+	}
+	if diff := cmp.Diff(expected, strings.Split(c.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
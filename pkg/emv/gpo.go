@@ -0,0 +1,131 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+	"github.com/moov-io/bertlv"
+)
+
+// GET PROCESSING OPTIONS (GPO) Logic according to EMV Book 3, section 6.5.8.
+// The GPO command (CLA '80', INS 'A8') initiates the transaction. Its data field is
+// the Command Template (Tag '83') holding the values requested by the PDOL, or an
+// empty template when the card has no PDOL.
+//
+// The card answers with the AIP and the AFL, in one of two formats:
+// - Format 1 (Tag '80'): AIP (2 bytes) followed by the AFL entries, without tags.
+// - Format 2 (Tag '77'): a BER-TLV template containing '82' (AIP), '94' (AFL) and
+//   possibly other data objects (e.g. contactless cryptogram data).
+
+// GetProcessingOptions creates a GPO command from already built PDOL data.
+func GetProcessingOptions(pdolData []byte) *iso7816.CommandAPDU {
+	data := []byte{0x83}
+	if len(pdolData) > 0x7F {
+		data = append(data, 0x81)
+	}
+	data = append(data, byte(len(pdolData)))
+	data = append(data, pdolData...)
+
+	return newEMVCommand(INS_GET_PROCESSING_OPTIONS, 0x00, 0x00, data, iso7816.MaxShortLe)
+}
+
+// GetProcessingOptionsWithPDOL builds the PDOL data from the terminal data and creates the GPO command.
+// The PDOL tags that the terminal could not provide are returned in missing (see DOL.Build).
+func GetProcessingOptionsWithPDOL(pdol DOL, src DataSource) (cmd *iso7816.CommandAPDU, missing []string) {
+	data, missing := pdol.Build(src)
+	return GetProcessingOptions(data), missing
+}
+
+// ProcessingOptions is the response to GET PROCESSING OPTIONS.
+type ProcessingOptions struct {
+	// Format is the tag of the response template: 0x80 (format 1) or 0x77 (format 2).
+	Format byte
+	AIP    AIP
+	AFL    AFL
+
+	// Additional holds the data objects of a format 2 response other than AIP and AFL.
+	Additional []bertlv.TLV
+}
+
+// responseTemplate2 is the content of a format 2 response (Tag '77').
+type responseTemplate2 struct {
+	AIP     []byte       `tlv:"82,mandatory,len=2"`
+	AFL     []byte       `tlv:"94"`
+	Unknown []bertlv.TLV `tlv:",unknown"`
+}
+
+// ParseProcessingOptions interprets the response data of a GPO command.
+func ParseProcessingOptions(data []byte) (*ProcessingOptions, error) {
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
+	}
+
+	if template, found := tlv.FindElement(elements, "80"); found {
+		return parseFormat1(template.Value)
+	}
+	if template, found := tlv.FindElement(elements, "77"); found {
+		return parseFormat2(template.Children)
+	}
+
+	return nil, fmt.Errorf("missing response template (Tag 80 or 77)")
+}
+
+func parseFormat1(value []byte) (*ProcessingOptions, error) {
+	if len(value) < 2 {
+		return nil, fmt.Errorf("format 1 response too short: %d bytes", len(value))
+	}
+
+	aip, _ := NewAIP(value[:2])
+	afl, err := ParseAFL(value[2:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid format 1 response: %w", err)
+	}
+
+	return &ProcessingOptions{Format: 0x80, AIP: aip, AFL: afl}, nil
+}
+
+func parseFormat2(elements []tlv.Element) (*ProcessingOptions, error) {
+	template := &responseTemplate2{}
+	if err := tlv.UnmarshalElements(elements, template); err != nil {
+		return nil, fmt.Errorf("invalid format 2 response: %w", err)
+	}
+
+	aip, _ := NewAIP(template.AIP)
+	afl, err := ParseAFL(template.AFL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format 2 response: %w", err)
+	}
+
+	return &ProcessingOptions{Format: 0x77, AIP: aip, AFL: afl, Additional: template.Unknown}, nil
+}
+
+// Report builds the structured report of the processing options.
+func (p *ProcessingOptions) Report() *report.Report {
+	rep := report.New("EMV GET PROCESSING OPTIONS")
+
+	format := "Format 1 (Tag 80)"
+	if p.Format == 0x77 {
+		format = "Format 2 (Tag 77)"
+	}
+	rep.AddSection("[=] RESPONSE:").Note("Format", format)
+
+	rep.Sections = append(rep.Sections, p.AIP.Report().Sections...)
+	rep.Sections = append(rep.Sections, p.AFL.Report().Sections...)
+
+	if len(p.Additional) > 0 {
+		section := rep.AddSection("Additional Data Objects:")
+		for _, obj := range p.Additional {
+			section.Note(tagLabel(obj.Tag), fmt.Sprintf("%X", obj.Value))
+		}
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the processing options.
+func (p *ProcessingOptions) Describe() string {
+	return report.Text(p.Report())
+}
@@ -0,0 +1,141 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+	"github.com/moov-io/bertlv"
+)
+
+func TestGetProcessingOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      func() []byte
+		expected []byte
+	}{
+		{
+			name: "Empty PDOL",
+			cmd: func() []byte {
+				raw, _ := GetProcessingOptions(nil).Bytes()
+				return raw
+			},
+			expected: tlv.Hex("80 A8 00 00 02 83 00 00"),
+		},
+		{
+			name: "Built From PDOL",
+			cmd: func() []byte {
+				pdol := DOL{{"9F66", 4}, {"9F02", 6}, {"5F2A", 2}}
+				cmd, missing := GetProcessingOptionsWithPDOL(pdol, TerminalData{
+					"9F66": tlv.Hex("36004000"),
+					"9F02": tlv.Hex("000000001000"),
+				})
+				if diff := cmp.Diff([]string{"5F2A"}, missing); diff != "" {
+					t.Errorf("Missing mismatch (-want +got):\n%s", diff)
+				}
+				raw, _ := cmd.Bytes()
+				return raw
+			},
+			expected: tlv.Hex("80 A8 00 00 0E", "83 0C 36004000 000000001000 0000", "00"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expected, tt.cmd()); diff != "" {
+				t.Errorf("APDU mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseProcessingOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected *ProcessingOptions
+	}{
+		{
+			name: "Format 1",
+			data: tlv.Hex("80 0A 1C00 08010100 10010301"),
+			expected: &ProcessingOptions{
+				Format: 0x80,
+				AIP:    AIP{0x1C, 0x00},
+				AFL:    AFL{{1, 1, 1, 0}, {2, 1, 3, 1}},
+			},
+		},
+		{
+			name: "Format 2",
+			data: tlv.Hex("77 0F", "82 02 3980", "94 04 18010100", "9F36 02 0042"),
+			expected: &ProcessingOptions{
+				Format:     0x77,
+				AIP:        AIP{0x39, 0x80},
+				AFL:        AFL{{3, 1, 1, 0}},
+				Additional: []bertlv.TLV{{Tag: "9F36", Value: []byte{0x00, 0x42}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProcessingOptions(tt.data)
+			if err != nil {
+				t.Fatalf("ParseProcessingOptions failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("Mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseProcessingOptions_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Unknown Template", tlv.Hex("70 02 8200")},
+		{"Format 1 Too Short", tlv.Hex("80 01 1C")},
+		{"Format 1 Bad AFL", tlv.Hex("80 05 1C00 080101")},
+		{"Format 2 Without AIP", tlv.Hex("77 06 94 04 08010100")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseProcessingOptions(tt.data); err == nil {
+				t.Errorf("ParseProcessingOptions(%X) should fail", tt.data)
+			}
+		})
+	}
+}
+
+func TestProcessingOptionsDescribe(t *testing.T) {
+	po, err := ParseProcessingOptions(tlv.Hex("77 0F", "82 02 0080", "94 04 08010100", "9F36 02 0042", "00 00"))
+	if err != nil {
+		t.Fatalf("ParseProcessingOptions failed: %v", err)
+	}
+
+	got := strings.Split(po.Describe(), "\n")
+	expectedStart := []string{
+		"=== EMV GET PROCESSING OPTIONS ===",
+		"[=] RESPONSE:",
+		"    - Format: Format 2 (Tag 77)",
+		"",
+		"AIP (82): 0080",
+	}
+	if diff := cmp.Diff(expectedStart, got[:len(expectedStart)]); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedEnd := []string{
+		"AFL (94): 1 entries",
+		"    - SFI 01 (1): Records 1-1, 0 for offline authentication",
+		"",
+		"Additional Data Objects:",
+		"    - Application Transaction Counter (ATC) (9F36): 0042",
+	}
+	if diff := cmp.Diff(expectedEnd, got[len(got)-len(expectedEnd):]); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,32 @@
+package emv
+
+import "github.com/gregLibert/smart-card/pkg/iso7816"
+
+// EMV COMMANDS (EMV Book 3, section 6.5):
+// Commands that are specific to EMV payment applications use the proprietary
+// class '80' and instruction codes that are not defined by ISO/IEC 7816-4.
+
+// EMV proprietary Instruction (INS) codes.
+const (
+	INS_GET_PROCESSING_OPTIONS iso7816.InsCode = 0xA8
+	INS_GENERATE_AC            iso7816.InsCode = 0xAE
+	INS_RECOVER_AC             iso7816.InsCode = 0xD0 // EMV Book C-2, same code as INS_WRITE_BINARY
+)
+
+// instructionNames names the EMV instructions in traces, instead of the ISO 7816-4 names
+// that InsCode.String() gives to their codes.
+var instructionNames = map[iso7816.InsCode]string{
+	INS_GET_PROCESSING_OPTIONS: "INS_GET_PROCESSING_OPTIONS",
+	INS_GENERATE_AC:            "INS_GENERATE_AC",
+	INS_RECOVER_AC:             "INS_RECOVER_AC",
+}
+
+// ClassEMV is the proprietary class byte ('80') used by EMV-specific commands.
+var ClassEMV = iso7816.Class{Raw: 0x80, IsProprietary: true}
+
+// newEMVCommand creates a command with the EMV proprietary class.
+func newEMVCommand(ins iso7816.InsCode, p1, p2 byte, data []byte, ne int) *iso7816.CommandAPDU {
+	instruction, _ := iso7816.NewInstruction(ins)
+	instruction.Name = instructionNames[ins]
+	return iso7816.NewCommandAPDU(ClassEMV, instruction, p1, p2, data, ne)
+}
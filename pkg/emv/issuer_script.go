@@ -0,0 +1,323 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// ISSUER AUTHENTICATION AND SCRIPT PROCESSING Logic according to EMV Book 3, sections 10.9
+// and 10.10. After an online authorisation, the issuer response may carry:
+//
+// 1. Issuer Authentication Data ('91', 8 to 16 bytes): when the AIP announces issuer
+//    authentication, the terminal sends it in an EXTERNAL AUTHENTICATE command (CLA '00',
+//    INS '82'). Any status other than '9000' sets "Issuer authentication failed" in the TVR.
+//    "Issuer authentication was performed" is set in the TSI in both cases.
+// 2. Issuer Script Templates: '71' scripts are executed before the final GENERATE AC, '72'
+//    scripts after it. Each template holds an optional Issuer Script Identifier ('9F18',
+//    4 bytes) and Issuer Script Commands ('86'), each one a complete C-APDU.
+//    The commands are sent in order. A status other than '90XX', '62XX' or '63XX' stops the
+//    script: the terminal continues with the next script, and sets "Script processing
+//    failed before (or after) final GENERATE AC" in the TVR.
+//
+// The outcome of each script is reported to the issuer in the Issuer Script Results
+// ('9F5B', Book 4 Annex A5), 5 bytes per script:
+// - Byte 1, bits 8-5: 0 = not performed, 1 = processing failed, 2 = processing successful.
+// - Byte 1, bits 4-1: sequence number of the failed command (1 to 14, 'F' for 15 and above).
+// - Bytes 2-5:        Issuer Script Identifier, zeros when not received.
+
+// Issuer Script Template tags.
+const (
+	ScriptBeforeFinalGenerateAC byte = 0x71
+	ScriptAfterFinalGenerateAC  byte = 0x72
+)
+
+// ExternalAuthenticate creates an EXTERNAL AUTHENTICATE command with the Issuer
+// Authentication Data ('91').
+func ExternalAuthenticate(issuerAuthenticationData []byte) *iso7816.CommandAPDU {
+	ins, _ := iso7816.NewInstruction(iso7816.INS_EXTERNAL_AUTHENTICATE)
+	return iso7816.NewCommandAPDU(iso7816.Class{}, ins, 0x00, 0x00, issuerAuthenticationData, 0)
+}
+
+// IssuerScript is an Issuer Script Template ('71' or '72').
+type IssuerScript struct {
+	Template byte
+	ID       []byte   // Issuer Script Identifier ('9F18'), nil when absent
+	Commands [][]byte // Issuer Script Commands ('86')
+}
+
+// ParseIssuerScripts extracts the Issuer Script Templates from the data of an issuer
+// response. The other data objects are ignored.
+func ParseIssuerScripts(data []byte) ([]IssuerScript, error) {
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode issuer response: %w", err)
+	}
+
+	var scripts []IssuerScript
+	for _, e := range elements {
+		if e.Tag != "71" && e.Tag != "72" {
+			continue
+		}
+		script, err := parseIssuerScript(e)
+		if err != nil {
+			return nil, fmt.Errorf("Issuer Script Template %s #%d: %w", e.Tag, len(scripts)+1, err)
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+func parseIssuerScript(e tlv.Element) (IssuerScript, error) {
+	script := IssuerScript{Template: ScriptBeforeFinalGenerateAC}
+	if e.Tag == "72" {
+		script.Template = ScriptAfterFinalGenerateAC
+	}
+
+	for _, child := range e.Children {
+		switch child.Tag {
+		case "9F18":
+			if len(child.Value) != 4 {
+				return script, fmt.Errorf("Issuer Script Identifier must be 4 bytes long (got %d)", len(child.Value))
+			}
+			script.ID = child.Value
+		case "86":
+			script.Commands = append(script.Commands, child.Value)
+		}
+	}
+	return script, nil
+}
+
+// ScriptStatus is the outcome of an issuer script (bits 8-5 of the result byte).
+type ScriptStatus byte
+
+const (
+	ScriptNotPerformed ScriptStatus = 0x00
+	ScriptFailed       ScriptStatus = 0x10
+	ScriptSuccessful   ScriptStatus = 0x20
+)
+
+func (s ScriptStatus) String() string {
+	switch s {
+	case ScriptNotPerformed:
+		return "Script not performed"
+	case ScriptFailed:
+		return "Script processing failed"
+	case ScriptSuccessful:
+		return "Script processing successful"
+	default:
+		return "RFU"
+	}
+}
+
+// ScriptCommandResult is the outcome of one Issuer Script Command.
+type ScriptCommandResult struct {
+	Command []byte
+	Status  iso7816.StatusWord
+	Error   string // set when the command could not be sent
+}
+
+// ScriptResult is the outcome of one issuer script.
+type ScriptResult struct {
+	Script   IssuerScript
+	Status   ScriptStatus
+	Sequence int // sequence number of the failed command, 0 when none
+	Commands []ScriptCommandResult
+}
+
+// Bytes encodes the 5 bytes of the script in the Issuer Script Results.
+func (r ScriptResult) Bytes() []byte {
+	sequence := r.Sequence
+	if sequence > 0x0F {
+		sequence = 0x0F
+	}
+	out := []byte{byte(r.Status) | byte(sequence)}
+	if len(r.Script.ID) == 4 {
+		return append(out, r.Script.ID...)
+	}
+	return append(out, 0x00, 0x00, 0x00, 0x00)
+}
+
+// IssuerAuthentication is the outcome of EXTERNAL AUTHENTICATE.
+type IssuerAuthentication struct {
+	Data       []byte // Issuer Authentication Data ('91')
+	Status     iso7816.StatusWord
+	Successful bool
+}
+
+// IssuerProcessing performs issuer authentication and issuer script processing for one
+// transaction, and accumulates the results of both script phases.
+type IssuerProcessing struct {
+	Client  *iso7816.Client
+	Scripts []IssuerScript
+
+	// Authentication is nil when EXTERNAL AUTHENTICATE was not sent.
+	Authentication *IssuerAuthentication
+	Results        []ScriptResult
+
+	// Trace keeps every exchange.
+	Trace iso7816.Trace
+}
+
+// Authenticate sends the Issuer Authentication Data to the card when the AIP supports
+// issuer authentication, and sets the related TVR and TSI bits.
+func (p *IssuerProcessing) Authenticate(aip AIP, data []byte, tvr *TVR, tsi *TSI) error {
+	if !aip.SupportsIssuerAuthentication() || len(data) == 0 {
+		return nil
+	}
+	if len(data) < 8 || len(data) > 16 {
+		return fmt.Errorf("Issuer Authentication Data must be 8 to 16 bytes long (got %d)", len(data))
+	}
+
+	trace, err := p.Client.Send(ExternalAuthenticate(data))
+	p.Trace = append(p.Trace, trace...)
+	if err != nil {
+		return fmt.Errorf("EXTERNAL AUTHENTICATE: %w", err)
+	}
+
+	sw := trace.Last().Response.Status
+	p.Authentication = &IssuerAuthentication{Data: data, Status: sw, Successful: sw == iso7816.SW_NO_ERROR}
+	tsi.Set(TSIIssuerAuthenticationPerformed)
+	if !p.Authentication.Successful {
+		tvr.Set(TVRIssuerAuthenticationFailed)
+	}
+	return nil
+}
+
+// RunScripts executes the scripts of the given template ('71' before the final GENERATE AC,
+// '72' after it) and sets the related TVR and TSI bits.
+func (p *IssuerProcessing) RunScripts(template byte, tvr *TVR, tsi *TSI) error {
+	failed := TVRScriptFailedBeforeFinalGenerateAC
+	if template == ScriptAfterFinalGenerateAC {
+		failed = TVRScriptFailedAfterFinalGenerateAC
+	}
+
+	for _, script := range p.Scripts {
+		if script.Template != template {
+			continue
+		}
+		result, err := p.runScript(script)
+		p.Results = append(p.Results, result)
+		if result.Status != ScriptNotPerformed {
+			tsi.Set(TSIScriptProcessingPerformed)
+		}
+		if result.Status == ScriptFailed {
+			tvr.Set(failed)
+		}
+		if err != nil {
+			return fmt.Errorf("Issuer Script %X #%d: %w", template, len(p.Results), err)
+		}
+	}
+	return nil
+}
+
+// runScript sends the commands of a script until the first error.
+func (p *IssuerProcessing) runScript(script IssuerScript) (ScriptResult, error) {
+	r := ScriptResult{Script: script, Status: ScriptNotPerformed}
+	if len(script.Commands) == 0 {
+		return r, nil
+	}
+
+	r.Status = ScriptSuccessful
+	for i, raw := range script.Commands {
+		c := ScriptCommandResult{Command: raw}
+		cmd, err := iso7816.ParseCommandAPDU(raw)
+		if err != nil {
+			c.Error = err.Error()
+			r.fail(i, c)
+			return r, nil
+		}
+
+		trace, err := p.Client.Send(cmd)
+		p.Trace = append(p.Trace, trace...)
+		if err != nil {
+			c.Error = err.Error()
+			r.fail(i, c)
+			return r, err
+		}
+
+		c.Status = trace.Last().Response.Status
+		if !scriptContinues(c.Status) {
+			r.fail(i, c)
+			return r, nil
+		}
+		r.Commands = append(r.Commands, c)
+	}
+	return r, nil
+}
+
+// scriptContinues reports whether a script goes on after a command: SW1 is '90', '62' or
+// '63' (EMV Book 3, section 10.10). The status is the last one of the exchange, after
+// GET RESPONSE when the card answered '61XX'.
+func scriptContinues(status iso7816.StatusWord) bool {
+	switch status.SW1() {
+	case 0x90, 0x62, 0x63:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *ScriptResult) fail(index int, c ScriptCommandResult) {
+	r.Status = ScriptFailed
+	r.Sequence = index + 1
+	r.Commands = append(r.Commands, c)
+}
+
+// ScriptResults builds the Issuer Script Results ('9F5B') of the scripts run so far.
+func (p *IssuerProcessing) ScriptResults() []byte {
+	var out []byte
+	for _, r := range p.Results {
+		out = append(out, r.Bytes()...)
+	}
+	return out
+}
+
+// Report builds the structured report of issuer authentication and script processing.
+func (p *IssuerProcessing) Report() *report.Report {
+	rep := report.New("EMV ISSUER AUTHENTICATION AND SCRIPT PROCESSING")
+
+	auth := rep.AddSection("[1] Issuer Authentication:")
+	if p.Authentication == nil {
+		auth.Note("", "Not performed")
+	} else {
+		auth.Note("Issuer Authentication Data (91)", fmt.Sprintf("%X", p.Authentication.Data))
+		auth.Note("EXTERNAL AUTHENTICATE", p.Authentication.Status.Verbose())
+	}
+
+	for i, r := range p.Results {
+		id := "no identifier"
+		if r.Script.ID != nil {
+			id = fmt.Sprintf("9F18 %X", r.Script.ID)
+		}
+		section := rep.AddSection(fmt.Sprintf("[%d] Script %X (%s): %s", i+2, r.Script.Template, id, r.Status))
+		for n, c := range r.Commands {
+			outcome := fmt.Sprintf("%04X", uint16(c.Status))
+			if c.Error != "" {
+				outcome = c.Error
+			}
+			section.Note(fmt.Sprintf("Command %d", n+1), fmt.Sprintf("%X: %s", c.Command, outcome))
+		}
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	if p.Authentication != nil {
+		result := "Successful"
+		if !p.Authentication.Successful {
+			result = "Failed"
+		}
+		outcome.Note("Issuer Authentication", result)
+	}
+	if len(p.Results) > 0 {
+		outcome.Note("Issuer Script Results (9F5B)", fmt.Sprintf("%X", p.ScriptResults()))
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of issuer authentication and script processing.
+func (p *IssuerProcessing) Describe() string {
+	return report.Text(p.Report())
+}
@@ -0,0 +1,245 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const (
+	testUnblock = "8418000008 0102030405060708"
+	testPutData = "84DA9F5805 01020304AA"
+	testBlock   = "841E000008 1122334455667788"
+)
+
+// issuerResponse holds two '71' scripts, the second one failing on its second command,
+// and one '72' script without identifier.
+func issuerResponse() []byte {
+	return tlv.Hex(
+		tlvHex("91", "1122334455667788", "3030"),
+		tlvHex("71", tlvHex("9F18", "11223344"), tlvHex("86", testUnblock), tlvHex("86", testPutData)),
+		tlvHex("71", tlvHex("9F18", "55667788"), tlvHex("86", testUnblock), tlvHex("86", testBlock), tlvHex("86", testPutData)),
+		tlvHex("72", tlvHex("86", testBlock)),
+	)
+}
+
+func scriptCard() *mockCard {
+	return testCard{
+		Responses: map[string]string{
+			testUnblock: "9000",
+			testPutData: "6283",
+		},
+		Sequences: map[string][]string{
+			testBlock: {"6985", "9000"},
+		},
+	}.mock()
+}
+
+func TestParseIssuerScripts(t *testing.T) {
+	scripts, err := ParseIssuerScripts(issuerResponse())
+	if err != nil {
+		t.Fatalf("ParseIssuerScripts failed: %v", err)
+	}
+
+	expected := []IssuerScript{
+		{Template: 0x71, ID: tlv.Hex("11223344"), Commands: [][]byte{tlv.Hex(testUnblock), tlv.Hex(testPutData)}},
+		{Template: 0x71, ID: tlv.Hex("55667788"), Commands: [][]byte{tlv.Hex(testUnblock), tlv.Hex(testBlock), tlv.Hex(testPutData)}},
+		{Template: 0x72, Commands: [][]byte{tlv.Hex(testBlock)}},
+	}
+	if diff := cmp.Diff(expected, scripts); diff != "" {
+		t.Errorf("Scripts mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, data := range []string{"7105 9F1802 1122", "71 05 86"} {
+		if _, err := ParseIssuerScripts(tlv.Hex(data)); err == nil {
+			t.Errorf("ParseIssuerScripts(%s) should fail", data)
+		}
+	}
+}
+
+func TestIssuerAuthentication(t *testing.T) {
+	supported := AIP{0x04, 0x00}
+	tests := []struct {
+		name     string
+		aip      AIP
+		data     string
+		response string
+		tvr      TVR
+		tsi      TSI
+		sent     int
+	}{
+		{"Successful", supported, "1122334455667788", "9000", TVR{}, TSI{0x10, 0x00}, 1},
+		{"Failed", supported, "1122334455667788", "6300", TVR{0x00, 0x00, 0x00, 0x00, 0x40}, TSI{0x10, 0x00}, 1},
+		{"Not Supported", AIP{}, "1122334455667788", "9000", TVR{}, TSI{}, 0},
+		{"No Data", supported, "", "9000", TVR{}, TSI{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &mockCard{responses: map[string]string{"00820000081122334455667788": tt.response}}
+			p := &IssuerProcessing{Client: iso7816.NewClient(card)}
+			var tvr TVR
+			var tsi TSI
+			if err := p.Authenticate(tt.aip, tlv.Hex(tt.data), &tvr, &tsi); err != nil {
+				t.Fatalf("Authenticate failed: %v", err)
+			}
+			if tvr != tt.tvr || tsi != tt.tsi {
+				t.Errorf("TVR %X, TSI %X, want %X, %X", tvr[:], tsi[:], tt.tvr[:], tt.tsi[:])
+			}
+			if len(card.sent) != tt.sent {
+				t.Errorf("%d commands sent, want %d", len(card.sent), tt.sent)
+			}
+		})
+	}
+
+	p := &IssuerProcessing{Client: iso7816.NewClient(&mockCard{})}
+	if err := p.Authenticate(supported, tlv.Hex("11223344"), &TVR{}, &TSI{}); err == nil {
+		t.Error("Authenticate should reject 4 bytes of Issuer Authentication Data")
+	}
+}
+
+func TestRunIssuerScripts(t *testing.T) {
+	scripts, err := ParseIssuerScripts(issuerResponse())
+	if err != nil {
+		t.Fatalf("ParseIssuerScripts failed: %v", err)
+	}
+	card := scriptCard()
+	p := &IssuerProcessing{Client: iso7816.NewClient(card), Scripts: scripts}
+	var tvr TVR
+	var tsi TSI
+
+	if err := p.RunScripts(ScriptBeforeFinalGenerateAC, &tvr, &tsi); err != nil {
+		t.Fatalf("RunScripts(71) failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("20 11223344 12 55667788"), p.ScriptResults()); diff != "" {
+		t.Errorf("Issuer Script Results before GENERATE AC mismatch (-want +got):\n%s", diff)
+	}
+	if len(card.sent) != 4 {
+		t.Errorf("%d commands sent, the failing script must stop after its second command", len(card.sent))
+	}
+
+	if err := p.RunScripts(ScriptAfterFinalGenerateAC, &tvr, &tsi); err != nil {
+		t.Fatalf("RunScripts(72) failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("20 11223344 12 55667788 20 00000000"), p.ScriptResults()); diff != "" {
+		t.Errorf("Issuer Script Results mismatch (-want +got):\n%s", diff)
+	}
+	if tvr != (TVR{0x00, 0x00, 0x00, 0x00, 0x20}) || tsi != (TSI{0x04, 0x00}) {
+		t.Errorf("TVR %X, TSI %X", tvr[:], tsi[:])
+	}
+	if len(p.Trace) != 5 {
+		t.Errorf("Trace has %d exchanges, want 5", len(p.Trace))
+	}
+}
+
+func TestRunIssuerScriptsStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string // Response to the script command, then to GET RESPONSE
+		expected  string
+	}{
+		{name: "Normal processing", responses: []string{"9000"}, expected: "20 00000000"},
+		{name: "Other 90XX", responses: []string{"9001"}, expected: "20 00000000"},
+		{name: "Warning 62XX", responses: []string{"6283"}, expected: "20 00000000"},
+		{name: "Warning 63XX", responses: []string{"63C2"}, expected: "20 00000000"},
+		{name: "61XX then 9000", responses: []string{"6104", "01020304 9000"}, expected: "20 00000000"},
+		{name: "61XX then error", responses: []string{"6104", "6A82"}, expected: "11 00000000"},
+		{name: "Error", responses: []string{"6985"}, expected: "11 00000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := testCard{Responses: map[string]string{testUnblock: tt.responses[0]}}
+			if len(tt.responses) > 1 {
+				card.Responses["84C0000004"] = tt.responses[1]
+			}
+			p := &IssuerProcessing{
+				Client:  iso7816.NewClient(card.mock()),
+				Scripts: []IssuerScript{{Template: 0x72, Commands: [][]byte{tlv.Hex(testUnblock)}}},
+			}
+			var tvr TVR
+			var tsi TSI
+			if err := p.RunScripts(ScriptAfterFinalGenerateAC, &tvr, &tsi); err != nil {
+				t.Fatalf("RunScripts failed: %v", err)
+			}
+			if diff := cmp.Diff(tlv.Hex(tt.expected), p.ScriptResults()); diff != "" {
+				t.Errorf("Issuer Script Results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScriptResultBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   ScriptResult
+		expected string
+	}{
+		{"Not Performed", ScriptResult{Script: IssuerScript{ID: tlv.Hex("01020304")}}, "00 01020304"},
+		{"Successful", ScriptResult{Status: ScriptSuccessful}, "20 00000000"},
+		{"Failed", ScriptResult{Status: ScriptFailed, Sequence: 3}, "13 00000000"},
+		{"Failed After 15 Commands", ScriptResult{Status: ScriptFailed, Sequence: 20}, "1F 00000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tlv.Hex(tt.expected), tt.result.Bytes()); diff != "" {
+				t.Errorf("Bytes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunIssuerScriptsMalformedCommand(t *testing.T) {
+	card := &mockCard{}
+	p := &IssuerProcessing{
+		Client:  iso7816.NewClient(card),
+		Scripts: []IssuerScript{{Template: 0x72, Commands: [][]byte{tlv.Hex("8418")}}, {Template: 0x72}},
+	}
+	var tvr TVR
+	var tsi TSI
+	if err := p.RunScripts(ScriptAfterFinalGenerateAC, &tvr, &tsi); err != nil {
+		t.Fatalf("RunScripts failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("11 00000000 00 00000000"), p.ScriptResults()); diff != "" {
+		t.Errorf("Issuer Script Results mismatch (-want +got):\n%s", diff)
+	}
+	if tvr != (TVR{0x00, 0x00, 0x00, 0x00, 0x10}) || len(card.sent) != 0 {
+		t.Errorf("TVR %X, %d commands sent", tvr[:], len(card.sent))
+	}
+}
+
+func TestIssuerProcessingDescribe(t *testing.T) {
+	scripts, err := ParseIssuerScripts(issuerResponse())
+	if err != nil {
+		t.Fatalf("ParseIssuerScripts failed: %v", err)
+	}
+	card := &mockCard{responses: map[string]string{"00820000081122334455667788": "9000"}}
+	p := &IssuerProcessing{Client: iso7816.NewClient(card), Scripts: scripts[2:]}
+	var tvr TVR
+	var tsi TSI
+	if err := p.Authenticate(AIP{0x04, 0x00}, tlv.Hex("1122334455667788"), &tvr, &tsi); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if err := p.RunScripts(ScriptAfterFinalGenerateAC, &tvr, &tsi); err != nil {
+		t.Fatalf("RunScripts failed: %v", err)
+	}
+
+	expected := []string{
+		"=== EMV ISSUER AUTHENTICATION AND SCRIPT PROCESSING ===",
+		"Issuer Authentication Data (91): 1122334455667788",
+		"[2] Script 72 (no identifier): Script processing failed",
+		"Command 1: 841E0000081122334455667788: 6A82",
+		"Issuer Authentication: Successful",
+		"Issuer Script Results (9F5B): 1100000000",
+	}
+	desc := p.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
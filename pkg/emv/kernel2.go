@@ -0,0 +1,706 @@
+package emv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// CONTACTLESS KERNEL 2 (EMV MODE) Logic according to EMV Book C-2.
+// Entry Point hands over a Combination (AID and Kernel ID '02'). Kernel 2 then runs:
+//
+//  1. Final SELECT of the ADF Name (and Extended Selection), then GET PROCESSING OPTIONS
+//     with the PDOL data. A card answering '6985' sends the terminal to the next candidate.
+//     The card must announce EMV mode in the AIP ('82', byte 2 bit 8): mag-stripe mode is
+//     not supported.
+//  2. READ RECORD of the records of the AFL.
+//  3. Data exchange: the card data listed in Tags To Read ('DF8112') is sent to the
+//     terminal (Data To Send, 'FF8104'), with the tags that the kernel still needs (Data
+//     Needed, 'DF8106'). The terminal answers with data objects that update the terminal
+//     data or the Kernel 2 configuration.
+//  4. Terminal Capabilities ('9F33') from the configuration: byte 2 is the CVM Capability -
+//     CVM Required ('DF8118') above the Reader CVM Required Limit ('DF8126') and the CVM
+//     Capability - No CVM Required ('DF8119') otherwise.
+//  5. Risk data: above the Reader Contactless Transaction Limit ('DF8124', or 'DF8125' when
+//     on-device cardholder verification is supported by the card and the kernel) the
+//     transaction cannot be performed; above the Reader Contactless Floor Limit ('DF8123')
+//     the TVR requests an online authorisation.
+//  6. Processing restrictions and CVM selection. With on-device cardholder verification,
+//     the CVM Results are '01 00 02' above the CVM Required Limit (CDCVM) and '3F 00 02'
+//     otherwise. Without it, the CVM List is processed.
+//  7. CDA is the only offline data authentication method of Kernel 2. It is requested with
+//     GENERATE AC when supported by the card and the Security Capability ('DF811F').
+//     With Integrated Data Storage (IDS), the card also signs DS Summary 2 and DS Summary 3
+//     after the Transaction Data Hash Code: DS Summary 2 must match the DS Summary 1
+//     ('9F7D') read from the card, and differs from DS Summary 3 when the card wrote the
+//     data storage.
+//  8. Terminal action analysis and a single GENERATE AC. A torn GENERATE AC is recovered
+//     with RECOVER AC during the next transaction with the card (see TornTransactionLog).
+//  9. Outcome: Approved (TC), Online Request (ARQC) or Declined (AAC).
+
+// Outcomes of contactless kernels (EMV Book A, Outcome Parameter Set).
+const (
+	OutcomeOnlineRequest       TransactionOutcome = "Online Request"
+	OutcomeTryAnotherInterface TransactionOutcome = "Try Another Interface"
+	OutcomeSelectNext          TransactionOutcome = "Select Next"
+	OutcomeTryAgain            TransactionOutcome = "Try Again"
+	OutcomeEndApplication      TransactionOutcome = "End Application"
+)
+
+// OutcomeCVM is the cardholder verification requested by the outcome of a contactless kernel.
+type OutcomeCVM string
+
+const (
+	OutcomeNoCVM               OutcomeCVM = "No CVM"
+	OutcomeCVMOnlinePIN        OutcomeCVM = "Online PIN"
+	OutcomeCVMSignature        OutcomeCVM = "Obtain Signature"
+	OutcomeCVMConfirmationCode OutcomeCVM = "Confirmation Code Verified"
+)
+
+// kernel2CDCVM is the CVM code of the CVM Results when the cardholder was verified on the
+// device (CDCVM): Kernel 2 reuses the code of plaintext PIN ('01').
+const kernel2CDCVM = CVMPlaintextPIN
+
+// DataExchanger exchanges data with the terminal during a transaction.
+type DataExchanger interface {
+	// Exchange receives the Data To Send ('FF8104') and the Data Needed ('DF8106'), and
+	// returns the data objects that update the terminal data and the configuration.
+	Exchange(toSend TerminalData, needed []string) (TerminalData, error)
+}
+
+// Kernel2 processes contactless transactions with Mastercard cards in EMV mode.
+type Kernel2 struct {
+	Client *iso7816.Client
+	Config Kernel2Config
+
+	// Exchange is the data exchange with the terminal. Nil disables it.
+	Exchange DataExchanger
+
+	// Torn is the Torn Transaction Log, kept by the terminal between transactions.
+	// Nil disables the recovery of torn transactions.
+	Torn *TornTransactionLog
+
+	// Random provides the Unpredictable Number. Nil uses crypto/rand.
+	Random io.Reader
+
+	// Now is the date and time of the transaction. Zero means time.Now().
+	Now time.Time
+}
+
+// IDSStatus is the outcome of the Integrated Data Storage checks.
+type IDSStatus struct {
+	Summary1 []byte // '9F7D', read from the card
+	Summary2 []byte // 'DF8101', signed before the update of the data storage
+	Summary3 []byte // 'DF8102', signed after the update of the data storage
+
+	// Read reports that DS Summary 2 matches DS Summary 1.
+	Read bool
+	// Written reports that the card updated the data storage.
+	Written bool
+}
+
+// Kernel2Result is the outcome of every step of a Kernel 2 transaction. The steps that
+// were not reached are nil.
+type Kernel2Result struct {
+	Amount      uint64
+	AmountOther uint64
+	Type        TransactionType
+	Candidate   CombinationCandidate
+
+	ProcessingOptions *ProcessingOptions
+	Data              *ApplicationData
+	Restrictions      *RestrictionsResult
+	CVM               *CVMEvaluation // Only when the CVM List was processed
+	ODA               *AuthenticationResult
+	IDS               *IDSStatus
+	Action            *ActionDecision
+	Cryptogram        *Cryptogram
+
+	// Recovered reports that the cryptogram was recovered from a torn transaction.
+	Recovered bool
+	// Removed lists the torn records removed from the log, expired or in excess.
+	Removed []TornRecord
+
+	// Terminal holds the terminal data of the transaction, including the Terminal
+	// Capabilities ('9F33'), the TVR ('95') and the CVM Results ('9F34').
+	Terminal TerminalData
+
+	TVR        TVR
+	CVMResults CVMResults
+	Outcome    TransactionOutcome
+	CVMOutcome OutcomeCVM
+	Steps      []string
+
+	// Trace keeps every exchange of the transaction, in order.
+	Trace iso7816.Trace
+}
+
+func (r *Kernel2Result) step(format string, args ...interface{}) {
+	r.Steps = append(r.Steps, fmt.Sprintf(format, args...))
+}
+
+// end sets the outcome of the transaction.
+func (r *Kernel2Result) end(outcome TransactionOutcome, format string, args ...interface{}) {
+	r.Outcome = outcome
+	r.step(format, args...)
+}
+
+// kernel2Run holds the state of one transaction.
+type kernel2Run struct {
+	k      *Kernel2
+	r      *Kernel2Result
+	config Kernel2Config
+
+	pdolData []byte
+	cda      bool
+}
+
+// Lookup implements DataSource: the terminal data first, then the card data.
+func (run *kernel2Run) Lookup(tag string) ([]byte, bool) {
+	run.r.Terminal["95"] = append([]byte{}, run.r.TVR[:]...)
+	if value, ok := run.r.Terminal.Lookup(tag); ok {
+		return value, true
+	}
+	if run.r.Data != nil {
+		return run.r.Data.Lookup(tag)
+	}
+	return nil, false
+}
+
+func (run *kernel2Run) send(cmd *iso7816.CommandAPDU) (iso7816.Trace, error) {
+	trace, err := run.k.Client.Send(cmd)
+	run.r.Trace = append(run.r.Trace, trace...)
+	return trace, err
+}
+
+func (k *Kernel2) now() time.Time {
+	if k.Now.IsZero() {
+		return time.Now()
+	}
+	return k.Now
+}
+
+// Run performs a transaction with a candidate of Entry Point. The result of the steps
+// performed so far is returned with the error when the transaction is terminated.
+func (k *Kernel2) Run(candidate CombinationCandidate, amount, amountOther uint64, txType TransactionType) (*Kernel2Result, error) {
+	run := &kernel2Run{
+		k:      k,
+		config: k.Config.clone(),
+		r: &Kernel2Result{
+			Amount:      amount,
+			AmountOther: amountOther,
+			Type:        txType,
+			Candidate:   candidate,
+			Terminal:    TerminalData{},
+		},
+	}
+	if err := run.initTerminalData(); err != nil {
+		return run.r, err
+	}
+
+	for _, phase := range []func() error{
+		run.initiate,
+		run.readRecords,
+		run.exchangeData,
+		run.checkLimits,
+		run.checkRestrictions,
+		run.verifyCardholder,
+		run.generateAC,
+	} {
+		if err := phase(); err != nil {
+			run.r.end(OutcomeEndApplication, "Transaction terminated: %v", err)
+			return run.r, err
+		}
+		if run.r.Outcome != "" {
+			break
+		}
+	}
+	return run.r, nil
+}
+
+// initTerminalData prepares the terminal data of the transaction.
+func (run *kernel2Run) initTerminalData() error {
+	for tag, value := range run.config.Data {
+		run.r.Terminal[tag] = value
+	}
+
+	un := make([]byte, 4)
+	random := run.k.Random
+	if random == nil {
+		random = rand.Reader
+	}
+	if _, err := io.ReadFull(random, un); err != nil {
+		return fmt.Errorf("Unpredictable Number: %w", err)
+	}
+
+	now := run.k.now()
+	run.r.Terminal["9F02"] = numericBytes(run.r.Amount, 6)
+	run.r.Terminal["9F03"] = numericBytes(run.r.AmountOther, 6)
+	run.r.Terminal["9C"] = []byte{byte(run.r.Type)}
+	run.r.Terminal["9A"], _ = hex.DecodeString(now.Format("060102"))
+	run.r.Terminal["9F21"], _ = hex.DecodeString(now.Format("150405"))
+	run.r.Terminal["9F37"] = un
+	run.setCapabilities()
+	return nil
+}
+
+// setCapabilities sets the Terminal Capabilities of the transaction.
+func (run *kernel2Run) setCapabilities() {
+	caps := run.config.TerminalCapabilities(run.r.Amount)
+	run.r.Terminal["9F33"] = caps[:]
+}
+
+// initiate performs the final selection and GET PROCESSING OPTIONS.
+func (run *kernel2Run) initiate() error {
+	trace, err := run.send(iso7816.SelectByAID(iso7816.Class{}, run.r.Candidate.SelectionName()))
+	if err != nil {
+		return fmt.Errorf("final selection: %w", err)
+	}
+	fci, parseErr := ParseFCI(trace.Last().Response.Data)
+	if !trace.IsSuccess() || parseErr != nil {
+		run.r.end(OutcomeSelectNext, "Final selection of %X failed", run.r.Candidate.ADFName)
+		return nil
+	}
+
+	pdol, err := fci.ProprietaryTemplate.ParsePDOL()
+	if err != nil {
+		return err
+	}
+	run.pdolData, _ = pdol.Build(run)
+	trace, err = run.send(GetProcessingOptions(run.pdolData))
+	if err != nil {
+		return fmt.Errorf("GET PROCESSING OPTIONS: %w", err)
+	}
+	status := trace.Last().Response.Status
+	if status == iso7816.SW_ERR_COND_OF_USE_NOT_SAT {
+		run.r.end(OutcomeSelectNext, "Conditions of use not satisfied")
+		return nil
+	}
+	if !status.IsSuccess() {
+		return fmt.Errorf("GET PROCESSING OPTIONS failed with status: %s", status.Verbose())
+	}
+
+	po, err := ParseProcessingOptions(trace.Last().Response.Data)
+	if err != nil {
+		return fmt.Errorf("GET PROCESSING OPTIONS: %w", err)
+	}
+	run.r.ProcessingOptions = po
+	if !po.AIP.SupportsEMVMode() || run.config.KernelConfiguration&Kernel2EMVModeNotSupported != 0 {
+		run.r.end(OutcomeTryAnotherInterface, "EMV mode not available, mag-stripe mode is not supported")
+		return nil
+	}
+	run.r.step("Application %X initiated in EMV mode", run.r.Candidate.ADFName)
+	return nil
+}
+
+// readRecords reads the records of the AFL.
+func (run *kernel2Run) readRecords() error {
+	po := run.r.ProcessingOptions
+	data, err := ReadApplicationData(run.k.Client, iso7816.Class{}, po.AFL)
+	run.r.Data = data
+	if data != nil {
+		run.r.Trace = append(run.r.Trace, data.Trace...)
+	}
+	if err != nil {
+		return fmt.Errorf("read application data: %w", err)
+	}
+	data.Set("82", append([]byte{}, po.AIP[:]...))
+	data.Set("84", run.r.Candidate.ADFName)
+	run.r.step("%d records read", len(data.Records))
+	return nil
+}
+
+// exchangeData sends the Tags To Read and the Data Needed to the terminal, and applies
+// the data objects of its answer.
+func (run *kernel2Run) exchangeData() error {
+	if run.k.Exchange == nil {
+		return nil
+	}
+
+	toSend := TerminalData{}
+	for _, tag := range run.config.TagsToRead {
+		if value, ok := run.r.Data.Lookup(tag); ok {
+			toSend[tag] = value
+		}
+	}
+	var needed []string
+	if cdol1, err := run.cdol1(); err == nil {
+		_, needed = cdol1.Build(run)
+	}
+
+	update, err := run.k.Exchange.Exchange(toSend, needed)
+	if err != nil {
+		return fmt.Errorf("data exchange: %w", err)
+	}
+	for tag, value := range update {
+		if err := run.config.Set(tag, value); err != nil {
+			return fmt.Errorf("data exchange: %w", err)
+		}
+		if _, ok := kernel2Settings[tag]; !ok {
+			run.r.Terminal[tag] = value
+		}
+	}
+	run.setCapabilities()
+	run.r.step("Data exchange: %d data objects sent, %d needed, %d received", len(toSend), len(needed), len(update))
+	return nil
+}
+
+// checkLimits compares the amount with the contactless limits.
+func (run *kernel2Run) checkLimits() error {
+	limit := run.config.TransactionLimit(run.r.ProcessingOptions.AIP)
+	if run.r.Amount > limit {
+		run.r.end(OutcomeSelectNext, "Amount %d exceeds the Reader Contactless Transaction Limit %d", run.r.Amount, limit)
+		return nil
+	}
+	if run.r.Amount > run.config.FloorLimit {
+		run.r.TVR.Set(TVRExceedsFloorLimit)
+		run.r.step("Amount %d exceeds the Reader Contactless Floor Limit %d", run.r.Amount, run.config.FloorLimit)
+	}
+	return nil
+}
+
+// checkRestrictions checks the application version, usage control and dates.
+func (run *kernel2Run) checkRestrictions() error {
+	restrictions, err := CheckProcessingRestrictions(run.r.Data, RestrictionsTransaction{
+		ApplicationVersion: run.r.Terminal["9F09"],
+		Country:            run.r.Terminal["9F1A"],
+		Type:               run.r.Type,
+		Date:               run.k.now(),
+		ATM:                run.config.ATM,
+		Services:           run.config.Services,
+	})
+	run.r.Restrictions = restrictions
+	if err != nil {
+		return fmt.Errorf("processing restrictions: %w", err)
+	}
+	run.r.TVR.Merge(restrictions.TVR)
+	return nil
+}
+
+// verifyCardholder selects the CVM of the transaction.
+func (run *kernel2Run) verifyCardholder() error {
+	aip := run.r.ProcessingOptions.AIP
+	switch {
+	case run.config.onDeviceCVM(aip) && run.r.Amount > run.config.CVMRequiredLimit:
+		run.setCVM(CVMResults{byte(kernel2CDCVM), 0x00, byte(CVMResultSuccessful)}, OutcomeCVMConfirmationCode)
+	case run.config.onDeviceCVM(aip):
+		run.setCVM(CVMResults{byte(CVMNotPerformed), 0x00, byte(CVMResultSuccessful)}, OutcomeNoCVM)
+	case !aip.SupportsCardholderVerification():
+		run.setCVM(CVMResults{byte(CVMNotPerformed), 0x00, byte(CVMResultUnknown)}, OutcomeNoCVM)
+	default:
+		return run.processCVMList()
+	}
+	return nil
+}
+
+// processCVMList evaluates the CVM List with the Terminal Capabilities of the transaction.
+func (run *kernel2Run) processCVMList() error {
+	var list *CVMList
+	if value, ok := run.r.Data.Lookup("8E"); ok {
+		parsed, err := ParseCVMList(value)
+		if err != nil {
+			return fmt.Errorf("invalid CVM List: %w", err)
+		}
+		list = parsed
+	}
+
+	appCurrency, _ := run.r.Data.Lookup("9F42")
+	evaluation := EvaluateCVM(list, CVMTransaction{
+		Capabilities:        run.config.TerminalCapabilities(run.r.Amount),
+		Type:                run.r.Type,
+		Amount:              run.r.Amount,
+		Currency:            run.r.Terminal["5F2A"],
+		ApplicationCurrency: appCurrency,
+	})
+	run.r.CVM = evaluation
+	run.r.TVR.Merge(evaluation.TVR)
+
+	cvm := OutcomeNoCVM
+	switch CVMethod(evaluation.Results[0] & 0x3F) {
+	case CVMOnlinePIN:
+		cvm = OutcomeCVMOnlinePIN
+	case CVMSignature:
+		cvm = OutcomeCVMSignature
+	}
+	run.setCVM(evaluation.Results, cvm)
+	return nil
+}
+
+func (run *kernel2Run) setCVM(results CVMResults, cvm OutcomeCVM) {
+	run.r.CVMResults = results
+	run.r.CVMOutcome = cvm
+	run.r.Terminal["9F34"] = append([]byte{}, results[:]...)
+	run.r.step("CVM Results: %s", results)
+}
+
+func (run *kernel2Run) cdol1() (DOL, error) {
+	value, ok := run.r.Data.Lookup("8C")
+	if !ok {
+		return nil, fmt.Errorf("missing %s", tagLabel("8C"))
+	}
+	cdol, err := ParseDOL(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", tagLabel("8C"), err)
+	}
+	return cdol, nil
+}
+
+// generateAC performs terminal action analysis, then recovers the cryptogram of a torn
+// transaction or sends GENERATE AC.
+func (run *kernel2Run) generateAC() error {
+	aip := run.r.ProcessingOptions.AIP
+	run.cda = aip.SupportsCDA() && run.config.SecurityCapability&kernel2SecurityCDA != 0
+	if !run.cda {
+		run.r.TVR.Set(TVROfflineDataAuthenticationNotPerformed)
+	}
+
+	iac, err := IssuerActionCodes(run.r.Data)
+	if err != nil {
+		return fmt.Errorf("terminal action analysis: %w", err)
+	}
+	run.r.Action = ActionAnalysis{
+		TVR:           run.r.TVR,
+		Issuer:        iac,
+		Terminal:      run.config.ActionCodes,
+		OnlineCapable: true,
+	}.Decide()
+
+	recovered, err := run.recover()
+	if err != nil || recovered {
+		return err
+	}
+
+	cdol1, err := run.cdol1()
+	if err != nil {
+		return err
+	}
+	requested := run.r.Action.Cryptogram
+	cda := run.cda && requested != CryptogramAAC
+	cmd, _ := GenerateACWithCDOL(requested, cda, cdol1, run)
+	trace, err := run.send(cmd)
+	if err != nil {
+		return run.torn(cmd.Data, cda, err)
+	}
+	if !trace.IsSuccess() {
+		return fmt.Errorf("GENERATE AC failed with status: %s", trace.Last().Response.Status.Verbose())
+	}
+	run.r.step("GENERATE AC: %s requested", requested)
+	return run.complete(trace.Last().Response.Data, cda, run.pdolData, cmd.Data, run.r.Terminal["9F37"])
+}
+
+// cardIdentity returns the PAN and PAN Sequence Number of the card.
+func (run *kernel2Run) cardIdentity() ([]byte, []byte) {
+	pan, _ := run.r.Data.Lookup("5A")
+	psn, _ := run.r.Data.Lookup("5F34")
+	return pan, psn
+}
+
+// recover sends RECOVER AC when the card has a torn transaction in the log.
+func (run *kernel2Run) recover() (bool, error) {
+	drdol, ok := run.r.Data.Lookup("9F51")
+	if run.k.Torn == nil || !ok {
+		return false, nil
+	}
+	run.r.Removed = append(run.r.Removed, run.k.Torn.expire(run.k.now(), run.config.MaxTornLifetime)...)
+
+	pan, psn := run.cardIdentity()
+	i, found := run.k.Torn.find(pan, psn)
+	if !found {
+		return false, nil
+	}
+	record := run.k.Torn.Records[i]
+	dol, err := ParseDOL(drdol)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", tagLabel("9F51"), err)
+	}
+
+	data, _ := dol.Build(sources{record.Terminal, run.r.Data})
+	trace, err := run.send(RecoverAC(data))
+	if err != nil {
+		return false, fmt.Errorf("RECOVER AC: %w", err)
+	}
+	if !trace.IsSuccess() {
+		run.r.step("RECOVER AC failed with status %s, GENERATE AC follows", trace.Last().Response.Status.Verbose())
+		return false, nil
+	}
+
+	run.k.Torn.remove(i)
+	run.r.Recovered = true
+	run.r.step("Torn transaction of %s recovered with RECOVER AC", record.Time.Format(time.RFC3339))
+	return true, run.complete(trace.Last().Response.Data, record.CDA, record.PDOLData, record.CDOL1Data, record.Terminal["9F37"])
+}
+
+// torn records a GENERATE AC that got no response.
+func (run *kernel2Run) torn(cdol1Data []byte, cda bool, err error) error {
+	_, hasDRDOL := run.r.Data.Lookup("9F51")
+	if run.k.Torn == nil || run.config.MaxTornRecords == 0 || !hasDRDOL {
+		return fmt.Errorf("GENERATE AC: %w", err)
+	}
+
+	pan, psn := run.cardIdentity()
+	terminal := TerminalData{}
+	for tag, value := range run.r.Terminal {
+		terminal[tag] = value
+	}
+	removed := run.k.Torn.add(TornRecord{
+		PAN:         pan,
+		PANSequence: psn,
+		Time:        run.k.now(),
+		Terminal:    terminal,
+		PDOLData:    run.pdolData,
+		CDOL1Data:   cdol1Data,
+		CDA:         cda,
+	}, run.config.MaxTornRecords)
+	run.r.Removed = append(run.r.Removed, removed...)
+	run.r.end(OutcomeTryAgain, "GENERATE AC torn (%v), transaction recorded in the Torn Transaction Log", err)
+	return nil
+}
+
+// complete verifies the response of GENERATE AC (or RECOVER AC) and sets the outcome.
+func (run *kernel2Run) complete(response []byte, cda bool, pdolData, cdol1Data, un []byte) error {
+	ac, err := ParseCryptogram(response)
+	if err != nil {
+		return fmt.Errorf("GENERATE AC: %w", err)
+	}
+	run.r.Cryptogram = ac
+
+	if cda && ac.CID.Type() != CryptogramAAC && !run.verifyCDA(response, pdolData, cdol1Data, un) {
+		return nil
+	}
+
+	switch ac.CID.Type() {
+	case CryptogramTC:
+		run.r.end(OutcomeApproved, "Approved offline")
+	case CryptogramARQC:
+		run.r.end(OutcomeOnlineRequest, "Online authorisation requested")
+	default:
+		run.r.end(OutcomeDeclined, "Declined offline")
+	}
+	return nil
+}
+
+// verifyCDA verifies the signature of the response and the IDS summaries. It ends the
+// transaction and returns false when a check fails.
+func (run *kernel2Run) verifyCDA(response, pdolData, cdol1Data, un []byte) bool {
+	result := VerifyCDA(ODAInput{
+		AID:    run.r.Candidate.ADFName,
+		AIP:    run.r.ProcessingOptions.AIP,
+		Data:   run.r.Data,
+		CAKeys: run.config.CAKeys,
+		Now:    run.k.now(),
+	}, CDAInput{PDOLData: pdolData, CDOLData: cdol1Data, UnpredictableNumber: un, Response: response})
+	run.r.ODA = result
+
+	if result.DataMissing {
+		run.r.TVR.Set(TVRICCDataMissing)
+	}
+	if !result.Passed() {
+		run.r.TVR.Set(TVRCDAFailed)
+		run.r.end(OutcomeDeclined, "CDA failed")
+		return false
+	}
+	run.r.step("CDA successful")
+
+	if ids := run.checkIDS(result.DynamicData); ids != nil && !ids.Read {
+		run.r.end(OutcomeEndApplication, "IDS: DS Summary 2 does not match DS Summary 1")
+		return false
+	}
+	return true
+}
+
+// checkIDS extracts the DS Summaries signed after the Transaction Data Hash Code. It
+// returns nil when IDS is not used by the card and the terminal.
+func (run *kernel2Run) checkIDS(dynamic *SignedDynamicData) *IDSStatus {
+	_, hasID := run.r.Data.Lookup("9F5E")
+	_, hasOperator := run.r.Terminal.Lookup("9F5C")
+	summary1, hasSummary := run.r.Data.Lookup("9F7D")
+	if !hasID || !hasOperator || !hasSummary {
+		return nil
+	}
+
+	ids := &IDSStatus{Summary1: summary1}
+	run.r.IDS = ids
+	dd := dynamic.ICCDynamicData
+	offset := 1 + len(dynamic.ICCDynamicNumber) + 29
+	n := len(summary1)
+	if len(dd) < offset+2*n {
+		return ids
+	}
+	ids.Summary2 = dd[offset : offset+n]
+	ids.Summary3 = dd[offset+n : offset+2*n]
+	ids.Read = bytes.Equal(ids.Summary1, ids.Summary2)
+	ids.Written = !bytes.Equal(ids.Summary2, ids.Summary3)
+	return ids
+}
+
+// sources looks a tag up in several data sources, in order.
+type sources []DataSource
+
+// Lookup implements DataSource.
+func (s sources) Lookup(tag string) ([]byte, bool) {
+	for _, src := range s {
+		if value, ok := src.Lookup(tag); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Report builds the structured report of the transaction.
+func (r *Kernel2Result) Report() *report.Report {
+	rep := report.New("EMV CONTACTLESS KERNEL 2")
+
+	tx := rep.AddSection("[1] Transaction:")
+	tx.Note("Type", r.Type.String())
+	tx.Note("Amount", fmt.Sprintf("%d", r.Amount))
+	if r.AmountOther > 0 {
+		tx.Note("Amount Other", fmt.Sprintf("%d", r.AmountOther))
+	}
+	tx.Note("Application", fmt.Sprintf("%X (%s)", r.Candidate.ADFName, r.Candidate.Label))
+	if caps, ok := r.Terminal["9F33"]; ok {
+		tx.Note("Terminal Capabilities (9F33)", fmt.Sprintf("%X", caps))
+	}
+
+	steps := rep.AddSection("[2] Steps:")
+	for _, s := range r.Steps {
+		steps.Note("", s)
+	}
+
+	if ac := r.Cryptogram; ac != nil {
+		section := rep.AddSection("[3] Cryptogram:")
+		section.Note("CID (9F27)", ac.CID.String())
+		section.Note("ATC (9F36)", fmt.Sprintf("%04X", ac.ATC))
+		if len(ac.ApplicationCryptogram) > 0 {
+			section.Note("Application Cryptogram (9F26)", fmt.Sprintf("%X", ac.ApplicationCryptogram))
+		}
+		if r.Recovered {
+			section.Note("Recovered", "RECOVER AC")
+		}
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	outcome.Note("Status", string(r.Outcome))
+	if r.CVMOutcome != "" {
+		outcome.Note("CVM", string(r.CVMOutcome))
+		outcome.Note("CVM Results (9F34)", r.CVMResults.String())
+	}
+	for _, n := range r.TVR.Names() {
+		outcome.Note(fmt.Sprintf("TVR (95) %X", r.TVR[:]), n)
+	}
+	if r.IDS != nil {
+		outcome.Note("IDS", fmt.Sprintf("read: %t, written: %t", r.IDS.Read, r.IDS.Written))
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the transaction.
+func (r *Kernel2Result) Describe() string {
+	return report.Text(r.Report())
+}
@@ -0,0 +1,255 @@
+package emv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// KERNEL 2 CONFIGURATION Logic according to EMV Book C-2, Annex A.
+// The behaviour of Kernel 2 is configured per AID with proprietary data objects of the
+// 'DF81xx' range, provided by the terminal as BER-TLV:
+//
+//	DF8117 Card Data Input Capability            byte 1 of the Terminal Capabilities
+//	DF8118 CVM Capability - CVM Required         byte 2, amount above the CVM Required Limit
+//	DF8119 CVM Capability - No CVM Required      byte 2 otherwise
+//	DF811F Security Capability                   byte 3 of the Terminal Capabilities
+//	DF811B Kernel Configuration                  e.g. on-device cardholder verification supported
+//	DF8120 / DF8121 / DF8122                     Terminal Action Codes Default / Denial / Online
+//	DF8123 Reader Contactless Floor Limit
+//	DF8124 Reader Contactless Transaction Limit (No On-device CVM)
+//	DF8125 Reader Contactless Transaction Limit (On-device CVM)
+//	DF8126 Reader CVM Required Limit
+//	DF811C Max Lifetime of Torn Transaction Log Record (seconds)
+//	DF811D Max Number of Torn Transaction Log Records
+//	DF8112 Tags To Read                          card data returned to the terminal
+//
+// Any other data object (e.g. '9F1A', '5F2A', '9F35', '9F40' or the DS Requested Operator
+// ID '9F5C') is terminal data made available to the DOLs of the card.
+
+// Kernel Configuration ('DF811B') bits.
+const (
+	Kernel2MagStripeModeNotSupported = 0x80
+	Kernel2EMVModeNotSupported       = 0x40
+	Kernel2OnDeviceCVMSupported      = 0x20
+)
+
+// Security Capability ('DF811F') bit announcing CDA, as in byte 3 of the Terminal Capabilities.
+const kernel2SecurityCDA = 0x08
+
+// Kernel2Config is the configuration of Kernel 2 for one AID.
+type Kernel2Config struct {
+	// Data holds the terminal data that is not a Kernel 2 setting.
+	Data TerminalData
+
+	CardDataInputCapability byte // 'DF8117'
+	CVMRequiredCapability   byte // 'DF8118'
+	NoCVMRequiredCapability byte // 'DF8119'
+	SecurityCapability      byte // 'DF811F'
+	KernelConfiguration     byte // 'DF811B'
+
+	ActionCodes ActionCodes // Terminal Action Codes, 'DF8120' to 'DF8122'
+
+	// Limits, in the minor unit of the currency.
+	FloorLimit         uint64 // 'DF8123'
+	NoOnDeviceCVMLimit uint64 // 'DF8124'
+	OnDeviceCVMLimit   uint64 // 'DF8125'
+	CVMRequiredLimit   uint64 // 'DF8126'
+
+	MaxTornLifetime time.Duration // 'DF811C'
+	MaxTornRecords  int           // 'DF811D'
+
+	TagsToRead []string // 'DF8112'
+
+	// Terminal context of the Application Usage Control check.
+	ATM      bool
+	Services bool // The purchases are for services rather than goods
+
+	CAKeys CAKeyStore
+}
+
+// kernel2Setting decodes the value of a Kernel 2 data object into the configuration.
+type kernel2Setting func(c *Kernel2Config, value []byte) error
+
+var kernel2Settings = map[string]kernel2Setting{
+	"DF8117": byteSetting(func(c *Kernel2Config) *byte { return &c.CardDataInputCapability }),
+	"DF8118": byteSetting(func(c *Kernel2Config) *byte { return &c.CVMRequiredCapability }),
+	"DF8119": byteSetting(func(c *Kernel2Config) *byte { return &c.NoCVMRequiredCapability }),
+	"DF811F": byteSetting(func(c *Kernel2Config) *byte { return &c.SecurityCapability }),
+	"DF811B": byteSetting(func(c *Kernel2Config) *byte { return &c.KernelConfiguration }),
+	"DF8120": actionCodeSetting(func(c *Kernel2Config) *TVR { return &c.ActionCodes.Default }),
+	"DF8121": actionCodeSetting(func(c *Kernel2Config) *TVR { return &c.ActionCodes.Denial }),
+	"DF8122": actionCodeSetting(func(c *Kernel2Config) *TVR { return &c.ActionCodes.Online }),
+	"DF8123": amountSetting(func(c *Kernel2Config) *uint64 { return &c.FloorLimit }),
+	"DF8124": amountSetting(func(c *Kernel2Config) *uint64 { return &c.NoOnDeviceCVMLimit }),
+	"DF8125": amountSetting(func(c *Kernel2Config) *uint64 { return &c.OnDeviceCVMLimit }),
+	"DF8126": amountSetting(func(c *Kernel2Config) *uint64 { return &c.CVMRequiredLimit }),
+	"DF811C": func(c *Kernel2Config, value []byte) error {
+		if len(value) != 2 {
+			return fmt.Errorf("must be 2 bytes long (got %d)", len(value))
+		}
+		c.MaxTornLifetime = time.Duration(int(value[0])<<8|int(value[1])) * time.Second
+		return nil
+	},
+	"DF811D": func(c *Kernel2Config, value []byte) error {
+		if len(value) != 1 {
+			return fmt.Errorf("must be 1 byte long (got %d)", len(value))
+		}
+		c.MaxTornRecords = int(value[0])
+		return nil
+	},
+	"DF8112": func(c *Kernel2Config, value []byte) (err error) {
+		c.TagsToRead, err = parseTagList(value)
+		return err
+	},
+}
+
+func byteSetting(field func(c *Kernel2Config) *byte) kernel2Setting {
+	return func(c *Kernel2Config, value []byte) error {
+		if len(value) != 1 {
+			return fmt.Errorf("must be 1 byte long (got %d)", len(value))
+		}
+		*field(c) = value[0]
+		return nil
+	}
+}
+
+func actionCodeSetting(field func(c *Kernel2Config) *TVR) kernel2Setting {
+	return func(c *Kernel2Config, value []byte) error {
+		code, err := NewTVR(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = code
+		return nil
+	}
+}
+
+func amountSetting(field func(c *Kernel2Config) *uint64) kernel2Setting {
+	return func(c *Kernel2Config, value []byte) error {
+		amount, ok := numericValue(value)
+		if len(value) != 6 || !ok {
+			return fmt.Errorf("must be an amount of format n 12 (got %X)", value)
+		}
+		*field(c) = amount
+		return nil
+	}
+}
+
+// parseTagList decodes a list of tags without lengths.
+func parseTagList(data []byte) ([]string, error) {
+	var tags []string
+	for offset := 0; offset < len(data); {
+		tagLen := tagLength(data, offset)
+		if offset+tagLen > len(data) {
+			return nil, fmt.Errorf("offset %d: tag is incomplete", offset)
+		}
+		tags = append(tags, fmt.Sprintf("%X", data[offset:offset+tagLen]))
+		offset += tagLen
+	}
+	return tags, nil
+}
+
+// Set updates the configuration with one data object: a Kernel 2 setting, or terminal data.
+func (c *Kernel2Config) Set(tag string, value []byte) error {
+	tag = strings.ToUpper(tag)
+	if setting, ok := kernel2Settings[tag]; ok {
+		if err := setting(c, value); err != nil {
+			return fmt.Errorf("%s: %w", tagLabel(tag), err)
+		}
+		return nil
+	}
+	if c.Data == nil {
+		c.Data = TerminalData{}
+	}
+	c.Data[tag] = value
+	return nil
+}
+
+// clone returns a copy of the configuration that can be updated (e.g. by a data exchange)
+// without changing c.
+func (c Kernel2Config) clone() Kernel2Config {
+	data := make(TerminalData, len(c.Data))
+	for tag, value := range c.Data {
+		data[tag] = value
+	}
+	c.Data = data
+	c.TagsToRead = append([]string(nil), c.TagsToRead...)
+	return c
+}
+
+// ParseKernel2Config decodes the BER-TLV configuration of Kernel 2.
+func ParseKernel2Config(data []byte) (Kernel2Config, error) {
+	config := Kernel2Config{Data: TerminalData{}}
+
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		return config, fmt.Errorf("BER-TLV decode failed: %w", err)
+	}
+	for _, e := range elements {
+		if err := config.Set(e.Tag, e.Value); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// TerminalCapabilities returns the Terminal Capabilities ('9F33') of a transaction: the CVM
+// capability depends on whether the amount exceeds the Reader CVM Required Limit.
+func (c Kernel2Config) TerminalCapabilities(amount uint64) TerminalCapabilities {
+	cvm := c.NoCVMRequiredCapability
+	if amount > c.CVMRequiredLimit {
+		cvm = c.CVMRequiredCapability
+	}
+	return TerminalCapabilities{c.CardDataInputCapability, cvm, c.SecurityCapability}
+}
+
+// onDeviceCVM reports whether the card and the kernel support on-device cardholder verification.
+func (c Kernel2Config) onDeviceCVM(aip AIP) bool {
+	return aip.SupportsOnDeviceCVM() && c.KernelConfiguration&Kernel2OnDeviceCVMSupported != 0
+}
+
+// TransactionLimit returns the Reader Contactless Transaction Limit that applies to a card.
+func (c Kernel2Config) TransactionLimit(aip AIP) uint64 {
+	if c.onDeviceCVM(aip) {
+		return c.OnDeviceCVMLimit
+	}
+	return c.NoOnDeviceCVMLimit
+}
+
+// Report builds the structured report of the configuration.
+func (c Kernel2Config) Report() *report.Report {
+	rep := report.New("EMV KERNEL 2 CONFIGURATION")
+
+	caps := rep.AddSection("[1] Capabilities:")
+	caps.Note("Card Data Input Capability (DF8117)", fmt.Sprintf("%02X", c.CardDataInputCapability))
+	caps.Note("CVM Capability - CVM Required (DF8118)", fmt.Sprintf("%02X", c.CVMRequiredCapability))
+	caps.Note("CVM Capability - No CVM Required (DF8119)", fmt.Sprintf("%02X", c.NoCVMRequiredCapability))
+	caps.Note("Security Capability (DF811F)", fmt.Sprintf("%02X", c.SecurityCapability))
+	caps.Note("Kernel Configuration (DF811B)", fmt.Sprintf("%02X", c.KernelConfiguration))
+
+	limits := rep.AddSection("[2] Limits:")
+	limits.Note("Reader Contactless Floor Limit (DF8123)", fmt.Sprintf("%d", c.FloorLimit))
+	limits.Note("Transaction Limit, No On-device CVM (DF8124)", fmt.Sprintf("%d", c.NoOnDeviceCVMLimit))
+	limits.Note("Transaction Limit, On-device CVM (DF8125)", fmt.Sprintf("%d", c.OnDeviceCVMLimit))
+	limits.Note("Reader CVM Required Limit (DF8126)", fmt.Sprintf("%d", c.CVMRequiredLimit))
+
+	codes := rep.AddSection("[3] Terminal Action Codes:")
+	codes.Note("Default (DF8120)", fmt.Sprintf("%X", c.ActionCodes.Default[:]))
+	codes.Note("Denial (DF8121)", fmt.Sprintf("%X", c.ActionCodes.Denial[:]))
+	codes.Note("Online (DF8122)", fmt.Sprintf("%X", c.ActionCodes.Online[:]))
+
+	torn := rep.AddSection("[4] Torn Transactions:")
+	torn.Note("Max Number of Records (DF811D)", fmt.Sprintf("%d", c.MaxTornRecords))
+	torn.Note("Max Lifetime of a Record (DF811C)", c.MaxTornLifetime.String())
+
+	return rep
+}
+
+// Describe generates a human-readable report of the configuration.
+func (c Kernel2Config) Describe() string {
+	return report.Text(c.Report())
+}
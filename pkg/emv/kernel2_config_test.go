@@ -0,0 +1,128 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestParseKernel2Config(t *testing.T) {
+	data := tlv.Hex(
+		tlvHex("9F1A", "0250"),
+		tlvHex("DF8117", "E0"),
+		tlvHex("DF8118", "60"),
+		tlvHex("DF8119", "08"),
+		tlvHex("DF811F", "08"),
+		tlvHex("DF811B", "20"),
+		tlvHex("DF8120", "8400000000"),
+		tlvHex("DF8121", "0000000000"),
+		tlvHex("DF8122", "8400008000"),
+		tlvHex("DF8123", "000000002000"),
+		tlvHex("DF8124", "000000050000"),
+		tlvHex("DF8125", "000000100000"),
+		tlvHex("DF8126", "000000010000"),
+		tlvHex("DF811C", "012C"),
+		tlvHex("DF811D", "05"),
+		tlvHex("DF8112", "5A 5F34 9F7D"),
+	)
+
+	got, err := ParseKernel2Config(data)
+	if err != nil {
+		t.Fatalf("ParseKernel2Config() error = %v", err)
+	}
+
+	want := Kernel2Config{
+		Data:                    TerminalData{"9F1A": tlv.Hex("0250")},
+		CardDataInputCapability: 0xE0,
+		CVMRequiredCapability:   0x60,
+		NoCVMRequiredCapability: 0x08,
+		SecurityCapability:      0x08,
+		KernelConfiguration:     Kernel2OnDeviceCVMSupported,
+		ActionCodes: ActionCodes{
+			Default: TVR{0x84, 0x00, 0x00, 0x00, 0x00},
+			Online:  TVR{0x84, 0x00, 0x00, 0x80, 0x00},
+		},
+		FloorLimit:         2000,
+		NoOnDeviceCVMLimit: 50000,
+		OnDeviceCVMLimit:   100000,
+		CVMRequiredLimit:   10000,
+		MaxTornLifetime:    300 * time.Second,
+		MaxTornRecords:     5,
+		TagsToRead:         []string{"5A", "5F34", "9F7D"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseKernel2Config() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseKernel2Config_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "Malformed TLV", data: "DF8123 06 0000", wantErr: "BER-TLV decode failed"},
+		{name: "Amount not BCD", data: tlvHex("DF8123", "00000000200A"), wantErr: "Reader Contactless Floor Limit (DF8123)"},
+		{name: "Amount length", data: tlvHex("DF8126", "2000"), wantErr: "format n 12"},
+		{name: "Capability length", data: tlvHex("DF8117", "E000"), wantErr: "must be 1 byte long"},
+		{name: "Action code length", data: tlvHex("DF8120", "0000"), wantErr: "Terminal Action Code - Default (DF8120)"},
+		{name: "Incomplete tag list", data: tlvHex("DF8112", "5A 9F"), wantErr: "tag is incomplete"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKernel2Config(tlv.Hex(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseKernel2Config() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKernel2ConfigLimits(t *testing.T) {
+	config := Kernel2Config{
+		CardDataInputCapability: 0xE0,
+		CVMRequiredCapability:   0x60,
+		NoCVMRequiredCapability: 0x08,
+		SecurityCapability:      0x08,
+		NoOnDeviceCVMLimit:      50000,
+		OnDeviceCVMLimit:        100000,
+		CVMRequiredLimit:        10000,
+	}
+
+	if diff := cmp.Diff(TerminalCapabilities{0xE0, 0x08, 0x08}, config.TerminalCapabilities(10000)); diff != "" {
+		t.Errorf("TerminalCapabilities(10000) mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(TerminalCapabilities{0xE0, 0x60, 0x08}, config.TerminalCapabilities(10001)); diff != "" {
+		t.Errorf("TerminalCapabilities(10001) mismatch (-want +got):\n%s", diff)
+	}
+
+	cdcvm := AIP{0x1B, 0x80}
+	if got := config.TransactionLimit(cdcvm); got != 50000 {
+		t.Errorf("TransactionLimit() without kernel support = %d, want 50000", got)
+	}
+	config.KernelConfiguration = Kernel2OnDeviceCVMSupported
+	if got := config.TransactionLimit(cdcvm); got != 100000 {
+		t.Errorf("TransactionLimit() with on-device CVM = %d, want 100000", got)
+	}
+	if got := config.TransactionLimit(AIP{0x19, 0x80}); got != 50000 {
+		t.Errorf("TransactionLimit() without card support = %d, want 50000", got)
+	}
+}
+
+func TestKernel2ConfigDescribe(t *testing.T) {
+	out := Kernel2Config{FloorLimit: 2000, MaxTornRecords: 2, MaxTornLifetime: time.Minute}.Describe()
+	for _, want := range []string{
+		"=== EMV KERNEL 2 CONFIGURATION ===",
+		"Reader Contactless Floor Limit (DF8123): 2000",
+		"Max Number of Records (DF811D): 2",
+		"Max Lifetime of a Record (DF811C): 1m0s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Describe() does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package emv
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const (
+	k2AID      = "A0000000041010"
+	k2AIP      = "1980" // Cardholder verification, terminal risk management, CDA, EMV mode
+	k2PDOLData = "0250"
+	k2Summary1 = "0102030405060708"
+)
+
+// k2Card builds a Kernel 2 card signed by the test PKI, valid for any transaction (AUC
+// 'FF00'). Record 1 is signed for CDA and holds the IDS data; records 2 and 3 hold the
+// certificates.
+func k2Card(t *testing.T, aip string) *mockCard {
+	t.Helper()
+	return k2CardAUC(t, aip, "FF00")
+}
+
+// k2CardAUC builds the card of k2Card with another Application Usage Control.
+func k2CardAUC(t *testing.T, aip, auc string) *mockCard {
+	t.Helper()
+	record1 := tlvHex("70",
+		tlvHex("5A", "4761739000100010"),
+		tlvHex("5F24", "271231"),
+		tlvHex("5F25", "200101"),
+		tlvHex("5F28", "0250"),
+		tlvHex("5F34", "01"),
+		tlvHex("9F07", auc),
+		tlvHex("8C", "9F0206 9F0306 9F1A02 9505 5F2A02 9A03 9C01 9F3704 9F3501"),
+		tlvHex("8E", "00000000 00000000 4203 1F03"),
+		tlvHex("9F0D", "0000008000"),
+		tlvHex("9F0E", "0000000000"),
+		tlvHex("9F0F", "0000008000"),
+		tlvHex("9F4A", "82"),
+		tlvHex("9F51", "9F3704 9F0206"),
+		tlvHex("9F5E", "4761739000100010"),
+		tlvHex("9F7D", k2Summary1),
+	)
+	record2, record3 := loadTestPKI(t).certificateRecords(t, aip, record1)
+
+	return testCard{
+		AID:         k2AID,
+		Label:       "4D415354455243415244",
+		Proprietary: tlvHex("9F38", "9F1A02"),
+		GPO:         "80A80000048302025000",
+		GPOResponse: tlvHex("77", tlvHex("82", aip), tlvHex("94", "08010301")),
+		Records:     []string{record1, record2, record3},
+	}.mock()
+}
+
+// certificateRecords builds the records 2 and 3 of a card whose signed data is record 1
+// (SFI 1) and the AIP: the issuer certificate, then the ICC certificate. The certificates
+// are split over two records, so that each record stays below 255 bytes.
+func (p testPKI) certificateRecords(t *testing.T, aip, record1 string) (record2, record3 string) {
+	t.Helper()
+
+	data := NewApplicationData()
+	if err := data.AddRecord(1, 1, tlv.Hex(record1), true); err != nil {
+		t.Fatalf("AddRecord failed: %v", err)
+	}
+	in := ODAInput{AIP: AIP(tlv.Hex(aip)), Data: data}
+	staticData, err := in.StaticDataToAuthenticate()
+	if err != nil {
+		t.Fatalf("StaticDataToAuthenticate failed: %v", err)
+	}
+
+	issuerCert, issuerRemainder := p.issuerCertificate("476173FF", "1227")
+	iccCert, iccRemainder := p.iccCertificate("4761739000100010FFFF", "0627", staticData)
+	record2 = tlvHex("70",
+		tlvHex("8F", "92"),
+		tlvHex("90", hex.EncodeToString(issuerCert)),
+		tlvHex("92", hex.EncodeToString(issuerRemainder)),
+		tlvHex("9F32", hex.EncodeToString(exponentBytes(p.issuer))),
+	)
+	record3 = tlvHex("70",
+		tlvHex("9F46", hex.EncodeToString(iccCert)),
+		tlvHex("9F47", hex.EncodeToString(exponentBytes(p.icc))),
+		tlvHex("9F48", hex.EncodeToString(iccRemainder)),
+	)
+	return record2, record3
+}
+
+// k2CDOL1 returns the CDOL1 data of a transaction.
+func k2CDOL1(amount, tvr, un string) string {
+	return amount + "000000000000" + "0250" + tvr + "0978" + "260615" + "00" + un + "22"
+}
+
+// k2GenerateAC returns the GENERATE AC command with CDA and its response, signed over the
+// PDOL and CDOL1 data. signedCID and summaries alter the signed ICC Dynamic Data.
+func k2GenerateAC(t *testing.T, requested CryptogramType, cid, signedCID, cdol1, un, summaries string) (string, string) {
+	t.Helper()
+	p := loadTestPKI(t)
+
+	raw, err := GenerateAC(requested, true, tlv.Hex(cdol1)).Bytes()
+	if err != nil {
+		t.Fatalf("GenerateAC failed: %v", err)
+	}
+	return strings.ToUpper(hex.EncodeToString(raw)), k2Response(p, cid, signedCID, cdol1, un, summaries)
+}
+
+func k2Response(p testPKI, cid, signedCID, cdol1, un, summaries string) string {
+	objects := tlvHex("9F27", cid) + tlvHex("9F36", "0001") + tlvHex("9F10", txIAD)
+	hash := sha1.Sum(tlv.Hex(k2PDOLData, cdol1, objects))
+	iccDynamicData := tlv.Hex("04 A1B2C3D4", signedCID, cdaAC, hex.EncodeToString(hash[:]), summaries)
+	sdad := p.signedDynamicData(iccDynamicData, tlv.Hex(un))
+	return tlvHex("77", objects, tlvHex("9F4B", hex.EncodeToString(sdad))) + "9000"
+}
+
+func k2Config(t *testing.T) Kernel2Config {
+	t.Helper()
+	config, err := ParseKernel2Config(tlv.Hex(
+		tlvHex("9F1A", "0250"),
+		tlvHex("5F2A", "0978"),
+		tlvHex("9F35", "22"),
+		tlvHex("9F5C", "0000000000000001"),
+		tlvHex("DF8117", "E0"),
+		tlvHex("DF8118", "48"),
+		tlvHex("DF8119", "08"),
+		tlvHex("DF811F", "08"),
+		tlvHex("DF811B", "20"),
+		tlvHex("DF8120", "0000000000"),
+		tlvHex("DF8121", "0000000000"),
+		tlvHex("DF8122", "0000000000"),
+		tlvHex("DF8123", "000000002000"),
+		tlvHex("DF8124", "000000050000"),
+		tlvHex("DF8125", "000000100000"),
+		tlvHex("DF8126", "000000010000"),
+		tlvHex("DF811C", "012C"),
+		tlvHex("DF811D", "02"),
+	))
+	if err != nil {
+		t.Fatalf("ParseKernel2Config failed: %v", err)
+	}
+	config.CAKeys = loadTestPKI(t).caKeys()
+	config.CAKeys[0].RID = tlv.Hex("A000000004")
+	return config
+}
+
+func newTestKernel2(t *testing.T, card iso7816.Transmitter, un string) *Kernel2 {
+	return &Kernel2{
+		Client: iso7816.NewClient(card),
+		Config: k2Config(t),
+		Random: bytes.NewReader(tlv.Hex(un)),
+		Now:    testTransactionTime,
+	}
+}
+
+var k2Candidate = CombinationCandidate{ADFName: tlv.Hex(k2AID), Label: "MASTERCARD", KernelID: []byte{0x02}}
+
+func TestKernel2Run(t *testing.T) {
+	const idsSummaries = k2Summary1 + "1112131415161718"
+
+	tests := []struct {
+		name    string
+		aip     string
+		amount  uint64
+		genAC   func(t *testing.T) (string, string)
+		outcome TransactionOutcome
+		cvm     OutcomeCVM
+		tvr     TVR
+		ids     *IDSStatus
+	}{
+		{
+			name:   "approved offline below the floor limit",
+			aip:    k2AIP,
+			amount: 1000,
+			genAC: func(t *testing.T) (string, string) {
+				return k2GenerateAC(t, CryptogramTC, "40", "40", k2CDOL1("000000001000", "0000000000", txUN), txUN, idsSummaries)
+			},
+			outcome: OutcomeApproved,
+			cvm:     OutcomeNoCVM,
+			ids: &IDSStatus{
+				Summary1: tlv.Hex(k2Summary1), Summary2: tlv.Hex(k2Summary1), Summary3: tlv.Hex("1112131415161718"),
+				Read: true, Written: true,
+			},
+		},
+		{
+			name:   "online request above the floor limit",
+			aip:    k2AIP,
+			amount: 5000,
+			genAC: func(t *testing.T) (string, string) {
+				return k2GenerateAC(t, CryptogramARQC, "80", "80", k2CDOL1("000000005000", "0000008000", txUN), txUN, k2Summary1+k2Summary1)
+			},
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeNoCVM,
+			tvr:     TVR{0x00, 0x00, 0x00, 0x80, 0x00},
+			ids: &IDSStatus{
+				Summary1: tlv.Hex(k2Summary1), Summary2: tlv.Hex(k2Summary1), Summary3: tlv.Hex(k2Summary1),
+				Read: true,
+			},
+		},
+		{
+			name:   "online PIN above the CVM Required Limit",
+			aip:    k2AIP,
+			amount: 15000,
+			genAC: func(t *testing.T) (string, string) {
+				return k2GenerateAC(t, CryptogramARQC, "80", "80", k2CDOL1("000000015000", "0000048000", txUN), txUN, idsSummaries)
+			},
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeCVMOnlinePIN,
+			tvr:     TVR{0x00, 0x00, 0x04, 0x80, 0x00},
+		},
+		{
+			name:   "confirmation code verified on the device",
+			aip:    "1B80",
+			amount: 15000,
+			genAC: func(t *testing.T) (string, string) {
+				return k2GenerateAC(t, CryptogramARQC, "80", "80", k2CDOL1("000000015000", "0000008000", txUN), txUN, idsSummaries)
+			},
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeCVMConfirmationCode,
+			tvr:     TVR{0x00, 0x00, 0x00, 0x80, 0x00},
+		},
+		{
+			name:    "amount above the transaction limit",
+			aip:     k2AIP,
+			amount:  60000,
+			outcome: OutcomeSelectNext,
+		},
+		{
+			name:    "mag-stripe mode only",
+			aip:     "1900",
+			amount:  1000,
+			outcome: OutcomeTryAnotherInterface,
+		},
+		{
+			name:   "CDA failure",
+			aip:    k2AIP,
+			amount: 1000,
+			genAC: func(t *testing.T) (string, string) {
+				return k2GenerateAC(t, CryptogramTC, "40", "80", k2CDOL1("000000001000", "0000000000", txUN), txUN, idsSummaries)
+			},
+			outcome: OutcomeDeclined,
+			cvm:     OutcomeNoCVM,
+			tvr:     TVR{0x04, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:   "IDS read error",
+			aip:    k2AIP,
+			amount: 1000,
+			genAC: func(t *testing.T) (string, string) {
+				return k2GenerateAC(t, CryptogramTC, "40", "40", k2CDOL1("000000001000", "0000000000", txUN), txUN, "FFFFFFFFFFFFFFFF"+k2Summary1)
+			},
+			outcome: OutcomeEndApplication,
+			cvm:     OutcomeNoCVM,
+			ids: &IDSStatus{
+				Summary1: tlv.Hex(k2Summary1), Summary2: tlv.Hex("FFFFFFFFFFFFFFFF"), Summary3: tlv.Hex(k2Summary1),
+				Written: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := k2Card(t, tt.aip)
+			if tt.genAC != nil {
+				cmd, resp := tt.genAC(t)
+				card.responses[cmd] = resp
+			}
+
+			r, err := newTestKernel2(t, card, txUN).Run(k2Candidate, tt.amount, 0, TransactionPurchase)
+			if err != nil {
+				t.Fatalf("Run() error = %v\nsent: %v", err, card.sent)
+			}
+			if r.Outcome != tt.outcome {
+				t.Errorf("Outcome = %s, want %s\n%s", r.Outcome, tt.outcome, r.Describe())
+			}
+			if r.CVMOutcome != tt.cvm {
+				t.Errorf("CVM = %q, want %q", r.CVMOutcome, tt.cvm)
+			}
+			if diff := cmp.Diff(tt.tvr, r.TVR); diff != "" {
+				t.Errorf("TVR mismatch (-want +got):\n%s", diff)
+			}
+			if tt.ids != nil {
+				if diff := cmp.Diff(tt.ids, r.IDS); diff != "" {
+					t.Errorf("IDS mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestKernel2Run_UsageControl(t *testing.T) {
+	const (
+		servicesOnly = "0D00" // Domestic and international services, not at ATMs
+		servicesATM  = "0E00" // Domestic and international services, at ATMs only
+	)
+
+	tests := []struct {
+		name     string
+		auc      string
+		atm      bool
+		services bool
+		tvr      string // TVR set by processing restrictions
+	}{
+		{name: "goods", auc: servicesOnly, tvr: "0010000000"}, // Requested service not allowed for card product
+		{name: "services", auc: servicesOnly, services: true, tvr: "0000000000"},
+		{name: "services at an ATM", auc: servicesOnly, atm: true, services: true, tvr: "0010000000"},
+		{name: "services at an ATM, ATM card", auc: servicesATM, atm: true, services: true, tvr: "0000000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := k2CardAUC(t, k2AIP, tt.auc)
+			cmd, resp := k2GenerateAC(t, CryptogramTC, "40", "40", k2CDOL1("000000001000", tt.tvr, txUN), txUN, k2Summary1+k2Summary1)
+			card.responses[cmd] = resp
+			k := newTestKernel2(t, card, txUN)
+			k.Config.ATM = tt.atm
+			k.Config.Services = tt.services
+
+			r, err := k.Run(k2Candidate, 1000, 0, TransactionPurchase)
+			if err != nil {
+				t.Fatalf("Run() error = %v\nsent: %v", err, card.sent)
+			}
+			if diff := cmp.Diff(tlv.Hex(tt.tvr), r.Restrictions.TVR[:]); diff != "" {
+				t.Errorf("Processing restrictions TVR mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKernel2Run_TerminalCapabilities(t *testing.T) {
+	for _, tt := range []struct {
+		amount uint64
+		want   []byte
+	}{
+		{amount: 10000, want: tlv.Hex("E00808")},
+		{amount: 10001, want: tlv.Hex("E04808")},
+	} {
+		card := k2Card(t, "1900") // Ends after GET PROCESSING OPTIONS
+		r, err := newTestKernel2(t, card, txUN).Run(k2Candidate, tt.amount, 0, TransactionPurchase)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if diff := cmp.Diff(tt.want, r.Terminal["9F33"]); diff != "" {
+			t.Errorf("amount %d: Terminal Capabilities mismatch (-want +got):\n%s", tt.amount, diff)
+		}
+	}
+}
+
+type fakeExchanger struct {
+	toSend TerminalData
+	needed []string
+	update TerminalData
+}
+
+func (f *fakeExchanger) Exchange(toSend TerminalData, needed []string) (TerminalData, error) {
+	f.toSend, f.needed = toSend, needed
+	return f.update, nil
+}
+
+func TestKernel2Run_DataExchange(t *testing.T) {
+	card := k2Card(t, k2AIP)
+	cmd, resp := k2GenerateAC(t, CryptogramARQC, "80", "80", k2CDOL1("000000001000", "0000008000", txUN), txUN, k2Summary1+k2Summary1)
+	card.responses[cmd] = resp
+
+	k := newTestKernel2(t, card, txUN)
+	delete(k.Config.Data, "9F35")
+	k.Config.TagsToRead = []string{"5A", "9F42"}
+	floorLimit := k.Config.FloorLimit
+
+	// The data exchanged during a run is not kept in the configuration of the next one.
+	for i := 1; i <= 2; i++ {
+		exchanger := &fakeExchanger{update: TerminalData{
+			"9F35":   tlv.Hex("22"),
+			"DF8123": tlv.Hex("000000000500"), // Lower floor limit
+		}}
+		k.Exchange = exchanger
+		k.Random = bytes.NewReader(tlv.Hex(txUN))
+
+		r, err := k.Run(k2Candidate, 1000, 0, TransactionPurchase)
+		if err != nil {
+			t.Fatalf("Run %d error = %v\nsent: %v", i, err, card.sent)
+		}
+		if r.Outcome != OutcomeOnlineRequest {
+			t.Errorf("Run %d Outcome = %s, want %s\n%s", i, r.Outcome, OutcomeOnlineRequest, r.Describe())
+		}
+		if diff := cmp.Diff(TerminalData{"5A": tlv.Hex("4761739000100010")}, exchanger.toSend); diff != "" {
+			t.Errorf("Run %d Data To Send mismatch (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff([]string{"9F35"}, exchanger.needed); diff != "" {
+			t.Errorf("Run %d Data Needed mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+
+	if _, ok := k.Config.Data["9F35"]; ok || k.Config.FloorLimit != floorLimit {
+		t.Errorf("Run() modified the configuration of the kernel: %+v", k.Config)
+	}
+}
+
+// tornCard fails to answer one command, as a card removed from the field.
+type tornCard struct {
+	*mockCard
+	torn string
+}
+
+func (c *tornCard) Transmit(cmd []byte) ([]byte, error) {
+	if strings.ToUpper(hex.EncodeToString(cmd)) == c.torn {
+		c.torn = ""
+		return nil, errors.New("card removed from the field")
+	}
+	return c.mockCard.Transmit(cmd)
+}
+
+func TestKernel2Run_TornTransaction(t *testing.T) {
+	const nextUN = "55667788"
+	cdol1 := k2CDOL1("000000005000", "0000008000", txUN)
+	cmd, resp := k2GenerateAC(t, CryptogramARQC, "80", "80", cdol1, txUN, k2Summary1+k2Summary1)
+
+	card := &tornCard{mockCard: k2Card(t, k2AIP), torn: cmd}
+	log := &TornTransactionLog{}
+
+	// The card leaves the field during GENERATE AC.
+	k := newTestKernel2(t, card, txUN)
+	k.Torn = log
+	r, err := k.Run(k2Candidate, 5000, 0, TransactionPurchase)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if r.Outcome != OutcomeTryAgain {
+		t.Fatalf("Outcome = %s, want %s\n%s", r.Outcome, OutcomeTryAgain, r.Describe())
+	}
+	if len(log.Records) != 1 {
+		t.Fatalf("Torn Transaction Log holds %d records, want 1", len(log.Records))
+	}
+
+	// The card is presented again: RECOVER AC returns the response of the torn transaction,
+	// built from the DRDOL with the data of the torn transaction.
+	card.responses["80D000000A"+txUN+"000000005000"+"00"] = resp
+	k = newTestKernel2(t, card, nextUN)
+	k.Torn = log
+	k.Now = testTransactionTime.Add(time.Minute)
+	r, err = k.Run(k2Candidate, 5000, 0, TransactionPurchase)
+	if err != nil {
+		t.Fatalf("Run() error = %v\nsent: %v", err, card.sent)
+	}
+	if r.Outcome != OutcomeOnlineRequest || !r.Recovered {
+		t.Errorf("Outcome = %s, Recovered = %t, want %s recovered\n%s", r.Outcome, r.Recovered, OutcomeOnlineRequest, r.Describe())
+	}
+	if r.ODA == nil || !r.ODA.Passed() {
+		t.Errorf("CDA of the recovered response failed:\n%s", r.Describe())
+	}
+	if len(log.Records) != 0 {
+		t.Errorf("Torn Transaction Log holds %d records, want 0", len(log.Records))
+	}
+}
+
+func TestKernel2Run_TornWithoutLog(t *testing.T) {
+	cmd, _ := k2GenerateAC(t, CryptogramARQC, "80", "80", k2CDOL1("000000005000", "0000008000", txUN), txUN, "")
+	card := &tornCard{mockCard: k2Card(t, k2AIP), torn: cmd}
+
+	r, err := newTestKernel2(t, card, txUN).Run(k2Candidate, 5000, 0, TransactionPurchase)
+	if err == nil {
+		t.Fatal("Run() error = nil, want an error")
+	}
+	if r.Outcome != OutcomeEndApplication {
+		t.Errorf("Outcome = %s, want %s", r.Outcome, OutcomeEndApplication)
+	}
+}
+
+func TestKernel2Result_Describe(t *testing.T) {
+	card := k2Card(t, k2AIP)
+	cmd, resp := k2GenerateAC(t, CryptogramTC, "40", "40", k2CDOL1("000000001000", "0000000000", txUN), txUN, k2Summary1+k2Summary1)
+	card.responses[cmd] = resp
+
+	r, err := newTestKernel2(t, card, txUN).Run(k2Candidate, 1000, 0, TransactionPurchase)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	out := r.Describe()
+	for _, want := range []string{
+		"=== EMV CONTACTLESS KERNEL 2 ===",
+		"Application: A0000000041010 (MASTERCARD)",
+		"Terminal Capabilities (9F33): E00808",
+		"CDA successful",
+		"Status: Approved",
+		"CVM: No CVM",
+		"IDS: read: true, written: false",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Describe() does not contain %q:\n%s", want, out)
+		}
+	}
+}
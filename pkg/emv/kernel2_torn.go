@@ -0,0 +1,94 @@
+package emv
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+)
+
+// TORN TRANSACTION RECOVERY Logic according to EMV Book C-2.
+// A contactless card may leave the field while it processes GENERATE AC: the transaction
+// is torn, and the terminal cannot know whether the card counted it. When the card has a
+// DRDOL ('9F51'), the kernel keeps a record of the torn transaction:
+//
+// 1. The Torn Transaction Log keeps at most 'Max Number of Torn Transaction Log Records'
+//    ('DF811D') records, the oldest one being removed first, and a record expires after
+//    'Max Lifetime of Torn Transaction Log Record' ('DF811C').
+// 2. When the same card (PAN and PAN Sequence Number) is presented again, the kernel sends
+//    RECOVER AC with the DRDOL data of the torn transaction instead of GENERATE AC.
+// 3. The response to RECOVER AC is the response that the card could not return. When the
+//    card cannot recover it, the kernel continues with GENERATE AC.
+
+// RecoverAC creates the RECOVER AC command with the DRDOL related data.
+func RecoverAC(drdolData []byte) *iso7816.CommandAPDU {
+	return newEMVCommand(INS_RECOVER_AC, 0x00, 0x00, drdolData, iso7816.MaxShortLe)
+}
+
+// TornRecord keeps the data of a transaction torn during GENERATE AC.
+type TornRecord struct {
+	PAN         []byte // '5A'
+	PANSequence []byte // '5F34', optional
+	Time        time.Time
+
+	// Terminal holds the terminal data of the torn transaction, used to build the DRDOL data.
+	Terminal TerminalData
+
+	// Data signed by the card with CDA.
+	PDOLData  []byte
+	CDOL1Data []byte
+	CDA       bool
+}
+
+// matches reports whether the record was made with the same card.
+func (r TornRecord) matches(pan, panSequence []byte) bool {
+	return bytes.Equal(r.PAN, pan) && bytes.Equal(r.PANSequence, panSequence)
+}
+
+// TornTransactionLog is the Torn Transaction Log of the kernel. The terminal keeps it
+// between transactions.
+type TornTransactionLog struct {
+	Records []TornRecord
+}
+
+// expire removes the records older than lifetime. They are returned to the terminal,
+// which may send them to the acquirer.
+func (l *TornTransactionLog) expire(now time.Time, lifetime time.Duration) []TornRecord {
+	var expired []TornRecord
+	kept := l.Records[:0]
+	for _, r := range l.Records {
+		if now.Sub(r.Time) > lifetime {
+			expired = append(expired, r)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	l.Records = kept
+	return expired
+}
+
+// find returns the index of the most recent record of a card.
+func (l *TornTransactionLog) find(pan, panSequence []byte) (int, bool) {
+	for i := len(l.Records) - 1; i >= 0; i-- {
+		if l.Records[i].matches(pan, panSequence) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// remove deletes a record.
+func (l *TornTransactionLog) remove(i int) {
+	l.Records = append(l.Records[:i], l.Records[i+1:]...)
+}
+
+// add keeps a record, removing the oldest ones beyond max. The removed records are returned.
+func (l *TornTransactionLog) add(r TornRecord, max int) []TornRecord {
+	l.Records = append(l.Records, r)
+	if len(l.Records) <= max {
+		return nil
+	}
+	removed := append([]TornRecord{}, l.Records[:len(l.Records)-max]...)
+	l.Records = append([]TornRecord{}, l.Records[len(l.Records)-max:]...)
+	return removed
+}
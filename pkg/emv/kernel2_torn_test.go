@@ -0,0 +1,64 @@
+package emv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestRecoverAC(t *testing.T) {
+	cmd := RecoverAC(tlv.Hex("11223344"))
+	raw, err := cmd.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("80D000000411223344 00"), raw); diff != "" {
+		t.Errorf("RecoverAC() mismatch (-want +got):\n%s", diff)
+	}
+
+	// 'D0' is also WRITE BINARY in ISO 7816-4.
+	expected := "INS: 0xD0 | Command: INS_RECOVER_AC | Format: Standard | P1: 00, P2: 00 | Lc: 4 | Le: 256"
+	if diff := cmp.Diff(expected, cmd.String()); diff != "" {
+		t.Errorf("String() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTornTransactionLog(t *testing.T) {
+	start := time.Date(2026, 6, 15, 10, 0, 0, 0, time.UTC)
+	record := func(pan string, minutes int) TornRecord {
+		return TornRecord{PAN: tlv.Hex(pan), PANSequence: tlv.Hex("01"), Time: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	log := &TornTransactionLog{}
+	if removed := log.add(record("1111", 0), 2); removed != nil {
+		t.Errorf("add() removed %d records, want none", len(removed))
+	}
+	log.add(record("2222", 1), 2)
+	removed := log.add(record("3333", 2), 2)
+	if diff := cmp.Diff([]TornRecord{record("1111", 0)}, removed); diff != "" {
+		t.Errorf("add() beyond the maximum mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, found := log.find(tlv.Hex("1111"), tlv.Hex("01")); found {
+		t.Error("find() found a removed record")
+	}
+	if _, found := log.find(tlv.Hex("2222"), tlv.Hex("02")); found {
+		t.Error("find() ignored the PAN Sequence Number")
+	}
+	i, found := log.find(tlv.Hex("3333"), tlv.Hex("01"))
+	if !found || i != 1 {
+		t.Errorf("find() = %d, %t, want 1, true", i, found)
+	}
+
+	expired := log.expire(start.Add(3*time.Minute), 90*time.Second)
+	if diff := cmp.Diff([]TornRecord{record("2222", 1)}, expired); diff != "" {
+		t.Errorf("expire() mismatch (-want +got):\n%s", diff)
+	}
+
+	log.remove(0)
+	if len(log.Records) != 0 {
+		t.Errorf("remove() left %d records, want 0", len(log.Records))
+	}
+}
@@ -0,0 +1,556 @@
+package emv
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// CONTACTLESS KERNEL 3 (VISA qVSDC) Logic according to EMV Book C-3.
+// With qVSDC the card generates its cryptogram during GET PROCESSING OPTIONS:
+//
+//  1. Pre-processing: the TTQ ('9F66') of the transaction is built from the reader
+//     capabilities. 'Online cryptogram required' is set above the Reader Contactless Floor
+//     Limit (and for a zero amount on an online capable reader), 'CVM required' from the
+//     Reader CVM Required Limit. From the Reader Contactless Transaction Limit the
+//     contactless interface is not allowed.
+//  2. Final SELECT, then GET PROCESSING OPTIONS with the PDOL data. The format 2 response
+//     carries the AIP, the optional AFL, the Application Cryptogram ('9F26'), the ATC, the
+//     Issuer Application Data, the CTQ ('9F6C'), the FFI ('9F6E') and, for offline
+//     approvals, the Signed Dynamic Application Data ('9F4B'). '6984' switches to the
+//     contact interface, '6985' selects the next application and '6986' asks the
+//     cardholder to try again (e.g. see phone).
+//  3. READ RECORD of the records of the AFL: certificates and static data of fDDA.
+//  4. Decision, from the CID ('9F27') or, when absent, from the Issuer Application Data
+//     (byte 5 bits 6-5: 00 AAC, 01 TC, 10 ARQC):
+//     - AAC: declined.
+//     - ARQC: online request. fDDA is also performed when the TTQ announces ODA for
+//       online authorisations.
+//     - TC: approved offline when the application has not expired and fDDA succeeds.
+//       Otherwise the CTQ decides: go online, switch to the contact interface, or decline.
+//  5. Cardholder verification: online PIN or signature when required by the CTQ and
+//     supported by the TTQ, Consumer Device CVM when performed on the device. When the
+//     reader requires a CVM that cannot be performed, the transaction is declined.
+
+// Kernel3Config is the configuration of Kernel 3 for one AID.
+type Kernel3Config struct {
+	// Data holds the terminal data requested by the PDOL, for instance the Terminal Country
+	// Code ('9F1A') and the Transaction Currency Code ('5F2A').
+	Data TerminalData
+
+	// TTQ holds the reader capabilities. Byte 2 bits 8-7 are set for each transaction.
+	TTQ TTQ
+
+	// Limits, in the minor unit of the currency.
+	FloorLimit       uint64
+	TransactionLimit uint64 // Zero means no limit
+	CVMRequiredLimit uint64 // Zero means no limit
+
+	CAKeys CAKeyStore
+}
+
+// TransactionTTQ returns the TTQ sent to the card for an amount.
+func (c Kernel3Config) TransactionTTQ(amount uint64) TTQ {
+	ttq := c.TTQ
+	ttq[1] &^= 0xC0
+	if amount > c.FloorLimit || (amount == 0 && !ttq.OfflineOnly()) {
+		ttq[1] |= 0x80
+	}
+	if c.CVMRequiredLimit > 0 && amount >= c.CVMRequiredLimit {
+		ttq[1] |= 0x40
+	}
+	return ttq
+}
+
+// Kernel3 processes contactless transactions with Visa cards (qVSDC).
+type Kernel3 struct {
+	Client *iso7816.Client
+	Config Kernel3Config
+
+	// Random provides the Unpredictable Number. Nil uses crypto/rand.
+	Random io.Reader
+
+	// Now is the date and time of the transaction. Zero means time.Now().
+	Now time.Time
+}
+
+// Kernel3Result is the outcome of every step of a Kernel 3 transaction. The steps that
+// were not reached are nil.
+type Kernel3Result struct {
+	Amount      uint64
+	AmountOther uint64
+	Type        TransactionType
+	Candidate   CombinationCandidate
+	TTQ         TTQ
+
+	ProcessingOptions *ProcessingOptions
+	Data              *ApplicationData
+	CTQ               *CTQ
+	FFI               *FFI
+	Cryptogram        *Cryptogram
+	ODA               *AuthenticationResult
+
+	// Terminal holds the terminal data of the transaction.
+	Terminal TerminalData
+
+	Outcome    TransactionOutcome
+	CVMOutcome OutcomeCVM
+	Steps      []string
+
+	// Trace keeps every exchange of the transaction, in order.
+	Trace iso7816.Trace
+}
+
+func (r *Kernel3Result) step(format string, args ...interface{}) {
+	r.Steps = append(r.Steps, fmt.Sprintf(format, args...))
+}
+
+// end sets the outcome of the transaction.
+func (r *Kernel3Result) end(outcome TransactionOutcome, format string, args ...interface{}) {
+	r.Outcome = outcome
+	r.step(format, args...)
+}
+
+// kernel3Run holds the state of one transaction.
+type kernel3Run struct {
+	k *Kernel3
+	r *Kernel3Result
+}
+
+func (k *Kernel3) now() time.Time {
+	if k.Now.IsZero() {
+		return time.Now()
+	}
+	return k.Now
+}
+
+// Run performs a transaction with a candidate of Entry Point. The result of the steps
+// performed so far is returned with the error when the transaction is terminated.
+func (k *Kernel3) Run(candidate CombinationCandidate, amount, amountOther uint64, txType TransactionType) (*Kernel3Result, error) {
+	run := &kernel3Run{
+		k: k,
+		r: &Kernel3Result{
+			Amount:      amount,
+			AmountOther: amountOther,
+			Type:        txType,
+			Candidate:   candidate,
+			TTQ:         k.Config.TransactionTTQ(amount),
+			Terminal:    TerminalData{},
+		},
+	}
+
+	for _, phase := range []func() error{
+		run.initTerminalData,
+		run.checkLimits,
+		run.initiate,
+		run.readRecords,
+		run.readQualifiers,
+		run.process,
+	} {
+		if err := phase(); err != nil {
+			run.r.end(OutcomeEndApplication, "Transaction terminated: %v", err)
+			return run.r, err
+		}
+		if run.r.Outcome != "" {
+			break
+		}
+	}
+	return run.r, nil
+}
+
+// initTerminalData prepares the terminal data of the transaction.
+func (run *kernel3Run) initTerminalData() error {
+	for tag, value := range run.k.Config.Data {
+		run.r.Terminal[tag] = value
+	}
+
+	un := make([]byte, 4)
+	random := run.k.Random
+	if random == nil {
+		random = rand.Reader
+	}
+	if _, err := io.ReadFull(random, un); err != nil {
+		return fmt.Errorf("Unpredictable Number: %w", err)
+	}
+
+	now := run.k.now()
+	run.r.Terminal["9F66"] = append([]byte{}, run.r.TTQ[:]...)
+	run.r.Terminal["9F02"] = numericBytes(run.r.Amount, 6)
+	run.r.Terminal["9F03"] = numericBytes(run.r.AmountOther, 6)
+	run.r.Terminal["9C"] = []byte{byte(run.r.Type)}
+	run.r.Terminal["9A"], _ = hex.DecodeString(now.Format("060102"))
+	run.r.Terminal["9F21"], _ = hex.DecodeString(now.Format("150405"))
+	run.r.Terminal["9F37"] = un
+	run.r.Terminal["95"] = make([]byte, 5)
+	return nil
+}
+
+// checkLimits refuses the contactless interface from the Reader Contactless Transaction Limit.
+func (run *kernel3Run) checkLimits() error {
+	limit := run.k.Config.TransactionLimit
+	if limit > 0 && run.r.Amount >= limit {
+		run.r.end(OutcomeTryAnotherInterface, "Amount %d reaches the Reader Contactless Transaction Limit %d", run.r.Amount, limit)
+	}
+	return nil
+}
+
+// initiate performs the final selection and GET PROCESSING OPTIONS.
+func (run *kernel3Run) initiate() error {
+	trace, err := run.send(iso7816.SelectByAID(iso7816.Class{}, run.r.Candidate.SelectionName()))
+	if err != nil {
+		return fmt.Errorf("final selection: %w", err)
+	}
+	fci, parseErr := ParseFCI(trace.Last().Response.Data)
+	if !trace.IsSuccess() || parseErr != nil {
+		run.r.end(OutcomeSelectNext, "Final selection of %X failed", run.r.Candidate.ADFName)
+		return nil
+	}
+
+	pdol, err := fci.ProprietaryTemplate.ParsePDOL()
+	if err != nil {
+		return err
+	}
+	cmd, _ := GetProcessingOptionsWithPDOL(pdol, run.r.Terminal)
+	trace, err = run.send(cmd)
+	if err != nil {
+		return fmt.Errorf("GET PROCESSING OPTIONS: %w", err)
+	}
+	if run.gpoStatus(trace.Last().Response.Status) {
+		return nil
+	}
+
+	response := trace.Last().Response.Data
+	po, err := ParseProcessingOptions(response)
+	if err != nil {
+		return fmt.Errorf("GET PROCESSING OPTIONS: %w", err)
+	}
+	if po.Format != 0x77 {
+		return fmt.Errorf("GET PROCESSING OPTIONS: qVSDC requires a format 2 response (Tag 77)")
+	}
+	run.r.ProcessingOptions = po
+	run.r.step("Application %X initiated", run.r.Candidate.ADFName)
+	return nil
+}
+
+// gpoStatus ends the transaction on the status words of GET PROCESSING OPTIONS handled by
+// Kernel 3. It returns true when the transaction ended.
+func (run *kernel3Run) gpoStatus(status iso7816.StatusWord) bool {
+	switch {
+	case status.IsSuccess():
+		return false
+	case status == 0x6984:
+		run.r.end(OutcomeTryAnotherInterface, "GET PROCESSING OPTIONS: switch to the contact interface")
+	case status == iso7816.SW_ERR_COND_OF_USE_NOT_SAT:
+		run.r.end(OutcomeSelectNext, "GET PROCESSING OPTIONS: conditions of use not satisfied")
+	case status == 0x6986:
+		run.r.end(OutcomeTryAgain, "GET PROCESSING OPTIONS: see phone, then try again")
+	default:
+		run.r.end(OutcomeEndApplication, "GET PROCESSING OPTIONS failed with status: %s", status.Verbose())
+	}
+	return true
+}
+
+func (run *kernel3Run) send(cmd *iso7816.CommandAPDU) (iso7816.Trace, error) {
+	trace, err := run.k.Client.Send(cmd)
+	run.r.Trace = append(run.r.Trace, trace...)
+	return trace, err
+}
+
+// readRecords reads the records of the AFL, then adds the data objects of the GPO response.
+func (run *kernel3Run) readRecords() error {
+	po := run.r.ProcessingOptions
+	data, err := ReadApplicationData(run.k.Client, iso7816.Class{}, po.AFL)
+	run.r.Data = data
+	if data != nil {
+		run.r.Trace = append(run.r.Trace, data.Trace...)
+	}
+	if err != nil {
+		return fmt.Errorf("read application data: %w", err)
+	}
+
+	for _, t := range po.Additional {
+		data.Set(t.Tag, t.Value)
+	}
+	data.Set("82", append([]byte{}, po.AIP[:]...))
+	data.Set("84", run.r.Candidate.ADFName)
+	run.r.step("%d records read", len(data.Records))
+	return nil
+}
+
+// readQualifiers decodes the CTQ and the FFI returned by the card.
+func (run *kernel3Run) readQualifiers() error {
+	if value, ok := run.r.Data.Lookup("9F6C"); ok {
+		ctq, err := NewCTQ(value)
+		if err != nil {
+			return err
+		}
+		run.r.CTQ = &ctq
+	}
+	if value, ok := run.r.Data.Lookup("9F6E"); ok {
+		ffi, err := NewFFI(value)
+		if err != nil {
+			return err
+		}
+		run.r.FFI = &ffi
+	}
+	return nil
+}
+
+// qvsdcCryptogram gathers the cryptogram returned in the GPO response.
+func qvsdcCryptogram(data *ApplicationData) (*Cryptogram, error) {
+	values := map[string][]byte{}
+	for _, tag := range []string{"9F26", "9F36", "9F10"} {
+		value, ok := data.Lookup(tag)
+		if !ok {
+			return nil, fmt.Errorf("missing %s", tagLabel(tag))
+		}
+		values[tag] = value
+	}
+	if len(values["9F26"]) != 8 || len(values["9F36"]) != 2 {
+		return nil, fmt.Errorf("Application Cryptogram and ATC must be 8 and 2 bytes long (got %d and %d)", len(values["9F26"]), len(values["9F36"]))
+	}
+
+	ac := &Cryptogram{
+		Format:                0x77,
+		ATC:                   binary.BigEndian.Uint16(values["9F36"]),
+		ApplicationCryptogram: values["9F26"],
+		IssuerApplicationData: values["9F10"],
+	}
+	ac.SignedDynamicApplicationData, _ = data.Lookup("9F4B")
+
+	iad := values["9F10"]
+	if cid, ok := data.Lookup("9F27"); ok && len(cid) == 1 {
+		ac.CID = CID(cid[0])
+	} else if len(iad) >= 5 {
+		ac.CID = CID((iad[4] >> 4 & 0x03) << 6)
+	} else {
+		return nil, fmt.Errorf("missing %s", tagLabel("9F27"))
+	}
+	return ac, nil
+}
+
+// process takes the decision of the transaction, then selects the CVM.
+func (run *kernel3Run) process() error {
+	ac, err := qvsdcCryptogram(run.r.Data)
+	if err != nil {
+		return err
+	}
+	run.r.Cryptogram = ac
+	if run.switchInterface() {
+		return nil
+	}
+
+	switch ac.CID.Type() {
+	case CryptogramAAC:
+		run.r.end(OutcomeDeclined, "Declined by the card (AAC)")
+		return nil
+	case CryptogramARQC:
+		run.online()
+	default:
+		run.offline()
+	}
+
+	if run.r.Outcome == OutcomeApproved || run.r.Outcome == OutcomeOnlineRequest {
+		run.verifyCardholder()
+	}
+	return nil
+}
+
+// switchInterface sends cash and cashback transactions to the contact interface when the
+// card requests it.
+func (run *kernel3Run) switchInterface() bool {
+	ctq := run.r.CTQ
+	if ctq == nil || !run.r.TTQ.SupportsEMVContact() {
+		return false
+	}
+	cash := run.r.Type == TransactionCash && ctq.SwitchInterfaceForCash()
+	cashback := run.r.AmountOther > 0 && ctq.SwitchInterfaceForCashback()
+	if cash || cashback {
+		run.r.end(OutcomeTryAnotherInterface, "The card requests the contact interface for this transaction")
+	}
+	return cash || cashback
+}
+
+// online completes an ARQC.
+func (run *kernel3Run) online() {
+	if run.r.TTQ.OfflineOnly() {
+		run.r.end(OutcomeDeclined, "ARQC on an offline-only reader")
+		return
+	}
+	if run.r.TTQ.SupportsODAForOnline() && len(run.r.Cryptogram.SignedDynamicApplicationData) > 0 {
+		run.verifyFDDA()
+	}
+	run.r.end(OutcomeOnlineRequest, "Online authorisation requested")
+}
+
+// offline completes a TC: the application must not have expired and fDDA must succeed.
+func (run *kernel3Run) offline() {
+	if reason, expired := run.expired(); expired {
+		run.fallback(reason, run.r.CTQ != nil && run.r.CTQ.OnlineIfExpired(), false)
+		return
+	}
+	if !run.verifyFDDA() {
+		ctq := run.r.CTQ
+		run.fallback("fDDA failed", ctq != nil && ctq.OnlineIfODAFails(), ctq != nil && ctq.SwitchInterfaceIfODAFails())
+		return
+	}
+	run.r.end(OutcomeApproved, "Approved offline")
+}
+
+// fallback ends an offline transaction that cannot be approved.
+func (run *kernel3Run) fallback(reason string, goOnline, switchInterface bool) {
+	switch {
+	case goOnline && !run.r.TTQ.OfflineOnly():
+		run.r.end(OutcomeOnlineRequest, "%s: online authorisation requested", reason)
+	case switchInterface && run.r.TTQ.SupportsEMVContact():
+		run.r.end(OutcomeTryAnotherInterface, "%s: switch to the contact interface", reason)
+	default:
+		run.r.end(OutcomeDeclined, "%s: declined", reason)
+	}
+}
+
+// expired reports whether the Application Expiration Date ('5F24') is before the date of the
+// transaction. A malformed date is handled as an expired one.
+func (run *kernel3Run) expired() (string, bool) {
+	value, ok := run.r.Data.Lookup("5F24")
+	if !ok {
+		return "", false
+	}
+	expiry, err := parseDate(value)
+	if err != nil {
+		return fmt.Sprintf("%s: %v", tagLabel("5F24"), err), true
+	}
+	now := run.k.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return "Application expired", today.After(expiry)
+}
+
+// verifyFDDA verifies the Signed Dynamic Application Data returned during GET PROCESSING OPTIONS.
+func (run *kernel3Run) verifyFDDA() bool {
+	sdad := run.r.Cryptogram.SignedDynamicApplicationData
+	if len(sdad) == 0 {
+		run.r.ODA = &AuthenticationResult{Method: "fDDA", DataMissing: true}
+		run.r.ODA.check("Signed Dynamic Application Data", false, "missing %s", tagLabel("9F4B"))
+		return false
+	}
+
+	run.r.ODA = VerifyFDDA(ODAInput{
+		AID:    run.r.Candidate.ADFName,
+		AIP:    run.r.ProcessingOptions.AIP,
+		Data:   run.r.Data,
+		CAKeys: run.k.Config.CAKeys,
+		Now:    run.k.now(),
+	}, sdad, run.r.Terminal)
+	if run.r.ODA.Passed() {
+		run.r.step("fDDA successful")
+		return true
+	}
+	run.r.step("fDDA failed")
+	return false
+}
+
+// verifyCardholder selects the CVM of the transaction.
+func (run *kernel3Run) verifyCardholder() {
+	cvm, ok := run.cardCVM()
+	if !ok && run.r.TTQ.CVMRequired() {
+		cvm, ok = run.readerCVM()
+	}
+	if !ok && run.r.TTQ.CVMRequired() {
+		run.r.end(OutcomeDeclined, "CVM required by the reader but not available")
+		return
+	}
+	if !ok {
+		cvm = OutcomeNoCVM
+	}
+
+	if cvm == OutcomeCVMOnlinePIN && run.r.Outcome == OutcomeApproved {
+		run.r.end(OutcomeDeclined, "Online PIN cannot be verified on an offline approval")
+		return
+	}
+	run.r.CVMOutcome = cvm
+	run.r.step("CVM: %s", cvm)
+}
+
+// cardCVM returns the CVM requested by the CTQ and supported by the reader.
+func (run *kernel3Run) cardCVM() (OutcomeCVM, bool) {
+	ctq, ttq := run.r.CTQ, run.r.TTQ
+	switch {
+	case ctq == nil:
+		return "", false
+	case ctq.OnlinePINRequired() && ttq.SupportsOnlinePIN():
+		return OutcomeCVMOnlinePIN, true
+	case ctq.ConsumerDeviceCVMPerformed() && ttq.SupportsConsumerDeviceCVM():
+		return OutcomeCVMConfirmationCode, true
+	case ctq.SignatureRequired() && ttq.SupportsSignature():
+		return OutcomeCVMSignature, true
+	}
+	return "", false
+}
+
+// readerCVM returns the CVM performed by the reader when it requires one.
+func (run *kernel3Run) readerCVM() (OutcomeCVM, bool) {
+	ttq := run.r.TTQ
+	switch {
+	case ttq.SupportsOnlinePIN() && run.r.Outcome == OutcomeOnlineRequest:
+		return OutcomeCVMOnlinePIN, true
+	case ttq.SupportsSignature():
+		return OutcomeCVMSignature, true
+	}
+	return "", false
+}
+
+// Report builds the structured report of the transaction.
+func (r *Kernel3Result) Report() *report.Report {
+	rep := report.New("EMV CONTACTLESS KERNEL 3")
+
+	tx := rep.AddSection("[1] Transaction:")
+	tx.Note("Type", r.Type.String())
+	tx.Note("Amount", fmt.Sprintf("%d", r.Amount))
+	if r.AmountOther > 0 {
+		tx.Note("Amount Other", fmt.Sprintf("%d", r.AmountOther))
+	}
+	tx.Note("Application", fmt.Sprintf("%X (%s)", r.Candidate.ADFName, r.Candidate.Label))
+
+	qualifiers := rep.AddSection("[2] Qualifiers:")
+	for _, f := range r.TTQ.Features() {
+		qualifiers.Note(fmt.Sprintf("TTQ (9F66) %X", r.TTQ[:]), f)
+	}
+	if r.CTQ != nil {
+		for _, f := range r.CTQ.Features() {
+			qualifiers.Note(fmt.Sprintf("CTQ (9F6C) %X", r.CTQ[:]), f)
+		}
+	}
+	if r.FFI != nil {
+		qualifiers.Note(fmt.Sprintf("FFI (9F6E) %X", r.FFI[:]), r.FFI.FormFactor())
+	}
+
+	steps := rep.AddSection("[3] Steps:")
+	for _, s := range r.Steps {
+		steps.Note("", s)
+	}
+
+	if ac := r.Cryptogram; ac != nil {
+		section := rep.AddSection("[4] Cryptogram:")
+		section.Note("CID", ac.CID.String())
+		section.Note("ATC (9F36)", fmt.Sprintf("%04X", ac.ATC))
+		section.Note("Application Cryptogram (9F26)", fmt.Sprintf("%X", ac.ApplicationCryptogram))
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	outcome.Note("Status", string(r.Outcome))
+	if r.CVMOutcome != "" {
+		outcome.Note("CVM", string(r.CVMOutcome))
+	}
+	return rep
+}
+
+// Describe generates a human-readable report of the transaction.
+func (r *Kernel3Result) Describe() string {
+	return report.Text(r.Report())
+}
@@ -0,0 +1,350 @@
+package emv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const (
+	k3AID = "A0000000031010"
+	k3AIP = "2000" // DDA
+	k3TTQ = "36004000"
+
+	k3IADTC   = "06010A03900000"
+	k3IADARQC = "06010A03A00000"
+	k3IADAAC  = "06010A03800000"
+)
+
+// k3Card builds a qVSDC card signed by the test PKI. Record 1 holds the static data signed
+// for fDDA, records 2 and 3 the certificates. The GPO responses are added by the tests.
+func k3Card(t *testing.T, expiry string) *mockCard {
+	t.Helper()
+	record1 := tlvHex("70",
+		tlvHex("5A", "4761739000100010"),
+		tlvHex("5F24", expiry),
+		tlvHex("5F34", "01"),
+		tlvHex("9F4A", "82"),
+	)
+	record2, record3 := loadTestPKI(t).certificateRecords(t, k3AIP, record1)
+
+	return testCard{
+		AID:         k3AID,
+		Label:       "56495341",
+		Proprietary: tlvHex("9F38", "9F6604 9F0206 9F3704 5F2A02"),
+		Records:     []string{record1, record2, record3},
+	}.mock()
+}
+
+// k3GPO returns the GET PROCESSING OPTIONS command of a transaction.
+func k3GPO(ttq, amount string) string {
+	return "80A80000128310" + ttq + amount + txUN + "0978" + "00"
+}
+
+// k3Response returns a format 2 GPO response. signedUN is the Unpredictable Number covered
+// by the Signed Dynamic Application Data; an empty value omits it.
+func k3Response(t *testing.T, iad, ctq, signedUN string) string {
+	t.Helper()
+	objects := tlvHex("82", k3AIP) + tlvHex("94", "08010301") +
+		tlvHex("9F36", "0001") + tlvHex("9F26", cdaAC) + tlvHex("9F10", iad) +
+		tlvHex("9F6C", ctq) + tlvHex("9F6E", "20700000")
+	if signedUN != "" {
+		sdad := loadTestPKI(t).signedDynamicData(tlv.Hex("04 A1B2C3D4"), tlv.Hex(signedUN))
+		objects += tlvHex("9F4B", hex.EncodeToString(sdad))
+	}
+	return tlvHex("77", objects) + "9000"
+}
+
+func k3Config(t *testing.T) Kernel3Config {
+	t.Helper()
+	return Kernel3Config{
+		Data:             TerminalData{"5F2A": tlv.Hex("0978"), "9F1A": tlv.Hex("0250")},
+		TTQ:              TTQ{0x36, 0x00, 0x40, 0x00},
+		FloorLimit:       5000,
+		TransactionLimit: 50000,
+		CVMRequiredLimit: 10000,
+		CAKeys:           loadTestPKI(t).caKeys(),
+	}
+}
+
+func newTestKernel3(t *testing.T, card iso7816.Transmitter) *Kernel3 {
+	return &Kernel3{
+		Client: iso7816.NewClient(card),
+		Config: k3Config(t),
+		Random: bytes.NewReader(tlv.Hex(txUN)),
+		Now:    testTransactionTime,
+	}
+}
+
+var k3Candidate = CombinationCandidate{ADFName: tlv.Hex(k3AID), Label: "VISA", KernelID: []byte{0x03}}
+
+func TestKernel3ConfigTransactionTTQ(t *testing.T) {
+	config := Kernel3Config{TTQ: TTQ{0x36, 0xC0, 0x40, 0x00}, FloorLimit: 5000, CVMRequiredLimit: 10000}
+
+	tests := []struct {
+		name   string
+		amount uint64
+		want   TTQ
+	}{
+		{name: "Below the floor limit", amount: 1000, want: TTQ{0x36, 0x00, 0x40, 0x00}},
+		{name: "Above the floor limit", amount: 5001, want: TTQ{0x36, 0x80, 0x40, 0x00}},
+		{name: "CVM Required Limit", amount: 10000, want: TTQ{0x36, 0xC0, 0x40, 0x00}},
+		{name: "Zero amount", amount: 0, want: TTQ{0x36, 0x80, 0x40, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, config.TransactionTTQ(tt.amount)); diff != "" {
+				t.Errorf("TransactionTTQ() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	config.TTQ[0] |= 0x08
+	if got := config.TransactionTTQ(0); got.OnlineCryptogramRequired() {
+		t.Error("TransactionTTQ(0) requests an online cryptogram from an offline-only reader")
+	}
+}
+
+func TestKernel3Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		expiry   string
+		amount   uint64
+		txType   TransactionType
+		ttq      string
+		gpo      func(t *testing.T) string
+		outcome  TransactionOutcome
+		cvm      OutcomeCVM
+		fddaPass *bool
+	}{
+		{
+			name:     "approved offline with fDDA",
+			amount:   1000,
+			ttq:      k3TTQ,
+			gpo:      func(t *testing.T) string { return k3Response(t, k3IADTC, "0000", txUN) },
+			outcome:  OutcomeApproved,
+			cvm:      OutcomeNoCVM,
+			fddaPass: boolPtr(true),
+		},
+		{
+			name:   "CID returned by the card",
+			amount: 1000,
+			ttq:    k3TTQ,
+			gpo: func(t *testing.T) string {
+				resp := k3Response(t, k3IADARQC, "0000", txUN)
+				return tlvHex("77", strings.TrimSuffix(resp, "9000")[6:], tlvHex("9F27", "40")) + "9000"
+			},
+			outcome:  OutcomeApproved,
+			cvm:      OutcomeNoCVM,
+			fddaPass: boolPtr(true),
+		},
+		{
+			name:    "online request on ARQC",
+			amount:  6000,
+			ttq:     "36804000",
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADARQC, "0000", "") },
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeNoCVM,
+		},
+		{
+			name:    "declined on AAC",
+			amount:  1000,
+			ttq:     k3TTQ,
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADAAC, "0000", "") },
+			outcome: OutcomeDeclined,
+		},
+		{
+			name:     "fDDA failure goes online",
+			amount:   1000,
+			ttq:      k3TTQ,
+			gpo:      func(t *testing.T) string { return k3Response(t, k3IADTC, "2000", "99999999") },
+			outcome:  OutcomeOnlineRequest,
+			cvm:      OutcomeNoCVM,
+			fddaPass: boolPtr(false),
+		},
+		{
+			name:     "fDDA failure switches interface",
+			amount:   1000,
+			ttq:      k3TTQ,
+			gpo:      func(t *testing.T) string { return k3Response(t, k3IADTC, "1000", "99999999") },
+			outcome:  OutcomeTryAnotherInterface,
+			fddaPass: boolPtr(false),
+		},
+		{
+			name:     "fDDA missing declines",
+			amount:   1000,
+			ttq:      k3TTQ,
+			gpo:      func(t *testing.T) string { return k3Response(t, k3IADTC, "0000", "") },
+			outcome:  OutcomeDeclined,
+			fddaPass: boolPtr(false),
+		},
+		{
+			name:    "expired application goes online",
+			expiry:  "260531",
+			amount:  1000,
+			ttq:     k3TTQ,
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADTC, "0800", txUN) },
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeNoCVM,
+		},
+		{
+			name:    "malformed expiration date goes online",
+			expiry:  "261331",
+			amount:  1000,
+			ttq:     k3TTQ,
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADTC, "0800", txUN) },
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeNoCVM,
+		},
+		{
+			name:    "online PIN requested by the card",
+			amount:  12000,
+			ttq:     "36C04000",
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADARQC, "8000", "") },
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeCVMOnlinePIN,
+		},
+		{
+			name:    "Consumer Device CVM",
+			amount:  12000,
+			ttq:     "36C04000",
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADARQC, "0080", "") },
+			outcome: OutcomeOnlineRequest,
+			cvm:     OutcomeCVMConfirmationCode,
+		},
+		{
+			name:     "signature on an offline approval",
+			amount:   1000,
+			ttq:      k3TTQ,
+			gpo:      func(t *testing.T) string { return k3Response(t, k3IADTC, "4000", txUN) },
+			outcome:  OutcomeApproved,
+			cvm:      OutcomeCVMSignature,
+			fddaPass: boolPtr(true),
+		},
+		{
+			name:     "online PIN declines an offline approval",
+			amount:   1000,
+			ttq:      k3TTQ,
+			gpo:      func(t *testing.T) string { return k3Response(t, k3IADTC, "8000", txUN) },
+			outcome:  OutcomeDeclined,
+			fddaPass: boolPtr(true),
+		},
+		{
+			name:    "cash switches interface",
+			amount:  1000,
+			txType:  TransactionCash,
+			ttq:     k3TTQ,
+			gpo:     func(t *testing.T) string { return k3Response(t, k3IADTC, "0400", txUN) },
+			outcome: OutcomeTryAnotherInterface,
+		},
+		{
+			name:    "transaction limit",
+			amount:  50000,
+			outcome: OutcomeTryAnotherInterface,
+		},
+		{
+			name:    "GPO asks to switch interface",
+			amount:  1000,
+			ttq:     k3TTQ,
+			gpo:     func(t *testing.T) string { return "6984" },
+			outcome: OutcomeTryAnotherInterface,
+		},
+		{
+			name:    "GPO asks to see phone",
+			amount:  1000,
+			ttq:     k3TTQ,
+			gpo:     func(t *testing.T) string { return "6986" },
+			outcome: OutcomeTryAgain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiry := tt.expiry
+			if expiry == "" {
+				expiry = "271231"
+			}
+			card := k3Card(t, expiry)
+			if tt.gpo != nil {
+				card.responses[k3GPO(tt.ttq, numericAmount(tt.amount))] = tt.gpo(t)
+			}
+
+			r, err := newTestKernel3(t, card).Run(k3Candidate, tt.amount, 0, tt.txType)
+			if err != nil {
+				t.Fatalf("Run() error = %v\n%s", err, r.Describe())
+			}
+			if r.Outcome != tt.outcome || r.CVMOutcome != tt.cvm {
+				t.Errorf("Run() = %s / %q, want %s / %q\n%s", r.Outcome, r.CVMOutcome, tt.outcome, tt.cvm, r.Describe())
+			}
+			if tt.fddaPass != nil && (r.ODA == nil || r.ODA.Passed() != *tt.fddaPass) {
+				t.Errorf("fDDA passed = %v, want %t\n%s", r.ODA, *tt.fddaPass, r.Describe())
+			}
+		})
+	}
+}
+
+func TestKernel3Run_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		gpo     string
+		wantErr string
+	}{
+		{name: "Format 1 response", gpo: tlvHex("80", k3AIP, "08010301") + "9000", wantErr: "format 2 response"},
+		{name: "Missing cryptogram", gpo: tlvHex("77", tlvHex("82", k3AIP), tlvHex("94", "08010301")) + "9000", wantErr: "Application Cryptogram"},
+		{name: "Malformed CTQ", gpo: tlvHex("77", tlvHex("82", k3AIP), tlvHex("94", "08010301"), tlvHex("9F6C", "00")) + "9000", wantErr: "CTQ must be 2 bytes long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := k3Card(t, "271231")
+			card.responses[k3GPO(k3TTQ, "000000001000")] = tt.gpo
+
+			r, err := newTestKernel3(t, card).Run(k3Candidate, 1000, 0, TransactionPurchase)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+			if r.Outcome != OutcomeEndApplication {
+				t.Errorf("Outcome = %s, want %s", r.Outcome, OutcomeEndApplication)
+			}
+		})
+	}
+}
+
+func TestKernel3Result_Describe(t *testing.T) {
+	card := k3Card(t, "271231")
+	card.responses[k3GPO(k3TTQ, "000000001000")] = k3Response(t, k3IADTC, "4000", txUN)
+
+	r, err := newTestKernel3(t, card).Run(k3Candidate, 1000, 0, TransactionPurchase)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	out := r.Describe()
+	for _, want := range []string{
+		"=== EMV CONTACTLESS KERNEL 3 ===",
+		"Application: A0000000031010 (VISA)",
+		"TTQ (9F66) 36004000: Consumer Device CVM supported",
+		"CTQ (9F6C) 4000: Signature required",
+		"FFI (9F6E) 20700000: Standard card",
+		"fDDA successful",
+		"Status: Approved",
+		"CVM: Obtain Signature",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Describe() does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// numericAmount encodes an amount in format n 12.
+func numericAmount(amount uint64) string {
+	return strings.ToUpper(hex.EncodeToString(numericBytes(amount, 6)))
+}
@@ -0,0 +1,457 @@
+package emv
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// OFFLINE DATA AUTHENTICATION (ODA) Logic according to EMV Book 2.
+// ODA relies on a chain of RSA public keys:
+//
+//   Certification Authority (CA) key, known by the terminal (RID + index '8F')
+//    └── Issuer public key, certified by the CA (Tags '90', '92', '9F32')
+//         └── ICC public key, certified by the issuer (Tags '9F46', '9F48', '9F47'), DDA/CDA only
+//
+// EMV signatures use "RSA with message recovery" (ISO/IEC 9796-2): the verifier
+// recovers the signed data with the public key, then checks its header ('6A'),
+// trailer ('BC') and SHA-1 hash.
+
+// RSAPublicKey is an RSA public key as found in EMV data.
+type RSAPublicKey struct {
+	Modulus  []byte
+	Exponent []byte
+}
+
+// recover applies the public key to a signature (m = s^e mod n).
+func (k RSAPublicKey) recover(signature []byte) ([]byte, error) {
+	return k.apply(signature, "signature")
+}
+
+// encipher applies the public key to a message (c = m^e mod n).
+func (k RSAPublicKey) encipher(message []byte) ([]byte, error) {
+	return k.apply(message, "message")
+}
+
+// apply performs the RSA public key operation on input of the modulus length.
+func (k RSAPublicKey) apply(input []byte, name string) ([]byte, error) {
+	if len(k.Modulus) == 0 {
+		return nil, fmt.Errorf("empty modulus")
+	}
+	if len(input) != len(k.Modulus) {
+		return nil, fmt.Errorf("%s length %d does not match the key length %d", name, len(input), len(k.Modulus))
+	}
+
+	n := new(big.Int).SetBytes(k.Modulus)
+	x := new(big.Int).SetBytes(input)
+	if x.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("%s is not lower than the modulus", name)
+	}
+
+	y := new(big.Int).Exp(x, new(big.Int).SetBytes(k.Exponent), n)
+	return y.FillBytes(make([]byte, len(k.Modulus))), nil
+}
+
+// CAPublicKey is a Certification Authority public key loaded in the terminal.
+type CAPublicKey struct {
+	RID   []byte // Registered Application Provider Identifier (5 bytes)
+	Index byte   // Certification Authority Public Key Index (Tag '8F')
+	RSAPublicKey
+}
+
+// CAKeyStore lists the Certification Authority public keys known by the terminal.
+type CAKeyStore []CAPublicKey
+
+// Find returns the key identified by a RID and an index.
+func (s CAKeyStore) Find(rid []byte, index byte) (CAPublicKey, bool) {
+	for _, k := range s {
+		if k.Index == index && bytes.Equal(k.RID, rid) {
+			return k, true
+		}
+	}
+	return CAPublicKey{}, false
+}
+
+// ODAInput gathers the data needed by offline data authentication.
+type ODAInput struct {
+	AID    []byte // DF Name of the selected application, its first 5 bytes are the RID
+	AIP    AIP
+	Data   *ApplicationData
+	CAKeys CAKeyStore
+
+	// Now is the current date for the expiry checks. Zero means time.Now().
+	Now time.Time
+}
+
+func (in ODAInput) now() time.Time {
+	if in.Now.IsZero() {
+		return time.Now()
+	}
+	return in.Now
+}
+
+// lookup returns a data object read from the card, or nil when absent.
+func (in ODAInput) lookup(tag string) []byte {
+	if in.Data == nil {
+		return nil
+	}
+	value, _ := in.Data.Lookup(tag)
+	return value
+}
+
+// StaticDataToAuthenticate builds the static data signed by the issuer: the records
+// flagged by the AFL followed, when the Static Data Authentication Tag List ('9F4A')
+// is present, by the value of the AIP (the only tag allowed in the list).
+func (in ODAInput) StaticDataToAuthenticate() ([]byte, error) {
+	data := []byte{}
+	if in.Data != nil {
+		data = append(data, in.Data.OfflineAuthenticationData...)
+	}
+
+	tagList := in.lookup("9F4A")
+	if len(tagList) == 0 {
+		return data, nil
+	}
+	if !bytes.Equal(tagList, []byte{0x82}) {
+		return nil, fmt.Errorf("Static Data Authentication Tag List must only contain '82' (got %X)", tagList)
+	}
+	return append(data, in.AIP[:]...), nil
+}
+
+// AuthenticationStep is a single check of the offline data authentication.
+type AuthenticationStep struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// AuthenticationResult is the detailed outcome of an offline data authentication, or of the
+// recovery of the PIN encipherment key.
+// Checks stop at the first failure.
+type AuthenticationResult struct {
+	Method string // SDA, DDA, fDDA, CDA or PIN Encipherment Key
+	Steps  []AuthenticationStep
+
+	// DataMissing reports that a data object required by the method was not provided by the card.
+	DataMissing bool
+
+	// Recovered data, kept even when a later check fails.
+	IssuerCertificate *PublicKeyCertificate
+	ICCCertificate    *PublicKeyCertificate
+	PINCertificate    *PublicKeyCertificate // ICC PIN Encipherment Public Key Certificate
+	DynamicData       *SignedDynamicData    // DDA, fDDA and CDA
+
+	IssuerPublicKey        *RSAPublicKey
+	ICCPublicKey           *RSAPublicKey
+	PINPublicKey           *RSAPublicKey // Offline enciphered PIN only
+	DataAuthenticationCode []byte        // SDA only
+}
+
+// Passed reports whether every check succeeded.
+func (r *AuthenticationResult) Passed() bool {
+	if len(r.Steps) == 0 {
+		return false
+	}
+	for _, s := range r.Steps {
+		if !s.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// check records a step and returns its outcome.
+func (r *AuthenticationResult) check(name string, passed bool, format string, args ...interface{}) bool {
+	r.Steps = append(r.Steps, AuthenticationStep{Name: name, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+	return passed
+}
+
+// require checks that the card provided the data objects needed by the method.
+func (r *AuthenticationResult) require(in ODAInput, tags ...string) bool {
+	var missing []string
+	for _, tag := range tags {
+		if len(in.lookup(tag)) == 0 {
+			missing = append(missing, tag)
+		}
+	}
+	r.DataMissing = len(missing) > 0
+	if r.DataMissing {
+		return r.check("Required Data", false, "missing %s", strings.Join(missing, ", "))
+	}
+	return r.check("Required Data", true, "%s present", strings.Join(tags, ", "))
+}
+
+// Report builds the step-by-step report of the authentication.
+func (r *AuthenticationResult) Report() *report.Report {
+	rep := report.New("EMV OFFLINE DATA AUTHENTICATION")
+
+	steps := rep.AddSection(fmt.Sprintf("[1] Method: %s", r.Method))
+	for _, s := range r.Steps {
+		state := "[OK]"
+		if !s.Passed {
+			state = "[!!]"
+		}
+		steps.Detail(s.Name, fmt.Sprintf("%s %s", state, s.Detail))
+	}
+
+	index := 2
+	if r.IssuerCertificate != nil {
+		r.IssuerCertificate.describe(rep.AddSection(fmt.Sprintf("[%d] Issuer Public Key Certificate:", index)), "Issuer Identifier")
+		index++
+	}
+	if r.ICCCertificate != nil {
+		r.ICCCertificate.describe(rep.AddSection(fmt.Sprintf("[%d] ICC Public Key Certificate:", index)), "Application PAN")
+		index++
+	}
+	if r.PINCertificate != nil {
+		r.PINCertificate.describe(rep.AddSection(fmt.Sprintf("[%d] ICC PIN Encipherment Public Key Certificate:", index)), "Application PAN")
+		index++
+	}
+	if r.DynamicData != nil {
+		r.DynamicData.describe(rep.AddSection(fmt.Sprintf("[%d] Signed Dynamic Application Data:", index)))
+	}
+
+	r.describeOutcome(rep.AddSection("[=] OUTCOME:"))
+	return rep
+}
+
+func (r *AuthenticationResult) describeOutcome(outcome *report.Section) {
+	if r.Passed() {
+		outcome.Note("", fmt.Sprintf("%s succeeded", r.Method))
+	} else {
+		outcome.Note("", fmt.Sprintf("%s failed", r.Method))
+	}
+	if r.IssuerPublicKey != nil {
+		outcome.Note("Issuer Public Key", fmt.Sprintf("%d bits", len(r.IssuerPublicKey.Modulus)*8))
+	}
+	if r.ICCPublicKey != nil {
+		outcome.Note("ICC Public Key", fmt.Sprintf("%d bits", len(r.ICCPublicKey.Modulus)*8))
+	}
+	if r.PINPublicKey != nil {
+		outcome.Note("PIN Encipherment Public Key", fmt.Sprintf("%d bits", len(r.PINPublicKey.Modulus)*8))
+	}
+	if len(r.DataAuthenticationCode) > 0 {
+		outcome.Note("Data Authentication Code", fmt.Sprintf("%X", r.DataAuthenticationCode))
+	}
+}
+
+// Describe generates the step-by-step report of the authentication.
+func (r *AuthenticationResult) Describe() string {
+	return report.Text(r.Report())
+}
+
+// PublicKeyCertificate holds the fields recovered from an issuer or ICC public key
+// certificate (EMV Book 2, Tables 6 and 14):
+//
+//	6A | Format (1) | Identifier (4 or 10) | Expiry MMYY (2) | Serial (3) | Hash Algo (1) |
+//	PK Algo (1) | PK Length (1) | PK Exponent Length (1) | PK or leftmost digits | Hash (20) | BC
+type PublicKeyCertificate struct {
+	Format             byte   // '02' for the issuer, '04' for the ICC
+	Identifier         []byte // Issuer Identifier (4 bytes) or Application PAN (10 bytes)
+	ExpirationDate     []byte // MMYY
+	SerialNumber       []byte
+	HashAlgorithm      byte
+	PublicKeyAlgorithm byte
+	PublicKeyLength    int
+	ExponentLength     int
+	PublicKey          []byte // Public key, or its leftmost digits when a remainder is needed
+	Hash               []byte
+}
+
+func (c *PublicKeyCertificate) describe(section *report.Section, identifier string) {
+	section.Note("Format", fmt.Sprintf("%02X", c.Format))
+	section.Note(identifier, strings.TrimRight(fmt.Sprintf("%X", c.Identifier), "F"))
+	section.Note("Expiration Date", fmt.Sprintf("%02X/%02X", c.ExpirationDate[0], c.ExpirationDate[1]))
+	section.Note("Serial Number", fmt.Sprintf("%X", c.SerialNumber))
+	section.Note("Hash Algorithm", fmt.Sprintf("%02X", c.HashAlgorithm))
+	section.Note("Public Key Algorithm", fmt.Sprintf("%02X", c.PublicKeyAlgorithm))
+	section.Note("Public Key Length", fmt.Sprintf("%d bytes", c.PublicKeyLength))
+	section.Note("Exponent Length", fmt.Sprintf("%d bytes", c.ExponentLength))
+	section.Note("Public Key", fmt.Sprintf("%X", c.PublicKey))
+	section.Note("Hash", fmt.Sprintf("%X", c.Hash))
+}
+
+// certificateSpec describes one of the two kinds of public key certificates.
+type certificateSpec struct {
+	owner            string // Issuer or ICC
+	format           byte
+	identifierLength int
+}
+
+var (
+	issuerCertificateSpec = certificateSpec{owner: "Issuer", format: 0x02, identifierLength: 4}
+	iccCertificateSpec    = certificateSpec{owner: "ICC", format: 0x04, identifierLength: 10}
+)
+
+// recoverCertificate recovers a public key certificate, checks its frame and verifies its hash,
+// computed over the recovered fields, the remainder, the exponent and extra data (the static
+// data to be authenticated for ICC certificates). The certificate is returned as soon as
+// it could be decoded, even when its hash is wrong.
+func recoverCertificate(r *AuthenticationResult, spec certificateSpec, key RSAPublicKey, data, remainder, exponent, extra []byte) (*PublicKeyCertificate, bool) {
+	name := spec.owner + " Certificate"
+	recovered, err := key.recover(data)
+	if !r.check(name+" Recovery", err == nil, "%d bytes%s", len(data), errorSuffix(err)) {
+		return nil, false
+	}
+
+	id := spec.identifierLength
+	minimum := id + 32
+	if !r.check(name+" Length", len(recovered) >= minimum, "%d bytes (at least %d)", len(recovered), minimum) {
+		return nil, false
+	}
+	if !checkFrame(r, name, recovered, spec.format) {
+		return nil, false
+	}
+
+	n := len(recovered)
+	cert := &PublicKeyCertificate{
+		Format:             recovered[1],
+		Identifier:         recovered[2 : 2+id],
+		ExpirationDate:     recovered[2+id : 4+id],
+		SerialNumber:       recovered[4+id : 7+id],
+		HashAlgorithm:      recovered[7+id],
+		PublicKeyAlgorithm: recovered[8+id],
+		PublicKeyLength:    int(recovered[9+id]),
+		ExponentLength:     int(recovered[10+id]),
+		PublicKey:          recovered[11+id : n-21],
+		Hash:               recovered[n-21 : n-1],
+	}
+
+	hashInput := concat(recovered[1:n-21], remainder, exponent, extra)
+	return cert, checkHash(r, name+" Hash", hashInput, cert.Hash)
+}
+
+// certifiedKey checks the validity of a recovered certificate and builds the public key
+// from its leftmost digits and the remainder.
+func certifiedKey(r *AuthenticationResult, spec certificateSpec, cert *PublicKeyCertificate, remainder, exponent []byte, now time.Time) (*RSAPublicKey, bool) {
+	if !checkExpiry(r, spec.owner+" Certificate Expiry", cert.ExpirationDate, now) {
+		return nil, false
+	}
+	if !r.check(spec.owner+" Key Algorithm", cert.PublicKeyAlgorithm == 0x01, "%02X (RSA)", cert.PublicKeyAlgorithm) {
+		return nil, false
+	}
+
+	modulus := append([]byte{}, cert.PublicKey...)
+	if cert.PublicKeyLength <= len(modulus) {
+		modulus = modulus[:cert.PublicKeyLength]
+	} else {
+		modulus = append(modulus, remainder...)
+	}
+	valid := len(modulus) == cert.PublicKeyLength && len(exponent) == cert.ExponentLength
+	if !r.check(spec.owner+" Public Key", valid, "%d/%d bytes expected, %d/%d built",
+		cert.PublicKeyLength, cert.ExponentLength, len(modulus), len(exponent)) {
+		return nil, false
+	}
+
+	return &RSAPublicKey{Modulus: modulus, Exponent: exponent}, true
+}
+
+// recoverIssuerKey retrieves the issuer public key with the CA public key (EMV Book 2, section 5.3).
+// Tags '8F', '90' and '9F32' must have been checked by the caller.
+func recoverIssuerKey(in ODAInput, r *AuthenticationResult) bool {
+	if len(in.AID) < 5 {
+		return r.check("CA Public Key", false, "AID %X is too short to hold a RID", in.AID)
+	}
+	rid, index := in.AID[:5], in.lookup("8F")[0]
+	caKey, found := in.CAKeys.Find(rid, index)
+	if !r.check("CA Public Key", found, "RID %X, index %02X", rid, index) {
+		return false
+	}
+
+	remainder, exponent := in.lookup("92"), in.lookup("9F32")
+	cert, ok := recoverCertificate(r, issuerCertificateSpec, caKey.RSAPublicKey, in.lookup("90"), remainder, exponent, nil)
+	r.IssuerCertificate = cert
+	if !ok {
+		return false
+	}
+
+	if !checkIssuerIdentifier(r, cert.Identifier, in.lookup("5A")) {
+		return false
+	}
+
+	r.IssuerPublicKey, ok = certifiedKey(r, issuerCertificateSpec, cert, remainder, exponent, in.now())
+	return ok
+}
+
+// recoverICCKey retrieves the ICC public key with the issuer public key (EMV Book 2, section 6.4).
+// The ICC certificate also signs the static data to be authenticated.
+// Tags '9F46' and '9F47' must have been checked by the caller.
+func recoverICCKey(in ODAInput, r *AuthenticationResult) bool {
+	staticData, err := in.StaticDataToAuthenticate()
+	if !r.check("Static Data", err == nil, "%d bytes%s", len(staticData), errorSuffix(err)) {
+		return false
+	}
+
+	remainder, exponent := in.lookup("9F48"), in.lookup("9F47")
+	cert, ok := recoverCertificate(r, iccCertificateSpec, *r.IssuerPublicKey, in.lookup("9F46"), remainder, exponent, staticData)
+	r.ICCCertificate = cert
+	if !ok {
+		return false
+	}
+
+	certPAN := strings.TrimRight(fmt.Sprintf("%X", cert.Identifier), "F")
+	cardPAN := strings.TrimRight(fmt.Sprintf("%X", in.lookup("5A")), "F")
+	if !r.check("Application PAN", certPAN == cardPAN, "%s (card: %s)", certPAN, cardPAN) {
+		return false
+	}
+
+	r.ICCPublicKey, ok = certifiedKey(r, iccCertificateSpec, cert, remainder, exponent, in.now())
+	return ok
+}
+
+// checkFrame verifies the trailer, the header and the format of recovered data (never empty).
+func checkFrame(r *AuthenticationResult, name string, recovered []byte, format byte) bool {
+	n := len(recovered)
+	if !r.check(name+" Trailer", recovered[n-1] == 0xBC, "%02X (expected BC)", recovered[n-1]) {
+		return false
+	}
+	if !r.check(name+" Header", recovered[0] == 0x6A, "%02X (expected 6A)", recovered[0]) {
+		return false
+	}
+	return r.check(name+" Format", recovered[1] == format, "%02X (expected %02X)", recovered[1], format)
+}
+
+// checkHash compares the SHA-1 hash of the input with the recovered hash.
+func checkHash(r *AuthenticationResult, name string, input, expected []byte) bool {
+	sum := sha1.Sum(input)
+	return r.check(name, bytes.Equal(sum[:], expected), "SHA-1 %X", sum)
+}
+
+// checkIssuerIdentifier verifies that the issuer identifier (cn, padded with 'F') matches
+// the leftmost digits of the PAN.
+func checkIssuerIdentifier(r *AuthenticationResult, issuerID, pan []byte) bool {
+	id := strings.TrimRight(fmt.Sprintf("%X", issuerID), "F")
+	digits := fmt.Sprintf("%X", pan)
+	return r.check("Issuer Identifier", len(id) >= 3 && strings.HasPrefix(digits, id), "%s matches PAN %s", id, strings.TrimRight(digits, "F"))
+}
+
+// checkExpiry verifies that a MMYY date (n 4) is not in the past.
+func checkExpiry(r *AuthenticationResult, name string, mmyy []byte, now time.Time) bool {
+	month := int(mmyy[0]>>4)*10 + int(mmyy[0]&0x0F)
+	year := 2000 + int(mmyy[1]>>4)*10 + int(mmyy[1]&0x0F)
+	// The certificate is valid until the last day of its expiry month.
+	expiry := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC)
+
+	return r.check(name, month >= 1 && month <= 12 && now.Before(expiry), "%02d/%d", month, year)
+}
+
+// concat joins byte slices into a new slice.
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func errorSuffix(err error) string {
+	if err == nil {
+		return ""
+	}
+	return ": " + err.Error()
+}
@@ -0,0 +1,266 @@
+package emv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// testPKI is a CA key (1280 bits), an issuer key (1152 bits) and an ICC key (1024 bits)
+// generated once for the tests. The issuer and ICC moduli do not fit in their certificates,
+// so remainders (Tags '92' and '9F48') are needed.
+type testPKI struct {
+	ca     *rsa.PrivateKey
+	issuer *rsa.PrivateKey
+	icc    *rsa.PrivateKey
+}
+
+var (
+	pkiOnce sync.Once
+	pki     testPKI
+	pkiErr  error
+)
+
+var testRID = tlv.Hex("A000000003")
+
+// testNow is the reference date of the expiry checks.
+var testNow = time.Date(2026, time.June, 15, 0, 0, 0, 0, time.UTC)
+
+func loadTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	pkiOnce.Do(func() {
+		if pki.ca, pkiErr = rsa.GenerateKey(rand.Reader, 1280); pkiErr != nil {
+			return
+		}
+		if pki.issuer, pkiErr = rsa.GenerateKey(rand.Reader, 1152); pkiErr != nil {
+			return
+		}
+		pki.icc, pkiErr = rsa.GenerateKey(rand.Reader, 1024)
+	})
+	if pkiErr != nil {
+		t.Fatalf("key generation failed: %v", pkiErr)
+	}
+	return pki
+}
+
+// sign applies an RSA private key to recoverable data (s = m^d mod n).
+func sign(key *rsa.PrivateKey, data []byte) []byte {
+	m := new(big.Int).SetBytes(data)
+	s := new(big.Int).Exp(m, key.D, key.N)
+	return s.FillBytes(make([]byte, key.Size()))
+}
+
+func exponentBytes(key *rsa.PrivateKey) []byte {
+	return big.NewInt(int64(key.E)).Bytes()
+}
+
+// caKeys returns the terminal key store holding the test CA key with index 92.
+func (p testPKI) caKeys() CAKeyStore {
+	return CAKeyStore{{
+		RID:          testRID,
+		Index:        0x92,
+		RSAPublicKey: RSAPublicKey{Modulus: p.ca.N.Bytes(), Exponent: exponentBytes(p.ca)},
+	}}
+}
+
+// certificate signs a public key certificate with the signer key.
+func certificate(signer, subject *rsa.PrivateKey, format byte, identifier, expiry, extra []byte) (cert, remainder []byte) {
+	modulus := subject.N.Bytes()
+	exponent := exponentBytes(subject)
+	split := signer.Size() - 32 - len(identifier)
+
+	body := concat([]byte{format}, identifier, expiry, tlv.Hex("000001", "01", "01"))
+	body = append(body, byte(len(modulus)), byte(len(exponent)))
+	body = append(body, modulus[:split]...)
+	remainder = modulus[split:]
+
+	hash := sha1.Sum(concat(body, remainder, exponent, extra))
+	return sign(signer, concat([]byte{0x6A}, body, hash[:], []byte{0xBC})), remainder
+}
+
+// issuerCertificate builds the Issuer Public Key Certificate ('90') and Remainder ('92').
+func (p testPKI) issuerCertificate(issuerID, expiry string) (cert, remainder []byte) {
+	return certificate(p.ca, p.issuer, 0x02, tlv.Hex(issuerID), tlv.Hex(expiry), nil)
+}
+
+// iccCertificate builds the ICC Public Key Certificate ('9F46') and Remainder ('9F48').
+func (p testPKI) iccCertificate(pan, expiry string, staticData []byte) (cert, remainder []byte) {
+	return certificate(p.issuer, p.icc, 0x04, tlv.Hex(pan), tlv.Hex(expiry), staticData)
+}
+
+// signedStaticData builds the Signed Static Application Data ('93').
+func (p testPKI) signedStaticData(dac string, staticData []byte) []byte {
+	body := append([]byte{0x03, 0x01}, tlv.Hex(dac)...)
+	body = append(body, bytes.Repeat([]byte{0xBB}, p.issuer.Size()-26)...)
+
+	hash := sha1.Sum(concat(body, staticData))
+	return sign(p.issuer, concat([]byte{0x6A}, body, hash[:], []byte{0xBC}))
+}
+
+// signedDynamicData builds the Signed Dynamic Application Data ('9F4B').
+func (p testPKI) signedDynamicData(iccDynamicData, terminalData []byte) []byte {
+	body := concat([]byte{0x05, 0x01, byte(len(iccDynamicData))}, iccDynamicData)
+	body = append(body, bytes.Repeat([]byte{0xBB}, p.icc.Size()-25-len(iccDynamicData))...)
+
+	hash := sha1.Sum(concat(body, terminalData))
+	return sign(p.icc, concat([]byte{0x6A}, body, hash[:], []byte{0xBC}))
+}
+
+const testRecord = "7010 5A0847617390001000105F2403271231"
+
+// odaInput builds the data of a card supporting SDA and DDA, signed by the test PKI.
+// The modifier may alter the data before the verification.
+func odaInput(t *testing.T, modify func(data *ApplicationData)) ODAInput {
+	t.Helper()
+	p := loadTestPKI(t)
+
+	data := NewApplicationData()
+	if err := data.AddRecord(1, 1, tlv.Hex(testRecord), true); err != nil {
+		t.Fatalf("AddRecord failed: %v", err)
+	}
+	data.Set("9F4A", tlv.Hex("82"))
+
+	in := ODAInput{AID: tlv.Hex("A0000000031010"), AIP: AIP{0x61, 0x00}, Data: data, CAKeys: p.caKeys(), Now: testNow}
+	staticData, err := in.StaticDataToAuthenticate()
+	if err != nil {
+		t.Fatalf("StaticDataToAuthenticate failed: %v", err)
+	}
+
+	cert, remainder := p.issuerCertificate("476173FF", "1227")
+	data.Set("8F", tlv.Hex("92"))
+	data.Set("90", cert)
+	data.Set("92", remainder)
+	data.Set("9F32", exponentBytes(p.issuer))
+	data.Set("93", p.signedStaticData("DAC1", staticData))
+
+	cert, remainder = p.iccCertificate("4761739000100010FFFF", "0627", staticData)
+	data.Set("9F46", cert)
+	data.Set("9F47", exponentBytes(p.icc))
+	data.Set("9F48", remainder)
+
+	if modify != nil {
+		modify(data)
+	}
+	return in
+}
+
+// replace overwrites a data object of the store.
+func replace(data *ApplicationData, tag string, value []byte) {
+	data.objects[tag] = value
+}
+
+func TestCAKeyStoreFind(t *testing.T) {
+	store := CAKeyStore{
+		{RID: tlv.Hex("A000000003"), Index: 0x92},
+		{RID: tlv.Hex("A000000004"), Index: 0x05},
+	}
+
+	tests := []struct {
+		name  string
+		rid   []byte
+		index byte
+		found bool
+	}{
+		{"Visa 92", tlv.Hex("A000000003"), 0x92, true},
+		{"Mastercard 05", tlv.Hex("A000000004"), 0x05, true},
+		{"Wrong Index", tlv.Hex("A000000003"), 0x05, false},
+		{"Unknown RID", tlv.Hex("A000000025"), 0x92, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, found := store.Find(tt.rid, tt.index)
+			if found != tt.found {
+				t.Fatalf("Find() found = %v, want %v", found, tt.found)
+			}
+			if found && (key.Index != tt.index || !bytes.Equal(key.RID, tt.rid)) {
+				t.Errorf("Find() returned %X/%02X", key.RID, key.Index)
+			}
+		})
+	}
+}
+
+func TestStaticDataToAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		tagList  string
+		expected []byte
+		wantErr  bool
+	}{
+		{"Records Only", "", tlv.Hex("5A0847617390001000105F2403271231"), false},
+		{"With AIP", "82", tlv.Hex("5A0847617390001000105F2403271231", "6100"), false},
+		{"Other Tags", "8295", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := NewApplicationData()
+			if err := data.AddRecord(1, 1, tlv.Hex(testRecord), true); err != nil {
+				t.Fatalf("AddRecord failed: %v", err)
+			}
+			if tt.tagList != "" {
+				data.Set("9F4A", tlv.Hex(tt.tagList))
+			}
+
+			in := ODAInput{AIP: AIP{0x61, 0x00}, Data: data}
+			got, err := in.StaticDataToAuthenticate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StaticDataToAuthenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("Static data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRSAPublicKeyRecover(t *testing.T) {
+	p := loadTestPKI(t)
+	key := RSAPublicKey{Modulus: p.issuer.N.Bytes(), Exponent: exponentBytes(p.issuer)}
+
+	message := append([]byte{0x6A}, bytes.Repeat([]byte{0x42}, p.issuer.Size()-1)...)
+	got, err := key.recover(sign(p.issuer, message))
+	if err != nil {
+		t.Fatalf("recover failed: %v", err)
+	}
+	if diff := cmp.Diff(message, got); diff != "" {
+		t.Errorf("Recovered data mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := key.recover(message[1:]); err == nil {
+		t.Error("expected an error for a signature shorter than the modulus")
+	}
+	if _, err := key.recover(bytes.Repeat([]byte{0xFF}, p.issuer.Size())); err == nil {
+		t.Error("expected an error for a signature greater than the modulus")
+	}
+	if _, err := (RSAPublicKey{}).recover(nil); err == nil {
+		t.Error("expected an error for an empty modulus")
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	tests := []struct {
+		mmyy     string
+		expected bool
+	}{
+		{"1227", true},
+		{"0626", true}, // Valid until the end of the month
+		{"0526", false},
+		{"1325", false}, // Invalid month
+	}
+
+	for _, tt := range tests {
+		r := &AuthenticationResult{}
+		if got := checkExpiry(r, "Expiry", tlv.Hex(tt.mmyy), testNow); got != tt.expected {
+			t.Errorf("checkExpiry(%s) = %v, want %v", tt.mmyy, got, tt.expected)
+		}
+	}
+}
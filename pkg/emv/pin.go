@@ -0,0 +1,366 @@
+package emv
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// OFFLINE PIN VERIFICATION Logic according to EMV Book 3, sections 6.5.12 and 10.5.1,
+// and EMV Book 2, section 7.
+//
+// VERIFY (CLA '00', INS '20', P1 '00'): P2 qualifies the data:
+// - '80': plaintext PIN, the data is the PIN block.
+// - '88': enciphered PIN, the data is the PIN block enciphered with a card public key.
+//
+// The PIN block (8 bytes) is: C (4 bits, '2') | N (4 bits, PIN length 4-12) | PIN digits | 'F' filler.
+//
+// Enciphered PIN:
+// 1. The PIN encipherment key is the ICC PIN Encipherment Public Key ('9F2D', '9F2E', '9F2F'),
+//    certified by the issuer like the ICC public key but without static data, or else the
+//    ICC public key ('9F46', '9F47', '9F48').
+// 2. GET CHALLENGE (CLA '00', INS '84') returns an 8-byte ICC Unpredictable Number.
+// 3. The terminal enciphers, with RSA, data of the key length:
+//
+//	7F | PIN Block (8) | ICC Unpredictable Number (8) | Random Pad
+//
+// The card answers:
+// - '9000': PIN correct.
+// - '63CX': PIN wrong, X tries remaining. X = 0 means that the PIN Try Limit is exceeded.
+// - '6983' or '6984': PIN blocked.
+//
+// Before VERIFY, the terminal may read the PIN Try Counter ('9F17') with GET DATA: a
+// counter of zero means that the PIN is blocked, so no VERIFY is sent. A wrong PIN is
+// never retried automatically: the cardholder is prompted again only through a new call.
+
+// PINFormat is the P2 of the VERIFY command.
+type PINFormat byte
+
+const (
+	PINFormatPlaintext  PINFormat = 0x80
+	PINFormatEnciphered PINFormat = 0x88
+)
+
+// pinChallengeLength is the length of the ICC Unpredictable Number returned by GET CHALLENGE.
+const pinChallengeLength = 8
+
+// PINBlock formats a PIN of 4 to 12 digits as a plaintext PIN block.
+func PINBlock(pin string) ([]byte, error) {
+	if len(pin) < 4 || len(pin) > 12 {
+		return nil, fmt.Errorf("PIN must have 4 to 12 digits (got %d)", len(pin))
+	}
+
+	nibbles := append([]byte{0x2, byte(len(pin))}, make([]byte, 14)...)
+	for i := 2; i < len(nibbles); i++ {
+		nibbles[i] = 0xF
+	}
+	for i, c := range pin {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("PIN must only contain digits")
+		}
+		nibbles[2+i] = byte(c - '0')
+	}
+
+	block := make([]byte, 8)
+	for i := range block {
+		block[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return block, nil
+}
+
+// Verify creates a VERIFY command.
+func Verify(format PINFormat, data []byte) *iso7816.CommandAPDU {
+	ins, _ := iso7816.NewInstruction(iso7816.INS_VERIFY)
+	return iso7816.NewCommandAPDU(iso7816.Class{}, ins, 0x00, byte(format), data, 0)
+}
+
+// GetChallenge creates a GET CHALLENGE command.
+func GetChallenge() *iso7816.CommandAPDU {
+	ins, _ := iso7816.NewInstruction(iso7816.INS_GET_CHALLENGE)
+	return iso7816.NewCommandAPDU(iso7816.Class{}, ins, 0x00, 0x00, nil, iso7816.MaxShortLe)
+}
+
+// EncipherPIN enciphers a PIN block with the PIN encipherment key and the ICC Unpredictable
+// Number. The random pad is read from random, or from crypto/rand when random is nil.
+func EncipherPIN(key RSAPublicKey, pinBlock, challenge []byte, random io.Reader) ([]byte, error) {
+	if len(pinBlock) != 8 {
+		return nil, fmt.Errorf("PIN block must be 8 bytes long (got %d)", len(pinBlock))
+	}
+	if len(challenge) != pinChallengeLength {
+		return nil, fmt.Errorf("ICC Unpredictable Number must be %d bytes long (got %d)", pinChallengeLength, len(challenge))
+	}
+	padLength := len(key.Modulus) - 1 - len(pinBlock) - len(challenge)
+	if padLength < 0 {
+		return nil, fmt.Errorf("key length %d is too short", len(key.Modulus))
+	}
+
+	if random == nil {
+		random = rand.Reader
+	}
+	pad := make([]byte, padLength)
+	if _, err := io.ReadFull(random, pad); err != nil {
+		return nil, fmt.Errorf("random pad: %w", err)
+	}
+
+	data := concat([]byte{0x7F}, pinBlock, challenge, pad)
+	defer wipe(data)
+	return key.encipher(data)
+}
+
+// pinCertificateSpec describes the ICC PIN Encipherment Public Key Certificate, coded as the ICC one.
+var pinCertificateSpec = certificateSpec{owner: "ICC PIN Encipherment", format: 0x04, identifierLength: 10}
+
+// RecoverPINKey retrieves the key used for offline enciphered PIN: the ICC PIN Encipherment
+// Public Key when the card provides its certificate ('9F2D'), otherwise the ICC public key.
+// On success, the key is PINPublicKey.
+func RecoverPINKey(in ODAInput) *AuthenticationResult {
+	r := &AuthenticationResult{Method: "PIN Encipherment Key"}
+
+	if len(in.lookup("9F2D")) == 0 {
+		if authenticateICC(in, r) {
+			r.PINPublicKey = r.ICCPublicKey
+		}
+		return r
+	}
+
+	if !r.require(in, "8F", "90", "9F32", "9F2D", "9F2E") || !recoverIssuerKey(in, r) {
+		return r
+	}
+
+	remainder, exponent := in.lookup("9F2F"), in.lookup("9F2E")
+	cert, ok := recoverCertificate(r, pinCertificateSpec, *r.IssuerPublicKey, in.lookup("9F2D"), remainder, exponent, nil)
+	r.PINCertificate = cert
+	if !ok {
+		return r
+	}
+
+	certPAN := strings.TrimRight(fmt.Sprintf("%X", cert.Identifier), "F")
+	cardPAN := strings.TrimRight(fmt.Sprintf("%X", in.lookup("5A")), "F")
+	if !r.check("Application PAN", certPAN == cardPAN, "%s (card: %s)", certPAN, cardPAN) {
+		return r
+	}
+
+	r.PINPublicKey, _ = certifiedKey(r, pinCertificateSpec, cert, remainder, exponent, in.now())
+	return r
+}
+
+// PINVerification is the outcome of an offline PIN verification.
+type PINVerification struct {
+	Method  CVMethod
+	Outcome CVMOutcome
+
+	// TriesRemaining is the PIN Try Counter, -1 when unknown.
+	TriesRemaining int
+
+	Steps []string
+
+	// Trace keeps every exchange. The data of VERIFY commands is zeroed so that the
+	// trace never holds the PIN.
+	Trace iso7816.Trace
+}
+
+func (p *PINVerification) step(format string, args ...interface{}) {
+	p.Steps = append(p.Steps, fmt.Sprintf(format, args...))
+}
+
+// PINVerifier performs offline PIN verification with the card.
+type PINVerifier struct {
+	Client *iso7816.Client
+
+	// EnterPIN prompts the cardholder, showing the tries remaining (-1 when unknown).
+	// ok is false when the PIN was not entered.
+	EnterPIN func(triesRemaining int) (pin string, ok bool)
+
+	// Key is the PIN encipherment key (see RecoverPINKey), required for enciphered PIN.
+	Key *RSAPublicKey
+
+	// Random provides the random pad of enciphered PIN. Nil uses crypto/rand.
+	Random io.Reader
+
+	// Last is the most recent verification performed through Perform.
+	Last *PINVerification
+}
+
+// Perform implements CVMPerformer: PIN methods are verified with the card, the other
+// methods get their default outcome. A PIN method combined with signature remains
+// unknown after a successful PIN verification, as the signature is still required.
+func (v *PINVerifier) Perform(rule CVRule, tvr *TVR) CVMOutcome {
+	switch rule.Method {
+	case CVMPlaintextPIN, CVMEncipheredPIN, CVMPlaintextPINAndSignature, CVMEncipheredPINAndSignature:
+	default:
+		return defaultCVMOutcome(rule, tvr)
+	}
+
+	v.Last = v.Verify(rule.Method, tvr)
+	if v.Last.Outcome == CVMResultSuccessful &&
+		(rule.Method == CVMPlaintextPINAndSignature || rule.Method == CVMEncipheredPINAndSignature) {
+		return CVMResultUnknown
+	}
+	return v.Last.Outcome
+}
+
+// Verify performs one offline PIN verification and sets the related TVR bits.
+func (v *PINVerifier) Verify(method CVMethod, tvr *TVR) *PINVerification {
+	p := &PINVerification{Method: method, Outcome: CVMResultFailed, TriesRemaining: -1}
+
+	format := PINFormatPlaintext
+	if method == CVMEncipheredPIN || method == CVMEncipheredPINAndSignature {
+		format = PINFormatEnciphered
+		if v.Key == nil {
+			p.step("no PIN encipherment key, verification not performed")
+			return p
+		}
+	}
+
+	if v.readTryCounter(p) && p.TriesRemaining == 0 {
+		tvr.Set(TVRPINTryLimitExceeded)
+		p.step("PIN Try Limit exceeded, VERIFY not sent")
+		return p
+	}
+
+	var pin string
+	ok := false
+	if v.EnterPIN != nil {
+		pin, ok = v.EnterPIN(p.TriesRemaining)
+	}
+	if !ok {
+		tvr.Set(TVRPINNotEntered)
+		p.step("PIN not entered")
+		return p
+	}
+
+	data, ok := v.buildData(p, format, pin)
+	if !ok {
+		return p
+	}
+	defer wipe(data)
+
+	v.verify(p, format, data, tvr)
+	return p
+}
+
+// readTryCounter reads the PIN Try Counter and reports whether the card provided it.
+func (v *PINVerifier) readTryCounter(p *PINVerification) bool {
+	trace, err := v.Client.Send(GetData(0x9F17))
+	p.Trace = append(p.Trace, trace...)
+	if err != nil || !trace.IsSuccess() {
+		p.step("PIN Try Counter (9F17) unavailable")
+		return false
+	}
+
+	data := trace.Last().Response.Data
+	if len(data) != 4 || data[0] != 0x9F || data[1] != 0x17 || data[2] != 0x01 {
+		p.step("PIN Try Counter (9F17) malformed: %X", data)
+		return false
+	}
+	p.TriesRemaining = int(data[3])
+	p.step("PIN Try Counter: %d", p.TriesRemaining)
+	return true
+}
+
+// buildData builds the data of the VERIFY command.
+func (v *PINVerifier) buildData(p *PINVerification, format PINFormat, pin string) ([]byte, bool) {
+	block, err := PINBlock(pin)
+	if err != nil {
+		p.step("invalid PIN: %v", err)
+		return nil, false
+	}
+	if format == PINFormatPlaintext {
+		return block, true
+	}
+	defer wipe(block)
+
+	trace, err := v.Client.Send(GetChallenge())
+	p.Trace = append(p.Trace, trace...)
+	if err != nil || !trace.IsSuccess() {
+		p.step("GET CHALLENGE failed%s", traceFailure(trace, err))
+		return nil, false
+	}
+
+	enciphered, err := EncipherPIN(*v.Key, block, trace.Last().Response.Data, v.Random)
+	if err != nil {
+		p.step("PIN encipherment failed: %v", err)
+		return nil, false
+	}
+	return enciphered, true
+}
+
+// verify sends VERIFY and interprets the status of the card.
+func (v *PINVerifier) verify(p *PINVerification, format PINFormat, data []byte, tvr *TVR) {
+	trace, err := v.Client.Send(Verify(format, data))
+	for _, tx := range trace {
+		if tx.Command != nil && tx.Command.Instruction.Raw == iso7816.INS_VERIFY {
+			masked := *tx.Command
+			masked.Data = make([]byte, len(tx.Command.Data))
+			tx.Command = &masked
+		}
+		p.Trace = append(p.Trace, tx)
+	}
+	if err != nil {
+		p.step("VERIFY failed: %v", err)
+		return
+	}
+
+	sw := trace.Last().Response.Status
+	switch {
+	case sw.IsSuccess():
+		p.Outcome = CVMResultSuccessful
+		p.step("PIN correct")
+	case sw.IsCounter():
+		p.TriesRemaining = int(sw.SW2() & 0x0F)
+		p.step("PIN wrong, %d tries remaining", p.TriesRemaining)
+		if p.TriesRemaining == 0 {
+			tvr.Set(TVRPINTryLimitExceeded)
+		}
+	case sw == iso7816.SW_ERR_AUTH_METHOD_BLOCKED || sw == iso7816.SW_ERR_REF_DATA_NOT_USABLE:
+		p.TriesRemaining = 0
+		tvr.Set(TVRPINTryLimitExceeded)
+		p.step("PIN blocked (%04X)", uint16(sw))
+	default:
+		p.step("VERIFY rejected: %s", sw.Verbose())
+	}
+}
+
+func traceFailure(trace iso7816.Trace, err error) string {
+	if err != nil {
+		return ": " + err.Error()
+	}
+	if last := trace.Last(); last != nil && last.Response != nil {
+		return ": " + last.Response.Status.Verbose()
+	}
+	return ""
+}
+
+// wipe zeroes sensitive data once used.
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// Report builds the structured report of the PIN verification. It never shows the PIN.
+func (p *PINVerification) Report() *report.Report {
+	rep := report.New("EMV OFFLINE PIN VERIFICATION")
+
+	steps := rep.AddSection(fmt.Sprintf("[1] Method: %s", p.Method))
+	for _, s := range p.Steps {
+		steps.Note("", s)
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	outcome.Note("Result", p.Outcome.String())
+	if p.TriesRemaining >= 0 {
+		outcome.Note("Tries Remaining", fmt.Sprintf("%d", p.TriesRemaining))
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the PIN verification.
+func (p *PINVerification) Describe() string {
+	return report.Text(p.Report())
+}
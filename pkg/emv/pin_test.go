@@ -0,0 +1,322 @@
+package emv
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// pinCard simulates the PIN handling of a card: PIN Try Counter, GET CHALLENGE and VERIFY.
+// Enciphered PIN blocks are deciphered with key.
+type pinCard struct {
+	pin        []byte // Plaintext PIN block
+	tries      byte
+	challenge  []byte
+	key        *rsa.PrivateKey
+	noCounter  bool
+	verifyData [][]byte
+}
+
+func (c *pinCard) Transmit(cmd []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(cmd, tlv.Hex("80CA9F1700")):
+		if c.noCounter {
+			return tlv.Hex("6A88"), nil
+		}
+		return append(tlv.Hex("9F1701"), c.tries, 0x90, 0x00), nil
+	case bytes.Equal(cmd, tlv.Hex("0084000000")):
+		return append(append([]byte{}, c.challenge...), 0x90, 0x00), nil
+	case bytes.HasPrefix(cmd, tlv.Hex("002000")):
+		data := append([]byte{}, cmd[5:]...)
+		c.verifyData = append(c.verifyData, data)
+		return c.verify(cmd[3], data), nil
+	default:
+		return tlv.Hex("6D00"), nil
+	}
+}
+
+func (c *pinCard) verify(p2 byte, data []byte) []byte {
+	if c.tries == 0 {
+		return tlv.Hex("6983")
+	}
+
+	block := data
+	if p2 == 0x88 {
+		m := new(big.Int).Exp(new(big.Int).SetBytes(data), c.key.D, c.key.N)
+		plain := m.FillBytes(make([]byte, c.key.Size()))
+		if plain[0] != 0x7F || !bytes.Equal(plain[9:17], c.challenge) {
+			return tlv.Hex("6A80")
+		}
+		block = plain[1:9]
+	}
+
+	if !bytes.Equal(block, c.pin) {
+		c.tries--
+		return []byte{0x63, 0xC0 | c.tries}
+	}
+	return tlv.Hex("9000")
+}
+
+func TestPINBlock(t *testing.T) {
+	tests := []struct {
+		pin      string
+		expected []byte
+		wantErr  bool
+	}{
+		{pin: "1234", expected: tlv.Hex("241234FFFFFFFFFF")},
+		{pin: "123456789012", expected: tlv.Hex("2C123456789012FF")},
+		{pin: "12345", expected: tlv.Hex("2512345FFFFFFFFF")},
+		{pin: "123", wantErr: true},
+		{pin: "1234567890123", wantErr: true},
+		{pin: "12A4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pin, func(t *testing.T) {
+			block, err := PINBlock(tt.pin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PINBlock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, block); diff != "" {
+				t.Errorf("PIN block mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPINCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      *iso7816.CommandAPDU
+		expected []byte
+	}{
+		{"VERIFY Plaintext", Verify(PINFormatPlaintext, tlv.Hex("241234FFFFFFFFFF")), tlv.Hex("00 20 00 80 08 241234FFFFFFFFFF")},
+		{"VERIFY Enciphered", Verify(PINFormatEnciphered, tlv.Hex("0102")), tlv.Hex("00 20 00 88 02 0102")},
+		{"GET CHALLENGE", GetChallenge(), tlv.Hex("00 84 00 00 00")},
+		{"GET DATA PIN Try Counter", GetData(0x9F17), tlv.Hex("80 CA 9F 17 00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("Command mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEncipherPIN(t *testing.T) {
+	p := loadTestPKI(t)
+	key := RSAPublicKey{Modulus: p.icc.N.Bytes(), Exponent: exponentBytes(p.icc)}
+	block := tlv.Hex("241234FFFFFFFFFF")
+	challenge := tlv.Hex("0102030405060708")
+
+	enciphered, err := EncipherPIN(key, block, challenge, bytes.NewReader(bytes.Repeat([]byte{0xAA}, 256)))
+	if err != nil {
+		t.Fatalf("EncipherPIN failed: %v", err)
+	}
+
+	plain := sign(p.icc, enciphered) // Deciphering is the private key operation
+	expected := concat(tlv.Hex("7F"), block, challenge, bytes.Repeat([]byte{0xAA}, p.icc.Size()-17))
+	if diff := cmp.Diff(expected, plain); diff != "" {
+		t.Errorf("Deciphered data mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := EncipherPIN(key, block, tlv.Hex("0102"), nil); err == nil {
+		t.Error("EncipherPIN should reject a short challenge")
+	}
+	if _, err := EncipherPIN(RSAPublicKey{Modulus: tlv.Hex("FFFF"), Exponent: tlv.Hex("03")}, block, challenge, nil); err == nil {
+		t.Error("EncipherPIN should reject a short key")
+	}
+}
+
+func TestRecoverPINKey(t *testing.T) {
+	p := loadTestPKI(t)
+	pinCertificate := func(data *ApplicationData) {
+		cert, remainder := certificate(p.issuer, p.icc, 0x04, tlv.Hex("4761739000100010FFFF"), tlv.Hex("0627"), nil)
+		data.Set("9F2D", cert)
+		data.Set("9F2E", exponentBytes(p.icc))
+		data.Set("9F2F", remainder)
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(data *ApplicationData)
+		passed     bool
+		pinCert    bool
+		failedStep string
+	}{
+		{name: "ICC Public Key", passed: true},
+		{name: "PIN Encipherment Key", modify: pinCertificate, passed: true, pinCert: true},
+		{
+			name: "PIN Certificate Wrong PAN",
+			modify: func(data *ApplicationData) {
+				pinCertificate(data)
+				cert, _ := certificate(p.issuer, p.icc, 0x04, tlv.Hex("4761739000100011FFFF"), tlv.Hex("0627"), nil)
+				replace(data, "9F2D", cert)
+			},
+			pinCert:    true,
+			failedStep: "Application PAN",
+		},
+		{
+			name: "PIN Certificate Missing Exponent",
+			modify: func(data *ApplicationData) {
+				pinCertificate(data)
+				replace(data, "9F2E", nil)
+			},
+			failedStep: "Required Data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RecoverPINKey(odaInput(t, tt.modify))
+			if r.Passed() != tt.passed {
+				t.Fatalf("Passed() = %v, want %v\n%s", r.Passed(), tt.passed, r.Describe())
+			}
+			if (r.PINCertificate != nil) != tt.pinCert {
+				t.Errorf("PINCertificate present = %v, want %v", r.PINCertificate != nil, tt.pinCert)
+			}
+			if tt.passed {
+				if r.PINPublicKey == nil || !bytes.Equal(r.PINPublicKey.Modulus, p.icc.N.Bytes()) {
+					t.Errorf("PINPublicKey is not the expected key")
+				}
+				return
+			}
+			if last := r.Steps[len(r.Steps)-1]; last.Name != tt.failedStep {
+				t.Errorf("failed at %q (%s), want %q", last.Name, last.Detail, tt.failedStep)
+			}
+		})
+	}
+}
+
+func TestPINVerifier(t *testing.T) {
+	p := loadTestPKI(t)
+	key := &RSAPublicKey{Modulus: p.icc.N.Bytes(), Exponent: exponentBytes(p.icc)}
+	pinBlock := tlv.Hex("241234FFFFFFFFFF")
+	enter := func(pin string) func(int) (string, bool) {
+		return func(int) (string, bool) { return pin, true }
+	}
+
+	tests := []struct {
+		name     string
+		card     *pinCard
+		method   CVMethod
+		enterPIN func(int) (string, bool)
+		key      *RSAPublicKey
+		outcome  CVMOutcome
+		tries    int
+		tvr      TVR
+		verifies int
+	}{
+		{
+			name: "Plaintext Correct", card: &pinCard{tries: 3}, method: CVMPlaintextPIN,
+			enterPIN: enter("1234"), outcome: CVMResultSuccessful, tries: 3, verifies: 1,
+		},
+		{
+			name: "Plaintext Wrong", card: &pinCard{tries: 3}, method: CVMPlaintextPIN,
+			enterPIN: enter("4321"), outcome: CVMResultFailed, tries: 2, verifies: 1,
+		},
+		{
+			name: "Last Try Wrong", card: &pinCard{tries: 1}, method: CVMPlaintextPIN,
+			enterPIN: enter("4321"), outcome: CVMResultFailed, tries: 0, tvr: TVR{0x00, 0x00, 0x20}, verifies: 1,
+		},
+		{
+			name: "Counter Exhausted", card: &pinCard{tries: 0}, method: CVMPlaintextPIN,
+			enterPIN: enter("1234"), outcome: CVMResultFailed, tries: 0, tvr: TVR{0x00, 0x00, 0x20},
+		},
+		{
+			name: "Blocked Without Counter", card: &pinCard{tries: 0, noCounter: true}, method: CVMPlaintextPIN,
+			enterPIN: enter("1234"), outcome: CVMResultFailed, tries: 0, tvr: TVR{0x00, 0x00, 0x20}, verifies: 1,
+		},
+		{
+			name: "PIN Not Entered", card: &pinCard{tries: 3}, method: CVMPlaintextPIN,
+			enterPIN: func(int) (string, bool) { return "", false }, outcome: CVMResultFailed, tries: 3, tvr: TVR{0x00, 0x00, 0x08},
+		},
+		{
+			name: "Enciphered Correct", card: &pinCard{tries: 3, challenge: tlv.Hex("0102030405060708"), key: p.icc},
+			method: CVMEncipheredPIN, enterPIN: enter("1234"), key: key, outcome: CVMResultSuccessful, tries: 3, verifies: 1,
+		},
+		{
+			name: "Enciphered Wrong", card: &pinCard{tries: 3, challenge: tlv.Hex("0102030405060708"), key: p.icc},
+			method: CVMEncipheredPIN, enterPIN: enter("9999"), key: key, outcome: CVMResultFailed, tries: 2, verifies: 1,
+		},
+		{
+			name: "Enciphered Without Key", card: &pinCard{tries: 3}, method: CVMEncipheredPIN,
+			enterPIN: enter("1234"), outcome: CVMResultFailed, tries: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.card.pin = pinBlock
+			v := &PINVerifier{Client: iso7816.NewClient(tt.card), EnterPIN: tt.enterPIN, Key: tt.key}
+
+			var tvr TVR
+			result := v.Verify(tt.method, &tvr)
+			if result.Outcome != tt.outcome || result.TriesRemaining != tt.tries {
+				t.Errorf("Verify() = %s, %d tries, want %s, %d tries\n%s",
+					result.Outcome, result.TriesRemaining, tt.outcome, tt.tries, result.Describe())
+			}
+			if diff := cmp.Diff(tt.tvr, tvr); diff != "" {
+				t.Errorf("TVR mismatch (-want +got):\n%s", diff)
+			}
+			if len(tt.card.verifyData) != tt.verifies {
+				t.Errorf("%d VERIFY sent, want %d", len(tt.card.verifyData), tt.verifies)
+			}
+
+			for _, tx := range result.Trace {
+				if tx.Command.Instruction.Raw == iso7816.INS_VERIFY && !bytes.Equal(tx.Command.Data, make([]byte, len(tx.Command.Data))) {
+					t.Errorf("Trace holds VERIFY data %X", tx.Command.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestPINVerifierPerform(t *testing.T) {
+	card := &pinCard{pin: tlv.Hex("241234FFFFFFFFFF"), tries: 3}
+	v := &PINVerifier{Client: iso7816.NewClient(card), EnterPIN: func(int) (string, bool) { return "1234", true }}
+
+	list, err := ParseCVMList(tlv.Hex("00000000 00000000", "4300", "1E00"))
+	if err != nil {
+		t.Fatalf("ParseCVMList failed: %v", err)
+	}
+	tx := CVMTransaction{Capabilities: TerminalCapabilities{0xE0, 0xA0, 0xC8}, Perform: v.Perform}
+
+	e := EvaluateCVM(list, tx)
+	if !e.Successful || e.Results != (CVMResults{0x43, 0x00, 0x00}) {
+		t.Errorf("EvaluateCVM() = %v, %s\n%s", e.Successful, e.Results, e.Describe())
+	}
+	if v.Last == nil || v.Last.Outcome != CVMResultSuccessful {
+		t.Fatalf("Last verification missing or failed: %+v", v.Last)
+	}
+
+	expected := []string{
+		"=== EMV OFFLINE PIN VERIFICATION ===",
+		"[1] Method: Plaintext PIN verification performed by ICC and signature",
+		"    - PIN Try Counter: 3",
+		"    - PIN correct",
+		"    - Result: Successful",
+		"    - Tries Remaining: 3",
+	}
+	desc := v.Last.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+	if strings.Contains(desc, "1234") {
+		t.Errorf("Describe() shows the PIN:\n%s", desc)
+	}
+}
@@ -0,0 +1,111 @@
+package emv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+	"github.com/moov-io/bertlv"
+)
+
+// PROXIMITY PAYMENT SYSTEM ENVIRONMENT (PPSE) Logic according to EMV Book B, section 3.3.
+// On the contactless interface, the terminal selects '2PAY.SYS.DDF01'. Unlike the PSE,
+// the PPSE has no directory file: its FCI directly lists the applications as
+// Directory Entries (Tag '61') inside the FCI Issuer Discretionary Data (Tag 'BF0C').
+//
+// Structure:
+//   6F (FCI Template)
+//    ├── 84 (DF Name: "2PAY.SYS.DDF01")
+//    └── A5 (FCI Proprietary Template)
+//         └── BF0C (FCI Issuer Discretionary Data)
+//              └── 61 (Directory Entry), one per application
+//                   ├── 4F   (ADF Name)
+//                   ├── 50   (Application Label)
+//                   ├── 87   (Application Priority Indicator)
+//                   ├── 9F2A (Kernel Identifier)
+//                   └── 9F29 (Extended Selection)
+
+// PPSEName is the DF Name of the Proximity Payment System Environment.
+var PPSEName = []byte("2PAY.SYS.DDF01")
+
+// PPSE represents the FCI returned in response to the selection of the PPSE.
+type PPSE struct {
+	DFName              []byte                  `tlv:"84,mandatory,len=5-16" fmt:"ascii"`
+	ProprietaryTemplate PPSEProprietaryTemplate `tlv:"A5,mandatory" report:"Proprietary"`
+
+	// Raw keeps the data objects of the template exactly as returned by the card.
+	Raw []tlv.Element `tlv:",raw"`
+}
+
+// PPSEProprietaryTemplate contains the issuer discretionary data of the PPSE (Tag 'A5').
+type PPSEProprietaryTemplate struct {
+	IssuerDiscretionaryData PPSEDiscretionaryData `tlv:"BF0C,mandatory" report:"Discretionary"`
+
+	Unknown []bertlv.TLV `tlv:",unknown"`
+}
+
+// PPSEDiscretionaryData holds the directory entries of the PPSE (Tag 'BF0C').
+type PPSEDiscretionaryData struct {
+	Entries []DirectoryEntry `tlv:"61" report:"Entry"`
+
+	Unknown []bertlv.TLV `tlv:",unknown"`
+}
+
+// DirectoryEntry (Tag '61') describes a contactless application of the PPSE.
+type DirectoryEntry struct {
+	ADFName                      []byte `tlv:"4F,mandatory,len=5-16"`
+	ApplicationLabel             []byte `tlv:"50,len=1-16" fmt:"ans"`
+	ApplicationPriorityIndicator []byte `tlv:"87,len=1" fmt:"int"`
+	KernelIdentifier             []byte `tlv:"9F2A,len=1-8"`
+	ExtendedSelection            []byte `tlv:"9F29"`
+
+	Unknown []bertlv.TLV `tlv:",unknown"`
+}
+
+// Entries returns the directory entries listed by the PPSE.
+func (p *PPSE) Entries() []DirectoryEntry {
+	return p.ProprietaryTemplate.IssuerDiscretionaryData.Entries
+}
+
+// ParsePPSE interprets the response to the selection of the PPSE.
+// When the FCI is readable but violates EMV constraints, the PPSE is returned
+// together with an error wrapping a *tlv.ValidationError.
+func ParsePPSE(data []byte) (*PPSE, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty data cannot be parsed")
+	}
+
+	elements, err := tlv.DecodeElements(data)
+	if err != nil {
+		return nil, fmt.Errorf("BER-TLV decode failed: %w", err)
+	}
+
+	template, found := tlv.FindElement(elements, "6F")
+	if !found {
+		return nil, fmt.Errorf("missing mandatory FCI Template (Tag 6F)")
+	}
+
+	ppse := &PPSE{}
+	if err := tlv.UnmarshalElements(template.Children, ppse); err != nil {
+		var verr *tlv.ValidationError
+		if errors.As(err, &verr) {
+			return ppse, fmt.Errorf("invalid PPSE: %w", err)
+		}
+		return nil, fmt.Errorf("failed to map PPSE: %w", err)
+	}
+
+	return ppse, nil
+}
+
+// Report builds the structured report of the PPSE content.
+func (p *PPSE) Report() *report.Report {
+	r := report.New("EMV PPSE")
+	r.AddSection("").AddNode(tlv.BuildNode("PPSE", p))
+	return r
+}
+
+// Describe generates a detailed, standardized report of the PPSE content.
+func (p *PPSE) Describe() string {
+	return report.Text(p.Report())
+}
@@ -0,0 +1,63 @@
+package emv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// testPPSE builds a PPSE FCI listing the given directory entries.
+func testPPSE(entries ...string) []byte {
+	return tlv.Hex(tlvHex("6F",
+		tlvHex("84", "325041592E5359532E4444463031"),
+		tlvHex("A5", tlvHex("BF0C", entries...)),
+	))
+}
+
+func TestParsePPSE(t *testing.T) {
+	data := testPPSE(
+		tlvHex("61", tlvHex("4F", aidMC), tlvHex("50", labelMC), "870101", "9F2A0102"),
+		tlvHex("61", tlvHex("4F", aidVisa), "9F2A0103", "9F29021234", "DF0101AA"),
+	)
+
+	ppse, err := ParsePPSE(data)
+	if err != nil {
+		t.Fatalf("ParsePPSE failed: %v", err)
+	}
+
+	if string(ppse.DFName) != "2PAY.SYS.DDF01" || len(ppse.Entries()) != 2 {
+		t.Fatalf("unexpected PPSE: %s with %d entries", ppse.DFName, len(ppse.Entries()))
+	}
+
+	expected := []string{
+		"=== EMV PPSE ===",
+		`    - PPSE.DFName (84): 325041592E5359532E4444463031 ("2PAY.SYS.DDF01")`,
+		"    - Discretionary.Entry[1].ADFName (4F): A0000000041010",
+		`    - Discretionary.Entry[1].ApplicationLabel (50): 4D415354455243415244 ("MASTERCARD")`,
+		"    - Discretionary.Entry[1].ApplicationPriorityIndicator (87): 01 (Dec: 1)",
+		"    - Discretionary.Entry[1].KernelIdentifier (9F2A): 02",
+		"    - Discretionary.Entry[2].ADFName (4F): A0000000031010",
+		"    - Discretionary.Entry[2].KernelIdentifier (9F2A): 03",
+		"    - Discretionary.Entry[2].ExtendedSelection (9F29): 1234",
+		"    - Discretionary.Entry[2].Unknown Tag DF01: AA",
+	}
+	if diff := cmp.Diff(expected, strings.Split(ppse.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParsePPSE_Errors(t *testing.T) {
+	if _, err := ParsePPSE(tlv.Hex("A5 00")); err == nil {
+		t.Error("a PPSE without FCI template should be rejected")
+	}
+
+	// Entry without ADF Name: still returned, with a validation error.
+	ppse, err := ParsePPSE(testPPSE(tlvHex("61", "9F2A0102")))
+	var verr *tlv.ValidationError
+	if !errors.As(err, &verr) || ppse == nil {
+		t.Fatalf("expected a validation error with the PPSE, got %v", err)
+	}
+}
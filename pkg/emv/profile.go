@@ -0,0 +1,263 @@
+package emv
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// CARD PROFILE Logic.
+// The profile summarises the data read from a card during a session:
+//
+// 1. Application selection gives the applications with their label, priority, language
+//    preference ('5F2D') and the Log Entry ('9F4D') of the FCI.
+// 2. The data read with the AFL (and the AIP of GET PROCESSING OPTIONS, '82') completes
+//    each application: PAN (masked), PAN Sequence Number, Cardholder Name, dates, Service
+//    Code, issuer country and application currency, AIP capabilities, the ODA methods
+//    supported and the CVM List.
+//
+// The profile is exported as JSON or printed as a compact summary. The PAN is never
+// exported in clear.
+
+// ApplicationProfile summarises one application of the card.
+type ApplicationProfile struct {
+	AID                  string    `json:"aid"`
+	Label                string    `json:"label,omitempty"`
+	Priority             int       `json:"priority,omitempty"`
+	RequiresConfirmation bool      `json:"requires_confirmation,omitempty"`
+	Languages            []string  `json:"languages,omitempty"`
+	TransactionLog       *LogEntry `json:"transaction_log,omitempty"`
+
+	PAN            string `json:"pan,omitempty"` // Masked
+	PANSequence    string `json:"pan_sequence,omitempty"`
+	CardholderName string `json:"cardholder_name,omitempty"`
+	EffectiveDate  string `json:"effective_date,omitempty"`  // YYYY-MM-DD
+	ExpirationDate string `json:"expiration_date,omitempty"` // YYYY-MM-DD
+	ServiceCode    string `json:"service_code,omitempty"`
+	IssuerCountry  string `json:"issuer_country,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+
+	AIP          string   `json:"aip,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	ODAMethods   []string `json:"oda_methods,omitempty"`
+	CVMList      []string `json:"cvm_list,omitempty"`
+}
+
+// CardProfile summarises a card.
+type CardProfile struct {
+	SelectionMethod SelectionMethod      `json:"selection_method,omitempty"`
+	Applications    []ApplicationProfile `json:"applications"`
+}
+
+// NewCardProfile starts the profile of a card from its candidate list. It returns an error
+// when the Log Entry of an application is malformed.
+func NewCardProfile(list *CandidateList) (*CardProfile, error) {
+	p := &CardProfile{SelectionMethod: list.Method, Applications: []ApplicationProfile{}}
+	for _, c := range list.Candidates {
+		app := ApplicationProfile{
+			AID:                  strings.ToUpper(hex.EncodeToString(c.AID)),
+			Label:                c.Label,
+			Priority:             int(c.Priority),
+			RequiresConfirmation: c.RequiresConfirmation,
+		}
+		if c.FCI != nil {
+			if err := app.addFCI(c.FCI); err != nil {
+				return nil, fmt.Errorf("application %s: %w", app.AID, err)
+			}
+		}
+		p.Applications = append(p.Applications, app)
+	}
+	return p, nil
+}
+
+func (a *ApplicationProfile) addFCI(fci *FCI) error {
+	prop := fci.ProprietaryTemplate
+	for lang := string(prop.LanguagePreference); len(lang) >= 2; lang = lang[2:] {
+		a.Languages = append(a.Languages, strings.ToLower(lang[:2]))
+	}
+	if dd := prop.IssuerDiscretionaryData; dd != nil {
+		entry, err := dd.ParseLogEntry()
+		if err != nil {
+			return err
+		}
+		a.TransactionLog = entry
+	}
+	return nil
+}
+
+// AddApplicationData completes the profile of an application with the data read from the
+// card. The application is added when it was not a candidate. It returns an error when a
+// data object is malformed.
+func (p *CardProfile) AddApplicationData(aid []byte, data DataSource) error {
+	id := strings.ToUpper(hex.EncodeToString(aid))
+	app := p.application(id)
+
+	for _, add := range []func(DataSource) error{app.addIdentity, app.addDates, app.addCapabilities, app.addCVMList} {
+		if err := add(data); err != nil {
+			return fmt.Errorf("application %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (p *CardProfile) application(aid string) *ApplicationProfile {
+	for i := range p.Applications {
+		if p.Applications[i].AID == aid {
+			return &p.Applications[i]
+		}
+	}
+	p.Applications = append(p.Applications, ApplicationProfile{AID: aid})
+	return &p.Applications[len(p.Applications)-1]
+}
+
+// addIdentity adds the PAN, the cardholder name, the Service Code and the codes of the card.
+func (a *ApplicationProfile) addIdentity(data DataSource) error {
+	if pan, ok := data.Lookup("5A"); ok {
+		a.PAN = MaskPAN(PANDigits(pan))
+	} else if value, ok := data.Lookup("57"); ok {
+		track, err := ParseTrack2(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tagLabel("57"), err)
+		}
+		a.PAN = MaskPAN(track.PAN)
+	}
+	if psn, ok := data.Lookup("5F34"); ok {
+		a.PANSequence = fmt.Sprintf("%02X", psn)
+	}
+	if name, ok := data.Lookup("5F20"); ok {
+		a.CardholderName = strings.TrimSpace(string(name))
+	}
+	if code, ok := data.Lookup("5F30"); ok && len(code) == 2 {
+		a.ServiceCode = fmt.Sprintf("%X", code)[1:]
+	}
+	if country, ok := data.Lookup("5F28"); ok {
+		a.IssuerCountry = countryOrCurrency(country)
+	}
+	if currency, ok := data.Lookup("9F42"); ok {
+		a.Currency = countryOrCurrency(currency)
+	}
+	return nil
+}
+
+// addDates adds the Application Effective and Expiration Dates.
+func (a *ApplicationProfile) addDates(data DataSource) error {
+	for _, d := range []struct {
+		tag   string
+		field *string
+	}{{"5F25", &a.EffectiveDate}, {"5F24", &a.ExpirationDate}} {
+		value, ok := data.Lookup(d.tag)
+		if !ok {
+			continue
+		}
+		date, err := parseDate(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tagLabel(d.tag), err)
+		}
+		*d.field = date.Format("2006-01-02")
+	}
+	return nil
+}
+
+// addCapabilities adds the AIP capabilities and the ODA methods supported.
+func (a *ApplicationProfile) addCapabilities(data DataSource) error {
+	value, ok := data.Lookup("82")
+	if !ok {
+		return nil
+	}
+	aip, err := NewAIP(value)
+	if err != nil {
+		return err
+	}
+	a.AIP = fmt.Sprintf("%X", aip[:])
+	a.Capabilities = aip.Features()
+
+	a.ODAMethods = nil
+	for _, m := range []struct {
+		name      string
+		supported bool
+	}{{"SDA", aip.SupportsSDA()}, {"DDA", aip.SupportsDDA()}, {"CDA", aip.SupportsCDA()}} {
+		if m.supported {
+			a.ODAMethods = append(a.ODAMethods, m.name)
+		}
+	}
+	return nil
+}
+
+// addCVMList adds the Cardholder Verification Rules.
+func (a *ApplicationProfile) addCVMList(data DataSource) error {
+	value, ok := data.Lookup("8E")
+	if !ok {
+		return nil
+	}
+	list, err := ParseCVMList(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tagLabel("8E"), err)
+	}
+	a.CVMList = nil
+	for _, r := range list.Rules {
+		a.CVMList = append(a.CVMList, r.String())
+	}
+	return nil
+}
+
+// WriteJSON exports the profile as JSON.
+func (p *CardProfile) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return fmt.Errorf("json export failed: %w", err)
+	}
+	return nil
+}
+
+// Report builds the compact summary of the profile.
+func (p *CardProfile) Report() *report.Report {
+	rep := report.New("EMV CARD PROFILE")
+	if p.SelectionMethod != "" {
+		rep.AddSection("Selection:").Note("Method", string(p.SelectionMethod))
+	}
+
+	for i, a := range p.Applications {
+		section := rep.AddSection(fmt.Sprintf("[%d] %s (%s):", i+1, a.AID, a.Label))
+		notes := []struct{ label, value string }{
+			{"PAN", a.PAN},
+			{"PAN Sequence", a.PANSequence},
+			{"Cardholder Name", a.CardholderName},
+			{"Effective", a.EffectiveDate},
+			{"Expires", a.ExpirationDate},
+			{"Service Code", a.ServiceCode},
+			{"Issuer Country", a.IssuerCountry},
+			{"Currency", a.Currency},
+			{"Languages", strings.Join(a.Languages, ", ")},
+			{"AIP", a.AIP},
+			{"ODA", strings.Join(a.ODAMethods, ", ")},
+		}
+		if a.Priority > 0 {
+			section.Note("Priority", fmt.Sprintf("%d", a.Priority))
+		}
+		for _, n := range notes {
+			if n.value != "" {
+				section.Note(n.label, n.value)
+			}
+		}
+		for j, rule := range a.CVMList {
+			section.Note(fmt.Sprintf("CVM #%d", j+1), rule)
+		}
+
+		log := "none"
+		if a.TransactionLog != nil {
+			log = fmt.Sprintf("SFI %d, up to %d records", a.TransactionLog.SFI, a.TransactionLog.Records)
+		}
+		section.Note("Transaction Log", log)
+	}
+	return rep
+}
+
+// Describe generates the compact summary of the profile.
+func (p *CardProfile) Describe() string {
+	return report.Text(p.Report())
+}
@@ -0,0 +1,191 @@
+package emv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// profileCandidates returns a candidate list with a Visa application keeping a transaction
+// log and a second application without FCI.
+func profileCandidates(t *testing.T) *CandidateList {
+	t.Helper()
+	visa := testCard{
+		AID:         aidVisa,
+		Label:       labelVisa,
+		Proprietary: tlvHex("87", "01") + tlvHex("5F2D", "6672656E") + tlvHex("BF0C", tlvHex("9F4D", "0B0A")),
+	}
+	fci, err := ParseFCI(tlv.Hex(visa.fci()))
+	if err != nil {
+		t.Fatalf("ParseFCI failed: %v", err)
+	}
+
+	return &CandidateList{
+		Method: MethodPSE,
+		Candidates: []Candidate{
+			{AID: tlv.Hex(visa.AID), Label: "VISA", Priority: 1, FCI: fci},
+			{AID: tlv.Hex("A0000000421010"), Label: "CB", Priority: 2},
+		},
+	}
+}
+
+var profileData = TerminalData{
+	"5A":   tlv.Hex("4761739001010010"),
+	"5F34": tlv.Hex("01"),
+	"5F20": []byte("VISA/TEST "),
+	"5F25": tlv.Hex("230101"),
+	"5F24": tlv.Hex("271231"),
+	"5F30": tlv.Hex("0201"),
+	"5F28": tlv.Hex("0250"),
+	"9F42": tlv.Hex("0978"),
+	"82":   tlv.Hex("3900"),
+	"8E":   tlv.Hex("00000000 00000000 4203 1F03"),
+}
+
+func TestCardProfile(t *testing.T) {
+	p, err := NewCardProfile(profileCandidates(t))
+	if err != nil {
+		t.Fatalf("NewCardProfile() error = %v", err)
+	}
+	if err := p.AddApplicationData(tlv.Hex("A0000000031010"), profileData); err != nil {
+		t.Fatalf("AddApplicationData() error = %v", err)
+	}
+	if err := p.AddApplicationData(tlv.Hex("A0000000041010"), TerminalData{"57": tlv.Hex("5413330089020011D2512101")}); err != nil {
+		t.Fatalf("AddApplicationData() error = %v", err)
+	}
+
+	want := &CardProfile{
+		SelectionMethod: MethodPSE,
+		Applications: []ApplicationProfile{
+			{
+				AID:            "A0000000031010",
+				Label:          "VISA",
+				Priority:       1,
+				Languages:      []string{"fr", "en"},
+				TransactionLog: &LogEntry{SFI: 11, Records: 10},
+				PAN:            "476173******0010",
+				PANSequence:    "01",
+				CardholderName: "VISA/TEST",
+				EffectiveDate:  "2023-01-01",
+				ExpirationDate: "2027-12-31",
+				ServiceCode:    "201",
+				IssuerCountry:  "250",
+				Currency:       "978",
+				AIP:            "3900",
+				Capabilities: []string{
+					"DDA supported",
+					"Cardholder verification is supported",
+					"Terminal risk management is to be performed",
+					"CDA supported",
+				},
+				ODAMethods: []string{"DDA", "CDA"},
+				CVMList: []string{
+					"4203: Enciphered PIN verified online, If terminal supports the CVM (apply next if unsuccessful)",
+					"1F03: No CVM required, If terminal supports the CVM (fail if unsuccessful)",
+				},
+			},
+			{AID: "A0000000421010", Label: "CB", Priority: 2},
+			{AID: "A0000000041010", PAN: "541333******0011"},
+		},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Errorf("CardProfile mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCardProfile_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    TerminalData
+		wantErr string
+	}{
+		{name: "AIP", data: TerminalData{"82": tlv.Hex("39")}, wantErr: "AIP must be 2 bytes long"},
+		{name: "Date", data: TerminalData{"5F24": tlv.Hex("271331")}, wantErr: "Application Expiration Date (5F24)"},
+		{name: "CVM List", data: TerminalData{"8E": tlv.Hex("0000")}, wantErr: "CVM List (8E)"},
+		{name: "Track 2", data: TerminalData{"57": tlv.Hex("4761739001010010")}, wantErr: "Track 2 Equivalent Data (57)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CardProfile{}
+			err := p.AddApplicationData(tlv.Hex("A0000000031010"), tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("AddApplicationData() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	list := profileCandidates(t)
+	list.Candidates[0].FCI.ProprietaryTemplate.IssuerDiscretionaryData.LogEntry = tlv.Hex("0B")
+	if _, err := NewCardProfile(list); err == nil || !strings.Contains(err.Error(), "invalid Log Entry") {
+		t.Errorf("NewCardProfile() error = %v", err)
+	}
+}
+
+func TestCardProfile_WriteJSON(t *testing.T) {
+	p, err := NewCardProfile(profileCandidates(t))
+	if err != nil {
+		t.Fatalf("NewCardProfile() error = %v", err)
+	}
+	if err := p.AddApplicationData(tlv.Hex("A0000000031010"), profileData); err != nil {
+		t.Fatalf("AddApplicationData() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`"selection_method": "PSE"`,
+		`"pan": "476173******0010"`,
+		`"transaction_log": {`,
+		`"max_records": 10`,
+		`"oda_methods": [`,
+		`"aid": "A0000000421010"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteJSON() does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "4761739001010010") {
+		t.Errorf("WriteJSON() exports the full PAN:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := (&CardProfile{Applications: []ApplicationProfile{}}).WriteJSON(&buf); err != nil || !strings.Contains(buf.String(), `"applications": []`) {
+		t.Errorf("WriteJSON() of an empty profile = %q, %v", buf.String(), err)
+	}
+}
+
+func TestCardProfile_Describe(t *testing.T) {
+	p, err := NewCardProfile(profileCandidates(t))
+	if err != nil {
+		t.Fatalf("NewCardProfile() error = %v", err)
+	}
+	if err := p.AddApplicationData(tlv.Hex("A0000000031010"), profileData); err != nil {
+		t.Fatalf("AddApplicationData() error = %v", err)
+	}
+
+	out := p.Describe()
+	for _, want := range []string{
+		"=== EMV CARD PROFILE ===",
+		"Method: PSE",
+		"[1] A0000000031010 (VISA):",
+		"PAN: 476173******0010",
+		"Expires: 2027-12-31",
+		"Languages: fr, en",
+		"ODA: DDA, CDA",
+		"CVM #1: 4203",
+		"Transaction Log: SFI 11, up to 10 records",
+		"[2] A0000000421010 (CB):",
+		"Transaction Log: none",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Describe() does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/bits"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// CONTACTLESS QUALIFIERS Logic according to EMV Book C-3, Annex A.
+// Kernel 3 (Visa qVSDC) exchanges its capabilities and decisions as bit fields:
+//
+// 1. Terminal Transaction Qualifiers (TTQ, Tag '9F66', 4 bytes): the reader capabilities
+//    and the requirements of the transaction, sent to the card through the PDOL.
+// 2. Card Transaction Qualifiers (CTQ, Tag '9F6C', 2 bytes): the CVM and the fallback
+//    requested by the card, returned in the GPO response.
+// 3. Form Factor Indicator (FFI, Tag '9F6E', 4 bytes, Visa Contactless Payment
+//    Specification): the device carrying the application (card, phone, wearable...).
+
+// TTQ is the Terminal Transaction Qualifiers.
+type TTQ [4]byte
+
+var ttqBits = []bitMeaning{
+	{0, 8, "Mag-stripe mode supported"},
+	{0, 6, "EMV mode supported"},
+	{0, 5, "EMV contact chip supported"},
+	{0, 4, "Offline-only reader"},
+	{0, 3, "Online PIN supported"},
+	{0, 2, "Signature supported"},
+	{0, 1, "Offline Data Authentication for Online Authorizations supported"},
+	{1, 8, "Online cryptogram required"},
+	{1, 7, "CVM required"},
+	{1, 6, "(Contact Chip) Offline PIN supported"},
+	{2, 8, "Issuer Update Processing supported"},
+	{2, 7, "Consumer Device CVM supported"},
+}
+
+// NewTTQ creates the TTQ from the value of Tag '9F66'.
+func NewTTQ(value []byte) (TTQ, error) {
+	if len(value) != 4 {
+		return TTQ{}, fmt.Errorf("TTQ must be 4 bytes long (got %d)", len(value))
+	}
+	return TTQ{value[0], value[1], value[2], value[3]}, nil
+}
+
+// SupportsEMVContact reports whether the reader also supports the contact interface.
+func (t TTQ) SupportsEMVContact() bool { return bits.IsSet(t[0], 5) }
+
+// OfflineOnly reports whether the reader is unable to go online.
+func (t TTQ) OfflineOnly() bool { return bits.IsSet(t[0], 4) }
+
+// SupportsOnlinePIN reports whether the reader supports online PIN.
+func (t TTQ) SupportsOnlinePIN() bool { return bits.IsSet(t[0], 3) }
+
+// SupportsSignature reports whether the reader supports signature.
+func (t TTQ) SupportsSignature() bool { return bits.IsSet(t[0], 2) }
+
+// SupportsODAForOnline reports whether the reader performs fDDA on online authorisations.
+func (t TTQ) SupportsODAForOnline() bool { return bits.IsSet(t[0], 1) }
+
+// OnlineCryptogramRequired reports whether the reader requests an ARQC.
+func (t TTQ) OnlineCryptogramRequired() bool { return bits.IsSet(t[1], 8) }
+
+// CVMRequired reports whether the reader requests a CVM.
+func (t TTQ) CVMRequired() bool { return bits.IsSet(t[1], 7) }
+
+// SupportsConsumerDeviceCVM reports whether the reader accepts a CVM performed on the device.
+func (t TTQ) SupportsConsumerDeviceCVM() bool { return bits.IsSet(t[2], 7) }
+
+// Features lists the names of the bits set in the TTQ.
+func (t TTQ) Features() []string {
+	return setBits(t[:], ttqBits)
+}
+
+// Report builds the structured report of the TTQ.
+func (t TTQ) Report() *report.Report {
+	rep := report.New("EMV TERMINAL TRANSACTION QUALIFIERS")
+	describeBits(rep.AddSection(fmt.Sprintf("TTQ (9F66): %X", t[:])), t[:], ttqBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the TTQ bits.
+func (t TTQ) Describe() string {
+	return report.Text(t.Report())
+}
+
+// CTQ is the Card Transaction Qualifiers.
+type CTQ [2]byte
+
+var ctqBits = []bitMeaning{
+	{0, 8, "Online PIN required"},
+	{0, 7, "Signature required"},
+	{0, 6, "Go online if Offline Data Authentication fails and reader is online capable"},
+	{0, 5, "Switch interface if Offline Data Authentication fails and reader supports contact chip"},
+	{0, 4, "Go online if application expired"},
+	{0, 3, "Switch interface for cash transactions"},
+	{0, 2, "Switch interface for cashback transactions"},
+	{1, 8, "Consumer Device CVM performed"},
+	{1, 7, "Card supports Issuer Update Processing at the POS"},
+}
+
+// NewCTQ creates the CTQ from the value of Tag '9F6C'.
+func NewCTQ(value []byte) (CTQ, error) {
+	if len(value) != 2 {
+		return CTQ{}, fmt.Errorf("CTQ must be 2 bytes long (got %d)", len(value))
+	}
+	return CTQ{value[0], value[1]}, nil
+}
+
+// OnlinePINRequired reports whether the card requests online PIN.
+func (c CTQ) OnlinePINRequired() bool { return bits.IsSet(c[0], 8) }
+
+// SignatureRequired reports whether the card requests a signature.
+func (c CTQ) SignatureRequired() bool { return bits.IsSet(c[0], 7) }
+
+// OnlineIfODAFails reports whether the transaction goes online when fDDA fails.
+func (c CTQ) OnlineIfODAFails() bool { return bits.IsSet(c[0], 6) }
+
+// SwitchInterfaceIfODAFails reports whether the transaction switches to contact when fDDA fails.
+func (c CTQ) SwitchInterfaceIfODAFails() bool { return bits.IsSet(c[0], 5) }
+
+// OnlineIfExpired reports whether the transaction goes online when the application has expired.
+func (c CTQ) OnlineIfExpired() bool { return bits.IsSet(c[0], 4) }
+
+// SwitchInterfaceForCash reports whether cash transactions switch to contact.
+func (c CTQ) SwitchInterfaceForCash() bool { return bits.IsSet(c[0], 3) }
+
+// SwitchInterfaceForCashback reports whether cashback transactions switch to contact.
+func (c CTQ) SwitchInterfaceForCashback() bool { return bits.IsSet(c[0], 2) }
+
+// ConsumerDeviceCVMPerformed reports whether the cardholder was verified on the device.
+func (c CTQ) ConsumerDeviceCVMPerformed() bool { return bits.IsSet(c[1], 8) }
+
+// Features lists the names of the bits set in the CTQ.
+func (c CTQ) Features() []string {
+	return setBits(c[:], ctqBits)
+}
+
+// Report builds the structured report of the CTQ.
+func (c CTQ) Report() *report.Report {
+	rep := report.New("EMV CARD TRANSACTION QUALIFIERS")
+	describeBits(rep.AddSection(fmt.Sprintf("CTQ (9F6C): %X", c[:])), c[:], ctqBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the CTQ bits.
+func (c CTQ) Describe() string {
+	return report.Text(c.Report())
+}
+
+// FFI is the Form Factor Indicator.
+type FFI [4]byte
+
+var ffiBits = []bitMeaning{
+	{1, 8, "Passcode capable"},
+	{1, 7, "Signature panel"},
+	{1, 6, "Hologram"},
+	{1, 5, "CVV2"},
+	{1, 4, "Two-way messaging"},
+	{1, 3, "Cloud based payment credentials"},
+	{1, 2, "Biometric cardholder verification capable"},
+}
+
+// formFactors names the consumer device form factors (byte 1, bits 5-1).
+var formFactors = map[byte]string{
+	0x00: "Standard card",
+	0x01: "Mini card",
+	0x02: "Non-card form factor",
+	0x03: "Consumer mobile phone",
+	0x04: "Wrist-worn device",
+}
+
+// NewFFI creates the FFI from the value of Tag '9F6E'.
+func NewFFI(value []byte) (FFI, error) {
+	if len(value) != 4 {
+		return FFI{}, fmt.Errorf("FFI must be 4 bytes long (got %d)", len(value))
+	}
+	return FFI{value[0], value[1], value[2], value[3]}, nil
+}
+
+// Version returns the FFI version number (byte 1, bits 8-6).
+func (f FFI) Version() byte { return bits.GetRange(f[0], 8, 6) }
+
+// FormFactor returns the name of the consumer device form factor (byte 1, bits 5-1).
+func (f FFI) FormFactor() string {
+	code := bits.GetRange(f[0], 5, 1)
+	if name, ok := formFactors[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (%02X)", code)
+}
+
+// Technology returns the payment transaction technology (byte 4, bits 4-1).
+func (f FFI) Technology() string {
+	if code := bits.GetRange(f[3], 4, 1); code != 0 {
+		return fmt.Sprintf("Unknown (%X)", code)
+	}
+	return "Proximity contactless interface (ISO/IEC 14443)"
+}
+
+// Features lists the names of the feature indicators set in the FFI.
+func (f FFI) Features() []string {
+	return setBits(f[:], ffiBits)
+}
+
+// Report builds the structured report of the FFI.
+func (f FFI) Report() *report.Report {
+	rep := report.New("EMV FORM FACTOR INDICATOR")
+	section := rep.AddSection(fmt.Sprintf("FFI (9F6E): %X", f[:]))
+	section.Note("Version", fmt.Sprintf("%d", f.Version()))
+	section.Note("Form Factor", f.FormFactor())
+	section.Note("Technology", f.Technology())
+	describeBits(section, f[:], ffiBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the FFI fields.
+func (f FFI) Describe() string {
+	return report.Text(f.Report())
+}
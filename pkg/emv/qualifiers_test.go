@@ -0,0 +1,153 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestTTQ(t *testing.T) {
+	ttq, err := NewTTQ(tlv.Hex("36C04000"))
+	if err != nil {
+		t.Fatalf("NewTTQ() error = %v", err)
+	}
+
+	checks := map[string]bool{
+		"SupportsEMVContact":        ttq.SupportsEMVContact(),
+		"OfflineOnly":               !ttq.OfflineOnly(),
+		"SupportsOnlinePIN":         ttq.SupportsOnlinePIN(),
+		"SupportsSignature":         ttq.SupportsSignature(),
+		"SupportsODAForOnline":      !ttq.SupportsODAForOnline(),
+		"OnlineCryptogramRequired":  ttq.OnlineCryptogramRequired(),
+		"CVMRequired":               ttq.CVMRequired(),
+		"SupportsConsumerDeviceCVM": ttq.SupportsConsumerDeviceCVM(),
+	}
+	for name, ok := range checks {
+		if !ok {
+			t.Errorf("%s() returned the wrong value for TTQ %X", name, ttq[:])
+		}
+	}
+
+	want := []string{
+		"EMV mode supported",
+		"EMV contact chip supported",
+		"Online PIN supported",
+		"Signature supported",
+		"Online cryptogram required",
+		"CVM required",
+		"Consumer Device CVM supported",
+	}
+	if diff := cmp.Diff(want, ttq.Features()); diff != "" {
+		t.Errorf("Features() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewTTQ(tlv.Hex("3600")); err == nil || !strings.Contains(err.Error(), "4 bytes long") {
+		t.Errorf("NewTTQ() with 2 bytes error = %v", err)
+	}
+}
+
+func TestCTQ(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "No bits", value: "0000"},
+		{name: "Online PIN", value: "8000", want: []string{"Online PIN required"}},
+		{
+			name:  "Fallbacks",
+			value: "3C00",
+			want: []string{
+				"Go online if Offline Data Authentication fails and reader is online capable",
+				"Switch interface if Offline Data Authentication fails and reader supports contact chip",
+				"Go online if application expired",
+				"Switch interface for cash transactions",
+			},
+		},
+		{name: "Consumer Device CVM", value: "0080", want: []string{"Consumer Device CVM performed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctq, err := NewCTQ(tlv.Hex(tt.value))
+			if err != nil {
+				t.Fatalf("NewCTQ() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, ctq.Features()); diff != "" {
+				t.Errorf("Features() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := NewCTQ(tlv.Hex("80")); err == nil {
+		t.Error("NewCTQ() with 1 byte did not fail")
+	}
+}
+
+func TestFFI(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		version    byte
+		formFactor string
+		features   []string
+	}{
+		{name: "Card", value: "20700000", version: 1, formFactor: "Standard card", features: []string{"Signature panel", "Hologram", "CVV2"}},
+		{name: "Phone", value: "23880000", version: 1, formFactor: "Consumer mobile phone", features: []string{"Passcode capable", "Two-way messaging"}},
+		{name: "Unknown", value: "1F000000", formFactor: "Unknown (1F)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffi, err := NewFFI(tlv.Hex(tt.value))
+			if err != nil {
+				t.Fatalf("NewFFI() error = %v", err)
+			}
+			if got := ffi.Version(); got != tt.version {
+				t.Errorf("Version() = %d, want %d", got, tt.version)
+			}
+			if got := ffi.FormFactor(); got != tt.formFactor {
+				t.Errorf("FormFactor() = %q, want %q", got, tt.formFactor)
+			}
+			if diff := cmp.Diff(tt.features, ffi.Features()); diff != "" {
+				t.Errorf("Features() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQualifiersDescribe(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+	}{
+		{
+			name: "TTQ",
+			out:  TTQ{0x36, 0x00, 0x40, 0x00}.Describe(),
+			want: []string{"=== EMV TERMINAL TRANSACTION QUALIFIERS ===", "TTQ (9F66): 36004000", "Online PIN supported"},
+		},
+		{
+			name: "CTQ",
+			out:  CTQ{0x80, 0x80}.Describe(),
+			want: []string{"=== EMV CARD TRANSACTION QUALIFIERS ===", "CTQ (9F6C): 8080", "Consumer Device CVM performed"},
+		},
+		{
+			name: "FFI",
+			out:  FFI{0x23, 0x80, 0x00, 0x00}.Describe(),
+			want: []string{"=== EMV FORM FACTOR INDICATOR ===", "Form Factor: Consumer mobile phone", "Technology: Proximity contactless interface (ISO/IEC 14443)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.want {
+				if !strings.Contains(tt.out, want) {
+					t.Errorf("Describe() does not contain %q:\n%s", want, tt.out)
+				}
+			}
+		})
+	}
+}
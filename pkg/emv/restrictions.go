@@ -0,0 +1,252 @@
+package emv
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/bits"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// PROCESSING RESTRICTIONS Logic according to EMV Book 3, section 10.4.
+// The terminal checks that the application read from the card may be used for the transaction:
+//
+// 1. Application Version Number: the card AVN ('9F08') must equal the terminal AVN ('9F09'),
+//    otherwise "ICC and terminal have different application versions" is set.
+// 2. Application Usage Control ('9F07', 2 bytes): the card must be valid at ATMs (or at other
+//    terminals), and, when the Issuer Country Code ('5F28') is present, for the domestic or
+//    international use of the transaction type. Otherwise "Requested service not allowed
+//    for card product" is set.
+// 3. Application Dates (YYMMDD): the transaction date must not precede the Application
+//    Effective Date ('5F25') nor follow the Application Expiration Date ('5F24').
+//
+// Each check is skipped when its card data is absent. Years 00-49 are 20YY, 50-99 are 19YY.
+
+// Application Usage Control bits.
+var applicationUsageControlBits = []bitMeaning{
+	{0, 8, "Valid for domestic cash transactions"},
+	{0, 7, "Valid for international cash transactions"},
+	{0, 6, "Valid for domestic goods"},
+	{0, 5, "Valid for international goods"},
+	{0, 4, "Valid for domestic services"},
+	{0, 3, "Valid for international services"},
+	{0, 2, "Valid at ATMs"},
+	{0, 1, "Valid at terminals other than ATMs"},
+	{1, 8, "Domestic cashback allowed"},
+	{1, 7, "International cashback allowed"},
+}
+
+// RestrictionsTransaction describes the terminal and the transaction for processing restrictions.
+type RestrictionsTransaction struct {
+	ApplicationVersion []byte // Application Version Number of the terminal ('9F09')
+	Country            []byte // Terminal Country Code ('9F1A')
+	Type               TransactionType
+	ATM                bool
+	Services           bool // The purchase is for services rather than goods
+
+	// Date is the transaction date. Zero means now.
+	Date time.Time
+}
+
+func (tx RestrictionsTransaction) date() time.Time {
+	if tx.Date.IsZero() {
+		return time.Now()
+	}
+	return tx.Date
+}
+
+// RestrictionsCheck explains one processing restrictions check.
+type RestrictionsCheck struct {
+	Name   string
+	Detail string
+}
+
+// RestrictionsResult is the outcome of processing restrictions.
+type RestrictionsResult struct {
+	TVR    TVR // Bits set by processing restrictions
+	Checks []RestrictionsCheck
+}
+
+func (r *RestrictionsResult) check(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, RestrictionsCheck{Name: name, Detail: fmt.Sprintf(format, args...)})
+}
+
+// CheckProcessingRestrictions performs the processing restrictions on the card data read
+// with the AFL. It returns an error when a data object is malformed.
+func CheckProcessingRestrictions(card DataSource, tx RestrictionsTransaction) (*RestrictionsResult, error) {
+	r := &RestrictionsResult{}
+
+	r.checkVersion(card, tx)
+	if err := r.checkUsageControl(card, tx); err != nil {
+		return r, err
+	}
+	if err := r.checkDates(card, tx.date()); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (r *RestrictionsResult) checkVersion(card DataSource, tx RestrictionsTransaction) {
+	const name = "Application Version"
+
+	avn, ok := card.Lookup("9F08")
+	if !ok {
+		r.check(name, "card AVN (9F08) absent, check skipped")
+		return
+	}
+	if !bytes.Equal(avn, tx.ApplicationVersion) {
+		r.TVR.Set(TVRDifferentApplicationVersions)
+		r.check(name, "card %X differs from terminal %X", avn, tx.ApplicationVersion)
+		return
+	}
+	r.check(name, "card and terminal %X", avn)
+}
+
+func (r *RestrictionsResult) checkUsageControl(card DataSource, tx RestrictionsTransaction) error {
+	const name = "Application Usage Control"
+
+	auc, ok := card.Lookup("9F07")
+	if !ok {
+		r.check(name, "AUC (9F07) absent, check skipped")
+		return nil
+	}
+	if len(auc) != 2 {
+		return fmt.Errorf("%s: must be 2 bytes long (got %d)", tagLabel("9F07"), len(auc))
+	}
+
+	required := []int{7} // Valid at terminals other than ATMs
+	if tx.ATM {
+		required = []int{6} // Valid at ATMs
+	}
+
+	issuerCountry, ok := card.Lookup("5F28")
+	if ok {
+		domestic := bytes.Equal(issuerCountry, tx.Country)
+		required = append(required, usageBits(tx, domestic)...)
+	} else {
+		r.check(name, "Issuer Country Code (5F28) absent, domestic and international checks skipped")
+	}
+
+	for _, i := range required {
+		m := applicationUsageControlBits[i]
+		if !bits.IsSet(auc[m.byteIndex], m.bit) {
+			r.TVR.Set(TVRServiceNotAllowed)
+			r.check(name, "%X: %q not set", auc, m.name)
+			return nil
+		}
+	}
+	r.check(name, "%X allows the transaction", auc)
+	return nil
+}
+
+// usageBits returns the indexes in applicationUsageControlBits required by the transaction type.
+func usageBits(tx RestrictionsTransaction, domestic bool) []int {
+	offset := 1 // International bits follow the domestic ones
+	if domestic {
+		offset = 0
+	}
+
+	purchase := 2 // Goods
+	if tx.Services {
+		purchase = 4
+	}
+
+	switch tx.Type {
+	case TransactionCash:
+		return []int{offset}
+	case TransactionPurchase:
+		return []int{purchase + offset}
+	case TransactionCashback:
+		return []int{purchase + offset, 8 + offset}
+	default:
+		return nil
+	}
+}
+
+func (r *RestrictionsResult) checkDates(card DataSource, now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if value, ok := card.Lookup("5F25"); ok {
+		effective, err := parseDate(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tagLabel("5F25"), err)
+		}
+		if today.Before(effective) {
+			r.TVR.Set(TVRApplicationNotYetEffective)
+			r.check("Effective Date", "%s, application not yet effective", effective.Format("2006-01-02"))
+		} else {
+			r.check("Effective Date", "%s, application effective", effective.Format("2006-01-02"))
+		}
+	}
+
+	value, ok := card.Lookup("5F24")
+	if !ok {
+		r.check("Expiration Date", "Application Expiration Date (5F24) absent, check skipped")
+		return nil
+	}
+	expiry, err := parseDate(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tagLabel("5F24"), err)
+	}
+	if today.After(expiry) {
+		r.TVR.Set(TVRExpiredApplication)
+		r.check("Expiration Date", "%s, application expired", expiry.Format("2006-01-02"))
+	} else {
+		r.check("Expiration Date", "%s, application not expired", expiry.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// parseDate decodes a YYMMDD date (format n 6).
+func parseDate(value []byte) (time.Time, error) {
+	if len(value) != 3 {
+		return time.Time{}, fmt.Errorf("date must be 3 bytes long (got %d)", len(value))
+	}
+
+	var fields [3]int
+	for i, b := range value {
+		if b>>4 > 9 || b&0x0F > 9 {
+			return time.Time{}, fmt.Errorf("invalid date %X", value)
+		}
+		fields[i] = int(b>>4)*10 + int(b&0x0F)
+	}
+
+	year, month, day := 2000+fields[0], time.Month(fields[1]), fields[2]
+	if fields[0] >= 50 {
+		year -= 100
+	}
+
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if date.Month() != month || date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date %X", value)
+	}
+	return date, nil
+}
+
+// Report builds the structured report of the processing restrictions.
+func (r *RestrictionsResult) Report() *report.Report {
+	rep := report.New("EMV PROCESSING RESTRICTIONS")
+
+	checks := rep.AddSection("[1] Checks:")
+	for _, c := range r.Checks {
+		checks.Note(c.Name, c.Detail)
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	names := r.TVR.Names()
+	if len(names) == 0 {
+		outcome.Note("", "No processing restriction applies")
+	}
+	for _, n := range names {
+		outcome.Note(fmt.Sprintf("TVR (95) %X", r.TVR[:]), n)
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the processing restrictions.
+func (r *RestrictionsResult) Describe() string {
+	return report.Text(r.Report())
+}
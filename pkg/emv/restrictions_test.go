@@ -0,0 +1,139 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestCheckProcessingRestrictions(t *testing.T) {
+	card := TerminalData{
+		"9F08": tlv.Hex("0002"),
+		"9F07": tlv.Hex("B980"), // Domestic cash, goods and services, international goods, other terminals, domestic cashback
+		"5F28": tlv.Hex("0250"),
+		"5F25": tlv.Hex("240101"),
+		"5F24": tlv.Hex("271231"),
+	}
+	france := RestrictionsTransaction{
+		ApplicationVersion: tlv.Hex("0002"),
+		Country:            tlv.Hex("0250"),
+		Type:               TransactionPurchase,
+		Date:               time.Date(2026, 6, 15, 10, 0, 0, 0, time.UTC),
+	}
+	abroad := france
+	abroad.Country = tlv.Hex("0840")
+
+	with := func(tx RestrictionsTransaction, modify func(*RestrictionsTransaction)) RestrictionsTransaction {
+		modify(&tx)
+		return tx
+	}
+	withCard := func(tag, value string) TerminalData {
+		data := TerminalData{}
+		for k, v := range card {
+			data[k] = v
+		}
+		if value == "" {
+			delete(data, tag)
+		} else {
+			data[tag] = tlv.Hex(value)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		card TerminalData
+		tx   RestrictionsTransaction
+		tvr  TVR
+	}{
+		{"Domestic Purchase", card, france, TVR{}},
+		{"International Goods", card, abroad, TVR{}},
+		{"International Services", card, with(abroad, func(tx *RestrictionsTransaction) { tx.Services = true }), TVR{0x00, 0x10}},
+		{"Domestic Cash", card, with(france, func(tx *RestrictionsTransaction) { tx.Type = TransactionCash }), TVR{}},
+		{"International Cash", card, with(abroad, func(tx *RestrictionsTransaction) { tx.Type = TransactionCash }), TVR{0x00, 0x10}},
+		{"Domestic Cashback", card, with(france, func(tx *RestrictionsTransaction) { tx.Type = TransactionCashback }), TVR{}},
+		{"International Cashback", card, with(abroad, func(tx *RestrictionsTransaction) { tx.Type = TransactionCashback }), TVR{0x00, 0x10}},
+		{"ATM", card, with(france, func(tx *RestrictionsTransaction) { tx.ATM = true }), TVR{0x00, 0x10}},
+		{"No Issuer Country", withCard("5F28", ""), with(abroad, func(tx *RestrictionsTransaction) { tx.Type = TransactionCash }), TVR{}},
+		{"No AUC", withCard("9F07", ""), with(france, func(tx *RestrictionsTransaction) { tx.ATM = true }), TVR{}},
+		{"Different Versions", card, with(france, func(tx *RestrictionsTransaction) { tx.ApplicationVersion = tlv.Hex("0003") }), TVR{0x00, 0x80}},
+		{"No Card Version", withCard("9F08", ""), with(france, func(tx *RestrictionsTransaction) { tx.ApplicationVersion = tlv.Hex("0003") }), TVR{}},
+		{"Not Yet Effective", withCard("5F25", "260616"), france, TVR{0x00, 0x20}},
+		{"Effective Today", withCard("5F25", "260615"), france, TVR{}},
+		{"Expired", withCard("5F24", "260614"), france, TVR{0x00, 0x40}},
+		{"Expires Today", withCard("5F24", "260615"), france, TVR{}},
+		{"Twentieth Century", withCard("5F24", "991231"), france, TVR{0x00, 0x40}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CheckProcessingRestrictions(tt.card, tt.tx)
+			if err != nil {
+				t.Fatalf("CheckProcessingRestrictions failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.tvr, r.TVR); diff != "" {
+				t.Errorf("TVR mismatch (-want +got):\n%s\n%s", diff, r.Describe())
+			}
+		})
+	}
+}
+
+func TestCheckProcessingRestrictionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		data string
+	}{
+		{"AUC Length", "9F07", "AB"},
+		{"Effective Date Length", "5F25", "2401"},
+		{"Expiration Date Digits", "5F24", "27123A"},
+		{"Expiration Date Day", "5F24", "270230"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := TerminalData{tt.tag: tlv.Hex(tt.data)}
+			if _, err := CheckProcessingRestrictions(card, RestrictionsTransaction{}); err == nil {
+				t.Errorf("CheckProcessingRestrictions should reject %s %s", tt.tag, tt.data)
+			}
+		})
+	}
+}
+
+func TestRestrictionsResultDescribe(t *testing.T) {
+	card := TerminalData{
+		"9F08": tlv.Hex("0002"),
+		"9F07": tlv.Hex("FF00"),
+		"5F28": tlv.Hex("0250"),
+		"5F24": tlv.Hex("251231"),
+	}
+	tx := RestrictionsTransaction{
+		ApplicationVersion: tlv.Hex("0001"),
+		Country:            tlv.Hex("0250"),
+		Type:               TransactionCashback,
+		Date:               time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	r, err := CheckProcessingRestrictions(card, tx)
+	if err != nil {
+		t.Fatalf("CheckProcessingRestrictions failed: %v", err)
+	}
+
+	expected := []string{
+		"=== EMV PROCESSING RESTRICTIONS ===",
+		"Application Version: card 0002 differs from terminal 0001",
+		`Application Usage Control: FF00: "Domestic cashback allowed" not set`,
+		"Expiration Date: 2025-12-31, application expired",
+		"TVR (95) 00D0000000: ICC and terminal have different application versions",
+		"TVR (95) 00D0000000: Requested service not allowed for card product",
+	}
+	desc := r.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package emv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// TERMINAL RISK MANAGEMENT Logic according to EMV Book 3, section 10.6.
+// The terminal protects the acquirer, the issuer and the payment system against fraud:
+//
+// 1. Floor Limit Checking: the amount authorised, added to the amount of the last logged
+//    transaction made with the same card (split sales), is compared with the Terminal Floor
+//    Limit ('9F1B'). If it is equal or greater, "Transaction exceeds floor limit" is set.
+// 2. Random Transaction Selection, for amounts below the floor limit: a random number
+//    between 1 and 99 is compared with a Target Percentage. Below the Threshold Value the
+//    Target Percentage applies; between the threshold and the floor limit, the percentage
+//    grows linearly up to the Maximum Target Percentage (biased selection).
+// 3. Velocity Checking, when the card provides the Lower and Upper Consecutive Offline
+//    Limits ('9F14', '9F23'): the terminal reads the ATC ('9F36') and the Last Online ATC
+//    Register ('9F13') with GET DATA. The number of offline transactions since the last
+//    online one is ATC - Last Online ATC:
+//    - Above the lower limit: "Lower consecutive offline limit exceeded".
+//    - Above the upper limit: "Upper consecutive offline limit exceeded".
+//    - Last Online ATC of zero: "New card".
+//    When either counter is unavailable, or ATC <= Last Online ATC, both limit bits are set.
+
+// GetData creates an EMV GET DATA command (class '80') for a data object such as the
+// ATC ('9F36') or the Last Online ATC Register ('9F13').
+func GetData(tag uint16) *iso7816.CommandAPDU {
+	return iso7816.GetData(ClassEMV, tag)
+}
+
+// TransactionLog keeps the transactions approved offline by the terminal.
+type TransactionLog interface {
+	// LastAmount returns the amount of the most recent logged transaction made with the
+	// same PAN and PAN Sequence Number.
+	LastAmount(pan, panSequence []byte) (uint64, bool)
+}
+
+// TransactionLogEntry is a logged transaction.
+type TransactionLogEntry struct {
+	PAN         []byte // '5A'
+	PANSequence []byte // '5F34'
+	Amount      uint64
+}
+
+// TransactionLogEntries is an in-memory TransactionLog, oldest entry first.
+type TransactionLogEntries []TransactionLogEntry
+
+// LastAmount implements TransactionLog.
+func (l TransactionLogEntries) LastAmount(pan, panSequence []byte) (uint64, bool) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if bytes.Equal(l[i].PAN, pan) && bytes.Equal(l[i].PANSequence, panSequence) {
+			return l[i].Amount, true
+		}
+	}
+	return 0, false
+}
+
+// RandomSelection holds the parameters of random transaction selection.
+type RandomSelection struct {
+	TargetPercentage        int // 0 to 99
+	MaximumTargetPercentage int // 0 to 99, at least TargetPercentage
+	ThresholdValue          uint64
+}
+
+// RiskManagement holds the terminal parameters of terminal risk management.
+// Amounts are in the minor unit of the transaction currency.
+type RiskManagement struct {
+	FloorLimit      uint64
+	RandomSelection RandomSelection
+
+	// Log provides the previous transactions for split sales detection. Nil disables it.
+	Log TransactionLog
+
+	// Random returns a number between 1 and 99. Nil uses math/rand.
+	Random func() int
+}
+
+func (rm RiskManagement) random() int {
+	if rm.Random == nil {
+		return rand.Intn(99) + 1
+	}
+	return rm.Random()
+}
+
+// RiskCheck explains one terminal risk management check.
+type RiskCheck struct {
+	Name   string
+	Detail string
+}
+
+// RiskResult is the outcome of terminal risk management.
+type RiskResult struct {
+	TVR    TVR // Bits set by terminal risk management
+	Checks []RiskCheck
+
+	// Counters read during velocity checking, nil when unavailable.
+	ATC           []byte
+	LastOnlineATC []byte
+
+	// Trace keeps every GET DATA exchange.
+	Trace iso7816.Trace
+}
+
+func (r *RiskResult) check(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, RiskCheck{Name: name, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Perform runs terminal risk management for an amount authorised. The card data provides
+// the PAN, the PAN Sequence Number and the consecutive offline limits; the client is
+// used for the GET DATA commands of velocity checking. An error is returned when the
+// card cannot be reached.
+func (rm RiskManagement) Perform(client *iso7816.Client, card DataSource, amount uint64) (*RiskResult, error) {
+	r := &RiskResult{}
+
+	exceeded := rm.checkFloorLimit(r, card, amount)
+	if !exceeded {
+		rm.selectRandomly(r, amount)
+	}
+	if err := r.checkVelocity(client, card); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (rm RiskManagement) checkFloorLimit(r *RiskResult, card DataSource, amount uint64) bool {
+	const name = "Floor Limit"
+
+	total := amount
+	if rm.Log != nil {
+		pan, _ := card.Lookup("5A")
+		psn, _ := card.Lookup("5F34")
+		if logged, ok := rm.Log.LastAmount(pan, psn); ok {
+			total += logged
+			r.check(name, "split sale: %d logged for the same card, %d in total", logged, total)
+		}
+	}
+
+	if total >= rm.FloorLimit {
+		r.TVR.Set(TVRExceedsFloorLimit)
+		r.check(name, "%d reaches the floor limit %d", total, rm.FloorLimit)
+		return true
+	}
+	r.check(name, "%d below the floor limit %d", total, rm.FloorLimit)
+	return false
+}
+
+func (rm RiskManagement) selectRandomly(r *RiskResult, amount uint64) {
+	const name = "Random Selection"
+
+	p := rm.RandomSelection
+	target := p.TargetPercentage
+	if amount >= p.ThresholdValue && rm.FloorLimit > p.ThresholdValue {
+		// Biased selection: interpolate between the target and the maximum percentages.
+		span := uint64(p.MaximumTargetPercentage - p.TargetPercentage)
+		target += int(span * (amount - p.ThresholdValue) / (rm.FloorLimit - p.ThresholdValue))
+	}
+
+	n := rm.random()
+	if n <= target {
+		r.TVR.Set(TVRRandomlySelectedOnline)
+		r.check(name, "random number %d within the target percentage %d, selected for online processing", n, target)
+		return
+	}
+	r.check(name, "random number %d above the target percentage %d, not selected", n, target)
+}
+
+func (r *RiskResult) checkVelocity(client *iso7816.Client, card DataSource) error {
+	const name = "Velocity Checking"
+
+	lower, lowerOK := card.Lookup("9F14")
+	upper, upperOK := card.Lookup("9F23")
+	if !lowerOK || !upperOK || len(lower) != 1 || len(upper) != 1 {
+		r.check(name, "consecutive offline limits (9F14, 9F23) not provided, check skipped")
+		return nil
+	}
+
+	var err error
+	if r.ATC, err = r.getData(client, 0x9F36); err != nil {
+		return err
+	}
+	if r.LastOnlineATC, err = r.getData(client, 0x9F13); err != nil {
+		return err
+	}
+
+	if len(r.ATC) != 2 || len(r.LastOnlineATC) != 2 {
+		r.TVR.Set(TVRLowerConsecutiveOfflineLimitExceeded)
+		r.TVR.Set(TVRUpperConsecutiveOfflineLimitExceeded)
+		r.check(name, "ATC (9F36) or Last Online ATC Register (9F13) unavailable, both limits exceeded")
+		return nil
+	}
+
+	atc := binary.BigEndian.Uint16(r.ATC)
+	lastOnline := binary.BigEndian.Uint16(r.LastOnlineATC)
+	if lastOnline == 0 {
+		r.TVR.Set(TVRNewCard)
+		r.check(name, "Last Online ATC Register is zero, new card")
+	}
+	if atc <= lastOnline {
+		r.TVR.Set(TVRLowerConsecutiveOfflineLimitExceeded)
+		r.TVR.Set(TVRUpperConsecutiveOfflineLimitExceeded)
+		r.check(name, "ATC %d not above the Last Online ATC %d, both limits exceeded", atc, lastOnline)
+		return nil
+	}
+
+	offline := int(atc - lastOnline)
+	r.compareLimit(offline, int(lower[0]), "lower", TVRLowerConsecutiveOfflineLimitExceeded)
+	r.compareLimit(offline, int(upper[0]), "upper", TVRUpperConsecutiveOfflineLimitExceeded)
+	return nil
+}
+
+func (r *RiskResult) compareLimit(offline, limit int, label string, bit TVRBit) {
+	const name = "Velocity Checking"
+
+	if offline > limit {
+		r.TVR.Set(bit)
+		r.check(name, "%d consecutive offline transactions exceed the %s limit %d", offline, label, limit)
+		return
+	}
+	r.check(name, "%d consecutive offline transactions within the %s limit %d", offline, label, limit)
+}
+
+// getData reads a data object with GET DATA. It returns nil when the card does not
+// provide it.
+func (r *RiskResult) getData(client *iso7816.Client, tag uint16) ([]byte, error) {
+	trace, err := client.Send(GetData(tag))
+	r.Trace = append(r.Trace, trace...)
+	if err != nil {
+		return nil, fmt.Errorf("GET DATA %04X: %w", tag, err)
+	}
+	if !trace.IsSuccess() {
+		return nil, nil
+	}
+
+	elements, err := tlv.DecodeElements(trace.Last().Response.Data)
+	if err != nil {
+		return nil, nil
+	}
+	e, ok := tlv.FindElement(elements, fmt.Sprintf("%04X", tag))
+	if !ok {
+		return nil, nil
+	}
+	return e.Value, nil
+}
+
+// Report builds the structured report of the terminal risk management.
+func (r *RiskResult) Report() *report.Report {
+	rep := report.New("EMV TERMINAL RISK MANAGEMENT")
+
+	checks := rep.AddSection("[1] Checks:")
+	for _, c := range r.Checks {
+		checks.Note(c.Name, c.Detail)
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	if r.ATC != nil {
+		outcome.Note("ATC (9F36)", fmt.Sprintf("%X", r.ATC))
+	}
+	if r.LastOnlineATC != nil {
+		outcome.Note("Last Online ATC (9F13)", fmt.Sprintf("%X", r.LastOnlineATC))
+	}
+	names := r.TVR.Names()
+	if len(names) == 0 {
+		outcome.Note("", "No risk detected")
+	}
+	for _, n := range names {
+		outcome.Note(fmt.Sprintf("TVR (95) %X", r.TVR[:]), n)
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the terminal risk management.
+func (r *RiskResult) Describe() string {
+	return report.Text(r.Report())
+}
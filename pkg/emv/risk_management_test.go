@@ -0,0 +1,139 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestTransactionLogEntries(t *testing.T) {
+	log := TransactionLogEntries{
+		{PAN: tlv.Hex("4761739001010010"), PANSequence: tlv.Hex("01"), Amount: 1000},
+		{PAN: tlv.Hex("5413330089020011"), PANSequence: tlv.Hex("00"), Amount: 500},
+		{PAN: tlv.Hex("4761739001010010"), PANSequence: tlv.Hex("01"), Amount: 2000},
+	}
+
+	if amount, ok := log.LastAmount(tlv.Hex("4761739001010010"), tlv.Hex("01")); !ok || amount != 2000 {
+		t.Errorf("LastAmount() = %d, %v, want 2000, true", amount, ok)
+	}
+	if _, ok := log.LastAmount(tlv.Hex("4761739001010010"), tlv.Hex("02")); ok {
+		t.Error("LastAmount() should ignore another PAN Sequence Number")
+	}
+}
+
+func TestRiskManagement(t *testing.T) {
+	pan := tlv.Hex("4761739001010010")
+	card := TerminalData{
+		"5A":   pan,
+		"5F34": tlv.Hex("01"),
+		"9F14": tlv.Hex("03"),
+		"9F23": tlv.Hex("05"),
+	}
+	noLimits := TerminalData{"5A": pan, "5F34": tlv.Hex("01")}
+
+	counters := func(atc, lastOnline string) map[string]string {
+		responses := map[string]string{}
+		if atc != "" {
+			responses["80CA9F3600"] = "9F3602" + atc + "9000"
+		}
+		if lastOnline != "" {
+			responses["80CA9F1300"] = "9F1302" + lastOnline + "9000"
+		}
+		return responses
+	}
+	always := func(n int) func() int { return func() int { return n } }
+
+	rm := RiskManagement{
+		FloorLimit: 10000,
+		RandomSelection: RandomSelection{
+			TargetPercentage:        20,
+			MaximumTargetPercentage: 60,
+			ThresholdValue:          5000,
+		},
+		Random: always(99),
+	}
+	withLog := rm
+	withLog.Log = TransactionLogEntries{{PAN: pan, PANSequence: tlv.Hex("01"), Amount: 4000}}
+	lowRandom := rm
+	lowRandom.Random = always(20)
+	biasedRandom := rm
+	biasedRandom.Random = always(40) // 7500 is halfway: target 40
+
+	tests := []struct {
+		name      string
+		rm        RiskManagement
+		card      TerminalData
+		responses map[string]string
+		amount    uint64
+		tvr       TVR
+	}{
+		{"Below Floor Limit", rm, noLimits, nil, 2000, TVR{}},
+		{"Exceeds Floor Limit", rm, noLimits, nil, 10000, TVR{0x00, 0x00, 0x00, 0x80}},
+		{"Split Sale", withLog, noLimits, nil, 6000, TVR{0x00, 0x00, 0x00, 0x80}},
+		{"Split Sale Other Card", withLog, TerminalData{"5A": pan, "5F34": tlv.Hex("02")}, nil, 6000, TVR{}},
+		{"Randomly Selected", lowRandom, noLimits, nil, 2000, TVR{0x00, 0x00, 0x00, 0x10}},
+		{"Biased Selection", biasedRandom, noLimits, nil, 7500, TVR{0x00, 0x00, 0x00, 0x10}},
+		{"Biased Not Selected", biasedRandom, noLimits, nil, 7400, TVR{}},
+		{"Velocity Within Limits", rm, card, counters("0012", "0010"), 2000, TVR{}},
+		{"Lower Limit Exceeded", rm, card, counters("0014", "0010"), 2000, TVR{0x00, 0x00, 0x00, 0x40}},
+		{"Both Limits Exceeded", rm, card, counters("0016", "0010"), 2000, TVR{0x00, 0x00, 0x00, 0x60}},
+		{"New Card", rm, card, counters("0002", "0000"), 2000, TVR{0x00, 0x08, 0x00, 0x00}},
+		{"ATC Not Above Last Online", rm, card, counters("0010", "0010"), 2000, TVR{0x00, 0x00, 0x00, 0x60}},
+		{"Last Online ATC Unavailable", rm, card, counters("0010", ""), 2000, TVR{0x00, 0x00, 0x00, 0x60}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockCard{responses: tt.responses}
+			r, err := tt.rm.Perform(iso7816.NewClient(mock), tt.card, tt.amount)
+			if err != nil {
+				t.Fatalf("Perform failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.tvr, r.TVR); diff != "" {
+				t.Errorf("TVR mismatch (-want +got):\n%s\n%s", diff, r.Describe())
+			}
+			if tt.responses == nil && len(mock.sent) > 0 {
+				t.Errorf("Unexpected commands: %v", mock.sent)
+			}
+		})
+	}
+}
+
+func TestRiskResultDescribe(t *testing.T) {
+	mock := &mockCard{responses: map[string]string{
+		"80CA9F3600": "9F3602 0014 9000",
+		"80CA9F1300": "9F1302 0010 9000",
+	}}
+	card := TerminalData{"9F14": tlv.Hex("03"), "9F23": tlv.Hex("05")}
+	rm := RiskManagement{FloorLimit: 5000, Random: func() int { return 50 }}
+
+	r, err := rm.Perform(iso7816.NewClient(mock), card, 6000)
+	if err != nil {
+		t.Fatalf("Perform failed: %v", err)
+	}
+
+	expected := []string{
+		"=== EMV TERMINAL RISK MANAGEMENT ===",
+		"Floor Limit: 6000 reaches the floor limit 5000",
+		"Velocity Checking: 4 consecutive offline transactions exceed the lower limit 3",
+		"Velocity Checking: 4 consecutive offline transactions within the upper limit 5",
+		"ATC (9F36): 0014",
+		"TVR (95) 000000C000: Transaction exceeds floor limit",
+		"TVR (95) 000000C000: Lower consecutive offline limit exceeded",
+	}
+	desc := r.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+	if strings.Contains(desc, "Random Selection") {
+		t.Errorf("Random selection should be skipped above the floor limit:\n%s", desc)
+	}
+	if len(r.Trace) != 2 {
+		t.Errorf("Trace has %d exchanges, want 2", len(r.Trace))
+	}
+}
@@ -0,0 +1,51 @@
+package emv
+
+// STATIC DATA AUTHENTICATION (SDA) Logic according to EMV Book 2, section 5.
+// 1. The issuer public key is recovered with the CA public key (see recoverIssuerKey).
+// 2. The Signed Static Application Data (Tag '93') is recovered with the issuer public key:
+//
+//	6A | 03 | Hash Algo (1) | Data Authentication Code (2) | Pad 'BB' (N_I - 26) | Hash (20) | BC
+//
+// 3. The hash covers the recovered data from the format to the pad pattern, followed
+//    by the static data to be authenticated (see ODAInput.StaticDataToAuthenticate).
+
+// sdaRequiredTags are the data objects the card must provide for SDA.
+var sdaRequiredTags = []string{"8F", "90", "9F32", "93"}
+
+// VerifySDA performs Static Data Authentication and returns the detail of every check.
+func VerifySDA(in ODAInput) *AuthenticationResult {
+	r := &AuthenticationResult{Method: "SDA"}
+
+	if !r.require(in, sdaRequiredTags...) {
+		return r
+	}
+	if !recoverIssuerKey(in, r) {
+		return r
+	}
+
+	ssad := in.lookup("93")
+	recovered, err := r.IssuerPublicKey.recover(ssad)
+	if !r.check("Signed Static Data Recovery", err == nil, "%d bytes%s", len(ssad), errorSuffix(err)) {
+		return r
+	}
+	if !r.check("Signed Static Data Length", len(recovered) >= 26, "%d bytes (at least 26)", len(recovered)) {
+		return r
+	}
+	if !checkFrame(r, "Signed Static Data", recovered, 0x03) {
+		return r
+	}
+
+	staticData, err := in.StaticDataToAuthenticate()
+	if !r.check("Static Data", err == nil, "%d bytes%s", len(staticData), errorSuffix(err)) {
+		return r
+	}
+
+	n := len(recovered)
+	hashInput := concat(recovered[1:n-21], staticData)
+	if !checkHash(r, "Signed Static Data Hash", hashInput, recovered[n-21:n-1]) {
+		return r
+	}
+
+	r.DataAuthenticationCode = recovered[3:5]
+	return r
+}
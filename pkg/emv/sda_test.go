@@ -0,0 +1,129 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestVerifySDA(t *testing.T) {
+	p := loadTestPKI(t)
+
+	tests := []struct {
+		name        string
+		modify      func(data *ApplicationData)
+		aip         *AIP
+		passed      bool
+		failedStep  string
+		dataMissing bool
+	}{
+		{name: "Valid", passed: true},
+		{
+			name:        "Missing Certificate",
+			modify:      func(d *ApplicationData) { delete(d.objects, "90") },
+			failedStep:  "Required Data",
+			dataMissing: true,
+		},
+		{
+			name:       "Unknown CA Key",
+			modify:     func(d *ApplicationData) { replace(d, "8F", tlv.Hex("05")) },
+			failedStep: "CA Public Key",
+		},
+		{
+			name:       "Wrong Remainder",
+			modify:     func(d *ApplicationData) { replace(d, "92", tlv.Hex("00000000")) },
+			failedStep: "Issuer Certificate Hash",
+		},
+		{
+			name: "Expired Certificate",
+			modify: func(d *ApplicationData) {
+				cert, _ := p.issuerCertificate("476173FF", "0126")
+				replace(d, "90", cert)
+			},
+			failedStep: "Issuer Certificate Expiry",
+		},
+		{
+			name: "Issuer Identifier Mismatch",
+			modify: func(d *ApplicationData) {
+				cert, _ := p.issuerCertificate("545454FF", "1227")
+				replace(d, "90", cert)
+			},
+			failedStep: "Issuer Identifier",
+		},
+		{
+			name:       "Certificate Signed By Issuer",
+			modify:     func(d *ApplicationData) { replace(d, "90", d.objects["93"]) },
+			failedStep: "Issuer Certificate Recovery",
+		},
+		{
+			name:       "Invalid Tag List",
+			modify:     func(d *ApplicationData) { replace(d, "9F4A", tlv.Hex("8295")) },
+			failedStep: "Static Data",
+		},
+		{
+			name:       "Modified AIP",
+			aip:        &AIP{0x41, 0x00},
+			failedStep: "Signed Static Data Hash",
+		},
+		{
+			name: "Modified Record",
+			modify: func(d *ApplicationData) {
+				d.OfflineAuthenticationData = tlv.Hex("5A0847617390001000995F2403271231")
+			},
+			failedStep: "Signed Static Data Hash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := odaInput(t, tt.modify)
+			if tt.aip != nil {
+				in.AIP = *tt.aip
+			}
+
+			result := VerifySDA(in)
+			if result.Passed() != tt.passed {
+				t.Fatalf("Passed() = %v, want %v\n%s", result.Passed(), tt.passed, result.Describe())
+			}
+			if result.DataMissing != tt.dataMissing {
+				t.Errorf("DataMissing = %v, want %v", result.DataMissing, tt.dataMissing)
+			}
+			if tt.passed {
+				return
+			}
+
+			last := result.Steps[len(result.Steps)-1]
+			if last.Passed || last.Name != tt.failedStep {
+				t.Errorf("last step = %+v, want failed %q", last, tt.failedStep)
+			}
+		})
+	}
+}
+
+func TestVerifySDADescribe(t *testing.T) {
+	result := VerifySDA(odaInput(t, nil))
+	desc := result.Describe()
+
+	for _, want := range []string{
+		"EMV OFFLINE DATA AUTHENTICATION",
+		"Method: SDA",
+		"CA Public Key",
+		"[OK] RID A000000003, index 92",
+		"Issuer Certificate Header",
+		"[OK] 6A (expected 6A)",
+		"Issuer Certificate Format",
+		"[OK] 02 (expected 02)",
+		"[OK] 12/2027",
+		"[OK] 476173 matches PAN 4761739000100010",
+		"Signed Static Data Format",
+		"[OK] 03 (expected 03)",
+		"SDA succeeded",
+		"1152 bits",
+		"DAC1",
+	} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("Describe() missing %q:\n%s", want, desc)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package emv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// APPLICATION SELECTION Logic according to EMV Book 1, section 12.
+//
+// 1. PSE Directory Method (12.3.2):
+//    The terminal selects the Payment System Environment '1PAY.SYS.DDF01' and reads
+//    the records of its directory (SFI found in the FCI) until '6A83'. Each ADF
+//    entry (Tag '61' with '4F') matching a terminal AID is a candidate, DDF entries
+//    (Tag '9D') are explored recursively.
+//
+// 2. List of AIDs Method (12.3.3):
+//    Used when the PSE is absent, blocked or yields no candidate. The terminal selects
+//    each of its AIDs. When the card answers with a longer DF Name and the terminal
+//    allows partial selection, the next occurrences are selected with the same AID
+//    (P2 = 'Next Occurrence') until the card answers with an error.
+//
+// 3. Candidate List (12.4):
+//    Applications answering '6283' are blocked and never become candidates.
+//    Candidates are ranked by the Application Priority Indicator (Tag '87'):
+//    bits 4-1 hold the priority (1 is the highest, 0 means no priority) and bit 8
+//    indicates that the application cannot be selected without cardholder confirmation.
+
+// PSEName is the DF Name of the Payment System Environment (contact interface).
+var PSEName = []byte("1PAY.SYS.DDF01")
+
+// ErrCardBlocked is returned when the card answers '6A81' to a SELECT: the card is
+// blocked or does not support the command, the transaction is terminated.
+var ErrCardBlocked = errors.New("card blocked or SELECT command not supported")
+
+// maxDirectoryDepth limits the exploration of nested DDFs.
+const maxDirectoryDepth = 3
+
+// SelectionMethod identifies how the candidate list was built.
+type SelectionMethod string
+
+const (
+	MethodPSE        SelectionMethod = "PSE"
+	MethodListOfAIDs SelectionMethod = "List of AIDs"
+)
+
+// TerminalAID is an application supported by the terminal.
+type TerminalAID struct {
+	AID []byte
+	// PartialSelection allows the card AID to be longer than the terminal AID
+	// (Application Selection Indicator).
+	PartialSelection bool
+}
+
+// matches reports whether a card AID (DF Name) designates this terminal application.
+func (t TerminalAID) matches(dfName []byte) bool {
+	if bytes.Equal(t.AID, dfName) {
+		return true
+	}
+	return t.PartialSelection && len(dfName) > len(t.AID) && bytes.HasPrefix(dfName, t.AID)
+}
+
+// Candidate is an application that may be selected for the transaction.
+type Candidate struct {
+	AID                  []byte
+	Label                string
+	Priority             byte // 1 (highest) to 15, 0 when no priority is given
+	RequiresConfirmation bool
+	FCI                  *FCI
+}
+
+// ExcludedApplication is an application found on the card that cannot be a candidate.
+type ExcludedApplication struct {
+	AID    []byte
+	Reason string
+}
+
+// CandidateList is the outcome of the application selection.
+type CandidateList struct {
+	Method     SelectionMethod
+	Candidates []Candidate
+	Excluded   []ExcludedApplication
+
+	// Trace keeps every exchange made to build the list.
+	Trace iso7816.Trace
+}
+
+// Selector builds the candidate list of a card.
+type Selector struct {
+	Client       *iso7816.Client
+	Class        iso7816.Class
+	TerminalAIDs []TerminalAID
+
+	// SupportsConfirmation indicates that the terminal can ask the cardholder to confirm
+	// an application. Otherwise, applications requiring confirmation are excluded.
+	SupportsConfirmation bool
+
+	list *CandidateList
+}
+
+// NewSelector creates a Selector using the interindustry class '00'.
+func NewSelector(client *iso7816.Client, aids []TerminalAID) *Selector {
+	return &Selector{Client: client, TerminalAIDs: aids, SupportsConfirmation: true}
+}
+
+// BuildCandidateList runs the PSE method, falls back to the list of AIDs, then ranks the candidates.
+// The list built so far is returned with the error, including the exchanged trace.
+func (s *Selector) BuildCandidateList() (*CandidateList, error) {
+	s.list = &CandidateList{Method: MethodPSE}
+
+	found, err := s.selectByPSE()
+	if err != nil {
+		return s.list, err
+	}
+
+	if !found {
+		s.list.Method = MethodListOfAIDs
+		s.list.Candidates = nil
+		if err := s.selectByListOfAIDs(); err != nil {
+			return s.list, err
+		}
+	}
+
+	s.rankCandidates()
+	return s.list, nil
+}
+
+// send transmits a command and keeps the exchange in the candidate list trace.
+func (s *Selector) send(cmd *iso7816.CommandAPDU) (iso7816.Trace, error) {
+	trace, err := s.Client.Send(cmd)
+	s.list.Trace = append(s.list.Trace, trace...)
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// selectByPSE reports whether the PSE method produced at least one candidate.
+func (s *Selector) selectByPSE() (bool, error) {
+	trace, err := s.send(iso7816.SelectByAID(s.Class, PSEName))
+	if err != nil {
+		return false, fmt.Errorf("selecting PSE: %w", err)
+	}
+
+	status := trace.Last().Response.Status
+	switch {
+	case status == iso7816.SW_ERR_FUNC_NOT_SUPPORTED:
+		return false, ErrCardBlocked
+	case !status.IsSuccess():
+		return false, nil
+	}
+
+	var aids [][]byte
+	if err := s.exploreDirectory(trace.Last().Response.Data, 0, &aids); err != nil {
+		return false, err
+	}
+
+	for _, aid := range aids {
+		if _, err := s.selectApplication(aid, iso7816.FirstOrOnlyOccurrence); err != nil {
+			return false, err
+		}
+	}
+
+	return len(s.list.Candidates) > 0, nil
+}
+
+// exploreDirectory reads the directory designated by a DDF FCI and collects the AIDs
+// that match a terminal application.
+func (s *Selector) exploreDirectory(fciData []byte, depth int, aids *[][]byte) error {
+	// A non-compliant DDF FCI remains usable as long as its SFI is readable.
+	fci, _ := ParseFCI(fciData)
+	if fci == nil || len(fci.ProprietaryTemplate.SFI) == 0 {
+		// Without directory SFI the PSE is unusable: the list of AIDs will be used.
+		return nil
+	}
+
+	sfi := fci.ProprietaryTemplate.SFI[0]
+	for record := byte(1); record <= 30; record++ {
+		trace, err := s.send(iso7816.ReadRecord(s.Class, sfi, record))
+		if err != nil {
+			return fmt.Errorf("reading directory SFI %d: %w", sfi, err)
+		}
+		if !trace.IsSuccess() {
+			// '6A83' marks the end of the directory; other errors end it as well.
+			return nil
+		}
+
+		// Entries of a non-compliant record are still checked one by one.
+		dir, _ := ParseDirectoryRecord(trace.Last().Response.Data)
+		if dir == nil {
+			continue
+		}
+
+		for _, app := range dir.Applications {
+			if err := s.collectEntry(app, depth, aids); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectEntry handles a directory entry: an ADF (AID) or a nested DDF.
+func (s *Selector) collectEntry(app ApplicationTemplate, depth int, aids *[][]byte) error {
+	if len(app.DDFName) > 0 && depth < maxDirectoryDepth {
+		trace, err := s.send(iso7816.SelectByAID(s.Class, app.DDFName))
+		if err != nil {
+			return fmt.Errorf("selecting DDF %X: %w", app.DDFName, err)
+		}
+		if trace.IsSuccess() {
+			return s.exploreDirectory(trace.Last().Response.Data, depth+1, aids)
+		}
+		return nil
+	}
+
+	if len(app.AID) > 0 && s.isSupported(app.AID) {
+		*aids = append(*aids, app.AID)
+	}
+	return nil
+}
+
+func (s *Selector) isSupported(aid []byte) bool {
+	for _, t := range s.TerminalAIDs {
+		if t.matches(aid) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectByListOfAIDs selects every terminal AID, following partial matches.
+func (s *Selector) selectByListOfAIDs() error {
+	for _, terminalAID := range s.TerminalAIDs {
+		occurrence := iso7816.FirstOrOnlyOccurrence
+
+		for i := 0; i < 32; i++ {
+			dfName, err := s.selectApplication(terminalAID.AID, occurrence)
+			if err != nil {
+				return err
+			}
+			// Next occurrences are only worth selecting after a partial match.
+			if dfName == nil || !terminalAID.PartialSelection || bytes.Equal(dfName, terminalAID.AID) {
+				break
+			}
+			occurrence = iso7816.NextOccurrence
+		}
+	}
+	return nil
+}
+
+// selectApplication selects an AID and adds it to the candidates when eligible.
+// It returns the DF Name answered by the card, or nil when the selection failed.
+func (s *Selector) selectApplication(aid []byte, occurrence iso7816.FileOccurrence) ([]byte, error) {
+	cmd := iso7816.NewSelectCommand(s.Class, iso7816.SelectByDFName, occurrence, iso7816.ReturnFCI, aid)
+	trace, err := s.send(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("selecting %X: %w", aid, err)
+	}
+
+	status := trace.Last().Response.Status
+	if status == iso7816.SW_ERR_FUNC_NOT_SUPPORTED {
+		return nil, ErrCardBlocked
+	}
+
+	fci, _ := ParseFCI(trace.Last().Response.Data)
+	dfName := aid
+	if fci != nil && len(fci.DFName) > 0 {
+		dfName = fci.DFName
+	}
+
+	switch {
+	case status == iso7816.SW_WARN_FILE_DEACTIVATED:
+		s.exclude(dfName, "Application blocked (6283)")
+		return dfName, nil
+	case !status.IsSuccess():
+		return nil, nil
+	case fci == nil:
+		s.exclude(dfName, "Invalid FCI")
+		return dfName, nil
+	case !s.isSupported(dfName):
+		s.exclude(dfName, "DF Name does not match a terminal AID")
+		return dfName, nil
+	}
+
+	s.addCandidate(dfName, fci)
+	return dfName, nil
+}
+
+func (s *Selector) addCandidate(aid []byte, fci *FCI) {
+	candidate := Candidate{AID: aid, FCI: fci}
+
+	prop := fci.ProprietaryTemplate
+	candidate.Label = string(prop.ApplicationLabel)
+	if len(prop.ApplicationPreferredName) > 0 {
+		candidate.Label = string(prop.ApplicationPreferredName)
+	}
+	if len(prop.ApplicationPriorityIndicator) > 0 {
+		api := prop.ApplicationPriorityIndicator[0]
+		candidate.Priority = api & 0x0F
+		candidate.RequiresConfirmation = api&0x80 != 0
+	}
+
+	if candidate.RequiresConfirmation && !s.SupportsConfirmation {
+		s.exclude(aid, "Cardholder confirmation required but not supported")
+		return
+	}
+
+	for _, c := range s.list.Candidates {
+		if bytes.Equal(c.AID, aid) {
+			return
+		}
+	}
+	s.list.Candidates = append(s.list.Candidates, candidate)
+}
+
+func (s *Selector) exclude(aid []byte, reason string) {
+	s.list.Excluded = append(s.list.Excluded, ExcludedApplication{AID: aid, Reason: reason})
+}
+
+// rankCandidates orders the candidates by priority. Applications without priority come
+// last; the card order is kept for equal priorities.
+func (s *Selector) rankCandidates() {
+	sort.SliceStable(s.list.Candidates, func(i, j int) bool {
+		return priorityRank(s.list.Candidates[i].Priority) < priorityRank(s.list.Candidates[j].Priority)
+	})
+}
+
+// priorityRank turns an application priority into a sort key: 1 comes first and
+// applications without priority (0) come last.
+func priorityRank(priority byte) int {
+	if priority == 0 {
+		return 16
+	}
+	return int(priority)
+}
+
+// Report builds the structured report of the candidate list.
+func (l *CandidateList) Report() *report.Report {
+	rep := report.New("EMV APPLICATION SELECTION")
+
+	section := rep.AddSection(fmt.Sprintf("[=] CANDIDATE LIST (%s method, %d exchanges):", l.Method, len(l.Trace)))
+	if len(l.Candidates) == 0 {
+		section.Note("", "No candidate application.")
+	}
+	for i, c := range l.Candidates {
+		priority := "none"
+		if c.Priority > 0 {
+			priority = fmt.Sprintf("%d", c.Priority)
+		}
+		detail := fmt.Sprintf("%X %q (Priority: %s)", c.AID, c.Label, priority)
+		if c.RequiresConfirmation {
+			detail += " [Confirmation required]"
+		}
+		section.Note(fmt.Sprintf("#%d", i+1), detail)
+	}
+
+	if len(l.Excluded) > 0 {
+		excluded := rep.AddSection("[!!] EXCLUDED APPLICATIONS:")
+		for _, e := range l.Excluded {
+			excluded.Note(fmt.Sprintf("%X", e.AID), e.Reason)
+		}
+	}
+
+	return rep
+}
+
+// Describe generates a human-readable report of the candidate list.
+func (l *CandidateList) Describe() string {
+	return report.Text(l.Report())
+}
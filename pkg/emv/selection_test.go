@@ -0,0 +1,153 @@
+package emv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// testFCI returns the FCI of an application, with an optional priority indicator.
+func testFCI(aid, label, api string) string {
+	proprietary := tlvHex("50", label)
+	if api != "" {
+		proprietary += tlvHex("87", api)
+	}
+	return tlvHex("6F", tlvHex("84", aid), tlvHex("A5", proprietary))
+}
+
+const (
+	selectPSE   = "00A404000E315041592E5359532E4444463031"
+	aidVisa     = "A0000000031010"
+	aidVisaElec = "A0000000032010"
+	aidVPay     = "A0000000033010"
+	aidMC       = "A0000000041010"
+	labelVisa   = "56495341"
+	labelMC     = "4D415354455243415244"
+)
+
+func TestSelector_PSEMethod(t *testing.T) {
+	pseFCI := tlvHex("6F", tlvHex("84", "315041592E5359532E4444463031"), tlvHex("A5", "880101", "5F2D02656E"))
+	record := tlvHex("70",
+		tlvHex("61", tlvHex("4F", aidVisa), tlvHex("50", labelVisa), "870102"),
+		tlvHex("61", tlvHex("4F", aidMC), tlvHex("50", labelMC), "870101"),
+		tlvHex("61", tlvHex("4F", "A0000000999999"), tlvHex("50", "58")), // not supported by the terminal
+	)
+
+	card := &mockCard{responses: map[string]string{
+		selectPSE:                  pseFCI + "9000",
+		"00B2010C00":               record + "9000",
+		"00B2020C00":               "6A83",
+		"00A4040007" + aidVisa:     testFCI(aidVisa, labelVisa, "02") + "9000",
+		"00A4040007" + aidMC:       testFCI(aidMC, labelMC, "01") + "9000",
+		"00A4040007A0000000999999": "6A82",
+	}}
+
+	selector := NewSelector(iso7816.NewClient(card), []TerminalAID{{AID: tlv.Hex(aidVisa)}, {AID: tlv.Hex(aidMC)}})
+	list, err := selector.BuildCandidateList()
+	if err != nil {
+		t.Fatalf("BuildCandidateList failed: %v", err)
+	}
+
+	if list.Method != MethodPSE {
+		t.Errorf("Method = %s, want PSE", list.Method)
+	}
+
+	expected := []string{
+		"=== EMV APPLICATION SELECTION ===",
+		"[=] CANDIDATE LIST (PSE method, 5 exchanges):",
+		`    - #1: A0000000041010 "MASTERCARD" (Priority: 1)`,
+		`    - #2: A0000000031010 "VISA" (Priority: 2)`,
+	}
+	if diff := cmp.Diff(expected, strings.Split(list.Describe(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+
+	if list.Candidates[0].FCI == nil || string(list.Candidates[0].FCI.ProprietaryTemplate.ApplicationLabel) != "MASTERCARD" {
+		t.Error("candidates should carry the FCI returned by their selection")
+	}
+}
+
+func TestSelector_ListOfAIDs(t *testing.T) {
+	newCard := func() *mockCard {
+		return &mockCard{
+			responses: map[string]string{
+				"00A4040005A000000003": testFCI(aidVisa, labelVisa, "81") + "9000",
+			},
+			sequences: map[string][]string{
+				"00A4040205A000000003": {
+					testFCI(aidVisaElec, labelVisa, "") + "6283", // blocked
+					testFCI(aidVPay, "5650415920", "") + "9000",
+					"6A82",
+				},
+			},
+		}
+	}
+	aids := []TerminalAID{
+		{AID: tlv.Hex("A000000003"), PartialSelection: true},
+		{AID: tlv.Hex(aidMC)},
+	}
+
+	tests := []struct {
+		name                 string
+		supportsConfirmation bool
+		expected             []string
+	}{
+		{
+			name:                 "With Cardholder Confirmation",
+			supportsConfirmation: true,
+			expected: []string{
+				"=== EMV APPLICATION SELECTION ===",
+				"[=] CANDIDATE LIST (List of AIDs method, 6 exchanges):",
+				`    - #1: A0000000031010 "VISA" (Priority: 1) [Confirmation required]`,
+				`    - #2: A0000000033010 "VPAY " (Priority: none)`,
+				"",
+				"[!!] EXCLUDED APPLICATIONS:",
+				"    - A0000000032010: Application blocked (6283)",
+			},
+		},
+		{
+			name:                 "Without Cardholder Confirmation",
+			supportsConfirmation: false,
+			expected: []string{
+				"=== EMV APPLICATION SELECTION ===",
+				"[=] CANDIDATE LIST (List of AIDs method, 6 exchanges):",
+				`    - #1: A0000000033010 "VPAY " (Priority: none)`,
+				"",
+				"[!!] EXCLUDED APPLICATIONS:",
+				"    - A0000000031010: Cardholder confirmation required but not supported",
+				"    - A0000000032010: Application blocked (6283)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := NewSelector(iso7816.NewClient(newCard()), aids)
+			selector.SupportsConfirmation = tt.supportsConfirmation
+
+			list, err := selector.BuildCandidateList()
+			if err != nil {
+				t.Fatalf("BuildCandidateList failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, strings.Split(list.Describe(), "\n")); diff != "" {
+				t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelector_CardBlocked(t *testing.T) {
+	card := &mockCard{responses: map[string]string{selectPSE: "6A81"}}
+
+	list, err := NewSelector(iso7816.NewClient(card), []TerminalAID{{AID: tlv.Hex(aidVisa)}}).BuildCandidateList()
+	if !errors.Is(err, ErrCardBlocked) {
+		t.Fatalf("expected ErrCardBlocked, got %v", err)
+	}
+	if len(list.Trace) != 1 {
+		t.Errorf("the trace should contain the PSE selection, got %d exchanges", len(list.Trace))
+	}
+}
@@ -0,0 +1,191 @@
+package emv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EMV DATA ELEMENTS DICTIONARY (EMV Book 3, Annex A).
+// Only the data elements that a terminal or a card usually exchange are listed.
+
+// Data formats of EMV data elements.
+const (
+	FormatN   = "n"   // Numeric, BCD, right justified with leading zeros
+	FormatCN  = "cn"  // Compressed numeric, BCD, left justified padded with 'F'
+	FormatA   = "a"   // Alphabetic
+	FormatAN  = "an"  // Alphanumeric
+	FormatANS = "ans" // Alphanumeric special
+	FormatB   = "b"   // Binary
+)
+
+// TagInfo describes an EMV data element.
+type TagInfo struct {
+	Tag    string
+	Name   string
+	Format string
+}
+
+var tagDictionary = map[string]TagInfo{}
+
+func init() {
+	for _, info := range []TagInfo{
+		{"42", "Issuer Identification Number", FormatN},
+		{"4F", "Application Identifier (ADF Name)", FormatB},
+		{"50", "Application Label", FormatANS},
+		{"56", "Track 1 Data", FormatANS},
+		{"57", "Track 2 Equivalent Data", FormatB},
+		{"5A", "Application PAN", FormatCN},
+		{"5F20", "Cardholder Name", FormatANS},
+		{"5F24", "Application Expiration Date", FormatN},
+		{"5F25", "Application Effective Date", FormatN},
+		{"5F28", "Issuer Country Code", FormatN},
+		{"5F2A", "Transaction Currency Code", FormatN},
+		{"5F2D", "Language Preference", FormatAN},
+		{"5F30", "Service Code", FormatN},
+		{"5F34", "PAN Sequence Number", FormatN},
+		{"5F36", "Transaction Currency Exponent", FormatN},
+		{"5F50", "Issuer URL", FormatANS},
+		{"5F53", "IBAN", FormatB},
+		{"5F54", "Bank Identifier Code", FormatB},
+		{"5F55", "Issuer Country Code (alpha2)", FormatA},
+		{"5F56", "Issuer Country Code (alpha3)", FormatA},
+		{"82", "Application Interchange Profile", FormatB},
+		{"84", "Dedicated File (DF) Name", FormatB},
+		{"87", "Application Priority Indicator", FormatB},
+		{"88", "Short File Identifier (SFI)", FormatB},
+		{"89", "Authorisation Code", FormatB},
+		{"8A", "Authorisation Response Code", FormatAN},
+		{"8C", "CDOL1", FormatB},
+		{"8D", "CDOL2", FormatB},
+		{"8E", "CVM List", FormatB},
+		{"8F", "Certification Authority Public Key Index", FormatB},
+		{"90", "Issuer Public Key Certificate", FormatB},
+		{"91", "Issuer Authentication Data", FormatB},
+		{"92", "Issuer Public Key Remainder", FormatB},
+		{"93", "Signed Static Application Data", FormatB},
+		{"94", "Application File Locator (AFL)", FormatB},
+		{"95", "Terminal Verification Results", FormatB},
+		{"97", "TDOL", FormatB},
+		{"98", "Transaction Certificate (TC) Hash Value", FormatB},
+		{"99", "Transaction PIN Data", FormatB},
+		{"9A", "Transaction Date", FormatN},
+		{"9B", "Transaction Status Information", FormatB},
+		{"9C", "Transaction Type", FormatN},
+		{"9D", "Directory Definition File (DDF) Name", FormatB},
+		{"9F01", "Acquirer Identifier", FormatN},
+		{"9F02", "Amount, Authorised (Numeric)", FormatN},
+		{"9F03", "Amount, Other (Numeric)", FormatN},
+		{"9F04", "Amount, Other (Binary)", FormatB},
+		{"9F05", "Application Discretionary Data", FormatB},
+		{"9F06", "Application Identifier (AID) - terminal", FormatB},
+		{"9F07", "Application Usage Control", FormatB},
+		{"9F08", "Application Version Number (card)", FormatB},
+		{"9F09", "Application Version Number (terminal)", FormatB},
+		{"9F0B", "Cardholder Name Extended", FormatANS},
+		{"9F0D", "Issuer Action Code - Default", FormatB},
+		{"9F0E", "Issuer Action Code - Denial", FormatB},
+		{"9F0F", "Issuer Action Code - Online", FormatB},
+		{"9F10", "Issuer Application Data", FormatB},
+		{"9F11", "Issuer Code Table Index", FormatN},
+		{"9F12", "Application Preferred Name", FormatANS},
+		{"9F13", "Last Online ATC Register", FormatB},
+		{"9F14", "Lower Consecutive Offline Limit", FormatB},
+		{"9F15", "Merchant Category Code", FormatN},
+		{"9F16", "Merchant Identifier", FormatANS},
+		{"9F17", "PIN Try Counter", FormatB},
+		{"9F1A", "Terminal Country Code", FormatN},
+		{"9F1B", "Terminal Floor Limit", FormatB},
+		{"9F1C", "Terminal Identification", FormatAN},
+		{"9F1D", "Terminal Risk Management Data", FormatB},
+		{"9F1E", "Interface Device (IFD) Serial Number", FormatAN},
+		{"9F1F", "Track 1 Discretionary Data", FormatANS},
+		{"9F20", "Track 2 Discretionary Data", FormatCN},
+		{"9F21", "Transaction Time", FormatN},
+		{"9F23", "Upper Consecutive Offline Limit", FormatB},
+		{"9F26", "Application Cryptogram", FormatB},
+		{"9F27", "Cryptogram Information Data", FormatB},
+		{"9F29", "Extended Selection", FormatB},
+		{"9F2A", "Kernel Identifier", FormatB},
+		{"9F32", "Issuer Public Key Exponent", FormatB},
+		{"9F33", "Terminal Capabilities", FormatB},
+		{"9F34", "Cardholder Verification Method (CVM) Results", FormatB},
+		{"9F35", "Terminal Type", FormatN},
+		{"9F36", "Application Transaction Counter (ATC)", FormatB},
+		{"9F37", "Unpredictable Number", FormatB},
+		{"9F38", "PDOL", FormatB},
+		{"9F39", "POS Entry Mode", FormatN},
+		{"9F3A", "Amount, Reference Currency", FormatB},
+		{"9F3B", "Application Reference Currency", FormatN},
+		{"9F3C", "Transaction Reference Currency Code", FormatN},
+		{"9F3D", "Transaction Reference Currency Exponent", FormatN},
+		{"9F40", "Additional Terminal Capabilities", FormatB},
+		{"9F41", "Transaction Sequence Counter", FormatN},
+		{"9F42", "Application Currency Code", FormatN},
+		{"9F43", "Application Reference Currency Exponent", FormatN},
+		{"9F44", "Application Currency Exponent", FormatN},
+		{"9F45", "Data Authentication Code", FormatB},
+		{"9F46", "ICC Public Key Certificate", FormatB},
+		{"9F47", "ICC Public Key Exponent", FormatB},
+		{"9F48", "ICC Public Key Remainder", FormatB},
+		{"9F49", "DDOL", FormatB},
+		{"9F4A", "Static Data Authentication Tag List", FormatB},
+		{"9F4B", "Signed Dynamic Application Data", FormatB},
+		{"9F4C", "ICC Dynamic Number", FormatB},
+		{"9F4D", "Log Entry", FormatB},
+		{"9F4E", "Merchant Name and Location", FormatANS},
+		{"9F4F", "Log Format", FormatB},
+		{"9F51", "DRDOL", FormatB},
+		{"9F5C", "DS Requested Operator ID", FormatB},
+		{"9F5E", "DS ID", FormatB},
+		{"9F66", "Terminal Transaction Qualifiers (TTQ)", FormatB},
+		{"9F69", "Card Authentication Related Data", FormatB},
+		{"9F6B", "Track 2 Data", FormatB},
+		{"9F6C", "Card Transaction Qualifiers (CTQ)", FormatB},
+		{"9F6E", "Form Factor Indicator (FFI)", FormatB},
+		{"9F7D", "DS Summary 1", FormatB},
+		{"BF0C", "FCI Issuer Discretionary Data", FormatB},
+		{"DF8101", "DS Summary 2", FormatB},
+		{"DF8102", "DS Summary 3", FormatB},
+		{"DF8106", "Data Needed", FormatB},
+		{"DF8112", "Tags To Read", FormatB},
+		{"DF8117", "Card Data Input Capability", FormatB},
+		{"DF8118", "CVM Capability - CVM Required", FormatB},
+		{"DF8119", "CVM Capability - No CVM Required", FormatB},
+		{"DF811B", "Kernel Configuration", FormatB},
+		{"DF811C", "Max Lifetime of Torn Transaction Log Record", FormatB},
+		{"DF811D", "Max Number of Torn Transaction Log Records", FormatB},
+		{"DF811F", "Security Capability", FormatB},
+		{"DF8120", "Terminal Action Code - Default", FormatB},
+		{"DF8121", "Terminal Action Code - Denial", FormatB},
+		{"DF8122", "Terminal Action Code - Online", FormatB},
+		{"DF8123", "Reader Contactless Floor Limit", FormatN},
+		{"DF8124", "Reader Contactless Transaction Limit (No On-device CVM)", FormatN},
+		{"DF8125", "Reader Contactless Transaction Limit (On-device CVM)", FormatN},
+		{"DF8126", "Reader CVM Required Limit", FormatN},
+		{"FF8104", "Data To Send", FormatB},
+	} {
+		tagDictionary[info.Tag] = info
+	}
+}
+
+// LookupTag returns the dictionary entry of an EMV tag ("9f02" and "9F02" are equivalent).
+func LookupTag(tag string) (TagInfo, bool) {
+	info, ok := tagDictionary[strings.ToUpper(tag)]
+	return info, ok
+}
+
+// TagName returns the name of an EMV tag, or "Unknown Tag XX" when it is not in the dictionary.
+func TagName(tag string) string {
+	if info, ok := LookupTag(tag); ok {
+		return info.Name
+	}
+	return "Unknown Tag " + strings.ToUpper(tag)
+}
+
+// tagLabel returns "Name (Tag)" for known tags and "Unknown Tag XX" otherwise.
+func tagLabel(tag string) string {
+	if info, ok := LookupTag(tag); ok {
+		return fmt.Sprintf("%s (%s)", info.Name, info.Tag)
+	}
+	return TagName(tag)
+}
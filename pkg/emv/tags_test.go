@@ -0,0 +1,18 @@
+package emv
+
+import "testing"
+
+func TestLookupTag(t *testing.T) {
+	info, ok := LookupTag("9f02")
+	if !ok || info.Name != "Amount, Authorised (Numeric)" || info.Format != FormatN {
+		t.Errorf("LookupTag(9f02) = %+v, %v", info, ok)
+	}
+
+	if _, ok := LookupTag("DF7F"); ok {
+		t.Error("DF7F should not be in the dictionary")
+	}
+
+	if got := TagName("df7f"); got != "Unknown Tag DF7F" {
+		t.Errorf("TagName(df7f) = %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/bits"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// TERMINAL CAPABILITIES Logic according to EMV Book 4, Annex A2.
+// The Terminal Capabilities (Tag '9F33', 3 bytes) describe the terminal:
+//
+// - Byte 1 (Card Data Input): manual key entry, magnetic stripe, IC with contacts.
+// - Byte 2 (CVM Capability): plaintext PIN for ICC verification, enciphered PIN for online
+//   verification, signature, enciphered PIN for offline verification, No CVM required.
+// - Byte 3 (Security Capability): SDA, DDA, card capture, CDA.
+//
+// TRANSACTION TYPE (Tag '9C', n 2): the first two digits of the ISO 8583:1987 Processing Code.
+
+// TerminalCapabilities is the value of Tag '9F33'.
+type TerminalCapabilities [3]byte
+
+var terminalCapabilitiesBits = []bitMeaning{
+	{0, 8, "Manual key entry"},
+	{0, 7, "Magnetic stripe"},
+	{0, 6, "IC with contacts"},
+	{1, 8, "Plaintext PIN for ICC verification"},
+	{1, 7, "Enciphered PIN for online verification"},
+	{1, 6, "Signature (paper)"},
+	{1, 5, "Enciphered PIN for offline verification"},
+	{1, 4, "No CVM required"},
+	{2, 8, "SDA"},
+	{2, 7, "DDA"},
+	{2, 6, "Card capture"},
+	{2, 4, "CDA"},
+}
+
+// NewTerminalCapabilities creates the capabilities from the value of Tag '9F33'.
+func NewTerminalCapabilities(value []byte) (TerminalCapabilities, error) {
+	if len(value) != 3 {
+		return TerminalCapabilities{}, fmt.Errorf("Terminal Capabilities must be 3 bytes long (got %d)", len(value))
+	}
+	return TerminalCapabilities{value[0], value[1], value[2]}, nil
+}
+
+// SupportsPlaintextPIN reports whether the terminal supports plaintext PIN for ICC verification.
+func (c TerminalCapabilities) SupportsPlaintextPIN() bool { return bits.IsSet(c[1], 8) }
+
+// SupportsOnlinePIN reports whether the terminal supports enciphered PIN for online verification.
+func (c TerminalCapabilities) SupportsOnlinePIN() bool { return bits.IsSet(c[1], 7) }
+
+// SupportsSignature reports whether the terminal supports signature (paper).
+func (c TerminalCapabilities) SupportsSignature() bool { return bits.IsSet(c[1], 6) }
+
+// SupportsEncipheredPIN reports whether the terminal supports enciphered PIN for offline verification.
+func (c TerminalCapabilities) SupportsEncipheredPIN() bool { return bits.IsSet(c[1], 5) }
+
+// SupportsNoCVM reports whether the terminal supports No CVM required.
+func (c TerminalCapabilities) SupportsNoCVM() bool { return bits.IsSet(c[1], 4) }
+
+// SupportsSDA reports whether the terminal supports Static Data Authentication.
+func (c TerminalCapabilities) SupportsSDA() bool { return bits.IsSet(c[2], 8) }
+
+// SupportsDDA reports whether the terminal supports Dynamic Data Authentication.
+func (c TerminalCapabilities) SupportsDDA() bool { return bits.IsSet(c[2], 7) }
+
+// SupportsCDA reports whether the terminal supports Combined DDA / Application Cryptogram Generation.
+func (c TerminalCapabilities) SupportsCDA() bool { return bits.IsSet(c[2], 4) }
+
+// Report builds the structured report of the terminal capabilities.
+func (c TerminalCapabilities) Report() *report.Report {
+	rep := report.New("EMV TERMINAL CAPABILITIES")
+	describeBits(rep.AddSection(fmt.Sprintf("Terminal Capabilities (9F33): %X", c[:])), c[:], terminalCapabilitiesBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the terminal capabilities.
+func (c TerminalCapabilities) Describe() string {
+	return report.Text(c.Report())
+}
+
+// TransactionType is the value of Tag '9C'.
+type TransactionType byte
+
+const (
+	TransactionPurchase TransactionType = 0x00 // Goods and services
+	TransactionCash     TransactionType = 0x01
+	TransactionCashback TransactionType = 0x09 // Purchase with cashback
+	TransactionRefund   TransactionType = 0x20
+)
+
+func (t TransactionType) String() string {
+	switch t {
+	case TransactionPurchase:
+		return "Purchase"
+	case TransactionCash:
+		return "Cash"
+	case TransactionCashback:
+		return "Purchase with cashback"
+	case TransactionRefund:
+		return "Refund"
+	default:
+		return fmt.Sprintf("Unknown (%02X)", byte(t))
+	}
+}
@@ -0,0 +1,74 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTerminalCapabilities(t *testing.T) {
+	caps, err := NewTerminalCapabilities([]byte{0xE0, 0x68, 0xC8})
+	if err != nil {
+		t.Fatalf("NewTerminalCapabilities failed: %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"PlaintextPIN", caps.SupportsPlaintextPIN(), false},
+		{"OnlinePIN", caps.SupportsOnlinePIN(), true},
+		{"Signature", caps.SupportsSignature(), true},
+		{"EncipheredPIN", caps.SupportsEncipheredPIN(), false},
+		{"NoCVM", caps.SupportsNoCVM(), true},
+		{"SDA", caps.SupportsSDA(), true},
+		{"DDA", caps.SupportsDDA(), true},
+		{"CDA", caps.SupportsCDA(), true},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	if _, err := NewTerminalCapabilities([]byte{0xE0, 0x68}); err == nil {
+		t.Error("NewTerminalCapabilities should reject a 2-byte value")
+	}
+}
+
+func TestTerminalCapabilitiesDescribe(t *testing.T) {
+	expected := []string{
+		"=== EMV TERMINAL CAPABILITIES ===",
+		"Terminal Capabilities (9F33): E068C8",
+		"    - Byte 1 Bit 8: [x] Manual key entry",
+		"    - Byte 2 Bit 8: [ ] Plaintext PIN for ICC verification",
+		"    - Byte 2 Bit 7: [x] Enciphered PIN for online verification",
+		"    - Byte 3 Bit 4: [x] CDA",
+	}
+
+	desc := TerminalCapabilities{0xE0, 0x68, 0xC8}.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
+
+func TestTransactionTypeString(t *testing.T) {
+	tests := []struct {
+		tt       TransactionType
+		expected string
+	}{
+		{TransactionPurchase, "Purchase"},
+		{TransactionCash, "Cash"},
+		{TransactionCashback, "Purchase with cashback"},
+		{TransactionRefund, "Refund"},
+		{TransactionType(0x31), "Unknown (31)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tt.String(); got != tt.expected {
+			t.Errorf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
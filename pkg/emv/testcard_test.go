@@ -0,0 +1,55 @@
+package emv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// testCard describes the exchanges of a mock card holding one application. Hex strings may
+// contain spaces.
+//
+// When AID is set, the card answers its SELECT with an FCI holding the Application Label
+// and the other Proprietary data objects (e.g. the PDOL). It answers the GPO command with
+// GPOResponse and the READ RECORD of SFI 1 with Records, from record 1. Responses and
+// Sequences hold the other exchanges, each response with its status word.
+type testCard struct {
+	AID         string
+	Label       string // Application Label, as hex
+	Proprietary string // Other data objects of the FCI Proprietary Template
+	GPO         string // GET PROCESSING OPTIONS command
+	GPOResponse string // Response to GPO, without status word
+	Records     []string
+	Responses   map[string]string
+	Sequences   map[string][]string
+}
+
+// fci returns the response to the SELECT of the application, without status word.
+func (c testCard) fci() string {
+	return tlvHex("6F", tlvHex("84", c.AID), tlvHex("A5", tlvHex("50", c.Label), c.Proprietary))
+}
+
+// mock builds the mockCard answering the exchanges of the card.
+func (c testCard) mock() *mockCard {
+	m := &mockCard{responses: map[string]string{}, sequences: map[string][]string{}}
+	if c.AID != "" {
+		m.responses[fmt.Sprintf("00A40400%02X%s", len(c.AID)/2, c.AID)] = c.fci() + "9000"
+	}
+	if c.GPO != "" {
+		m.responses[commandKey(c.GPO)] = c.GPOResponse + "9000"
+	}
+	for i, record := range c.Records {
+		m.responses[fmt.Sprintf("00B2%02X0C00", i+1)] = record + "9000"
+	}
+	for cmd, resp := range c.Responses {
+		m.responses[commandKey(cmd)] = resp
+	}
+	for cmd, queue := range c.Sequences {
+		m.sequences[commandKey(cmd)] = queue
+	}
+	return m
+}
+
+// commandKey returns the key of a command in a mockCard.
+func commandKey(cmd string) string {
+	return strings.ToUpper(strings.ReplaceAll(cmd, " ", ""))
+}
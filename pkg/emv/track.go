@@ -0,0 +1,359 @@
+package emv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// TRACK DATA Logic according to EMV Book 3, Annex A, and ISO/IEC 7813.
+// The card carries an image of its magnetic stripe:
+//
+// 1. Track 2 Equivalent Data ('57', and Track 2 Data '9F6B' of contactless mag-stripe mode),
+//    compressed numeric: PAN (up to 19 digits), separator 'D', Expiration Date (YYMM),
+//    Service Code (3 digits) and discretionary data, padded with 'F' to a whole byte.
+// 2. Track 1 Data ('56'), format B of ISO/IEC 7813: 'B', PAN, '^', cardholder name, '^',
+//    Expiration Date (YYMM), Service Code and discretionary data.
+// 3. Service Code: digit 1 is the interchange (and chip presence), digit 2 the
+//    authorisation processing, digit 3 the allowed services and the PIN requirements.
+// 4. The PAN ends with a Luhn check digit. It is masked when displayed: the first six and
+//    last four digits remain visible.
+//
+// The track images must agree with the Application PAN ('5A'), the Application Expiration
+// Date ('5F24'), the Service Code ('5F30') and the Cardholder Name ('5F20').
+
+// ServiceCode is the 3 digit Service Code of the track data.
+type ServiceCode string
+
+var serviceCodeInterchange = map[byte]string{
+	'1': "International interchange OK",
+	'2': "International interchange, use IC (chip) where feasible",
+	'5': "National interchange only, except under bilateral agreement",
+	'6': "National interchange only, use IC (chip) where feasible",
+	'7': "No interchange, except under bilateral agreement",
+	'9': "Test",
+}
+
+var serviceCodeAuthorization = map[byte]string{
+	'0': "Normal",
+	'2': "Contact issuer via online means",
+	'4': "Contact issuer via online means, except under bilateral agreement",
+}
+
+var serviceCodeServices = map[byte]string{
+	'0': "No restrictions, PIN required",
+	'1': "No restrictions",
+	'2': "Goods and services only",
+	'3': "ATM only, PIN required",
+	'4': "Cash only",
+	'5': "Goods and services only, PIN required",
+	'6': "No restrictions, use PIN where feasible",
+	'7': "Goods and services only, use PIN where feasible",
+}
+
+func (s ServiceCode) digit(i int, meanings map[byte]string) string {
+	if len(s) != 3 {
+		return "Invalid"
+	}
+	if name, ok := meanings[s[i]]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved (%c)", s[i])
+}
+
+// Interchange returns the meaning of the first digit.
+func (s ServiceCode) Interchange() string { return s.digit(0, serviceCodeInterchange) }
+
+// Authorization returns the meaning of the second digit.
+func (s ServiceCode) Authorization() string { return s.digit(1, serviceCodeAuthorization) }
+
+// PINRequirement returns the meaning of the third digit: the allowed services and PIN requirements.
+func (s ServiceCode) PINRequirement() string { return s.digit(2, serviceCodeServices) }
+
+// Chip reports whether the card carries an IC (first digit 2 or 6).
+func (s ServiceCode) Chip() bool {
+	return len(s) == 3 && (s[0] == '2' || s[0] == '6')
+}
+
+func (s ServiceCode) describe(section *report.Section) {
+	section.Note("Service Code", string(s))
+	section.Note("Interchange", s.Interchange())
+	section.Note("Authorization", s.Authorization())
+	section.Note("Services and PIN", s.PINRequirement())
+}
+
+// Track2 is the decoded Track 2 Equivalent Data.
+type Track2 struct {
+	PAN           string
+	Expiry        string // YYMM
+	ServiceCode   ServiceCode
+	Discretionary string
+}
+
+// ParseTrack2 decodes the value of Track 2 Equivalent Data ('57') or Track 2 Data ('9F6B').
+func ParseTrack2(value []byte) (*Track2, error) {
+	digits := strings.TrimSuffix(fmt.Sprintf("%X", value), "F")
+
+	pan, rest, found := strings.Cut(digits, "D")
+	if !found {
+		return nil, fmt.Errorf("Track 2: missing field separator 'D'")
+	}
+	if err := checkPANDigits(pan); err != nil {
+		return nil, fmt.Errorf("Track 2: %w", err)
+	}
+	if len(rest) < 7 || !isDigits(rest) {
+		return nil, fmt.Errorf("Track 2: expiry, service code and discretionary data must be at least 7 digits (got %q)", rest)
+	}
+
+	return &Track2{
+		PAN:           pan,
+		Expiry:        rest[:4],
+		ServiceCode:   ServiceCode(rest[4:7]),
+		Discretionary: rest[7:],
+	}, nil
+}
+
+// Report builds the structured report of the Track 2 data, with a masked PAN.
+func (t *Track2) Report() *report.Report {
+	rep := report.New("EMV TRACK 2 EQUIVALENT DATA")
+	t.describe(rep.AddSection("[1] Fields:"))
+	return rep
+}
+
+func (t *Track2) describe(section *report.Section) {
+	section.Note("PAN", MaskPAN(t.PAN))
+	section.Note("Expiration Date (YYMM)", t.Expiry)
+	t.ServiceCode.describe(section)
+	section.Note("Discretionary Data", t.Discretionary)
+}
+
+// Describe generates a human-readable report of the Track 2 data.
+func (t *Track2) Describe() string {
+	return report.Text(t.Report())
+}
+
+// Track1 is the decoded Track 1 Data.
+type Track1 struct {
+	PAN           string
+	Name          string
+	Expiry        string // YYMM
+	ServiceCode   ServiceCode
+	Discretionary string
+}
+
+// ParseTrack1 decodes the value of Track 1 Data ('56').
+func ParseTrack1(value []byte) (*Track1, error) {
+	fields := strings.SplitN(string(value), "^", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("Track 1: expected 3 fields separated by '^' (got %d)", len(fields))
+	}
+	if !strings.HasPrefix(fields[0], "B") {
+		return nil, fmt.Errorf("Track 1: format code must be 'B' (got %q)", fields[0])
+	}
+	pan := fields[0][1:]
+	if err := checkPANDigits(pan); err != nil {
+		return nil, fmt.Errorf("Track 1: %w", err)
+	}
+	rest := fields[2]
+	if len(rest) < 7 || !isDigits(rest[:7]) {
+		return nil, fmt.Errorf("Track 1: expiry and service code must be 7 digits (got %q)", rest)
+	}
+
+	return &Track1{
+		PAN:           pan,
+		Name:          strings.TrimSpace(fields[1]),
+		Expiry:        rest[:4],
+		ServiceCode:   ServiceCode(rest[4:7]),
+		Discretionary: rest[7:],
+	}, nil
+}
+
+// Report builds the structured report of the Track 1 data, with a masked PAN.
+func (t *Track1) Report() *report.Report {
+	rep := report.New("EMV TRACK 1 DATA")
+	t.describe(rep.AddSection("[1] Fields:"))
+	return rep
+}
+
+func (t *Track1) describe(section *report.Section) {
+	section.Note("PAN", MaskPAN(t.PAN))
+	section.Note("Name", t.Name)
+	section.Note("Expiration Date (YYMM)", t.Expiry)
+	t.ServiceCode.describe(section)
+	section.Note("Discretionary Data", t.Discretionary)
+}
+
+// Describe generates a human-readable report of the Track 1 data.
+func (t *Track1) Describe() string {
+	return report.Text(t.Report())
+}
+
+// PANDigits returns the digits of a PAN in format cn, without the 'F' padding.
+func PANDigits(value []byte) string {
+	return strings.TrimRight(fmt.Sprintf("%X", value), "F")
+}
+
+// LuhnValid reports whether the last digit of the PAN is its Luhn check digit.
+func LuhnValid(pan string) bool {
+	if len(pan) < 2 || !isDigits(pan) {
+		return false
+	}
+	sum := 0
+	for i := range pan {
+		d := int(pan[len(pan)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// MaskPAN hides the digits of the PAN except the first six and the last four. PANs of 10
+// digits or less only keep the last four.
+func MaskPAN(pan string) string {
+	if len(pan) <= 4 {
+		return strings.Repeat("*", len(pan))
+	}
+	first := 6
+	if len(pan) <= 10 {
+		first = 0
+	}
+	return pan[:first] + strings.Repeat("*", len(pan)-first-4) + pan[len(pan)-4:]
+}
+
+func checkPANDigits(pan string) error {
+	if len(pan) == 0 || len(pan) > 19 || !isDigits(pan) {
+		return fmt.Errorf("PAN must be 1 to 19 digits (got %q)", pan)
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// TrackCheck is one consistency check of the track data.
+type TrackCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// TrackData is the decoded track data of a card and its consistency with the other data objects.
+type TrackData struct {
+	PAN    string // Application PAN ('5A'), empty when absent
+	Track2 *Track2
+	Track1 *Track1
+	Checks []TrackCheck
+}
+
+func (r *TrackData) check(name string, passed bool, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, TrackCheck{Name: name, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Consistent reports whether every check passed.
+func (r *TrackData) Consistent() bool {
+	for _, c := range r.Checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadTrackData decodes the track data of the card and checks it against the Application
+// PAN, Expiration Date, Service Code and Cardholder Name. It returns an error when a track
+// is malformed.
+func ReadTrackData(card DataSource) (*TrackData, error) {
+	r := &TrackData{}
+	if pan, ok := card.Lookup("5A"); ok {
+		r.PAN = PANDigits(pan)
+		r.check("Application PAN", LuhnValid(r.PAN), "%s Luhn check digit", MaskPAN(r.PAN))
+	}
+
+	for _, tag := range []string{"57", "9F6B"} {
+		if value, ok := card.Lookup(tag); ok {
+			track, err := ParseTrack2(value)
+			if err != nil {
+				return r, fmt.Errorf("%s: %w", tagLabel(tag), err)
+			}
+			r.Track2 = track
+			r.checkTrack(card, tagLabel(tag), track.PAN, track.Expiry, track.ServiceCode)
+			break
+		}
+	}
+
+	if value, ok := card.Lookup("56"); ok {
+		track, err := ParseTrack1(value)
+		if err != nil {
+			return r, fmt.Errorf("%s: %w", tagLabel("56"), err)
+		}
+		r.Track1 = track
+		r.checkTrack(card, tagLabel("56"), track.PAN, track.Expiry, track.ServiceCode)
+		if name, ok := card.Lookup("5F20"); ok {
+			cardName := strings.TrimSpace(string(name))
+			r.check("Track 1 Cardholder Name", cardName == track.Name, "%q (card: %q)", track.Name, cardName)
+		}
+	}
+	return r, nil
+}
+
+// checkTrack compares the fields of a track with the data objects of the card.
+func (r *TrackData) checkTrack(card DataSource, label, pan, expiry string, sc ServiceCode) {
+	if r.PAN != "" {
+		r.check(label+" PAN", pan == r.PAN, "%s (card: %s)", MaskPAN(pan), MaskPAN(r.PAN))
+	} else {
+		r.check(label+" PAN", LuhnValid(pan), "%s Luhn check digit", MaskPAN(pan))
+	}
+	if date, ok := card.Lookup("5F24"); ok && len(date) == 3 {
+		cardExpiry := fmt.Sprintf("%X", date[:2])
+		r.check(label+" Expiration Date", expiry == cardExpiry, "%s (card: %s)", expiry, cardExpiry)
+	}
+	if code, ok := card.Lookup("5F30"); ok && len(code) == 2 {
+		cardCode := ServiceCode(fmt.Sprintf("%X", code)[1:])
+		r.check(label+" Service Code", sc == cardCode, "%s (card: %s)", sc, cardCode)
+	}
+}
+
+// Report builds the structured report of the track data, with masked PANs.
+func (r *TrackData) Report() *report.Report {
+	rep := report.New("EMV TRACK DATA")
+	index := 1
+
+	if r.Track2 != nil {
+		r.Track2.describe(rep.AddSection(fmt.Sprintf("[%d] Track 2:", index)))
+		index++
+	}
+	if r.Track1 != nil {
+		r.Track1.describe(rep.AddSection(fmt.Sprintf("[%d] Track 1:", index)))
+		index++
+	}
+
+	checks := rep.AddSection(fmt.Sprintf("[%d] Consistency:", index))
+	for _, c := range r.Checks {
+		state := "[OK]"
+		if !c.Passed {
+			state = "[!!]"
+		}
+		checks.Note(c.Name, fmt.Sprintf("%s %s", state, c.Detail))
+	}
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	outcome.Note("Consistent", fmt.Sprintf("%t", r.Consistent()))
+	return rep
+}
+
+// Describe generates a human-readable report of the track data.
+func (r *TrackData) Describe() string {
+	return report.Text(r.Report())
+}
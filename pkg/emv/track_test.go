@@ -0,0 +1,242 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const testTrack1 = "B4761739001010010^VISA/TEST ^27122011234500000"
+
+func TestParseTrack2(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *Track2
+		wantErr string
+	}{
+		{
+			name:  "Padded",
+			value: "4761739001010010D2712201123450000F",
+			want:  &Track2{PAN: "4761739001010010", Expiry: "2712", ServiceCode: "201", Discretionary: "123450000"},
+		},
+		{
+			name:  "No discretionary data",
+			value: "5413330089020011D2512101",
+			want:  &Track2{PAN: "5413330089020011", Expiry: "2512", ServiceCode: "101"},
+		},
+		{name: "Missing separator", value: "476173900101001027", wantErr: "missing field separator"},
+		{name: "PAN too long", value: "47617390010100101234D2712201", wantErr: "PAN must be 1 to 19 digits"},
+		{name: "Too short", value: "4761739001010010D271220F", wantErr: "at least 7 digits"},
+		{name: "Not numeric", value: "4761739001010010D2712201AB", wantErr: "at least 7 digits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTrack2(tlv.Hex(tt.value))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseTrack2() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTrack2() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ParseTrack2() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseTrack1(t *testing.T) {
+	got, err := ParseTrack1([]byte(testTrack1))
+	if err != nil {
+		t.Fatalf("ParseTrack1() error = %v", err)
+	}
+	want := &Track1{PAN: "4761739001010010", Name: "VISA/TEST", Expiry: "2712", ServiceCode: "201", Discretionary: "1234500000"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseTrack1() mismatch (-want +got):\n%s", diff)
+	}
+
+	for value, wantErr := range map[string]string{
+		"B4761739001010010^VISA":      "expected 3 fields",
+		"%4761739001010010^A^2712201": "format code must be 'B'",
+		"B4761739001010010^A^2712":    "must be 7 digits",
+		"B47617390010A0010^A^2712201": "PAN must be 1 to 19 digits",
+	} {
+		if _, err := ParseTrack1([]byte(value)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("ParseTrack1(%q) error = %v, want %q", value, err, wantErr)
+		}
+	}
+}
+
+func TestServiceCode(t *testing.T) {
+	tests := []struct {
+		code          ServiceCode
+		interchange   string
+		authorization string
+		services      string
+		chip          bool
+	}{
+		{"201", "International interchange, use IC (chip) where feasible", "Normal", "No restrictions", true},
+		{"101", "International interchange OK", "Normal", "No restrictions", false},
+		{"620", "National interchange only, use IC (chip) where feasible", "Contact issuer via online means", "No restrictions, PIN required", true},
+		{"383", "Reserved (3)", "Reserved (8)", "ATM only, PIN required", false},
+		{"20", "Invalid", "Invalid", "Invalid", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			got := []string{tt.code.Interchange(), tt.code.Authorization(), tt.code.PINRequirement()}
+			if diff := cmp.Diff([]string{tt.interchange, tt.authorization, tt.services}, got); diff != "" {
+				t.Errorf("ServiceCode mismatch (-want +got):\n%s", diff)
+			}
+			if got := tt.code.Chip(); got != tt.chip {
+				t.Errorf("Chip() = %t, want %t", got, tt.chip)
+			}
+		})
+	}
+}
+
+func TestPANUtilities(t *testing.T) {
+	if got := PANDigits(tlv.Hex("4761739001010010FFFF")); got != "4761739001010010" {
+		t.Errorf("PANDigits() = %q", got)
+	}
+
+	luhn := map[string]bool{
+		"4761739001010010": true,
+		"5413330089020011": true,
+		"4761739001010011": false,
+		"476173900101001A": false,
+		"4":                false,
+	}
+	for pan, want := range luhn {
+		if got := LuhnValid(pan); got != want {
+			t.Errorf("LuhnValid(%q) = %t, want %t", pan, got, want)
+		}
+	}
+
+	masks := map[string]string{
+		"4761739001010010":    "476173******0010",
+		"6799998900000000019": "679999*********0019",
+		"1234567890":          "******7890",
+		"123":                 "***",
+	}
+	for pan, want := range masks {
+		if got := MaskPAN(pan); got != want {
+			t.Errorf("MaskPAN(%q) = %q, want %q", pan, got, want)
+		}
+	}
+}
+
+func TestReadTrackData(t *testing.T) {
+	card := TerminalData{
+		"5A":   tlv.Hex("4761739001010010"),
+		"57":   tlv.Hex("4761739001010010D2712201123450000F"),
+		"56":   []byte(testTrack1),
+		"5F20": []byte("VISA/TEST "),
+		"5F24": tlv.Hex("271231"),
+		"5F30": tlv.Hex("0201"),
+	}
+
+	tests := []struct {
+		name   string
+		modify func(card TerminalData)
+		failed []string
+	}{
+		{name: "Consistent"},
+		{
+			name:   "Different PAN",
+			modify: func(card TerminalData) { card["5A"] = tlv.Hex("5413330089020011") },
+			failed: []string{"Track 2 Equivalent Data (57) PAN", "Track 1 Data (56) PAN"},
+		},
+		{
+			name:   "Different expiry and service code",
+			modify: func(card TerminalData) { card["5F24"] = tlv.Hex("281231"); card["5F30"] = tlv.Hex("0101") },
+			failed: []string{
+				"Track 2 Equivalent Data (57) Expiration Date", "Track 2 Equivalent Data (57) Service Code",
+				"Track 1 Data (56) Expiration Date", "Track 1 Data (56) Service Code",
+			},
+		},
+		{
+			name: "Invalid Luhn",
+			modify: func(card TerminalData) {
+				delete(card, "5A")
+				delete(card, "56")
+				card["57"] = tlv.Hex("4761739001010011D2712201")
+			},
+			failed: []string{"Track 2 Equivalent Data (57) PAN"},
+		},
+		{
+			name:   "Different name",
+			modify: func(card TerminalData) { card["5F20"] = []byte("OTHER/NAME") },
+			failed: []string{"Track 1 Cardholder Name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := TerminalData{}
+			for tag, value := range card {
+				data[tag] = value
+			}
+			if tt.modify != nil {
+				tt.modify(data)
+			}
+
+			r, err := ReadTrackData(data)
+			if err != nil {
+				t.Fatalf("ReadTrackData() error = %v", err)
+			}
+			var failed []string
+			for _, c := range r.Checks {
+				if !c.Passed {
+					failed = append(failed, c.Name)
+				}
+			}
+			if diff := cmp.Diff(tt.failed, failed); diff != "" {
+				t.Errorf("failed checks mismatch (-want +got):\n%s", diff)
+			}
+			if r.Consistent() != (len(tt.failed) == 0) {
+				t.Errorf("Consistent() = %t", r.Consistent())
+			}
+		})
+	}
+}
+
+func TestReadTrackData_Malformed(t *testing.T) {
+	_, err := ReadTrackData(TerminalData{"9F6B": tlv.Hex("4761739001010010")})
+	if err == nil || !strings.Contains(err.Error(), "Track 2 Data (9F6B)") {
+		t.Errorf("ReadTrackData() error = %v", err)
+	}
+}
+
+func TestTrackData_Describe(t *testing.T) {
+	r, err := ReadTrackData(TerminalData{
+		"5A": tlv.Hex("4761739001010010"),
+		"57": tlv.Hex("4761739001010010D2712201123450000F"),
+	})
+	if err != nil {
+		t.Fatalf("ReadTrackData() error = %v", err)
+	}
+
+	out := r.Describe()
+	for _, want := range []string{
+		"=== EMV TRACK DATA ===",
+		"PAN: 476173******0010",
+		"Interchange: International interchange, use IC (chip) where feasible",
+		"Track 2 Equivalent Data (57) PAN: [OK] 476173******0010 (card: 476173******0010)",
+		"Consistent: true",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Describe() does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "4761739001010010") {
+		t.Errorf("Describe() shows the full PAN:\n%s", out)
+	}
+}
@@ -0,0 +1,793 @@
+package emv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// CONTACT TRANSACTION FLOW Logic according to EMV Book 3, section 10 and Book 4, section 6.
+// A Transaction runs every step of a contact transaction with the card, in order:
+//
+//  1. Application Selection (Book 1, 12): candidate list, then final SELECT of the
+//     highest priority candidate.
+//  2. Initiate Application Processing (10.1): GET PROCESSING OPTIONS with the PDOL data.
+//     A card answering '6985' removes the application from the candidate list.
+//  3. Read Application Data (10.2): READ RECORD of every record of the AFL.
+//  4. Offline Data Authentication (10.3): CDA when supported by both the card and the
+//     terminal, otherwise DDA, otherwise SDA. CDA is verified with the GENERATE AC responses.
+//  5. Processing Restrictions (10.4), Cardholder Verification (10.5) and Terminal Risk
+//     Management (10.6), when announced by the AIP.
+//  6. Terminal Action Analysis (10.7) and first GENERATE AC (10.8). The card answers with
+//     an AAC (declined offline), a TC (approved offline) or an ARQC (go online).
+//  7. Online Processing (10.9): the ARQC is sent to the issuer through the OnlineAuthorizer.
+//     The response may carry Issuer Authentication Data, sent with EXTERNAL AUTHENTICATE,
+//     and issuer scripts, run before ('71') and after ('72') the second GENERATE AC.
+//     When the issuer cannot be reached, the Default action codes decide.
+//  8. Completion (10.11): second GENERATE AC, requesting a TC when the transaction is
+//     approved, an AAC otherwise.
+
+// ErrNoApplication is returned when no candidate application could be selected.
+var ErrNoApplication = errors.New("no application could be selected")
+
+// Authorisation Response Codes ('8A') generated by the terminal when the issuer cannot
+// be reached (Book 4, Annex A6).
+var (
+	ARCUnableToGoOnlineApproved = []byte("Y3")
+	ARCUnableToGoOnlineDeclined = []byte("Z3")
+)
+
+// approvedARCs are the Authorisation Response Codes approving a transaction.
+var approvedARCs = []string{"00", "08", "10", "11"}
+
+// TransactionConfig is the configuration of a contact terminal.
+type TransactionConfig struct {
+	AIDs         []TerminalAID
+	Capabilities TerminalCapabilities
+
+	// Data holds the terminal data requested by the DOLs, for instance the Terminal Country
+	// Code ('9F1A'), the Transaction Currency Code ('5F2A'), the Terminal Type ('9F35') and
+	// the Application Version Number ('9F09').
+	Data TerminalData
+
+	CAKeys         CAKeyStore
+	ActionCodes    ActionCodes // Terminal Action Codes
+	RiskManagement RiskManagement
+
+	ATM        bool
+	Services   bool // The purchases are for services rather than goods
+	Unattended bool
+}
+
+// OnlineRequest is the authorisation request sent to the issuer.
+type OnlineRequest struct {
+	// Terminal holds the terminal and transaction data, including the TVR ('95') and the
+	// Unpredictable Number ('9F37').
+	Terminal   TerminalData
+	Card       *ApplicationData
+	CDOL1Data  []byte
+	Cryptogram *Cryptogram
+}
+
+// OnlineResponse is the response of the issuer.
+type OnlineResponse struct {
+	ARC                      []byte // Authorisation Response Code ('8A')
+	IssuerAuthenticationData []byte // '91', optional
+	Scripts                  []IssuerScript
+}
+
+// Approved reports whether the Authorisation Response Code approves the transaction.
+func (r *OnlineResponse) Approved() bool {
+	for _, code := range approvedARCs {
+		if string(r.ARC) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlineAuthorizer sends authorisation requests to the issuer. An error means that the
+// terminal is unable to go online.
+type OnlineAuthorizer interface {
+	Authorize(req *OnlineRequest) (*OnlineResponse, error)
+}
+
+// Transaction runs contact transactions with a card.
+type Transaction struct {
+	Client *iso7816.Client
+	Config TransactionConfig
+
+	// Online sends the authorisation requests. Nil means an offline-only terminal.
+	Online OnlineAuthorizer
+
+	// PIN performs offline PIN verification. Nil assumes that the cardholder completes
+	// the offline CVMs. Each run uses a copy of it, completed with Client and with the
+	// PIN encipherment key of the card when enciphered PIN is performed.
+	PIN *PINVerifier
+
+	// Random provides the Unpredictable Number. Nil uses crypto/rand.
+	Random io.Reader
+
+	// Now is the date and time of the transaction. Zero means time.Now().
+	Now time.Time
+}
+
+// TransactionOutcome is the final decision of a transaction.
+type TransactionOutcome string
+
+const (
+	OutcomeApproved TransactionOutcome = "Approved"
+	OutcomeDeclined TransactionOutcome = "Declined"
+)
+
+// TransactionResult is the outcome of every step of a transaction. The steps that were
+// not reached are nil.
+type TransactionResult struct {
+	Amount      uint64
+	AmountOther uint64
+	Type        TransactionType
+
+	Selection         *CandidateList
+	Application       *Candidate
+	ProcessingOptions *ProcessingOptions
+	Data              *ApplicationData
+	ODA               *AuthenticationResult
+	Restrictions      *RestrictionsResult
+	CVM               *CVMEvaluation
+	Risk              *RiskResult
+	Action            *ActionDecision
+	FirstAC           *Cryptogram
+	Online            *OnlineResponse
+	Issuer            *IssuerProcessing
+	SecondAC          *Cryptogram
+
+	// OnlineError is set when the terminal was unable to go online.
+	OnlineError string
+
+	TVR     TVR
+	TSI     TSI
+	Outcome TransactionOutcome
+	Steps   []string
+
+	// Trace keeps every exchange of the transaction, in order.
+	Trace iso7816.Trace
+}
+
+func (r *TransactionResult) step(format string, args ...interface{}) {
+	r.Steps = append(r.Steps, fmt.Sprintf(format, args...))
+}
+
+// transactionRun holds the state of one transaction.
+type transactionRun struct {
+	t        *Transaction
+	r        *TransactionResult
+	terminal TerminalData
+
+	pdolData  []byte
+	cdol1Data []byte
+	cda       bool
+}
+
+// Lookup implements DataSource: the terminal data first, then the card data.
+func (run *transactionRun) Lookup(tag string) ([]byte, bool) {
+	run.terminal["95"] = append([]byte{}, run.r.TVR[:]...)
+	run.terminal["9B"] = append([]byte{}, run.r.TSI[:]...)
+	if value, ok := run.terminal.Lookup(tag); ok {
+		return value, true
+	}
+	if run.r.Data != nil {
+		return run.r.Data.Lookup(tag)
+	}
+	return nil, false
+}
+
+func (run *transactionRun) send(cmd *iso7816.CommandAPDU) (iso7816.Trace, error) {
+	trace, err := run.t.Client.Send(cmd)
+	run.r.Trace = append(run.r.Trace, trace...)
+	return trace, err
+}
+
+func (t *Transaction) now() time.Time {
+	if t.Now.IsZero() {
+		return time.Now()
+	}
+	return t.Now
+}
+
+// Run performs a transaction. The result of the steps performed so far is returned with
+// the error when the transaction is terminated.
+func (t *Transaction) Run(amount, amountOther uint64, txType TransactionType) (*TransactionResult, error) {
+	run := &transactionRun{
+		t:        t,
+		r:        &TransactionResult{Amount: amount, AmountOther: amountOther, Type: txType},
+		terminal: TerminalData{},
+	}
+	if err := run.initTerminalData(); err != nil {
+		return run.r, err
+	}
+
+	for _, phase := range []func() error{
+		run.selectApplication,
+		run.readApplicationData,
+		run.authenticate,
+		run.checkRestrictions,
+		run.verifyCardholder,
+		run.manageRisk,
+		run.firstGenerateAC,
+	} {
+		if err := phase(); err != nil {
+			run.r.step("Transaction terminated: %v", err)
+			return run.r, err
+		}
+	}
+	return run.r, nil
+}
+
+// initTerminalData prepares the terminal data of the transaction.
+func (run *transactionRun) initTerminalData() error {
+	for tag, value := range run.t.Config.Data {
+		run.terminal[strings.ToUpper(tag)] = value
+	}
+
+	un := make([]byte, 4)
+	random := run.t.Random
+	if random == nil {
+		random = rand.Reader
+	}
+	if _, err := io.ReadFull(random, un); err != nil {
+		return fmt.Errorf("Unpredictable Number: %w", err)
+	}
+
+	now := run.t.now()
+	run.terminal["9F02"] = numericBytes(run.r.Amount, 6)
+	run.terminal["9F03"] = numericBytes(run.r.AmountOther, 6)
+	run.terminal["9C"] = []byte{byte(run.r.Type)}
+	run.terminal["9A"], _ = hex.DecodeString(now.Format("060102"))
+	run.terminal["9F21"], _ = hex.DecodeString(now.Format("150405"))
+	run.terminal["9F37"] = un
+	run.terminal["9F33"] = append([]byte{}, run.t.Config.Capabilities[:]...)
+	return nil
+}
+
+// numericBytes encodes a number in format n (BCD) on length bytes.
+func numericBytes(n uint64, length int) []byte {
+	value, _ := hex.DecodeString(fmt.Sprintf("%0*d", length*2, n))
+	return value
+}
+
+// selectApplication builds the candidate list, then selects and initiates the first
+// candidate that accepts the transaction.
+func (run *transactionRun) selectApplication() error {
+	selector := NewSelector(run.t.Client, run.t.Config.AIDs)
+	list, err := selector.BuildCandidateList()
+	run.r.Selection = list
+	if list != nil {
+		run.r.Trace = append(run.r.Trace, list.Trace...)
+	}
+	if err != nil {
+		return fmt.Errorf("application selection: %w", err)
+	}
+
+	for i := range list.Candidates {
+		ok, err := run.initiate(list.Candidates[i])
+		if err != nil {
+			return err
+		}
+		if ok {
+			run.r.Application = &list.Candidates[i]
+			return nil
+		}
+	}
+	return ErrNoApplication
+}
+
+// initiate performs the final selection and GET PROCESSING OPTIONS of a candidate. It
+// returns false when the candidate must be removed from the list.
+func (run *transactionRun) initiate(c Candidate) (bool, error) {
+	cmd := iso7816.SelectByAID(iso7816.Class{}, c.AID)
+	trace, err := run.send(cmd)
+	if err != nil {
+		return false, fmt.Errorf("final selection of %X: %w", c.AID, err)
+	}
+	fci, parseErr := ParseFCI(trace.Last().Response.Data)
+	if !trace.IsSuccess() || parseErr != nil {
+		run.r.step("Application %X: final selection failed, removed from the candidate list", c.AID)
+		return false, nil
+	}
+
+	pdol, err := fci.ProprietaryTemplate.ParsePDOL()
+	if err != nil {
+		return false, err
+	}
+	run.pdolData, _ = pdol.Build(run)
+	trace, err = run.send(GetProcessingOptions(run.pdolData))
+	if err != nil {
+		return false, fmt.Errorf("GET PROCESSING OPTIONS: %w", err)
+	}
+	status := trace.Last().Response.Status
+	if status == iso7816.SW_ERR_COND_OF_USE_NOT_SAT {
+		run.r.step("Application %X: conditions of use not satisfied, removed from the candidate list", c.AID)
+		return false, nil
+	}
+	if !status.IsSuccess() {
+		return false, fmt.Errorf("GET PROCESSING OPTIONS failed with status: %s", status.Verbose())
+	}
+
+	po, err := ParseProcessingOptions(trace.Last().Response.Data)
+	if err != nil {
+		return false, fmt.Errorf("GET PROCESSING OPTIONS: %w", err)
+	}
+	run.r.ProcessingOptions = po
+	run.r.step("Application %X selected (%s)", c.AID, c.Label)
+	return true, nil
+}
+
+// readApplicationData reads the records of the AFL.
+func (run *transactionRun) readApplicationData() error {
+	po := run.r.ProcessingOptions
+	data, err := ReadApplicationData(run.t.Client, iso7816.Class{}, po.AFL)
+	run.r.Data = data
+	if data != nil {
+		run.r.Trace = append(run.r.Trace, data.Trace...)
+	}
+	if err != nil {
+		return fmt.Errorf("read application data: %w", err)
+	}
+	data.Set("82", append([]byte{}, po.AIP[:]...))
+	data.Set("84", run.r.Application.AID)
+	run.r.step("%d records read", len(data.Records))
+	return nil
+}
+
+func (run *transactionRun) odaInput() ODAInput {
+	return ODAInput{
+		AID:    run.r.Application.AID,
+		AIP:    run.r.ProcessingOptions.AIP,
+		Data:   run.r.Data,
+		CAKeys: run.t.Config.CAKeys,
+		Now:    run.t.now(),
+	}
+}
+
+// authenticate selects and performs the offline data authentication method.
+func (run *transactionRun) authenticate() error {
+	aip, caps := run.r.ProcessingOptions.AIP, run.t.Config.Capabilities
+	switch {
+	case aip.SupportsCDA() && caps.SupportsCDA():
+		run.cda = true
+		run.r.step("CDA selected, verified with GENERATE AC")
+	case aip.SupportsDDA() && caps.SupportsDDA():
+		return run.performDDA()
+	case aip.SupportsSDA() && caps.SupportsSDA():
+		run.r.TVR.Set(TVRSDASelected)
+		run.recordODA(VerifySDA(run.odaInput()), TVRSDAFailed)
+	default:
+		run.r.TVR.Set(TVROfflineDataAuthenticationNotPerformed)
+		run.r.step("Offline data authentication not performed")
+	}
+	return nil
+}
+
+// performDDA sends INTERNAL AUTHENTICATE with the DDOL of the card, or the default DDOL.
+func (run *transactionRun) performDDA() error {
+	ddol := DefaultDDOL
+	if value, ok := run.r.Data.Lookup("9F49"); ok {
+		parsed, err := ParseDOL(value)
+		if err != nil {
+			return fmt.Errorf("invalid DDOL: %w", err)
+		}
+		ddol = parsed
+	}
+
+	ddolData, _ := ddol.Build(run)
+	trace, err := run.send(InternalAuthenticate(ddolData))
+	if err != nil {
+		return fmt.Errorf("INTERNAL AUTHENTICATE: %w", err)
+	}
+
+	if !trace.IsSuccess() {
+		r := &AuthenticationResult{Method: "DDA"}
+		r.check("INTERNAL AUTHENTICATE", false, "%s", trace.Last().Response.Status.Verbose())
+		run.recordODA(r, TVRDDAFailed)
+		return nil
+	}
+	sdad, err := ParseInternalAuthenticate(trace.Last().Response.Data)
+	if err != nil {
+		r := &AuthenticationResult{Method: "DDA"}
+		r.check("INTERNAL AUTHENTICATE", false, "%v", err)
+		run.recordODA(r, TVRDDAFailed)
+		return nil
+	}
+	run.recordODA(VerifyDDA(run.odaInput(), sdad, ddolData), TVRDDAFailed)
+	return nil
+}
+
+// recordODA keeps the result of offline data authentication and sets the TVR and TSI bits.
+func (run *transactionRun) recordODA(result *AuthenticationResult, failed TVRBit) {
+	run.r.ODA = result
+	run.r.TSI.Set(TSIOfflineDataAuthenticationPerformed)
+	if result.DataMissing {
+		run.r.TVR.Set(TVRICCDataMissing)
+	}
+	if result.Passed() {
+		run.r.step("%s successful", result.Method)
+		return
+	}
+	run.r.TVR.Set(failed)
+	run.r.step("%s failed", result.Method)
+}
+
+// checkRestrictions checks the application version, usage control and dates.
+func (run *transactionRun) checkRestrictions() error {
+	restrictions, err := CheckProcessingRestrictions(run.r.Data, RestrictionsTransaction{
+		ApplicationVersion: run.terminal["9F09"],
+		Country:            run.terminal["9F1A"],
+		Type:               run.r.Type,
+		ATM:                run.t.Config.ATM,
+		Services:           run.t.Config.Services,
+		Date:               run.t.now(),
+	})
+	run.r.Restrictions = restrictions
+	if err != nil {
+		return fmt.Errorf("processing restrictions: %w", err)
+	}
+	run.r.TVR.Merge(restrictions.TVR)
+	return nil
+}
+
+// verifyCardholder processes the CVM List when the AIP announces cardholder verification.
+func (run *transactionRun) verifyCardholder() error {
+	if !run.r.ProcessingOptions.AIP.SupportsCardholderVerification() {
+		run.terminal["9F34"] = []byte{byte(CVMNotPerformed), 0x00, byte(CVMResultUnknown)}
+		run.r.step("Cardholder verification not supported by the card")
+		return nil
+	}
+
+	var list *CVMList
+	if value, ok := run.r.Data.Lookup("8E"); ok {
+		parsed, err := ParseCVMList(value)
+		if err != nil {
+			return fmt.Errorf("invalid CVM List: %w", err)
+		}
+		list = parsed
+	}
+
+	appCurrency, _ := run.r.Data.Lookup("9F42")
+	evaluation := EvaluateCVM(list, CVMTransaction{
+		Capabilities:        run.t.Config.Capabilities,
+		Unattended:          run.t.Config.Unattended,
+		Type:                run.r.Type,
+		Amount:              run.r.Amount,
+		Currency:            run.terminal["5F2A"],
+		ApplicationCurrency: appCurrency,
+		Perform:             run.cvmPerformer(),
+	})
+	run.r.CVM = evaluation
+	run.r.TVR.Merge(evaluation.TVR)
+	run.r.TSI.Set(TSICardholderVerificationPerformed)
+	run.terminal["9F34"] = append([]byte{}, evaluation.Results[:]...)
+	run.r.step("CVM Results: %s", evaluation.Results)
+	return nil
+}
+
+// cvmPerformer returns the PIN verifier of this run, keeping its exchanges in the transaction
+// trace. It works on a copy of Transaction.PIN, so that the client and the key of one card
+// are never reused for the next one.
+func (run *transactionRun) cvmPerformer() CVMPerformer {
+	if run.t.PIN == nil {
+		return nil
+	}
+	v := *run.t.PIN
+	if v.Client == nil {
+		v.Client = run.t.Client
+	}
+	keyRecovered := v.Key != nil
+
+	return func(rule CVRule, tvr *TVR) CVMOutcome {
+		if !keyRecovered && (rule.Method == CVMEncipheredPIN || rule.Method == CVMEncipheredPINAndSignature) {
+			v.Key = run.pinKey()
+			keyRecovered = true
+		}
+		v.Last = nil
+		outcome := v.Perform(rule, tvr)
+		if v.Last != nil {
+			run.r.Trace = append(run.r.Trace, v.Last.Trace...)
+		}
+		return outcome
+	}
+}
+
+// pinKey recovers the PIN encipherment key of the card, nil when it is not available.
+func (run *transactionRun) pinKey() *RSAPublicKey {
+	if len(run.t.Config.CAKeys) == 0 {
+		return nil
+	}
+	if r := RecoverPINKey(run.odaInput()); r.Passed() {
+		return r.PINPublicKey
+	}
+	return nil
+}
+
+// manageRisk performs terminal risk management when the AIP requests it.
+func (run *transactionRun) manageRisk() error {
+	if !run.r.ProcessingOptions.AIP.RequiresTerminalRiskManagement() {
+		return nil
+	}
+	risk, err := run.t.Config.RiskManagement.Perform(run.t.Client, run.r.Data, run.r.Amount)
+	run.r.Risk = risk
+	if risk != nil {
+		run.r.Trace = append(run.r.Trace, risk.Trace...)
+	}
+	if err != nil {
+		return fmt.Errorf("terminal risk management: %w", err)
+	}
+	run.r.TVR.Merge(risk.TVR)
+	run.r.TSI.Set(TSITerminalRiskManagementPerformed)
+	return nil
+}
+
+// analysis returns the inputs of terminal action analysis.
+func (run *transactionRun) analysis() (ActionAnalysis, error) {
+	iac, err := IssuerActionCodes(run.r.Data)
+	if err != nil {
+		return ActionAnalysis{}, fmt.Errorf("terminal action analysis: %w", err)
+	}
+	return ActionAnalysis{
+		TVR:           run.r.TVR,
+		Issuer:        iac,
+		Terminal:      run.t.Config.ActionCodes,
+		OnlineCapable: run.t.Online != nil,
+	}, nil
+}
+
+// generateAC sends GENERATE AC with the data of a CDOL ('8C' or '8D') and verifies CDA
+// when it was requested.
+func (run *transactionRun) generateAC(requested CryptogramType, cdolTag string, previousData []byte) (*Cryptogram, []byte, error) {
+	value, ok := run.r.Data.Lookup(cdolTag)
+	if !ok {
+		return nil, nil, fmt.Errorf("missing %s", tagLabel(cdolTag))
+	}
+	cdol, err := ParseDOL(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", tagLabel(cdolTag), err)
+	}
+
+	cda := run.cda && requested != CryptogramAAC
+	cmd, _ := GenerateACWithCDOL(requested, cda, cdol, run)
+	trace, err := run.send(cmd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GENERATE AC: %w", err)
+	}
+	if !trace.IsSuccess() {
+		return nil, nil, fmt.Errorf("GENERATE AC failed with status: %s", trace.Last().Response.Status.Verbose())
+	}
+	response := trace.Last().Response.Data
+	ac, err := ParseCryptogram(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GENERATE AC: %w", err)
+	}
+	run.r.TSI.Set(TSICardRiskManagementPerformed)
+	run.r.step("GENERATE AC: %s requested, %s returned", requested, ac.CID.Type())
+
+	if cda && ac.CID.Type() != CryptogramAAC {
+		run.recordODA(VerifyCDA(run.odaInput(), CDAInput{
+			PDOLData:            run.pdolData,
+			CDOLData:            concat(previousData, cmd.Data),
+			UnpredictableNumber: run.terminal["9F37"],
+			Response:            response,
+		}), TVRCDAFailed)
+	}
+	return ac, cmd.Data, nil
+}
+
+// cdaFailed reports whether CDA was performed and failed.
+func (run *transactionRun) cdaFailed() bool {
+	return run.cda && run.r.ODA != nil && !run.r.ODA.Passed()
+}
+
+// firstGenerateAC performs terminal action analysis and the first GENERATE AC, then
+// completes the transaction offline or online.
+func (run *transactionRun) firstGenerateAC() error {
+	analysis, err := run.analysis()
+	if err != nil {
+		return err
+	}
+	run.r.Action = analysis.Decide()
+
+	ac, data, err := run.generateAC(run.r.Action.Cryptogram, "8C", nil)
+	if err != nil {
+		return fmt.Errorf("first %w", err)
+	}
+	run.r.FirstAC, run.cdol1Data = ac, data
+
+	switch {
+	case ac.CID.Type() == CryptogramARQC && run.cdaFailed():
+		return run.secondGenerateAC(CryptogramAAC)
+	case ac.CID.Type() == CryptogramARQC:
+		return run.goOnline(analysis)
+	case ac.CID.Type() == CryptogramTC && !run.cdaFailed():
+		run.r.Outcome = OutcomeApproved
+		run.r.step("Approved offline")
+	default:
+		run.r.Outcome = OutcomeDeclined
+		run.r.step("Declined offline")
+	}
+	return nil
+}
+
+// goOnline sends the authorisation request, processes the issuer response and completes
+// the transaction.
+func (run *transactionRun) goOnline(analysis ActionAnalysis) error {
+	var resp *OnlineResponse
+	err := errors.New("the terminal is offline-only")
+	if run.t.Online != nil {
+		resp, err = run.t.Online.Authorize(&OnlineRequest{
+			Terminal:   run.snapshot(),
+			Card:       run.r.Data,
+			CDOL1Data:  run.cdol1Data,
+			Cryptogram: run.r.FirstAC,
+		})
+	}
+	if err != nil {
+		return run.unableToGoOnline(analysis, err)
+	}
+
+	run.r.Online = resp
+	run.r.Issuer = &IssuerProcessing{Client: run.t.Client, Scripts: resp.Scripts}
+	run.terminal["8A"] = resp.ARC
+	if len(resp.IssuerAuthenticationData) > 0 {
+		run.terminal["91"] = resp.IssuerAuthenticationData
+	}
+	run.r.step("Online response: ARC %q", resp.ARC)
+
+	if err := run.issuerStep(func(p *IssuerProcessing) error {
+		return p.Authenticate(run.r.ProcessingOptions.AIP, resp.IssuerAuthenticationData, &run.r.TVR, &run.r.TSI)
+	}); err != nil {
+		return err
+	}
+	if err := run.issuerStep(func(p *IssuerProcessing) error {
+		return p.RunScripts(ScriptBeforeFinalGenerateAC, &run.r.TVR, &run.r.TSI)
+	}); err != nil {
+		return err
+	}
+
+	requested := CryptogramAAC
+	if resp.Approved() {
+		requested = CryptogramTC
+	}
+	if err := run.secondGenerateAC(requested); err != nil {
+		return err
+	}
+
+	err = run.issuerStep(func(p *IssuerProcessing) error {
+		return p.RunScripts(ScriptAfterFinalGenerateAC, &run.r.TVR, &run.r.TSI)
+	})
+	if results := run.r.Issuer.ScriptResults(); len(results) > 0 {
+		run.terminal["9F5B"] = results
+	}
+	return err
+}
+
+// issuerStep runs a step of issuer processing, keeping its exchanges in the trace.
+func (run *transactionRun) issuerStep(step func(p *IssuerProcessing) error) error {
+	p := run.r.Issuer
+	before := len(p.Trace)
+	err := step(p)
+	run.r.Trace = append(run.r.Trace, p.Trace[before:]...)
+	return err
+}
+
+// unableToGoOnline completes the transaction with the Default action codes.
+func (run *transactionRun) unableToGoOnline(analysis ActionAnalysis, err error) error {
+	run.r.OnlineError = err.Error()
+	run.r.step("Unable to go online: %v", err)
+
+	analysis.TVR = run.r.TVR
+	decision := analysis.DecideUnableToGoOnline()
+	run.terminal["8A"] = ARCUnableToGoOnlineDeclined
+	if decision.Cryptogram == CryptogramTC {
+		run.terminal["8A"] = ARCUnableToGoOnlineApproved
+	}
+	return run.secondGenerateAC(decision.Cryptogram)
+}
+
+// secondGenerateAC completes the transaction.
+func (run *transactionRun) secondGenerateAC(requested CryptogramType) error {
+	ac, _, err := run.generateAC(requested, "8D", run.cdol1Data)
+	if err != nil {
+		return fmt.Errorf("second %w", err)
+	}
+	run.r.SecondAC = ac
+
+	run.r.Outcome = OutcomeDeclined
+	if ac.CID.Type() == CryptogramTC && !run.cdaFailed() {
+		run.r.Outcome = OutcomeApproved
+	}
+	run.r.step("%s after the second GENERATE AC", run.r.Outcome)
+	return nil
+}
+
+// snapshot copies the terminal data for the authorisation request.
+func (run *transactionRun) snapshot() TerminalData {
+	run.Lookup("95")
+	data := TerminalData{}
+	for tag, value := range run.terminal {
+		data[tag] = value
+	}
+	return data
+}
+
+// Report builds the structured report of the transaction.
+func (r *TransactionResult) Report() *report.Report {
+	rep := report.New("EMV CONTACT TRANSACTION")
+
+	tx := rep.AddSection("[1] Transaction:")
+	tx.Note("Type", r.Type.String())
+	tx.Note("Amount", fmt.Sprintf("%d", r.Amount))
+	if r.AmountOther > 0 {
+		tx.Note("Amount Other", fmt.Sprintf("%d", r.AmountOther))
+	}
+	if r.Application != nil {
+		tx.Note("Application", fmt.Sprintf("%X (%s)", r.Application.AID, r.Application.Label))
+	}
+
+	steps := rep.AddSection("[2] Steps:")
+	for _, s := range r.Steps {
+		steps.Note("", s)
+	}
+
+	r.reportCryptograms(rep)
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	if r.Outcome != "" {
+		outcome.Note("Result", string(r.Outcome))
+	} else {
+		outcome.Note("Result", "Terminated")
+	}
+	for _, n := range r.TVR.Names() {
+		outcome.Note(fmt.Sprintf("TVR (95) %X", r.TVR[:]), n)
+	}
+	for _, n := range r.TSI.Names() {
+		outcome.Note(fmt.Sprintf("TSI (9B) %X", r.TSI[:]), n)
+	}
+	if r.CVM != nil {
+		outcome.Note("CVM Results (9F34)", r.CVM.Results.String())
+	}
+	if r.Issuer != nil && len(r.Issuer.Results) > 0 {
+		outcome.Note("Issuer Script Results (9F5B)", fmt.Sprintf("%X", r.Issuer.ScriptResults()))
+	}
+
+	return rep
+}
+
+func (r *TransactionResult) reportCryptograms(rep *report.Report) {
+	for _, c := range []struct {
+		name string
+		ac   *Cryptogram
+	}{
+		{"First GENERATE AC", r.FirstAC},
+		{"Second GENERATE AC", r.SecondAC},
+	} {
+		if c.ac == nil {
+			continue
+		}
+		section := rep.AddSection(fmt.Sprintf("[%d] %s:", len(rep.Sections)+1, c.name))
+		section.Note("CID (9F27)", c.ac.CID.String())
+		section.Note("ATC (9F36)", fmt.Sprintf("%04X", c.ac.ATC))
+		if len(c.ac.ApplicationCryptogram) > 0 {
+			section.Note("Application Cryptogram (9F26)", fmt.Sprintf("%X", c.ac.ApplicationCryptogram))
+		}
+	}
+}
+
+// Describe generates a human-readable report of the transaction.
+func (r *TransactionResult) Describe() string {
+	return report.Text(r.Report())
+}
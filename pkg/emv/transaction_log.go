@@ -0,0 +1,345 @@
+package emv
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// TRANSACTION LOG Logic according to EMV Book 3, Annex D.
+// Cards may keep a log of their transactions, readable by any terminal:
+//
+// 1. The Log Entry ('9F4D', in the FCI Issuer Discretionary Data or in the directory
+//    entries) locates the log: SFI (1) | Maximum number of records (1).
+// 2. The Log Format ('9F4F'), read with GET DATA, is a DOL describing the records.
+// 3. Each record, read with READ RECORD from record 1, is the concatenation of the values
+//    listed by the Log Format, without tags nor lengths. The most recent transaction is
+//    record 1. Reading stops at the maximum number of records or at the first missing
+//    record ('6A83').
+
+// LogEntry locates the transaction log of the card.
+type LogEntry struct {
+	SFI     byte `json:"sfi"`
+	Records int  `json:"max_records"` // Maximum number of records
+}
+
+// ParseLogEntry decodes the value of Tag '9F4D'.
+func ParseLogEntry(value []byte) (LogEntry, error) {
+	if len(value) != 2 {
+		return LogEntry{}, fmt.Errorf("Log Entry must be 2 bytes long (got %d)", len(value))
+	}
+	if value[0] < 1 || value[0] > 30 {
+		return LogEntry{}, fmt.Errorf("Log Entry SFI %d is out of range (1-30)", value[0])
+	}
+	return LogEntry{SFI: value[0], Records: int(value[1])}, nil
+}
+
+func parseOptionalLogEntry(value []byte) (*LogEntry, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	entry, err := ParseLogEntry(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Log Entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// FindLogEntry locates the transaction log of an application: the Log Entry of its FCI
+// first, then the one of its PSE directory entry. Either source may be nil. A nil entry is
+// returned when neither provides a Log Entry.
+func FindLogEntry(fci *FCI, app *ApplicationTemplate) (*LogEntry, error) {
+	if fci != nil && fci.ProprietaryTemplate.IssuerDiscretionaryData != nil {
+		entry, err := fci.ProprietaryTemplate.IssuerDiscretionaryData.ParseLogEntry()
+		if entry != nil || err != nil {
+			return entry, err
+		}
+	}
+	if app != nil {
+		entry, err := app.DirectoryDiscretionaryData.ParseLogEntry()
+		if err != nil {
+			return nil, fmt.Errorf("directory entry: %w", err)
+		}
+		return entry, nil
+	}
+	return nil, nil
+}
+
+// LogTransaction is a decoded log record. Typed fields are empty when the Log Format does
+// not list their tag or when the value cannot be decoded; Data keeps every value.
+type LogTransaction struct {
+	Record      int    `json:"record"`
+	Date        string `json:"date,omitempty"`         // YYYY-MM-DD ('9A')
+	Time        string `json:"time,omitempty"`         // HH:MM:SS ('9F21')
+	Amount      uint64 `json:"amount"`                 // '9F02', minor unit
+	AmountOther uint64 `json:"amount_other,omitempty"` // '9F03', minor unit
+	Currency    string `json:"currency,omitempty"`     // ISO 4217 numeric code ('5F2A')
+	Country     string `json:"country,omitempty"`      // ISO 3166-1 numeric code ('9F1A')
+	Type        string `json:"type,omitempty"`         // '9C'
+	ATC         string `json:"atc,omitempty"`          // '9F36', decimal
+	CID         string `json:"cid,omitempty"`          // '9F27'
+	Merchant    string `json:"merchant,omitempty"`     // '9F4E'
+
+	// Data maps each tag of the Log Format to its value in hex.
+	Data map[string]string `json:"data"`
+}
+
+// DecodeLogRecord splits a log record according to the Log Format.
+func DecodeLogRecord(format DOL, record []byte) (LogTransaction, error) {
+	if len(record) < format.Length() {
+		return LogTransaction{}, fmt.Errorf("record is %d bytes long, the Log Format requires %d", len(record), format.Length())
+	}
+
+	t := LogTransaction{Data: map[string]string{}}
+	offset := 0
+	for _, e := range format {
+		value := record[offset : offset+e.Length]
+		offset += e.Length
+
+		t.Data[e.Tag] = strings.ToUpper(hex.EncodeToString(value))
+		if fill, ok := logFields[e.Tag]; ok {
+			fill(&t, value)
+		}
+	}
+
+	return t, nil
+}
+
+// logFields fill the typed fields of LogTransaction, by tag.
+var logFields = map[string]func(t *LogTransaction, value []byte){
+	"9A": func(t *LogTransaction, value []byte) {
+		if date, err := parseDate(value); err == nil {
+			t.Date = date.Format("2006-01-02")
+		}
+	},
+	"9F21": func(t *LogTransaction, value []byte) {
+		if digits, ok := numericDigits(value); ok && len(digits) == 6 {
+			t.Time = digits[0:2] + ":" + digits[2:4] + ":" + digits[4:6]
+		}
+	},
+	"9F02": func(t *LogTransaction, value []byte) { t.Amount, _ = numericValue(value) },
+	"9F03": func(t *LogTransaction, value []byte) { t.AmountOther, _ = numericValue(value) },
+	"5F2A": func(t *LogTransaction, value []byte) { t.Currency = countryOrCurrency(value) },
+	"9F1A": func(t *LogTransaction, value []byte) { t.Country = countryOrCurrency(value) },
+	"9C": func(t *LogTransaction, value []byte) {
+		if len(value) == 1 {
+			t.Type = TransactionType(value[0]).String()
+		}
+	},
+	"9F36": func(t *LogTransaction, value []byte) {
+		if len(value) == 2 {
+			t.ATC = strconv.Itoa(int(binary.BigEndian.Uint16(value)))
+		}
+	},
+	"9F27": func(t *LogTransaction, value []byte) { t.CID = fmt.Sprintf("%X", value) },
+	"9F4E": func(t *LogTransaction, value []byte) { t.Merchant = strings.TrimRight(string(value), " \x00") },
+}
+
+// numericDigits returns the digits of a value of format n (BCD).
+func numericDigits(value []byte) (string, bool) {
+	digits := fmt.Sprintf("%X", value)
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return digits, true
+}
+
+// numericValue decodes a value of format n (BCD), such as an amount.
+func numericValue(value []byte) (uint64, bool) {
+	digits, ok := numericDigits(value)
+	if !ok || len(digits) == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	return n, err == nil
+}
+
+// countryOrCurrency returns the 3 digits of a code of format n 3.
+func countryOrCurrency(value []byte) string {
+	digits, ok := numericDigits(value)
+	if !ok || len(digits) < 3 {
+		return ""
+	}
+	return digits[len(digits)-3:]
+}
+
+// CardLog is the transaction log read from the card.
+type CardLog struct {
+	Entry        LogEntry
+	Format       DOL
+	Transactions []LogTransaction
+
+	// Trace keeps every exchange.
+	Trace iso7816.Trace
+}
+
+// ReadCardLog reads the Log Format and the log records.
+func ReadCardLog(client *iso7816.Client, entry LogEntry) (*CardLog, error) {
+	l := &CardLog{Entry: entry}
+
+	trace, err := client.Send(GetData(0x9F4F))
+	l.Trace = append(l.Trace, trace...)
+	if err != nil {
+		return l, fmt.Errorf("GET DATA Log Format: %w", err)
+	}
+	if !trace.IsSuccess() {
+		return l, fmt.Errorf("GET DATA Log Format failed with status: %s", trace.Last().Response.Status.Verbose())
+	}
+	if l.Format, err = parseLogFormat(trace.Last().Response.Data); err != nil {
+		return l, err
+	}
+
+	for record := 1; record <= entry.Records; record++ {
+		trace, err := client.Send(iso7816.ReadRecord(iso7816.Class{}, entry.SFI, byte(record)))
+		l.Trace = append(l.Trace, trace...)
+		if err != nil {
+			return l, fmt.Errorf("log record %d: %w", record, err)
+		}
+		if trace.Last().Response.Status == iso7816.SW_ERR_RECORD_NOT_FOUND {
+			break
+		}
+		if !trace.IsSuccess() {
+			return l, fmt.Errorf("log record %d: read failed with status: %s", record, trace.Last().Response.Status.Verbose())
+		}
+
+		t, err := DecodeLogRecord(l.Format, trace.Last().Response.Data)
+		if err != nil {
+			return l, fmt.Errorf("log record %d: %w", record, err)
+		}
+		t.Record = record
+		l.Transactions = append(l.Transactions, t)
+	}
+
+	return l, nil
+}
+
+// parseLogFormat extracts the DOL from the GET DATA response ('9F4F' object).
+func parseLogFormat(data []byte) (DOL, error) {
+	if len(data) < 3 || data[0] != 0x9F || data[1] != 0x4F || int(data[2]) != len(data)-3 {
+		return nil, fmt.Errorf("invalid Log Format response: %X", data)
+	}
+	format, err := ParseDOL(data[3:])
+	if err != nil {
+		return nil, fmt.Errorf("Log Format: %w", err)
+	}
+	return format, nil
+}
+
+// logColumns are the columns of the table and CSV exports.
+var logColumns = []string{"Record", "Date", "Time", "Amount", "Amount Other", "Currency", "Country", "Type", "ATC", "CID", "Merchant"}
+
+func (t LogTransaction) columns() []string {
+	amountOther := ""
+	if t.AmountOther > 0 {
+		amountOther = strconv.FormatUint(t.AmountOther, 10)
+	}
+	return []string{
+		strconv.Itoa(t.Record), t.Date, t.Time, strconv.FormatUint(t.Amount, 10), amountOther,
+		t.Currency, t.Country, t.Type, t.ATC, t.CID, t.Merchant,
+	}
+}
+
+// Table renders the transactions as an aligned text table.
+func (l *CardLog) Table() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(logColumns, "\t"))
+	for _, t := range l.Transactions {
+		fmt.Fprintln(w, strings.Join(t.columns(), "\t"))
+	}
+	w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// WriteJSON exports the transactions as a JSON array.
+func (l *CardLog) WriteJSON(w io.Writer) error {
+	transactions := l.Transactions
+	if transactions == nil {
+		transactions = []LogTransaction{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(transactions); err != nil {
+		return fmt.Errorf("json export failed: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV exports the transactions as CSV, with a header line.
+func (l *CardLog) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(logColumns); err != nil {
+		return fmt.Errorf("csv export failed: %w", err)
+	}
+	for _, t := range l.Transactions {
+		if err := cw.Write(t.columns()); err != nil {
+			return fmt.Errorf("csv export failed: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("csv export failed: %w", err)
+	}
+	return nil
+}
+
+// Report builds the structured report of the transaction log.
+func (l *CardLog) Report() *report.Report {
+	rep := report.New("EMV TRANSACTION LOG")
+
+	location := rep.AddSection("Log Entry (9F4D):")
+	location.Note("SFI", fmt.Sprintf("%d", l.Entry.SFI))
+	location.Note("Maximum Records", fmt.Sprintf("%d", l.Entry.Records))
+	location.Note("Log Format (9F4F)", fmt.Sprintf("%X", l.Format.Bytes()))
+
+	transactions := rep.AddSection(fmt.Sprintf("Transactions: %d", len(l.Transactions)))
+	for _, t := range l.Transactions {
+		transactions.Note(fmt.Sprintf("#%d", t.Record), t.summary())
+	}
+
+	return rep
+}
+
+// summary describes a transaction on one line.
+func (t LogTransaction) summary() string {
+	var parts []string
+	for _, p := range []string{t.Date, t.Time} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	amount := strconv.FormatUint(t.Amount, 10)
+	if t.Currency != "" {
+		amount += " (" + t.Currency + ")"
+	}
+	parts = append(parts, amount)
+	if t.Type != "" {
+		parts = append(parts, t.Type)
+	}
+	if t.Country != "" {
+		parts = append(parts, "country "+t.Country)
+	}
+	if t.ATC != "" {
+		parts = append(parts, "ATC "+t.ATC)
+	}
+	if t.Merchant != "" {
+		parts = append(parts, t.Merchant)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Describe generates a human-readable report of the transaction log.
+func (l *CardLog) Describe() string {
+	return report.Text(l.Report())
+}
@@ -0,0 +1,241 @@
+package emv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const testLogFormat = "9A03 9F2103 9F0206 9F0306 5F2A02 9F1A02 9C01 9F3602 9F2701 9F4E08"
+
+// logCard holds two log records in SFI 11, the third record is missing.
+func logCard() *mockCard {
+	return testCard{Responses: map[string]string{
+		"80CA9F4F00": "9F4F1C" + testLogFormat + "9000",
+		"00B2015C00": "260615 102030 000000001234 000000000000 0978 0250 00 0012 40 53484F50204F4E45 9000",
+		"00B2025C00": "260614 091500 000000005000 000000001000 0978 0250 09 0011 40 4341464520202020 9000",
+		"00B2035C00": "6A83",
+	}}.mock()
+}
+
+func TestParseLogEntry(t *testing.T) {
+	entry, err := ParseLogEntry(tlv.Hex("0B0A"))
+	if err != nil {
+		t.Fatalf("ParseLogEntry failed: %v", err)
+	}
+	if diff := cmp.Diff(LogEntry{SFI: 11, Records: 10}, entry); diff != "" {
+		t.Errorf("Log Entry mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, value := range []string{"0B", "000A", "1F0A"} {
+		if _, err := ParseLogEntry(tlv.Hex(value)); err == nil {
+			t.Errorf("ParseLogEntry(%s) should fail", value)
+		}
+	}
+}
+
+func TestDiscretionaryDataLogEntry(t *testing.T) {
+	fciData := &FCIIssuerDiscretionaryData{LogEntry: tlv.Hex("0B0A")}
+	entry, err := fciData.ParseLogEntry()
+	if err != nil || entry == nil || *entry != (LogEntry{SFI: 11, Records: 10}) {
+		t.Errorf("FCI ParseLogEntry() = %v, %v", entry, err)
+	}
+
+	directory := &DirectoryDiscretionaryTemplate{}
+	if entry, err := directory.ParseLogEntry(); entry != nil || err != nil {
+		t.Errorf("Directory ParseLogEntry() without 9F4D = %v, %v", entry, err)
+	}
+	directory.LogEntry = tlv.Hex("00")
+	if _, err := directory.ParseLogEntry(); err == nil {
+		t.Error("Directory ParseLogEntry() should reject an invalid 9F4D")
+	}
+}
+
+func TestFindLogEntry(t *testing.T) {
+	record, err := ParseDirectoryRecord(tlv.Hex(tlvHex("70", tlvHex("61",
+		tlvHex("4F", "A0000000031010"),
+		tlvHex("50", "56495341"),
+		tlvHex("73", tlvHex("9F4D", "0B0A")),
+	))))
+	if err != nil {
+		t.Fatalf("ParseDirectoryRecord failed: %v", err)
+	}
+	app := &record.Applications[0]
+	fci := &FCI{ProprietaryTemplate: FCIProprietaryTemplate{IssuerDiscretionaryData: &FCIIssuerDiscretionaryData{LogEntry: tlv.Hex("0C05")}}}
+
+	tests := []struct {
+		name     string
+		fci      *FCI
+		app      *ApplicationTemplate
+		expected *LogEntry
+	}{
+		{name: "Directory entry", app: app, expected: &LogEntry{SFI: 11, Records: 10}},
+		{name: "FCI without Log Entry", fci: &FCI{}, app: app, expected: &LogEntry{SFI: 11, Records: 10}},
+		{name: "FCI first", fci: fci, app: app, expected: &LogEntry{SFI: 12, Records: 5}},
+		{name: "None", fci: &FCI{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := FindLogEntry(tt.fci, tt.app)
+			if err != nil {
+				t.Fatalf("FindLogEntry failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, entry); diff != "" {
+				t.Errorf("Log Entry mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The log located by the directory entry is read from the card.
+	entry, _ := FindLogEntry(nil, app)
+	log, err := ReadCardLog(iso7816.NewClient(logCard()), *entry)
+	if err != nil || len(log.Transactions) != 2 {
+		t.Errorf("ReadCardLog from the directory entry = %v, %v", log, err)
+	}
+
+	app.DirectoryDiscretionaryData.LogEntry = tlv.Hex("00")
+	if _, err := FindLogEntry(nil, app); err == nil || !strings.Contains(err.Error(), "directory entry") {
+		t.Errorf("FindLogEntry() error = %v", err)
+	}
+}
+
+func TestDecodeLogRecord(t *testing.T) {
+	format, err := ParseDOL(tlv.Hex("9A03 9F0206 9F3602 9F5A02"))
+	if err != nil {
+		t.Fatalf("ParseDOL failed: %v", err)
+	}
+
+	got, err := DecodeLogRecord(format, tlv.Hex("261301 000000001234 00FF 1234"))
+	if err != nil {
+		t.Fatalf("DecodeLogRecord failed: %v", err)
+	}
+	expected := LogTransaction{
+		Amount: 1234,
+		ATC:    "255",
+		Data:   map[string]string{"9A": "261301", "9F02": "000000001234", "9F36": "00FF", "9F5A": "1234"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Transaction mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := DecodeLogRecord(format, tlv.Hex("261301")); err == nil {
+		t.Error("DecodeLogRecord should reject a short record")
+	}
+}
+
+func TestReadCardLog(t *testing.T) {
+	card := logCard()
+	log, err := ReadCardLog(iso7816.NewClient(card), LogEntry{SFI: 11, Records: 10})
+	if err != nil {
+		t.Fatalf("ReadCardLog failed: %v", err)
+	}
+
+	expected := []LogTransaction{
+		{
+			Record: 1, Date: "2026-06-15", Time: "10:20:30", Amount: 1234, Currency: "978", Country: "250",
+			Type: "Purchase", ATC: "18", CID: "40", Merchant: "SHOP ONE",
+		},
+		{
+			Record: 2, Date: "2026-06-14", Time: "09:15:00", Amount: 5000, AmountOther: 1000, Currency: "978", Country: "250",
+			Type: "Purchase with cashback", ATC: "17", CID: "40", Merchant: "CAFE",
+		},
+	}
+	if diff := cmp.Diff(expected, log.Transactions, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".Data"
+	}, cmp.Ignore())); diff != "" {
+		t.Errorf("Transactions mismatch (-want +got):\n%s", diff)
+	}
+	if len(card.sent) != 4 || len(log.Trace) != 4 {
+		t.Errorf("%d commands sent, %d traced, want 4", len(card.sent), len(log.Trace))
+	}
+
+	limited, err := ReadCardLog(iso7816.NewClient(logCard()), LogEntry{SFI: 11, Records: 1})
+	if err != nil || len(limited.Transactions) != 1 {
+		t.Errorf("ReadCardLog with 1 record = %d transactions, %v", len(limited.Transactions), err)
+	}
+}
+
+func TestReadCardLogErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string]string
+	}{
+		{"No Log Format", map[string]string{}},
+		{"Invalid Log Format", map[string]string{"80CA9F4F00": "9F4E02 9A03 9000"}},
+		{"Read Failed", map[string]string{"80CA9F4F00": "9F4F03 9A03 9000", "00B2015C00": "6982"}},
+		{"Short Record", map[string]string{"80CA9F4F00": "9F4F03 9A03 9000", "00B2015C00": "26 9000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &mockCard{responses: tt.responses}
+			if _, err := ReadCardLog(iso7816.NewClient(card), LogEntry{SFI: 11, Records: 1}); err == nil {
+				t.Error("ReadCardLog should fail")
+			}
+		})
+	}
+}
+
+func TestCardLogExports(t *testing.T) {
+	log, err := ReadCardLog(iso7816.NewClient(logCard()), LogEntry{SFI: 11, Records: 10})
+	if err != nil {
+		t.Fatalf("ReadCardLog failed: %v", err)
+	}
+
+	table := strings.Split(log.Table(), "\n")
+	if len(table) != 3 || !strings.HasPrefix(table[0], "Record  Date        Time      Amount") ||
+		!strings.HasPrefix(table[1], "1       2026-06-15  10:20:30  1234") {
+		t.Errorf("Table() unexpected:\n%s", log.Table())
+	}
+
+	var csv bytes.Buffer
+	if err := log.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	expectedCSV := "Record,Date,Time,Amount,Amount Other,Currency,Country,Type,ATC,CID,Merchant\n" +
+		"1,2026-06-15,10:20:30,1234,,978,250,Purchase,18,40,SHOP ONE\n" +
+		"2,2026-06-14,09:15:00,5000,1000,978,250,Purchase with cashback,17,40,CAFE\n"
+	if diff := cmp.Diff(expectedCSV, csv.String()); diff != "" {
+		t.Errorf("CSV mismatch (-want +got):\n%s", diff)
+	}
+
+	var json bytes.Buffer
+	if err := log.WriteJSON(&json); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	for _, fragment := range []string{
+		`"record": 1,`,
+		`"date": "2026-06-15",`,
+		`"amount_other": 1000,`,
+		`"merchant": "SHOP ONE",`,
+		`"9F4E": "53484F50204F4E45"`,
+	} {
+		if !strings.Contains(json.String(), fragment) {
+			t.Errorf("JSON missing %s:\n%s", fragment, json.String())
+		}
+	}
+
+	var empty bytes.Buffer
+	if err := (&CardLog{}).WriteJSON(&empty); err != nil || strings.TrimSpace(empty.String()) != "[]" {
+		t.Errorf("WriteJSON() of an empty log = %q, %v", empty.String(), err)
+	}
+
+	expected := []string{
+		"=== EMV TRANSACTION LOG ===",
+		"    - SFI: 11",
+		"    - Log Format (9F4F): " + strings.ReplaceAll(testLogFormat, " ", ""),
+		"Transactions: 2",
+		"    - #1: 2026-06-15, 10:20:30, 1234 (978), Purchase, country 250, ATC 18, SHOP ONE",
+	}
+	desc := log.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package emv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/iso7816"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+const (
+	txUN      = "11223344"
+	txIAD     = "06010A03A00000"
+	txIssAuth = "AABBCCDDEEFF0011 3030"
+)
+
+// testTransactionTime is the clock of the test transactions, on the reference date of
+// the expiry checks (testNow).
+var testTransactionTime = testNow.Add(10*time.Hour + 30*time.Minute)
+
+// txCard is a card without offline data authentication, requesting No CVM and terminal
+// risk management, with Issuer Action Codes going online above the floor limit.
+func txCard(extra map[string]string) testCard {
+	return testCard{
+		AID:         aidVisa,
+		Label:       labelVisa,
+		Proprietary: tlvHex("9F38", "9F1A02"),
+		GPO:         "80A80000048302025000",
+		GPOResponse: "8006 1C00 08010100",
+		Records:     []string{txRecord("FF00", "00000000 00000000 1F00")},
+		Responses:   extra,
+	}
+}
+
+// txRecord returns the record of txCard with an Application Usage Control and a CVM List.
+func txRecord(auc, cvmList string) string {
+	return tlvHex("70",
+		tlvHex("5A", "4761739001010010"),
+		tlvHex("5F24", "271231"),
+		tlvHex("5F25", "200101"),
+		tlvHex("5F28", "0250"),
+		tlvHex("5F34", "01"),
+		tlvHex("9F07", auc),
+		tlvHex("9F08", "008C"),
+		tlvHex("8C", "9F0206 9F0306 9F1A02 9505 5F2A02 9A03 9C01 9F3704"),
+		tlvHex("8D", "8A02 9F3704 9505"),
+		tlvHex("8E", cvmList),
+		tlvHex("9F0D", "0000008000"),
+		tlvHex("9F0E", "0000000000"),
+		tlvHex("9F0F", "0000008000"),
+	)
+}
+
+// txGenerateAC returns the GENERATE AC command and a format 1 response.
+func txGenerateAC(requested, returned string, data ...string) (string, string) {
+	content := strings.ReplaceAll(strings.Join(data, ""), " ", "")
+	cmd := fmt.Sprintf("80AE%s00%02X%s00", requested, len(content)/2, content)
+	return cmd, "8012" + returned + "0001 0102030405060708" + txIAD + "9000"
+}
+
+func txCDOL1(amount, tvr string) string {
+	return amount + "000000000000 0250" + tvr + "0978 260615 00" + txUN
+}
+
+type fakeAuthorizer struct {
+	resp *OnlineResponse
+	err  error
+	req  *OnlineRequest
+}
+
+func (f *fakeAuthorizer) Authorize(req *OnlineRequest) (*OnlineResponse, error) {
+	f.req = req
+	return f.resp, f.err
+}
+
+func newTestTransaction(card *mockCard, online OnlineAuthorizer) *Transaction {
+	return &Transaction{
+		Client: iso7816.NewClient(card),
+		Config: TransactionConfig{
+			AIDs:         []TerminalAID{{AID: tlv.Hex(aidVisa)}},
+			Capabilities: TerminalCapabilities{0xE0, 0x08, 0x00},
+			Data: TerminalData{
+				"9F1A": tlv.Hex("0250"),
+				"5F2A": tlv.Hex("0978"),
+				"9F09": tlv.Hex("008C"),
+				"9F35": tlv.Hex("22"),
+			},
+			RiskManagement: RiskManagement{FloorLimit: 10000, Random: func() int { return 99 }},
+		},
+		Online: online,
+		Random: bytes.NewReader(tlv.Hex(txUN)),
+		Now:    testTransactionTime,
+	}
+}
+
+func TestTransactionRun(t *testing.T) {
+	issuerScripts := []IssuerScript{
+		{Template: ScriptBeforeFinalGenerateAC, Commands: [][]byte{tlv.Hex("8418000004 01020304")}},
+		{Template: ScriptAfterFinalGenerateAC, Commands: [][]byte{tlv.Hex("8424000000")}},
+	}
+
+	ac1Online, ac1OnlineResp := txGenerateAC("80", "80", txCDOL1("000000020000", "8000008000"))
+	ac1Offline, ac1OfflineResp := txGenerateAC("40", "40", txCDOL1("000000005000", "8000000000"))
+	ac2Approved, ac2ApprovedResp := txGenerateAC("40", "40", "3030", txUN, "8000008000")
+	ac2Declined, ac2DeclinedResp := txGenerateAC("00", "00", "3035", txUN, "8000008000")
+	ac2Unable, ac2UnableResp := txGenerateAC("00", "00", "5A33", txUN, "8000008000")
+
+	tests := []struct {
+		name      string
+		amount    uint64
+		responses map[string]string
+		online    *fakeAuthorizer
+		outcome   TransactionOutcome
+		tsi       TSI
+		secondAC  bool
+	}{
+		{
+			name:      "approved offline below the floor limit",
+			amount:    5000,
+			responses: map[string]string{ac1Offline: ac1OfflineResp},
+			online:    &fakeAuthorizer{err: errors.New("not expected")},
+			outcome:   OutcomeApproved,
+			tsi:       TSI{0x68, 0x00},
+		},
+		{
+			name:   "approved online with issuer authentication and scripts",
+			amount: 20000,
+			responses: map[string]string{
+				ac1Online:                ac1OnlineResp,
+				"008200000A" + txIssAuth: "9000",
+				"8418000004 01020304":    "9000",
+				ac2Approved:              ac2ApprovedResp,
+				"8424000000":             "9000",
+			},
+			online: &fakeAuthorizer{resp: &OnlineResponse{
+				ARC:                      []byte("00"),
+				IssuerAuthenticationData: tlv.Hex(txIssAuth),
+				Scripts:                  issuerScripts,
+			}},
+			outcome:  OutcomeApproved,
+			tsi:      TSI{0x7C, 0x00},
+			secondAC: true,
+		},
+		{
+			name:   "declined by the issuer",
+			amount: 20000,
+			responses: map[string]string{
+				ac1Online:   ac1OnlineResp,
+				ac2Declined: ac2DeclinedResp,
+			},
+			online:   &fakeAuthorizer{resp: &OnlineResponse{ARC: []byte("05")}},
+			outcome:  OutcomeDeclined,
+			tsi:      TSI{0x68, 0x00},
+			secondAC: true,
+		},
+		{
+			name:   "unable to go online, declined by the Default action codes",
+			amount: 20000,
+			responses: map[string]string{
+				ac1Online: ac1OnlineResp,
+				ac2Unable: ac2UnableResp,
+			},
+			online:   &fakeAuthorizer{err: errors.New("host unreachable")},
+			outcome:  OutcomeDeclined,
+			tsi:      TSI{0x68, 0x00},
+			secondAC: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := txCard(tt.responses).mock()
+			r, err := newTestTransaction(card, tt.online).Run(tt.amount, 0, TransactionPurchase)
+			if err != nil {
+				t.Fatalf("Run() error = %v\nsent: %v", err, card.sent)
+			}
+			if r.Outcome != tt.outcome {
+				t.Errorf("Outcome = %s, want %s\n%s", r.Outcome, tt.outcome, r.Describe())
+			}
+			if diff := cmp.Diff(tt.tsi, r.TSI); diff != "" {
+				t.Errorf("TSI mismatch (-want +got):\n%s", diff)
+			}
+			if (r.SecondAC != nil) != tt.secondAC {
+				t.Errorf("SecondAC = %v, want present: %v", r.SecondAC, tt.secondAC)
+			}
+			if diff := cmp.Diff(len(card.sent), len(r.Trace)); diff != "" {
+				t.Errorf("Trace does not keep every exchange (-sent +trace):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTransactionRun_PINVerifier(t *testing.T) {
+	ac1, ac1Resp := txGenerateAC("40", "40", txCDOL1("000000005000", "8000000000"))
+	pinCard := func() *mockCard {
+		card := txCard(map[string]string{
+			ac1:                          ac1Resp,
+			"80CA9F1700":                 "9F170103 9000",
+			"0020008008241234FFFFFFFFFF": "9000",
+		})
+		card.Records[0] = txRecord("FF00", "00000000 00000000 0100")
+		return card.mock()
+	}
+
+	tx := newTestTransaction(pinCard(), nil)
+	tx.Config.Capabilities = TerminalCapabilities{0xE0, 0x88, 0x00}
+	tx.PIN = &PINVerifier{EnterPIN: func(int) (string, bool) { return "1234", true }}
+
+	for i := 0; i < 2; i++ {
+		card := pinCard()
+		tx.Client = iso7816.NewClient(card)
+		tx.Random = bytes.NewReader(tlv.Hex(txUN))
+
+		r, err := tx.Run(5000, 0, TransactionPurchase)
+		if err != nil {
+			t.Fatalf("Run %d error = %v\nsent: %v", i+1, err, card.sent)
+		}
+		if diff := cmp.Diff(CVMResults{0x01, 0x00, 0x02}, r.CVM.Results); diff != "" {
+			t.Errorf("Run %d CVM Results mismatch (-want +got):\n%s", i+1, diff)
+		}
+		if diff := cmp.Diff(len(card.sent), len(r.Trace)); diff != "" {
+			t.Errorf("Run %d trace does not keep every exchange (-sent +trace):\n%s", i+1, diff)
+		}
+	}
+
+	if tx.PIN.Client != nil || tx.PIN.Key != nil || tx.PIN.Last != nil {
+		t.Errorf("Run() modified the PIN verifier of the transaction: %+v", tx.PIN)
+	}
+}
+
+func TestTransactionRun_ServicesOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		services bool
+		tvr      string // TVR set by processing restrictions
+	}{
+		{name: "goods", tvr: "0010000000"}, // Requested service not allowed for card product
+		{name: "services", services: true, tvr: "0000000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cdolTVR := fmt.Sprintf("80%s", tt.tvr[2:]) // Offline data authentication not performed
+			ac1, ac1Resp := txGenerateAC("40", "40", txCDOL1("000000005000", cdolTVR))
+			fixture := txCard(map[string]string{ac1: ac1Resp})
+			fixture.Records[0] = txRecord("0D00", "00000000 00000000 1F00") // Domestic and international services only
+			card := fixture.mock()
+
+			tx := newTestTransaction(card, nil)
+			tx.Config.Services = tt.services
+			r, err := tx.Run(5000, 0, TransactionPurchase)
+			if err != nil {
+				t.Fatalf("Run() error = %v\nsent: %v", err, card.sent)
+			}
+			if diff := cmp.Diff(tlv.Hex(tt.tvr), r.Restrictions.TVR[:]); diff != "" {
+				t.Errorf("Processing restrictions TVR mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTransactionRun_OnlineRequest(t *testing.T) {
+	ac1, ac1Resp := txGenerateAC("80", "80", txCDOL1("000000020000", "8000008000"))
+	ac2, ac2Resp := txGenerateAC("40", "40", "3030", txUN, "8000008000")
+	online := &fakeAuthorizer{resp: &OnlineResponse{ARC: []byte("00")}}
+
+	r, err := newTestTransaction(txCard(map[string]string{ac1: ac1Resp, ac2: ac2Resp}).mock(), online).Run(20000, 0, TransactionPurchase)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if r.Outcome != OutcomeApproved {
+		t.Errorf("Outcome = %s, want %s", r.Outcome, OutcomeApproved)
+	}
+
+	req := online.req
+	if diff := cmp.Diff(tlv.Hex("8000008000"), req.Terminal["95"]); diff != "" {
+		t.Errorf("TVR mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(tlv.Hex(txCDOL1("000000020000", "8000008000")), req.CDOL1Data); diff != "" {
+		t.Errorf("CDOL1 data mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(tlv.Hex("0102030405060708"), req.Cryptogram.ApplicationCryptogram); diff != "" {
+		t.Errorf("ARQC mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(tlv.Hex("4761739001010010"), req.Card.objects["5A"]); diff != "" {
+		t.Errorf("PAN mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTransactionRun_Terminated(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   map[string]string
+		wantErr error
+	}{
+		{
+			name:    "conditions of use not satisfied",
+			extra:   map[string]string{"80A80000048302025000": "6985"},
+			wantErr: ErrNoApplication,
+		},
+		{
+			name: "no application",
+			extra: map[string]string{
+				"00A4040007A0000000031010": "6A82",
+			},
+			wantErr: ErrNoApplication,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newTestTransaction(txCard(tt.extra).mock(), nil).Run(100, 0, TransactionPurchase)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if r == nil || r.Outcome != "" {
+				t.Errorf("Run() result = %+v, want a result without outcome", r)
+			}
+		})
+	}
+}
+
+func TestTransactionResult_Describe(t *testing.T) {
+	ac1, ac1Resp := txGenerateAC("40", "40", txCDOL1("000000005000", "8000000000"))
+	r, err := newTestTransaction(txCard(map[string]string{ac1: ac1Resp}).mock(), nil).Run(5000, 0, TransactionPurchase)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	out := r.Describe()
+	for _, want := range []string{
+		"=== EMV CONTACT TRANSACTION ===",
+		"Application: A0000000031010",
+		"First GENERATE AC:",
+		"[=] OUTCOME:",
+		"Result: Approved",
+		"Offline data authentication was not performed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Describe() does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package emv
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/bits"
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// TERMINAL VERIFICATION RESULTS (TVR) Logic according to EMV Book 3, Annex C5.
+// The TVR (Tag '95', 5 bytes) records the outcome of the functions performed by the
+// terminal. Each function sets its own bits:
+//
+// - Byte 1: Offline data authentication.
+// - Byte 2: Processing restrictions.
+// - Byte 3: Cardholder verification.
+// - Byte 4: Terminal risk management.
+// - Byte 5: Issuer authentication and script processing.
+//
+// TRANSACTION STATUS INFORMATION (TSI) Logic according to EMV Book 3, Annex C6.
+// The TSI (Tag '9B', 2 bytes) records the functions performed during the transaction.
+// Only byte 1 is defined, byte 2 is RFU.
+
+// TVR is the Terminal Verification Results.
+type TVR [5]byte
+
+// NewTVR creates a TVR from the value of Tag '95'.
+func NewTVR(value []byte) (TVR, error) {
+	var t TVR
+	if len(value) != len(t) {
+		return t, fmt.Errorf("TVR must be 5 bytes long (got %d)", len(value))
+	}
+	copy(t[:], value)
+	return t, nil
+}
+
+// TVRBit identifies a bit of the TVR.
+type TVRBit int
+
+const (
+	TVROfflineDataAuthenticationNotPerformed TVRBit = iota
+	TVRSDAFailed
+	TVRICCDataMissing
+	TVRCardOnExceptionFile
+	TVRDDAFailed
+	TVRCDAFailed
+	TVRSDASelected
+
+	TVRDifferentApplicationVersions
+	TVRExpiredApplication
+	TVRApplicationNotYetEffective
+	TVRServiceNotAllowed
+	TVRNewCard
+
+	TVRCardholderVerificationNotSuccessful
+	TVRUnrecognisedCVM
+	TVRPINTryLimitExceeded
+	TVRPINPadNotPresent
+	TVRPINNotEntered
+	TVROnlinePINEntered
+
+	TVRExceedsFloorLimit
+	TVRLowerConsecutiveOfflineLimitExceeded
+	TVRUpperConsecutiveOfflineLimitExceeded
+	TVRRandomlySelectedOnline
+	TVRMerchantForcedOnline
+
+	TVRDefaultTDOLUsed
+	TVRIssuerAuthenticationFailed
+	TVRScriptFailedBeforeFinalGenerateAC
+	TVRScriptFailedAfterFinalGenerateAC
+)
+
+// tvrBits is indexed by TVRBit.
+var tvrBits = []bitMeaning{
+	{0, 8, "Offline data authentication was not performed"},
+	{0, 7, "SDA failed"},
+	{0, 6, "ICC data missing"},
+	{0, 5, "Card appears on terminal exception file"},
+	{0, 4, "DDA failed"},
+	{0, 3, "CDA failed"},
+	{0, 2, "SDA selected"},
+
+	{1, 8, "ICC and terminal have different application versions"},
+	{1, 7, "Expired application"},
+	{1, 6, "Application not yet effective"},
+	{1, 5, "Requested service not allowed for card product"},
+	{1, 4, "New card"},
+
+	{2, 8, "Cardholder verification was not successful"},
+	{2, 7, "Unrecognised CVM"},
+	{2, 6, "PIN Try Limit exceeded"},
+	{2, 5, "PIN entry required and PIN pad not present or not working"},
+	{2, 4, "PIN entry required, PIN pad present, but PIN was not entered"},
+	{2, 3, "Online PIN entered"},
+
+	{3, 8, "Transaction exceeds floor limit"},
+	{3, 7, "Lower consecutive offline limit exceeded"},
+	{3, 6, "Upper consecutive offline limit exceeded"},
+	{3, 5, "Transaction selected randomly for online processing"},
+	{3, 4, "Merchant forced transaction online"},
+
+	{4, 8, "Default TDOL used"},
+	{4, 7, "Issuer authentication failed"},
+	{4, 6, "Script processing failed before final GENERATE AC"},
+	{4, 5, "Script processing failed after final GENERATE AC"},
+}
+
+func (b TVRBit) String() string {
+	if b < 0 || int(b) >= len(tvrBits) {
+		return fmt.Sprintf("Unknown TVR bit (%d)", int(b))
+	}
+	m := tvrBits[b]
+	return fmt.Sprintf("Byte %d Bit %d: %s", m.byteIndex+1, m.bit, m.name)
+}
+
+// Set sets a bit of the TVR.
+func (t *TVR) Set(b TVRBit) {
+	m := tvrBits[b]
+	t[m.byteIndex] = bits.Set(t[m.byteIndex], m.bit)
+}
+
+// IsSet reports whether a bit of the TVR is set.
+func (t TVR) IsSet(b TVRBit) bool {
+	m := tvrBits[b]
+	return bits.IsSet(t[m.byteIndex], m.bit)
+}
+
+// Merge sets the bits that are set in other.
+func (t *TVR) Merge(other TVR) {
+	for i := range t {
+		t[i] |= other[i]
+	}
+}
+
+// SetBits returns the bits set in the TVR, in order.
+func (t TVR) SetBits() []TVRBit {
+	var set []TVRBit
+	for b := range tvrBits {
+		if t.IsSet(TVRBit(b)) {
+			set = append(set, TVRBit(b))
+		}
+	}
+	return set
+}
+
+// Names lists the names of the bits set in the TVR.
+func (t TVR) Names() []string {
+	return setBits(t[:], tvrBits)
+}
+
+// Report builds the structured report of the TVR.
+func (t TVR) Report() *report.Report {
+	rep := report.New("EMV TERMINAL VERIFICATION RESULTS")
+	describeBits(rep.AddSection(fmt.Sprintf("TVR (95): %X", t[:])), t[:], tvrBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the TVR bits.
+func (t TVR) Describe() string {
+	return report.Text(t.Report())
+}
+
+// TSI is the Transaction Status Information.
+type TSI [2]byte
+
+// NewTSI creates a TSI from the value of Tag '9B'.
+func NewTSI(value []byte) (TSI, error) {
+	var t TSI
+	if len(value) != len(t) {
+		return t, fmt.Errorf("TSI must be 2 bytes long (got %d)", len(value))
+	}
+	copy(t[:], value)
+	return t, nil
+}
+
+// TSIBit identifies a bit of the TSI.
+type TSIBit int
+
+const (
+	TSIOfflineDataAuthenticationPerformed TSIBit = iota
+	TSICardholderVerificationPerformed
+	TSICardRiskManagementPerformed
+	TSIIssuerAuthenticationPerformed
+	TSITerminalRiskManagementPerformed
+	TSIScriptProcessingPerformed
+)
+
+// tsiBits is indexed by TSIBit.
+var tsiBits = []bitMeaning{
+	{0, 8, "Offline data authentication was performed"},
+	{0, 7, "Cardholder verification was performed"},
+	{0, 6, "Card risk management was performed"},
+	{0, 5, "Issuer authentication was performed"},
+	{0, 4, "Terminal risk management was performed"},
+	{0, 3, "Script processing was performed"},
+}
+
+func (b TSIBit) String() string {
+	if b < 0 || int(b) >= len(tsiBits) {
+		return fmt.Sprintf("Unknown TSI bit (%d)", int(b))
+	}
+	m := tsiBits[b]
+	return fmt.Sprintf("Byte %d Bit %d: %s", m.byteIndex+1, m.bit, m.name)
+}
+
+// Set sets a bit of the TSI.
+func (t *TSI) Set(b TSIBit) {
+	m := tsiBits[b]
+	t[m.byteIndex] = bits.Set(t[m.byteIndex], m.bit)
+}
+
+// IsSet reports whether a bit of the TSI is set.
+func (t TSI) IsSet(b TSIBit) bool {
+	m := tsiBits[b]
+	return bits.IsSet(t[m.byteIndex], m.bit)
+}
+
+// Names lists the names of the bits set in the TSI.
+func (t TSI) Names() []string {
+	return setBits(t[:], tsiBits)
+}
+
+// Report builds the structured report of the TSI.
+func (t TSI) Report() *report.Report {
+	rep := report.New("EMV TRANSACTION STATUS INFORMATION")
+	describeBits(rep.AddSection(fmt.Sprintf("TSI (9B): %X", t[:])), t[:], tsiBits)
+	return rep
+}
+
+// Describe generates a human-readable list of the TSI bits.
+func (t TSI) Describe() string {
+	return report.Text(t.Report())
+}
@@ -0,0 +1,149 @@
+package emv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTVR(t *testing.T) {
+	var tvr TVR
+	tvr.Set(TVROfflineDataAuthenticationNotPerformed)
+	tvr.Set(TVRExpiredApplication)
+	tvr.Set(TVROnlinePINEntered)
+	tvr.Set(TVRExceedsFloorLimit)
+	tvr.Set(TVRScriptFailedAfterFinalGenerateAC)
+
+	if diff := cmp.Diff(TVR{0x80, 0x40, 0x04, 0x80, 0x10}, tvr); diff != "" {
+		t.Errorf("TVR mismatch (-want +got):\n%s", diff)
+	}
+
+	if !tvr.IsSet(TVRExpiredApplication) || tvr.IsSet(TVRNewCard) {
+		t.Error("IsSet returned an unexpected state")
+	}
+
+	expectedBits := []TVRBit{
+		TVROfflineDataAuthenticationNotPerformed,
+		TVRExpiredApplication,
+		TVROnlinePINEntered,
+		TVRExceedsFloorLimit,
+		TVRScriptFailedAfterFinalGenerateAC,
+	}
+	if diff := cmp.Diff(expectedBits, tvr.SetBits()); diff != "" {
+		t.Errorf("SetBits mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedNames := []string{
+		"Offline data authentication was not performed",
+		"Expired application",
+		"Online PIN entered",
+		"Transaction exceeds floor limit",
+		"Script processing failed after final GENERATE AC",
+	}
+	if diff := cmp.Diff(expectedNames, tvr.Names()); diff != "" {
+		t.Errorf("Names mismatch (-want +got):\n%s", diff)
+	}
+
+	other := TVR{0x00, 0x00, 0x80}
+	tvr.Merge(other)
+	if !tvr.IsSet(TVRCardholderVerificationNotSuccessful) || !tvr.IsSet(TVROnlinePINEntered) {
+		t.Errorf("Merge lost bits: %X", tvr[:])
+	}
+}
+
+func TestTVRBitString(t *testing.T) {
+	tests := []struct {
+		bit      TVRBit
+		expected string
+	}{
+		{TVRSDAFailed, "Byte 1 Bit 7: SDA failed"},
+		{TVRPINTryLimitExceeded, "Byte 3 Bit 6: PIN Try Limit exceeded"},
+		{TVRIssuerAuthenticationFailed, "Byte 5 Bit 7: Issuer authentication failed"},
+		{TVRBit(99), "Unknown TVR bit (99)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.bit.String(); got != tt.expected {
+			t.Errorf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestNewTVR(t *testing.T) {
+	tvr, err := NewTVR([]byte{0x80, 0x00, 0x00, 0x80, 0x00})
+	if err != nil {
+		t.Fatalf("NewTVR failed: %v", err)
+	}
+	if !tvr.IsSet(TVRExceedsFloorLimit) {
+		t.Errorf("NewTVR lost bits: %X", tvr[:])
+	}
+	if _, err := NewTVR([]byte{0x80}); err == nil {
+		t.Error("NewTVR should reject a 1-byte value")
+	}
+}
+
+func TestTVRDescribe(t *testing.T) {
+	expected := []string{
+		"=== EMV TERMINAL VERIFICATION RESULTS ===",
+		"TVR (95): 8000008000",
+		"    - Byte 1 Bit 8: [x] Offline data authentication was not performed",
+		"    - Byte 1 Bit 7: [ ] SDA failed",
+		"    - Byte 4 Bit 8: [x] Transaction exceeds floor limit",
+		"    - Byte 5 Bit 5: [ ] Script processing failed after final GENERATE AC",
+	}
+
+	desc := TVR{0x80, 0x00, 0x00, 0x80, 0x00}.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+}
+
+func TestTSI(t *testing.T) {
+	var tsi TSI
+	tsi.Set(TSIOfflineDataAuthenticationPerformed)
+	tsi.Set(TSICardRiskManagementPerformed)
+	tsi.Set(TSIScriptProcessingPerformed)
+
+	if diff := cmp.Diff(TSI{0xA4, 0x00}, tsi); diff != "" {
+		t.Errorf("TSI mismatch (-want +got):\n%s", diff)
+	}
+	if !tsi.IsSet(TSICardRiskManagementPerformed) || tsi.IsSet(TSICardholderVerificationPerformed) {
+		t.Error("IsSet returned an unexpected state")
+	}
+
+	expectedNames := []string{
+		"Offline data authentication was performed",
+		"Card risk management was performed",
+		"Script processing was performed",
+	}
+	if diff := cmp.Diff(expectedNames, tsi.Names()); diff != "" {
+		t.Errorf("Names mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := TSITerminalRiskManagementPerformed.String(); got != "Byte 1 Bit 4: Terminal risk management was performed" {
+		t.Errorf("String() = %q", got)
+	}
+
+	parsed, err := NewTSI([]byte{0xE8, 0x00})
+	if err != nil {
+		t.Fatalf("NewTSI failed: %v", err)
+	}
+	desc := parsed.Describe()
+	for _, line := range []string{
+		"=== EMV TRANSACTION STATUS INFORMATION ===",
+		"TSI (9B): E800",
+		"    - Byte 1 Bit 7: [x] Cardholder verification was performed",
+		"    - Byte 1 Bit 5: [ ] Issuer authentication was performed",
+	} {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+
+	if _, err := NewTSI([]byte{0xE8}); err == nil {
+		t.Error("NewTSI should reject a 1-byte value")
+	}
+}
@@ -151,6 +151,77 @@ func (c *CommandAPDU) Bytes() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ParseCommandAPDU decodes a raw C-APDU, such as a command of an issuer script.
+// Both Short and Extended encodings are accepted, for the four cases.
+func ParseCommandAPDU(raw []byte) (*CommandAPDU, error) {
+	if len(raw) < 4 {
+		return nil, fmt.Errorf("command too short: length %d", len(raw))
+	}
+
+	cla, err := NewClass(raw[0])
+	if err != nil {
+		return nil, err
+	}
+	ins, err := NewInstruction(InsCode(raw[1]))
+	if err != nil {
+		return nil, err
+	}
+	cmd := NewCommandAPDU(cla, ins, raw[2], raw[3], nil, 0)
+
+	body := raw[4:]
+	switch {
+	case len(body) == 0:
+		// Case 1
+	case len(body) == 1:
+		// Case 2 Short
+		cmd.Ne = decodeLe(body, MaxShortLe)
+	case body[0] != 0x00:
+		// Case 3/4 Short
+		nc := int(body[0])
+		if err := setBody(cmd, body[1:], nc, 1, MaxShortLe); err != nil {
+			return nil, err
+		}
+	case len(body) == 3:
+		// Case 2 Extended
+		cmd.Ne = decodeLe(body[1:], MaxExtendedLe)
+	case len(body) > 3:
+		// Case 3/4 Extended
+		nc := int(body[1])<<8 | int(body[2])
+		if err := setBody(cmd, body[3:], nc, 2, MaxExtendedLe); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid command body: %X", body)
+	}
+
+	return cmd, nil
+}
+
+// setBody sets the Data field (nc bytes) and the optional Le field (leSize bytes).
+func setBody(cmd *CommandAPDU, rest []byte, nc, leSize, maxLe int) error {
+	switch len(rest) {
+	case nc:
+	case nc + leSize:
+		cmd.Ne = decodeLe(rest[nc:], maxLe)
+	default:
+		return fmt.Errorf("Lc %d does not match the %d bytes of the command body", nc, len(rest))
+	}
+	cmd.Data = rest[:nc]
+	return nil
+}
+
+// decodeLe decodes a 1 or 2 bytes Le field, where zero encodes the maximum.
+func decodeLe(le []byte, maxLe int) int {
+	n := 0
+	for _, b := range le {
+		n = n<<8 | int(b)
+	}
+	if n == 0 {
+		return maxLe
+	}
+	return n
+}
+
 // String returns a readable representation of the command meta-data.
 func (c *CommandAPDU) String() string {
 	return fmt.Sprintf("%s | P1: %02X, P2: %02X | Lc: %d | Le: %d",
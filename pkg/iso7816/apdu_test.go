@@ -105,3 +105,49 @@ func TestParseResponseAPDU_TooShort(t *testing.T) {
 		t.Error("Expected error for short response, got nil")
 	}
 }
+
+func TestParseCommandAPDU(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		nc   int
+		ne   int
+	}{
+		{"Case 1", "841E0000", 0, 0},
+		{"Case 2 Short", "00B2010C00", 0, MaxShortLe},
+		{"Case 3 Short", "84DA9F580501020304AA", 5, 0},
+		{"Case 4 Short", "00A4040002A0000A", 2, 10},
+		{"Case 2 Extended", "00B00000000000", 0, MaxExtendedLe},
+		{"Case 3 Extended", "00A40000000104" + hex.EncodeToString(make([]byte, 260)), 260, 0},
+		{"Case 4 Extended", "00A40000000104" + hex.EncodeToString(make([]byte, 260)) + "0102", 260, 258},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.raw)
+			cmd, err := ParseCommandAPDU(raw)
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			if len(cmd.Data) != tt.nc || cmd.Ne != tt.ne {
+				t.Errorf("Got Nc %d, Ne %d, want %d, %d", len(cmd.Data), cmd.Ne, tt.nc, tt.ne)
+			}
+			encoded, err := cmd.Bytes()
+			if err != nil {
+				t.Fatalf("Encoding failed: %v", err)
+			}
+			if got := strings.ToUpper(hex.EncodeToString(encoded)); got != strings.ToUpper(tt.raw) {
+				t.Errorf("Round trip mismatch: got %s", got)
+			}
+		})
+	}
+}
+
+func TestParseCommandAPDU_Invalid(t *testing.T) {
+	for _, raw := range []string{"00B201", "FFB20100", "00600000", "84DA9F5805010203", "00A400000000", "00A4000000000201"} {
+		b, _ := hex.DecodeString(raw)
+		if _, err := ParseCommandAPDU(b); err == nil {
+			t.Errorf("ParseCommandAPDU(%s) should fail", raw)
+		}
+	}
+}
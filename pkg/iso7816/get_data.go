@@ -0,0 +1,19 @@
+package iso7816
+
+// GET DATA COMMAND LOGIC (ISO 7816-4):
+// The GET DATA command (INS 'CA') retrieves a data object from the current context,
+// e.g. the current application.
+//
+// P1-P2 (Tag):
+// - '00XX': XX is a one-byte tag.
+// - 'XXXX': a two-byte tag (e.g. '9F36' for the Application Transaction Counter).
+//
+// The response data is the data object, tag and length included.
+
+// GetData creates a GET DATA command for the data object identified by tag.
+func GetData(cla Class, tag uint16) *CommandAPDU {
+	ins, _ := NewInstruction(INS_GET_DATA)
+
+	// GET DATA is a "Case 2" command (No data sent, Data expected).
+	return NewCommandAPDU(cla, ins, byte(tag>>8), byte(tag), nil, MaxShortLe)
+}
@@ -0,0 +1,43 @@
+package iso7816
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestGetData(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      *CommandAPDU
+		expected []byte
+	}{
+		{
+			name:     "Two-byte Tag (ATC)",
+			cmd:      GetData(Class{Raw: 0x80, IsProprietary: true}, 0x9F36),
+			expected: tlv.Hex("80 CA 9F 36", "00"),
+		},
+		{
+			name:     "One-byte Tag",
+			cmd:      GetData(Class{}, 0x5A),
+			expected: tlv.Hex("00 CA 00 5A", "00"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Bytes()
+			if err != nil {
+				t.Fatalf("Failed to encode bytes: %v", err)
+			}
+
+			if !bytes.Equal(got, tt.expected) {
+				t.Errorf("Mismatch:\nExpected: %s\nGot:      %s",
+					hex.EncodeToString(tt.expected),
+					hex.EncodeToString(got))
+			}
+		})
+	}
+}
@@ -86,6 +86,10 @@ const (
 type Instruction struct {
 	Raw      InsCode
 	IsBERTLV bool
+
+	// Name overrides the ISO 7816-4 name of Raw, for proprietary instructions whose
+	// code is also an ISO one (e.g. EMV RECOVER AC is 'D0', as WRITE BINARY).
+	Name string
 }
 
 // NewInstruction creates an Instruction object with validation.
@@ -109,5 +113,9 @@ func (i Instruction) Verbose() string {
 	if i.IsBERTLV {
 		format = "BER-TLV"
 	}
-	return fmt.Sprintf("INS: 0x%02X | Command: %s | Format: %s", byte(i.Raw), i.Raw.String(), format)
+	name := i.Raw.String()
+	if i.Name != "" {
+		name = i.Name
+	}
+	return fmt.Sprintf("INS: 0x%02X | Command: %s | Format: %s", byte(i.Raw), name, format)
 }
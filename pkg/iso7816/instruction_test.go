@@ -63,14 +63,17 @@ func TestInstruction_Verbose(t *testing.T) {
 	// Tests stringer integration and formatting
 	tests := []struct {
 		ins      InsCode
+		name     string
 		contains []string
 	}{
-		{INS_SELECT, []string{"INS: 0xA4", "Command: INS_SELECT", "Format: Standard"}},
-		{INS_READ_BINARY_BER, []string{"INS: 0xB1", "Command: INS_READ_BINARY_BER", "Format: BER-TLV"}},
+		{INS_SELECT, "", []string{"INS: 0xA4", "Command: INS_SELECT", "Format: Standard"}},
+		{INS_READ_BINARY_BER, "", []string{"INS: 0xB1", "Command: INS_READ_BINARY_BER", "Format: BER-TLV"}},
+		{INS_WRITE_BINARY, "PROPRIETARY_D0", []string{"INS: 0xD0", "Command: PROPRIETARY_D0"}}, // Proprietary instruction with an ISO code
 	}
 
 	for _, tt := range tests {
 		i, _ := NewInstruction(tt.ins)
+		i.Name = tt.name
 		desc := i.Verbose()
 		for _, part := range tt.contains {
 			if !strings.Contains(desc, part) {
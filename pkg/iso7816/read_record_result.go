@@ -2,8 +2,8 @@ package iso7816
 
 import (
 	"fmt"
-	"strings"
 
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/gregLibert/smart-card/pkg/tlv"
 )
 
@@ -24,11 +24,9 @@ func NewReadRecordResult(t Trace) (*ReadRecordResult, error) {
 	return &ReadRecordResult{Trace: t}, nil
 }
 
-// Describe generates a detailed, ASCII-formatted report of the read operation.
-func (r *ReadRecordResult) Describe() string {
-	var sb strings.Builder
-
-	sb.WriteString("=== READ RECORD COMMAND REPORT ===\n")
+// Report builds the structured report of the read operation.
+func (r *ReadRecordResult) Report() *report.Report {
+	rep := report.New("READ RECORD COMMAND REPORT")
 
 	tx0 := r.Trace[0]
 	cmd := tx0.Command
@@ -37,13 +35,14 @@ func (r *ReadRecordResult) Describe() string {
 	sfi := cmd.P2 >> 3
 	mode := ReadRecordMode(cmd.P2 & 0x07)
 
-	sb.WriteString("[1] Command: READ RECORD\n")
+	section := rep.AddSection("[1] Command: READ RECORD")
+	section.LabelWidth = 9
 
 	targetStr := "Current EF"
 	if sfi > 0 {
 		targetStr = fmt.Sprintf("SFI %02X (%d)", sfi, sfi)
 	}
-	sb.WriteString(fmt.Sprintf("    + Target:  %s\n", targetStr))
+	section.Detail("Target", targetStr)
 
 	var p1Desc string
 	if (mode & 0b100) != 0 {
@@ -56,47 +55,32 @@ func (r *ReadRecordResult) Describe() string {
 		p1Desc = fmt.Sprintf("Record Identifier %02X", cmd.P1)
 	}
 
-	sb.WriteString(fmt.Sprintf("    + P1:      %02X -> %s\n", cmd.P1, p1Desc))
-	sb.WriteString(fmt.Sprintf("    + Mode:    %02X -> %s\n", byte(mode), mode))
-
-	swVal := uint16(tx0.Response.Status)
-	sw1 := byte(swVal >> 8)
-	sw2 := byte(swVal)
-	swHex := fmt.Sprintf("%02X %02X", sw1, sw2)
-
-	resultMsg := "[OK]"
-	resultDesc := "SW_NO_ERROR"
-
-	switch {
-	case sw1 == 0x61:
-		resultDesc = fmt.Sprintf("%02X (%d) bytes still available", sw2, sw2)
-	case sw1 == 0x6C:
-		resultMsg = "[!!]"
-		resultDesc = fmt.Sprintf("Wrong length, correct is %02X (%d)", sw2, sw2)
-	case swVal != 0x9000:
-		resultMsg = "[!!]"
-		resultDesc = tx0.Response.Status.Verbose()
-	}
-
-	sb.WriteString(fmt.Sprintf("    + Result:  [%s] %s %s\n", swHex, resultMsg, resultDesc))
-	sb.WriteString("\n")
+	section.Detail("P1", fmt.Sprintf("%02X -> %s", cmd.P1, p1Desc))
+	section.Detail("Mode", fmt.Sprintf("%02X -> %s", byte(mode), mode))
+	section.Detail("Result", describeInitialStatus(tx0.Response.Status))
 
 	lastTx := r.Last()
 	finalPayload := lastTx.Response.Data
 
 	if len(r.Trace) > 1 {
-		sb.WriteString(fmt.Sprintf("[2] Protocol: Auto-handling (%d steps)\n", len(r.Trace)))
-		sb.WriteString(fmt.Sprintf("    + Final SW: [%04X]\n", uint16(lastTx.Response.Status)))
+		rep.AddSection(fmt.Sprintf("[2] Protocol: Auto-handling (%d steps)", len(r.Trace))).
+			Detail("Final SW", fmt.Sprintf("[%04X]", uint16(lastTx.Response.Status)))
 	}
 
-	sb.WriteString("[=] DATA OUTCOME:\n")
+	outcome := rep.AddSection("[=] DATA OUTCOME:")
+	outcome.LabelWidth = 8
 	if len(finalPayload) > 0 {
-		sb.WriteString(fmt.Sprintf("    + Length: %d bytes\n", len(finalPayload)))
-		sb.WriteString(fmt.Sprintf("    + Dump:   %X\n", finalPayload))
-		sb.WriteString(fmt.Sprintf("    + ASCII:  %q\n", tlv.MakeSafeASCII(finalPayload)))
+		outcome.Detail("Length", fmt.Sprintf("%d bytes", len(finalPayload)))
+		outcome.Detail("Dump", fmt.Sprintf("%X", finalPayload))
+		outcome.Detail("ASCII", fmt.Sprintf("%q", tlv.MakeSafeASCII(finalPayload)))
 	} else {
-		sb.WriteString("    - No Data Received.\n")
+		outcome.Note("", "No Data Received.")
 	}
 
-	return strings.TrimRight(sb.String(), "\n")
+	return rep
+}
+
+// Describe generates a detailed, ASCII-formatted report of the read operation.
+func (r *ReadRecordResult) Describe() string {
+	return report.Text(r.Report())
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/gregLibert/smart-card/pkg/tlv"
 )
 
@@ -51,75 +52,54 @@ func (r *SelectResult) FCI() (*FileControlInfo, error) {
 	return ParseSelectData(lastTx.Response.Data, initialP2)
 }
 
-// Describe generates a detailed, ASCII-formatted report of the selection process.
-func (r *SelectResult) Describe() string {
-	var sb strings.Builder
-
-	sb.WriteString("=== SELECT COMMAND REPORT ===\n")
+// Report builds the structured report of the selection process.
+func (r *SelectResult) Report() *report.Report {
+	rep := report.New("SELECT COMMAND REPORT")
 
 	tx0 := r.Trace[0]
-	r.writeCommandDetails(&sb, tx0.Command)
-	r.writeInitialResult(&sb, tx0.Response)
-
-	finalPayload := tx0.Response.Data
+	r.writeCommandDetails(rep, tx0.Command, tx0.Response)
 
 	// Handle Protocol Trace (Auto-handling)
 	if len(r.Trace) > 1 {
-		lastTx := r.Last()
-		finalPayload = lastTx.Response.Data
-		r.writeProtocolTrace(&sb, r.Trace, lastTx)
+		r.writeProtocolTrace(rep, r.Trace, r.Last())
 	}
 
 	// Handle Final FCI Parsing
-	r.writeFinalOutcome(&sb, finalPayload)
+	r.writeFinalOutcome(rep, r.Last().Response.Data)
+
+	return rep
+}
 
-	return strings.TrimRight(sb.String(), "\n")
+// Describe generates a detailed, ASCII-formatted report of the selection process.
+func (r *SelectResult) Describe() string {
+	return report.Text(r.Report())
 }
 
-func (r *SelectResult) writeCommandDetails(sb *strings.Builder, cmd *CommandAPDU) {
+func (r *SelectResult) writeCommandDetails(rep *report.Report, cmd *CommandAPDU, resp *ResponseAPDU) {
 	method := SelectionMethod(cmd.P1)
 	occ := FileOccurrence(cmd.P2 & 0x03)
 	ctrl := SelectionControl(cmd.P2 & 0x0C)
 
-	sb.WriteString("[1] Command: SELECT FILE (Initial Request)\n")
-	sb.WriteString(fmt.Sprintf("    + Method:  %02X -> %s\n", cmd.P1, method))
-	sb.WriteString(fmt.Sprintf("    + Control: %02X -> %s | %s\n", cmd.P2, occ, ctrl))
-
-	if len(cmd.Data) > 0 {
-		sb.WriteString(fmt.Sprintf("    + Data:    %X (%q)\n", cmd.Data, tlv.MakeSafeASCII(cmd.Data)))
-	}
-}
+	section := rep.AddSection("[1] Command: SELECT FILE (Initial Request)")
+	section.LabelWidth = 9
 
-func (r *SelectResult) writeInitialResult(sb *strings.Builder, resp *ResponseAPDU) {
-	swVal := uint16(resp.Status)
-	sw1 := byte(swVal >> 8)
-	sw2 := byte(swVal)
-	swHex := fmt.Sprintf("%02X %02X", sw1, sw2)
+	section.Detail("Method", fmt.Sprintf("%02X -> %s", cmd.P1, method))
+	section.Detail("Control", fmt.Sprintf("%02X -> %s | %s", cmd.P2, occ, ctrl))
 
-	resultMsg := "[OK]"
-	resultDesc := "SW_NO_ERROR"
-
-	switch {
-	case sw1 == 0x61:
-		resultDesc = fmt.Sprintf("%02X (%d) bytes still available", sw2, sw2)
-	case sw1 == 0x6C:
-		resultMsg = "[!!]"
-		resultDesc = fmt.Sprintf("Wrong length, correct is %02X (%d)", sw2, sw2)
-	case swVal != 0x9000:
-		resultMsg = "[!!]"
-		resultDesc = resp.Status.Verbose()
+	if len(cmd.Data) > 0 {
+		section.Detail("Data", fmt.Sprintf("%X (%q)", cmd.Data, tlv.MakeSafeASCII(cmd.Data)))
 	}
 
-	sb.WriteString(fmt.Sprintf("    + Result:  [%s] %s %s\n", swHex, resultMsg, resultDesc))
+	section.Detail("Result", describeInitialStatus(resp.Status))
 
 	if len(resp.Data) > 0 {
-		sb.WriteString(fmt.Sprintf("    + Payload: %d bytes received directly\n", len(resp.Data)))
+		section.Detail("Payload", fmt.Sprintf("%d bytes received directly", len(resp.Data)))
 	}
-	sb.WriteString("\n")
 }
 
-func (r *SelectResult) writeProtocolTrace(sb *strings.Builder, trace Trace, lastTx *Transaction) {
-	sb.WriteString(fmt.Sprintf("[2] Protocol: Auto-handling (Sequence of %d steps)\n", len(trace)))
+func (r *SelectResult) writeProtocolTrace(rep *report.Report, trace Trace, lastTx *Transaction) {
+	section := rep.AddSection(fmt.Sprintf("[2] Protocol: Auto-handling (Sequence of %d steps)", len(trace)))
+	section.LabelWidth = 9
 
 	finalPayload := lastTx.Response.Data
 	finalSW := uint16(lastTx.Response.Status)
@@ -132,25 +112,24 @@ func (r *SelectResult) writeProtocolTrace(sb *strings.Builder, trace Trace, last
 		opName = "RE-SELECT (Correction)"
 	}
 
-	sb.WriteString(fmt.Sprintf("    + Action:  Sending %s\n", opName))
-	sb.WriteString(fmt.Sprintf("    + Result:  [%04X] [OK] Final Status\n", finalSW))
+	section.Detail("Action", fmt.Sprintf("Sending %s", opName))
+	section.Detail("Result", fmt.Sprintf("[%04X] [OK] Final Status", finalSW))
 
 	if len(finalPayload) > 0 {
-		sb.WriteString(fmt.Sprintf("    + Payload: %d bytes received\n", len(finalPayload)))
-		sb.WriteString(fmt.Sprintf("      Dump:    %X\n", finalPayload))
+		section.Detail("Payload", fmt.Sprintf("%d bytes received", len(finalPayload)))
+		section.Continuation("Dump", fmt.Sprintf("%X", finalPayload))
 	}
-	sb.WriteString("\n")
 }
 
-func (r *SelectResult) writeFinalOutcome(sb *strings.Builder, payload []byte) {
-	sb.WriteString("[=] FINAL OUTCOME:\n")
+func (r *SelectResult) writeFinalOutcome(rep *report.Report, payload []byte) {
+	section := rep.AddSection("[=] FINAL OUTCOME:")
 
 	fci, err := r.FCI()
 	if err != nil {
 		if len(payload) > 0 {
-			sb.WriteString(fmt.Sprintf("    - FCI Parsing Failed: %v\n", err))
+			section.Note("FCI Parsing Failed", err.Error())
 		} else {
-			sb.WriteString("    - No Data returned to parse.\n")
+			section.Note("", "No Data returned to parse.")
 		}
 		return
 	}
@@ -170,15 +149,49 @@ func (r *SelectResult) writeFinalOutcome(sb *strings.Builder, payload []byte) {
 	if len(structures) > 0 {
 		strList = strings.Join(structures, " + ")
 	}
-	sb.WriteString(fmt.Sprintf("    - Structure: %s", strList))
+	section.Note("Structure", strList)
 
-	if fci.FCP != nil {
-		tlv.WriteStructFields(sb, "FCP", fci.FCP)
+	noteStructFields(section, "FCP", fci.FCP)
+	noteStructFields(section, "FMD", fci.FMD)
+	if len(fci.ProprietaryRawData) > 0 {
+		section.Note("Proprietary", fmt.Sprintf("%X", fci.ProprietaryRawData)).AlignLast(proprietaryLabelWidth)
 	}
-	if fci.FMD != nil {
-		tlv.WriteStructFields(sb, "FMD", fci.FMD)
+}
+
+// proprietaryLabelWidth aligns the proprietary data as in the historical layout ("Proprietary:   ").
+const proprietaryLabelWidth = 15
+
+// noteStructFields adds the fields of a template and of its nested templates as
+// "Template.Field (Tag)" notes, so that they keep their place among the other notes of the section.
+func noteStructFields(section *report.Section, prefix string, s interface{}) {
+	node := tlv.BuildNode(prefix, s)
+	if node == nil {
+		return
 	}
-	if len(fci.ProprietaryRawData) > 0 {
-		sb.WriteString(fmt.Sprintf("    - Proprietary:   %X\n", fci.ProprietaryRawData))
+	report.WalkFields(node, func(path string, f report.Field) {
+		section.Note(path+"."+f.Label(), f.Text)
+	})
+}
+
+// describeInitialStatus summarizes the status word of the first response of a trace,
+// e.g. "[61 2B] [OK] 2B (43) bytes still available".
+func describeInitialStatus(status StatusWord) string {
+	sw1 := status.SW1()
+	sw2 := status.SW2()
+
+	resultMsg := "[OK]"
+	resultDesc := "SW_NO_ERROR"
+
+	switch {
+	case sw1 == 0x61:
+		resultDesc = fmt.Sprintf("%02X (%d) bytes still available", sw2, sw2)
+	case sw1 == 0x6C:
+		resultMsg = "[!!]"
+		resultDesc = fmt.Sprintf("Wrong length, correct is %02X (%d)", sw2, sw2)
+	case status != SW_NO_ERROR:
+		resultMsg = "[!!]"
+		resultDesc = status.Verbose()
 	}
+
+	return fmt.Sprintf("[%02X %02X] %s %s", sw1, sw2, resultMsg, resultDesc)
 }
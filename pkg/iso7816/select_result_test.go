@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/gregLibert/smart-card/pkg/tlv"
 )
 
@@ -64,6 +65,55 @@ func TestSelectResult_Describe(t *testing.T) {
 			t.Errorf("Report mismatch (-want +got):\n%s", diff)
 		}
 	})
+
+	t.Run("Proprietary Response", func(t *testing.T) {
+		trace := Trace{{
+			Command:  cmdSelect,
+			Response: &ResponseAPDU{Data: tlv.Hex("C0 01 02"), Status: SW_NO_ERROR},
+		}}
+
+		res, _ := NewSelectResult(trace)
+		lines := strings.Split(res.Describe(), "\n")
+
+		expectedTail := []string{
+			"[=] FINAL OUTCOME:",
+			"    - Structure: ProprietaryRaw",
+			"    - Proprietary:   C00102",
+		}
+		if diff := cmp.Diff(expectedTail, lines[len(lines)-3:]); diff != "" {
+			t.Errorf("Final outcome mismatch (-want +got):\n%s", diff)
+		}
+
+		sections := res.Report().Sections
+		items := sections[len(sections)-1].Items
+		last := items[len(items)-1]
+		if last.Label != "Proprietary" || last.Value != "C00102" {
+			t.Errorf("Proprietary item = %q: %q, want the label apart from the value", last.Label, last.Value)
+		}
+	})
+}
+
+func TestNoteStructFields(t *testing.T) {
+	type nested struct {
+		Label []byte `tlv:"50"`
+	}
+	template := struct {
+		DFName []byte  `tlv:"84"`
+		Inner  *nested `report:"Inner"`
+	}{DFName: []byte{0xA0}, Inner: &nested{Label: []byte{0x41}}}
+
+	section := report.New("T").AddSection("")
+	noteStructFields(section, "FCP", &template)
+	noteStructFields(section, "FMD", (*FMDTemplate)(nil))
+
+	var got []string
+	for _, item := range section.Items {
+		got = append(got, item.Label+": "+item.Value)
+	}
+	expected := []string{"FCP.DFName (84): A0", "Inner.Label (50): 41"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Notes mismatch (-want +got):\n%s", diff)
+	}
 }
 
 func NewInstructionMust(code InsCode) Instruction {
@@ -0,0 +1,73 @@
+package issuer
+
+import "fmt"
+
+// AUTHORISATION RESPONSE CRYPTOGRAM Logic according to EMV Book 2, section 8.2.
+// The issuer proves its identity to the card with the ARPC, sent back in the Issuer
+// Authentication Data ('91') and checked by the card during EXTERNAL AUTHENTICATE or
+// the second GENERATE AC:
+//
+// 1. Method 1: ARPC = 3DES(SK)[ARQC xor (ARC || '00 00 00 00 00 00')], 8 bytes.
+//    '91' = ARPC || Authorisation Response Code (2 bytes).
+// 2. Method 2: ARPC = the 4 leftmost bytes of MAC(SK)[ARQC || CSU || Proprietary
+//    Authentication Data], with the MAC of the Application Cryptogram.
+//    '91' = ARPC || Card Status Update (4 bytes) || Proprietary Authentication Data (0-8 bytes).
+
+// ARPCMethod1 computes the ARPC of method 1 from the ARQC and the Authorisation Response
+// Code ('8A').
+func ARPCMethod1(sessionKey, arqc, arc []byte) ([]byte, error) {
+	if len(arqc) != 8 {
+		return nil, fmt.Errorf("ARQC must be 8 bytes long (got %d)", len(arqc))
+	}
+	if len(arc) != 2 {
+		return nil, fmt.Errorf("Authorisation Response Code must be 2 bytes long (got %d)", len(arc))
+	}
+
+	y := append([]byte{}, arqc...)
+	y[0] ^= arc[0]
+	y[1] ^= arc[1]
+	arpc, err := encryptBlock(sessionKey, y)
+	if err != nil {
+		return nil, fmt.Errorf("ARPC: %w", err)
+	}
+	return arpc, nil
+}
+
+// ARPCMethod2 computes the ARPC of method 2 from the ARQC, the Card Status Update and the
+// optional Proprietary Authentication Data.
+func ARPCMethod2(sessionKey, arqc, csu, proprietary []byte) ([]byte, error) {
+	if len(arqc) != 8 {
+		return nil, fmt.Errorf("ARQC must be 8 bytes long (got %d)", len(arqc))
+	}
+	if len(csu) != 4 {
+		return nil, fmt.Errorf("Card Status Update must be 4 bytes long (got %d)", len(csu))
+	}
+	if len(proprietary) > 8 {
+		return nil, fmt.Errorf("Proprietary Authentication Data must be at most 8 bytes long (got %d)", len(proprietary))
+	}
+
+	data := append(append(append([]byte{}, arqc...), csu...), proprietary...)
+	mac, err := MAC(sessionKey, data)
+	if err != nil {
+		return nil, fmt.Errorf("ARPC: %w", err)
+	}
+	return mac[:4], nil
+}
+
+// IssuerAuthenticationDataMethod1 builds the value of Tag '91' for method 1.
+func IssuerAuthenticationDataMethod1(sessionKey, arqc, arc []byte) ([]byte, error) {
+	arpc, err := ARPCMethod1(sessionKey, arqc, arc)
+	if err != nil {
+		return nil, err
+	}
+	return append(arpc, arc...), nil
+}
+
+// IssuerAuthenticationDataMethod2 builds the value of Tag '91' for method 2.
+func IssuerAuthenticationDataMethod2(sessionKey, arqc, csu, proprietary []byte) ([]byte, error) {
+	arpc, err := ARPCMethod2(sessionKey, arqc, csu, proprietary)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(arpc, csu...), proprietary...), nil
+}
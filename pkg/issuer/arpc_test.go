@@ -0,0 +1,53 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestIssuerAuthenticationData(t *testing.T) {
+	arqc := tlv.Hex("5C789810634D0747")
+
+	method1, err := IssuerAuthenticationDataMethod1(testSessionKey, arqc, tlv.Hex("3030"))
+	if err != nil {
+		t.Fatalf("Method 1 failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("9656325BEA648F59 3030"), method1); diff != "" {
+		t.Errorf("Method 1 mismatch (-want +got):\n%s", diff)
+	}
+
+	method2, err := IssuerAuthenticationDataMethod2(testSessionKey, arqc, tlv.Hex("00820000"), nil)
+	if err != nil {
+		t.Fatalf("Method 2 failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("D5EBCFFA 00820000"), method2); diff != "" {
+		t.Errorf("Method 2 mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestARPCErrors(t *testing.T) {
+	arqc := tlv.Hex("5C789810634D0747")
+	tests := []struct {
+		name string
+		arpc func() ([]byte, error)
+	}{
+		{"Method 1 Short ARQC", func() ([]byte, error) { return ARPCMethod1(testSessionKey, arqc[:4], tlv.Hex("3030")) }},
+		{"Method 1 Long ARC", func() ([]byte, error) { return ARPCMethod1(testSessionKey, arqc, tlv.Hex("303030")) }},
+		{"Method 1 Short Key", func() ([]byte, error) { return ARPCMethod1(testSessionKey[:8], arqc, tlv.Hex("3030")) }},
+		{"Method 2 Short ARQC", func() ([]byte, error) { return ARPCMethod2(testSessionKey, arqc[:4], tlv.Hex("00820000"), nil) }},
+		{"Method 2 Short CSU", func() ([]byte, error) { return ARPCMethod2(testSessionKey, arqc, tlv.Hex("0082"), nil) }},
+		{"Method 2 Long Proprietary Data", func() ([]byte, error) {
+			return ARPCMethod2(testSessionKey, arqc, tlv.Hex("00820000"), make([]byte, 9))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.arpc(); err == nil {
+				t.Error("ARPC should fail")
+			}
+		})
+	}
+}
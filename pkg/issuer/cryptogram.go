@@ -0,0 +1,115 @@
+package issuer
+
+import (
+	"crypto/des"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/emv"
+)
+
+// APPLICATION CRYPTOGRAM Logic according to EMV Book 2, section 8.1 and Annex A1.2.
+// The ARQC, TC and AAC are MACs computed by the card with the session key over:
+//
+// 1. The terminal data sent with CDOL1 (or CDOL2). The recommended minimum set is, in
+//    this order: Amount Authorised ('9F02'), Amount Other ('9F03'), Terminal Country Code
+//    ('9F1A'), TVR ('95'), Transaction Currency Code ('5F2A'), Transaction Date ('9A'),
+//    Transaction Type ('9C') and Unpredictable Number ('9F37').
+// 2. The AIP ('82') and the ATC ('9F36') of the card.
+// 3. Issuer specific data, such as the Card Verification Results of the Issuer
+//    Application Data ('9F10').
+//
+// The MAC follows ISO/IEC 9797-1 Algorithm 3 with padding method 2: the data is padded
+// with '80' and zeros to a multiple of 8 bytes, enciphered with single DES in CBC mode
+// with the left half of the key, and the last block is deciphered with the right half
+// then enciphered again with the left half.
+
+// RecommendedTerminalData lists, in order, the terminal data of the recommended minimum
+// set for the Application Cryptogram.
+var RecommendedTerminalData = []string{"9F02", "9F03", "9F1A", "95", "5F2A", "9A", "9C", "9F37"}
+
+// CryptogramInput gathers the data covered by an Application Cryptogram.
+type CryptogramInput struct {
+	CDOL     emv.DOL // CDOL1 ('8C') or CDOL2 ('8D')
+	CDOLData []byte  // Data sent in GENERATE AC
+
+	AIP []byte // '82'
+	ATC []byte // '9F36'
+
+	// IssuerData is appended as is, for instance the CVR.
+	IssuerData []byte
+}
+
+// Bytes builds the input of the MAC: the recommended terminal data taken from the CDOL
+// data, then the AIP, the ATC and the issuer data.
+func (in CryptogramInput) Bytes() ([]byte, error) {
+	values, err := in.CDOL.Split(in.CDOLData)
+	if err != nil {
+		return nil, fmt.Errorf("CDOL data: %w", err)
+	}
+
+	var out []byte
+	for _, tag := range RecommendedTerminalData {
+		value, ok := values[tag]
+		if !ok {
+			return nil, fmt.Errorf("CDOL does not request %s", tag)
+		}
+		out = append(out, value...)
+	}
+	if len(in.AIP) != 2 || len(in.ATC) != 2 {
+		return nil, fmt.Errorf("AIP and ATC must be 2 bytes long (got %d and %d)", len(in.AIP), len(in.ATC))
+	}
+	out = append(out, in.AIP...)
+	out = append(out, in.ATC...)
+	return append(out, in.IssuerData...), nil
+}
+
+// MAC computes the 8 bytes ISO/IEC 9797-1 Algorithm 3 MAC of data with padding method 2.
+func MAC(key, data []byte) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("MAC key must be 16 bytes long (got %d)", len(key))
+	}
+	left, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC key: %w", err)
+	}
+	right, err := des.NewCipher(key[8:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC key: %w", err)
+	}
+
+	padded := append(append([]byte{}, data...), 0x80)
+	for len(padded)%des.BlockSize != 0 {
+		padded = append(padded, 0x00)
+	}
+
+	mac := make([]byte, des.BlockSize)
+	for offset := 0; offset < len(padded); offset += des.BlockSize {
+		for i := range mac {
+			mac[i] ^= padded[offset+i]
+		}
+		left.Encrypt(mac, mac)
+	}
+	right.Decrypt(mac, mac)
+	left.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// GenerateCryptogram computes the Application Cryptogram (ARQC, TC or AAC) with the
+// session key.
+func GenerateCryptogram(sessionKey []byte, in CryptogramInput) ([]byte, error) {
+	data, err := in.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return MAC(sessionKey, data)
+}
+
+// VerifyCryptogram reports whether the cryptogram of the card matches the input.
+func VerifyCryptogram(sessionKey []byte, in CryptogramInput, cryptogram []byte) (bool, error) {
+	expected, err := GenerateCryptogram(sessionKey, in)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, cryptogram) == 1, nil
+}
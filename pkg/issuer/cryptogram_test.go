@@ -0,0 +1,108 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/emv"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+var testSessionKey = tlv.Hex("DD43A16846223F21 CB1BCC7B0C0BC484")
+
+// testInput requests the recommended terminal data, followed by the Terminal Type and the
+// CVM Results that are not covered by the cryptogram.
+func testInput(t *testing.T) CryptogramInput {
+	t.Helper()
+	cdol, err := emv.ParseDOL(tlv.Hex("9F0206 9F0306 9F1A02 9505 5F2A02 9A03 9C01 9F3704 9F3501 9F3403"))
+	if err != nil {
+		t.Fatalf("ParseDOL failed: %v", err)
+	}
+	return CryptogramInput{
+		CDOL:       cdol,
+		CDOLData:   tlv.Hex("000000001000 000000000000 0250 0000000000 0978 260615 00 11223344 22 1E0300"),
+		AIP:        tlv.Hex("1800"),
+		ATC:        tlv.Hex("0001"),
+		IssuerData: tlv.Hex("03A00000"),
+	}
+}
+
+func TestCryptogramInput(t *testing.T) {
+	got, err := testInput(t).Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	expected := tlv.Hex("000000001000 000000000000 0250 0000000000 0978 260615 00 11223344 1800 0001 03A00000")
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Input mismatch (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(in *CryptogramInput)
+	}{
+		{"Short CDOL Data", func(in *CryptogramInput) { in.CDOLData = in.CDOLData[1:] }},
+		{"Unpredictable Number Not Requested", func(in *CryptogramInput) {
+			in.CDOL = in.CDOL[:7]
+			in.CDOLData = in.CDOLData[:in.CDOL.Length()]
+		}},
+		{"Missing ATC", func(in *CryptogramInput) { in.ATC = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testInput(t)
+			tt.modify(&in)
+			if _, err := in.Bytes(); err == nil {
+				t.Error("Bytes should fail")
+			}
+		})
+	}
+}
+
+func TestMAC(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"Empty Data Is One Padding Block", "", "A4B7A00591C73D08"},
+		{"Cryptogram Input", "000000001000 000000000000 0250 0000000000 0978 260615 00 11223344 1800 0001 03A00000", "5C789810634D0747"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac, err := MAC(testSessionKey, tlv.Hex(tt.data))
+			if err != nil {
+				t.Fatalf("MAC failed: %v", err)
+			}
+			if diff := cmp.Diff(tlv.Hex(tt.expected), mac); diff != "" {
+				t.Errorf("MAC mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := MAC(testSessionKey[:8], nil); err == nil {
+		t.Error("MAC should reject a single length key")
+	}
+}
+
+func TestVerifyCryptogram(t *testing.T) {
+	in := testInput(t)
+	arqc := tlv.Hex("5C789810634D0747")
+
+	got, err := GenerateCryptogram(testSessionKey, in)
+	if err != nil {
+		t.Fatalf("GenerateCryptogram failed: %v", err)
+	}
+	if diff := cmp.Diff(arqc, got); diff != "" {
+		t.Errorf("Cryptogram mismatch (-want +got):\n%s", diff)
+	}
+
+	if ok, err := VerifyCryptogram(testSessionKey, in, arqc); !ok || err != nil {
+		t.Errorf("VerifyCryptogram() = %v, %v, want true", ok, err)
+	}
+	in.ATC = tlv.Hex("0002")
+	if ok, err := VerifyCryptogram(testSessionKey, in, arqc); ok || err != nil {
+		t.Errorf("VerifyCryptogram() with another ATC = %v, %v, want false", ok, err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package issuer emulates the cryptographic side of an EMV card issuer, so that cards can
+// be tested end-to-end without a real authorisation host.
+//
+// Flow of an online authorisation:
+//
+//	Issuer Master Key (IMK AC)
+//	 └── ICC Master Key (MK), derived from the PAN and PAN Sequence Number
+//	      └── Session Key (SK), derived from the ATC
+//	           ├── ARQC verification over the CDOL1 data, AIP and ATC
+//	           └── ARPC generation, returned in the Issuer Authentication Data ('91')
+package issuer
+
+import (
+	"fmt"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// Authorisation Response Codes ('8A') returned by Authorise.
+var (
+	ARCApproved = []byte{0x30, 0x30} // "00"
+	ARCDeclined = []byte{0x30, 0x35} // "05", do not honour
+)
+
+// Issuer holds the keys of an emulated issuer.
+type Issuer struct {
+	// MasterKey is the Issuer Master Key for Application Cryptograms (16 bytes).
+	MasterKey []byte
+
+	// CardStatusUpdate selects ARPC method 2 when set (4 bytes). Method 1 is used otherwise.
+	CardStatusUpdate []byte
+}
+
+// AuthorisationRequest is the online authorisation request of a card.
+type AuthorisationRequest struct {
+	PAN         []byte // '5A'
+	PANSequence []byte // '5F34', optional
+	Input       CryptogramInput
+	ARQC        []byte // '9F26'
+}
+
+// Authorisation is the response of the emulated issuer.
+type Authorisation struct {
+	Request AuthorisationRequest
+
+	// Valid reports whether the ARQC was verified.
+	Valid bool
+
+	ARC                      []byte // '8A'
+	IssuerAuthenticationData []byte // '91'
+}
+
+// ICCMasterKey derives the ICC Master Key of a card (Option B, which is Option A for PANs
+// of 16 digits or less).
+func (i Issuer) ICCMasterKey(pan, panSequence []byte) ([]byte, error) {
+	return DeriveICCMasterKeyOptionB(i.MasterKey, pan, panSequence)
+}
+
+// SessionKey derives the session key of a card for one ATC.
+func (i Issuer) SessionKey(pan, panSequence, atc []byte) ([]byte, error) {
+	mk, err := i.ICCMasterKey(pan, panSequence)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveSessionKey(mk, atc)
+}
+
+// Authorise verifies the ARQC, approves the transaction when it is valid and builds the
+// Issuer Authentication Data for the card.
+func (i Issuer) Authorise(req AuthorisationRequest) (*Authorisation, error) {
+	sk, err := i.SessionKey(req.PAN, req.PANSequence, req.Input.ATC)
+	if err != nil {
+		return nil, fmt.Errorf("session key: %w", err)
+	}
+
+	a := &Authorisation{Request: req, ARC: ARCDeclined}
+	if a.Valid, err = VerifyCryptogram(sk, req.Input, req.ARQC); err != nil {
+		return nil, fmt.Errorf("ARQC verification: %w", err)
+	}
+	if a.Valid {
+		a.ARC = ARCApproved
+	}
+
+	if i.CardStatusUpdate != nil {
+		a.IssuerAuthenticationData, err = IssuerAuthenticationDataMethod2(sk, req.ARQC, i.CardStatusUpdate, nil)
+	} else {
+		a.IssuerAuthenticationData, err = IssuerAuthenticationDataMethod1(sk, req.ARQC, a.ARC)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Report builds the structured report of the authorisation. Keys are never shown.
+func (a *Authorisation) Report() *report.Report {
+	rep := report.New("EMV ISSUER AUTHORISATION")
+
+	request := rep.AddSection("[1] Request:")
+	request.Note("PAN (5A)", fmt.Sprintf("%X", a.Request.PAN))
+	if a.Request.PANSequence != nil {
+		request.Note("PAN Sequence Number (5F34)", fmt.Sprintf("%X", a.Request.PANSequence))
+	}
+	request.Note("ATC (9F36)", fmt.Sprintf("%X", a.Request.Input.ATC))
+	request.Note("ARQC (9F26)", fmt.Sprintf("%X", a.Request.ARQC))
+
+	outcome := rep.AddSection("[=] OUTCOME:")
+	verification := "Valid"
+	if !a.Valid {
+		verification = "Invalid"
+	}
+	outcome.Note("ARQC Verification", verification)
+	outcome.Note("Authorisation Response Code (8A)", fmt.Sprintf("%X (%q)", a.ARC, a.ARC))
+	outcome.Note("Issuer Authentication Data (91)", fmt.Sprintf("%X", a.IssuerAuthenticationData))
+
+	return rep
+}
+
+// Describe generates a human-readable report of the authorisation.
+func (a *Authorisation) Describe() string {
+	return report.Text(a.Report())
+}
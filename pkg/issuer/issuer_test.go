@@ -0,0 +1,84 @@
+package issuer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+func TestAuthorise(t *testing.T) {
+	tests := []struct {
+		name     string
+		issuer   Issuer
+		arqc     string
+		valid    bool
+		expected string
+	}{
+		{"Approved Method 1", Issuer{MasterKey: testIMK}, "5C789810634D0747", true, "9656325BEA648F59 3030"},
+		{"Approved Method 2", Issuer{MasterKey: testIMK, CardStatusUpdate: tlv.Hex("00820000")}, "5C789810634D0747", true, "D5EBCFFA 00820000"},
+		{"Invalid ARQC", Issuer{MasterKey: testIMK}, "5C789810634D0748", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := AuthorisationRequest{
+				PAN:         tlv.Hex("4761739001010010"),
+				PANSequence: tlv.Hex("01"),
+				Input:       testInput(t),
+				ARQC:        tlv.Hex(tt.arqc),
+			}
+			a, err := tt.issuer.Authorise(req)
+			if err != nil {
+				t.Fatalf("Authorise failed: %v", err)
+			}
+			if a.Valid != tt.valid {
+				t.Errorf("Valid = %v, want %v", a.Valid, tt.valid)
+			}
+			if !tt.valid {
+				if diff := cmp.Diff(ARCDeclined, a.ARC); diff != "" {
+					t.Errorf("ARC mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tlv.Hex(tt.expected), a.IssuerAuthenticationData); diff != "" {
+				t.Errorf("Issuer Authentication Data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := (Issuer{MasterKey: testIMK[:8]}).Authorise(AuthorisationRequest{PAN: tlv.Hex("4761739001010010")}); err == nil {
+		t.Error("Authorise should fail with a single length IMK")
+	}
+}
+
+func TestAuthorisationDescribe(t *testing.T) {
+	a, err := Issuer{MasterKey: testIMK}.Authorise(AuthorisationRequest{
+		PAN:         tlv.Hex("4761739001010010"),
+		PANSequence: tlv.Hex("01"),
+		Input:       testInput(t),
+		ARQC:        tlv.Hex("5C789810634D0747"),
+	})
+	if err != nil {
+		t.Fatalf("Authorise failed: %v", err)
+	}
+
+	expected := []string{
+		"=== EMV ISSUER AUTHORISATION ===",
+		"PAN Sequence Number (5F34): 01",
+		"ARQC (9F26): 5C789810634D0747",
+		"ARQC Verification: Valid",
+		`Authorisation Response Code (8A): 3030 ("00")`,
+		"Issuer Authentication Data (91): 9656325BEA648F593030",
+	}
+	desc := a.Describe()
+	for _, line := range expected {
+		if !strings.Contains(desc, line) {
+			t.Errorf("Describe() missing %q:\n%s", line, desc)
+		}
+	}
+	if strings.Contains(desc, "DD43A168") {
+		t.Errorf("Describe() must not show the session key:\n%s", desc)
+	}
+}
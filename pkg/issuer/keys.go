@@ -0,0 +1,167 @@
+package issuer
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// KEY DERIVATION Logic according to EMV Book 2, Annex A1.3 and A1.4.
+//
+// 1. ICC Master Key (A1.4): each card holds a unique double length key (MK) derived from
+//    the Issuer Master Key (IMK) and the card identity.
+//    - Option A: Y is the rightmost 16 digits of PAN || PAN Sequence Number ('00' when
+//      absent), left padded with zeros. ZL = 3DES(IMK)[Y], ZR = 3DES(IMK)[Y xor 'FF..FF'].
+//    - Option B, for PANs longer than 16 digits: X = PAN || PSN (left padded to an even
+//      number of digits) is hashed with SHA-1. The 40 hex digits of the hash are
+//      decimalised: the decimal digits are taken from left to right, then, when fewer than
+//      16 were found, the digits 'A' to 'F' are converted to '0' to '5'. The first 16
+//      digits are used as Y of Option A. Shorter PANs use Option A.
+//    MK = ZL || ZR, with each byte adjusted to odd parity.
+// 2. Common Session Key (A1.3): the key used for the Application Cryptogram of one
+//    transaction is derived from MK and the ATC:
+//    SKL = 3DES(MK)[ATC || 'F0' || '00 00 00 00 00'], SKR = 3DES(MK)[ATC || '0F' || '00 00 00 00 00'].
+
+// newTripleDES creates a two key 3DES cipher (K1, K2, K1) from a 16 bytes key.
+func newTripleDES(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("double length key must be 16 bytes long (got %d)", len(key))
+	}
+	block, err := des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid 3DES key: %w", err)
+	}
+	return block, nil
+}
+
+// encryptBlock enciphers one 8 bytes block with a double length key.
+func encryptBlock(key, data []byte) ([]byte, error) {
+	block, err := newTripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != des.BlockSize {
+		return nil, fmt.Errorf("block must be %d bytes long (got %d)", des.BlockSize, len(data))
+	}
+	out := make([]byte, des.BlockSize)
+	block.Encrypt(out, data)
+	return out, nil
+}
+
+// DeriveICCMasterKeyOptionA derives the ICC Master Key with Option A. pan and psn are the
+// values of Tags '5A' and '5F34'; psn may be nil.
+func DeriveICCMasterKeyOptionA(imk, pan, psn []byte) ([]byte, error) {
+	digits, err := panDigits(pan, psn)
+	if err != nil {
+		return nil, err
+	}
+	if len(digits) > 16 {
+		digits = digits[len(digits)-16:]
+	}
+	return deriveMasterKey(imk, strings.Repeat("0", 16-len(digits))+digits)
+}
+
+// DeriveICCMasterKeyOptionB derives the ICC Master Key with Option B. PANs of 16 digits or
+// less use Option A.
+func DeriveICCMasterKeyOptionB(imk, pan, psn []byte) ([]byte, error) {
+	digits, err := panDigits(pan, psn)
+	if err != nil {
+		return nil, err
+	}
+	if len(digits) <= 18 { // PAN of 16 digits or less, and the 2 PSN digits
+		return DeriveICCMasterKeyOptionA(imk, pan, psn)
+	}
+
+	if len(digits)%2 != 0 {
+		digits = "0" + digits
+	}
+	x, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha1.Sum(x)
+	return deriveMasterKey(imk, decimalise(fmt.Sprintf("%X", hash)))
+}
+
+// panDigits returns the digits of PAN || PSN, without the 'F' padding of the PAN.
+func panDigits(pan, psn []byte) (string, error) {
+	digits := strings.TrimRight(fmt.Sprintf("%X", pan), "F")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", fmt.Errorf("invalid PAN: %X", pan)
+	}
+	if len(psn) > 1 {
+		return "", fmt.Errorf("PAN Sequence Number must be 1 byte long (got %d)", len(psn))
+	}
+	if len(psn) == 0 {
+		return digits + "00", nil
+	}
+	return digits + fmt.Sprintf("%02X", psn[0]), nil
+}
+
+// decimalise extracts 16 decimal digits from the hex digits of the SHA-1 hash.
+func decimalise(hexDigits string) string {
+	var sb strings.Builder
+	for _, c := range hexDigits {
+		if c >= '0' && c <= '9' && sb.Len() < 16 {
+			sb.WriteRune(c)
+		}
+	}
+	for _, c := range hexDigits {
+		if c >= 'A' && c <= 'F' && sb.Len() < 16 {
+			sb.WriteRune(c - 'A' + '0')
+		}
+	}
+	return sb.String()
+}
+
+// deriveMasterKey enciphers the 16 digits of Y and its complement with the IMK.
+func deriveMasterKey(imk []byte, y string) ([]byte, error) {
+	yBytes, err := hex.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+	left, err := encryptBlock(imk, yBytes)
+	if err != nil {
+		return nil, fmt.Errorf("ICC Master Key derivation: %w", err)
+	}
+	for i := range yBytes {
+		yBytes[i] ^= 0xFF
+	}
+	right, err := encryptBlock(imk, yBytes)
+	if err != nil {
+		return nil, fmt.Errorf("ICC Master Key derivation: %w", err)
+	}
+	return oddParity(append(left, right...)), nil
+}
+
+// DeriveSessionKey derives the EMV Common Session Key from the ICC Master Key and the ATC.
+func DeriveSessionKey(mk, atc []byte) ([]byte, error) {
+	if len(atc) != 2 {
+		return nil, fmt.Errorf("ATC must be 2 bytes long (got %d)", len(atc))
+	}
+	left, err := encryptBlock(mk, []byte{atc[0], atc[1], 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("session key derivation: %w", err)
+	}
+	right, err := encryptBlock(mk, []byte{atc[0], atc[1], 0x0F, 0x00, 0x00, 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("session key derivation: %w", err)
+	}
+	return append(left, right...), nil
+}
+
+// oddParity sets the least significant bit of each byte so that it has an odd number of
+// bits set.
+func oddParity(key []byte) []byte {
+	for i, b := range key {
+		ones := 0
+		for v := b >> 1; v != 0; v >>= 1 {
+			ones += int(v & 1)
+		}
+		key[i] = b&0xFE | byte(1-ones%2)
+	}
+	return key
+}
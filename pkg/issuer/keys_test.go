@@ -0,0 +1,106 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/tlv"
+)
+
+// The expected keys, MACs and ARPCs of this package are not published EMV test vectors.
+// They were computed outside of this package, with the OpenSSL DES and 3DES ciphers
+// following EMV Book 2, Annex A1 and section 8, and only show that the package agrees with
+// that reading of the specification.
+//
+// TODO: replace them with published sample values (Option A derivation, common session
+// key, ARQC and ARPC methods 1 and 2) and cite their source.
+
+var testIMK = tlv.Hex("0123456789ABCDEF FEDCBA9876543210")
+
+func TestDeriveICCMasterKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		derive   func(imk, pan, psn []byte) ([]byte, error)
+		pan      string
+		psn      string
+		expected string
+	}{
+		{"Option A", DeriveICCMasterKeyOptionA, "4761739001010010", "01", "2F02C8B0E9CBC7B0 5B5167F7A1CDE6E5"},
+		{"Option A Without PSN", DeriveICCMasterKeyOptionA, "4761739001010010", "", "7C89E3641F4FE9CD FD8989B02FF149CB"},
+		{"Option B Short PAN", DeriveICCMasterKeyOptionB, "4761739001010010", "01", "2F02C8B0E9CBC7B0 5B5167F7A1CDE6E5"},
+		{"Option B Long PAN", DeriveICCMasterKeyOptionB, "4761739001010010123F", "01", "31E986A1890DD964 6D1F40D37F31343E"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var psn []byte
+			if tt.psn != "" {
+				psn = tlv.Hex(tt.psn)
+			}
+			mk, err := tt.derive(testIMK, tlv.Hex(tt.pan), psn)
+			if err != nil {
+				t.Fatalf("Derivation failed: %v", err)
+			}
+			if diff := cmp.Diff(tlv.Hex(tt.expected), mk); diff != "" {
+				t.Errorf("ICC Master Key mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDeriveICCMasterKeyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		imk  []byte
+		pan  []byte
+		psn  []byte
+	}{
+		{"Short IMK", testIMK[:8], tlv.Hex("4761739001010010"), nil},
+		{"Invalid PAN", testIMK, tlv.Hex("47617390A1010010"), nil},
+		{"Long PSN", testIMK, tlv.Hex("4761739001010010"), tlv.Hex("0001")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DeriveICCMasterKeyOptionB(tt.imk, tt.pan, tt.psn); err == nil {
+				t.Error("Derivation should fail")
+			}
+		})
+	}
+}
+
+func TestDecimalise(t *testing.T) {
+	tests := []struct {
+		hash     string
+		expected string
+	}{
+		{"C2981BDC540ACAF071417A22C99F2ED982A872E3", "2981540071417229"},
+		{"ABCDEF0123ABCDEFABCDEFABCDEFABCDEFABCDEF", "0123012345012345"},
+	}
+
+	for _, tt := range tests {
+		if got := decimalise(tt.hash); got != tt.expected {
+			t.Errorf("decimalise(%s) = %s, want %s", tt.hash, got, tt.expected)
+		}
+	}
+}
+
+func TestDeriveSessionKey(t *testing.T) {
+	sk, err := DeriveSessionKey(tlv.Hex("2F02C8B0E9CBC7B0 5B5167F7A1CDE6E5"), tlv.Hex("0001"))
+	if err != nil {
+		t.Fatalf("DeriveSessionKey failed: %v", err)
+	}
+	if diff := cmp.Diff(tlv.Hex("DD43A16846223F21 CB1BCC7B0C0BC484"), sk); diff != "" {
+		t.Errorf("Session Key mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := DeriveSessionKey(testIMK, tlv.Hex("01")); err == nil {
+		t.Error("DeriveSessionKey should reject a 1 byte ATC")
+	}
+}
+
+func TestOddParity(t *testing.T) {
+	if diff := cmp.Diff(tlv.Hex("01 01 02 07 FE"), oddParity(tlv.Hex("00 01 03 07 FF"))); diff != "" {
+		t.Errorf("oddParity mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,214 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Renderer turns a Report into a printable document.
+type Renderer interface {
+	Render(r *Report) (string, error)
+}
+
+// TextRenderer produces the ASCII layout used by the Describe() methods:
+//
+//	=== TITLE ===
+//	[1] Heading
+//	    + Label:   Value
+//	    - Template.Field (Tag): Value
+//
+// Sections are separated by an empty line. Nested templates are flattened with
+// dotted paths; the name of a root template is not repeated in its children paths.
+type TextRenderer struct{}
+
+// JSONRenderer produces the Report as JSON.
+type JSONRenderer struct {
+	// Indent is used for pretty-printing. Empty means compact output.
+	Indent string
+}
+
+// MarkdownRenderer produces a Markdown document (one table per template).
+type MarkdownRenderer struct{}
+
+// Text renders a report with the TextRenderer.
+func Text(r *Report) string {
+	out, _ := TextRenderer{}.Render(r)
+	return out
+}
+
+// Render implements Renderer.
+func (TextRenderer) Render(r *Report) (string, error) {
+	lines := []string{fmt.Sprintf("=== %s ===", r.Title)}
+
+	for i, s := range r.Sections {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		if s.Heading != "" {
+			lines = append(lines, s.Heading)
+		}
+		for _, item := range s.Items {
+			width := s.LabelWidth
+			if item.Width > 0 {
+				width = item.Width
+			}
+			lines = append(lines, formatItem(item, width))
+		}
+		for _, n := range s.Nodes {
+			lines = append(lines, NodeLines(n)...)
+		}
+	}
+
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n"), nil
+}
+
+func formatItem(item Item, width int) string {
+	indent := "    " + item.Marker + " "
+	if item.Marker == MarkerContinuation {
+		indent = "      "
+	}
+
+	if item.Label == "" {
+		return indent + item.Value
+	}
+
+	label := item.Label + ":"
+	if len(label) < width {
+		label += strings.Repeat(" ", width-len(label))
+	} else {
+		label += " "
+	}
+	return indent + label + item.Value
+}
+
+// NodeLines flattens a template and its children into text lines.
+func NodeLines(n *Node) []string {
+	var lines []string
+	for _, pf := range flatten(n) {
+		lines = append(lines, FieldLines(pf.path, []Field{pf.field})...)
+	}
+	return lines
+}
+
+// FieldLines formats the fields of a template as "    - Path.Field (Tag): Value" lines.
+func FieldLines(path string, fields []Field) []string {
+	lines := make([]string, 0, len(fields))
+	for _, f := range fields {
+		lines = append(lines, fmt.Sprintf("    - %s.%s: %s", path, f.Label(), f.Text))
+	}
+	return lines
+}
+
+// WalkFields calls fn for every field of a template tree, located by the path used in
+// text output (e.g. "Discretionary", "App[1].Discretionary").
+func WalkFields(n *Node, fn func(path string, f Field)) {
+	for _, pf := range flatten(n) {
+		fn(pf.path, pf.field)
+	}
+}
+
+// pathField is a field located by the dotted path of its template.
+type pathField struct {
+	path  string
+	field Field
+}
+
+// flatten lists the fields of a template tree, each template's own fields first.
+// A template is named after itself ("Discretionary"), except list entries and the
+// templates they contain, which keep the path of their parent ("App[1].Discretionary").
+func flatten(root *Node) []pathField {
+	var out []pathField
+
+	var walk func(n *Node, path string)
+	walk = func(n *Node, path string) {
+		for _, f := range n.Fields {
+			out = append(out, pathField{path: path, field: f})
+		}
+		for _, child := range n.Children {
+			childPath := child.Name
+			if n != root && (isListEntry(n.Name) || isListEntry(child.Name)) {
+				childPath = path + "." + child.Name
+			}
+			walk(child, childPath)
+		}
+	}
+	walk(root, root.Name)
+
+	return out
+}
+
+func isListEntry(name string) bool {
+	return strings.HasSuffix(name, "]")
+}
+
+// Render implements Renderer.
+func (j JSONRenderer) Render(r *Report) (string, error) {
+	var out []byte
+	var err error
+
+	if j.Indent != "" {
+		out, err = json.MarshalIndent(r, "", j.Indent)
+	} else {
+		out, err = json.Marshal(r)
+	}
+	if err != nil {
+		return "", fmt.Errorf("json rendering failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// Render implements Renderer.
+func (MarkdownRenderer) Render(r *Report) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n", r.Title))
+
+	for _, s := range r.Sections {
+		sb.WriteString("\n")
+		if s.Heading != "" {
+			sb.WriteString(fmt.Sprintf("## %s\n\n", escapeMarkdown(s.Heading)))
+		}
+		for _, item := range s.Items {
+			writeMarkdownItem(&sb, item)
+		}
+		for i, n := range s.Nodes {
+			if i > 0 || len(s.Items) > 0 {
+				sb.WriteString("\n")
+			}
+			writeMarkdownNode(&sb, n)
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+func writeMarkdownItem(sb *strings.Builder, item Item) {
+	indent := ""
+	if item.Marker == MarkerContinuation {
+		indent = "  "
+	}
+
+	if item.Label == "" {
+		sb.WriteString(fmt.Sprintf("%s- %s\n", indent, escapeMarkdown(item.Value)))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("%s- **%s:** %s\n", indent, escapeMarkdown(item.Label), escapeMarkdown(item.Value)))
+}
+
+func writeMarkdownNode(sb *strings.Builder, n *Node) {
+	sb.WriteString("| Template | Field | Tag | Value |\n")
+	sb.WriteString("|---|---|---|---|\n")
+
+	for _, pf := range flatten(n) {
+		name := pf.field.Name
+		if pf.field.Unknown {
+			name = "Unknown"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
+			escapeMarkdown(pf.path), escapeMarkdown(name), pf.field.Tag, escapeMarkdown(pf.field.Text)))
+	}
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]").Replace(s)
+}
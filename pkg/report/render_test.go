@@ -0,0 +1,151 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func sampleReport() *Report {
+	r := New("SAMPLE")
+
+	cmd := r.AddSection("[1] Command")
+	cmd.LabelWidth = 9
+	cmd.Detail("Method", "04 -> By Name")
+	cmd.Detail("Result", "[90 00] [OK]")
+	cmd.Continuation("Dump", "9000")
+
+	out := r.AddSection("[=] OUTCOME:")
+	out.Note("Structure", "FCP")
+	out.AddNode(&Node{
+		Name:   "FCI",
+		Fields: []Field{{Name: "DFName", Tag: "84", Hex: "A0", Text: "A0"}},
+		Children: []*Node{{
+			Name:   "Proprietary",
+			Fields: []Field{{Name: "Label", Tag: "50", Hex: "41", Text: `41 ("A")`}},
+			Children: []*Node{{
+				Name:   "Discretionary",
+				Fields: []Field{{Name: "Unknown", Tag: "99", Hex: "11", Text: "11", Unknown: true}},
+			}},
+		}},
+	})
+	out.AddNode(nil)
+
+	return r
+}
+
+func TestTextRenderer(t *testing.T) {
+	expected := []string{
+		"=== SAMPLE ===",
+		"[1] Command",
+		"    + Method:  04 -> By Name",
+		"    + Result:  [90 00] [OK]",
+		"      Dump:    9000",
+		"",
+		"[=] OUTCOME:",
+		"    - Structure: FCP",
+		"    - FCI.DFName (84): A0",
+		`    - Proprietary.Label (50): 41 ("A")`,
+		"    - Discretionary.Unknown Tag 99: 11",
+	}
+
+	got := strings.Split(Text(sampleReport()), "\n")
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Text mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTextRenderer_AlignLast(t *testing.T) {
+	r := New("T")
+	r.AddSection("").Note("Structure", "Raw").Note("Raw", "C0").AlignLast(8)
+
+	expected := []string{"=== T ===", "    - Structure: Raw", "    - Raw:    C0"}
+	if diff := cmp.Diff(expected, strings.Split(Text(r), "\n")); diff != "" {
+		t.Errorf("Text mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWalkFields(t *testing.T) {
+	var got []string
+	WalkFields(sampleReport().Sections[1].Nodes[0], func(path string, f Field) {
+		got = append(got, path+"."+f.Label())
+	})
+
+	expected := []string{"FCI.DFName (84)", "Proprietary.Label (50)", "Discretionary.Unknown Tag 99"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("WalkFields mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestJSONRenderer(t *testing.T) {
+	r := New("T")
+	r.AddSection("").Note("Key", "Value").AddNode(&Node{
+		Name:   "N",
+		Fields: []Field{{Name: "F", Tag: "5A", Hex: "01", Text: "01"}},
+	})
+
+	tests := []struct {
+		name     string
+		renderer JSONRenderer
+		expected string
+	}{
+		{
+			name:     "Compact",
+			renderer: JSONRenderer{},
+			expected: `{"title":"T","sections":[{"items":[{"label":"Key","value":"Value"}],` +
+				`"nodes":[{"name":"N","fields":[{"name":"F","tag":"5A","hex":"01","text":"01"}]}]}]}`,
+		},
+		{
+			name:     "Indented",
+			renderer: JSONRenderer{Indent: " "},
+			expected: "{\n \"title\": \"T\",\n \"sections\": [\n  {\n   \"items\": [\n    {\n" +
+				"     \"label\": \"Key\",\n     \"value\": \"Value\"\n    }\n   ],\n" +
+				"   \"nodes\": [\n    {\n     \"name\": \"N\",\n     \"fields\": [\n      {\n" +
+				"       \"name\": \"F\",\n       \"tag\": \"5A\",\n       \"hex\": \"01\",\n" +
+				"       \"text\": \"01\"\n      }\n     ]\n    }\n   ]\n  }\n ]\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.renderer.Render(r)
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("JSON mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	expected := []string{
+		"# SAMPLE",
+		"",
+		`## \[1\] Command`,
+		"",
+		"- **Method:** 04 -> By Name",
+		`- **Result:** \[90 00\] \[OK\]`,
+		"  - **Dump:** 9000",
+		"",
+		`## \[=\] OUTCOME:`,
+		"",
+		"- **Structure:** FCP",
+		"",
+		"| Template | Field | Tag | Value |",
+		"|---|---|---|---|",
+		"| FCI | DFName | 84 | A0 |",
+		`| Proprietary | Label | 50 | 41 ("A") |`,
+		"| Discretionary | Unknown | 99 | 11 |",
+	}
+
+	out, err := MarkdownRenderer{}.Render(sampleReport())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if diff := cmp.Diff(expected, strings.Split(out, "\n")); diff != "" {
+		t.Errorf("Markdown mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,138 @@
+// Package report provides the structured model behind every Describe() output.
+//
+// An analysis (a SELECT trace, an EMV template, a validation result...) is first
+// built as a Report, then handed to a Renderer. The same Report can therefore be
+// printed as the historical text layout, exported as JSON for tooling, or
+// embedded in Markdown documentation.
+//
+// Structure:
+//
+//	Report
+//	 └── Section (optional heading, e.g. "[1] Command: SELECT FILE")
+//	      ├── Item  (a labeled line, e.g. "+ Method:  04 -> Select by DF Name")
+//	      └── Node  (a TLV template, with Fields and nested Children templates)
+package report
+
+// Report is the root of an analysis: a title and an ordered list of sections.
+type Report struct {
+	Title    string     `json:"title"`
+	Sections []*Section `json:"sections,omitempty"`
+}
+
+// Section groups the lines describing one step of the analysis.
+// Items are rendered before Nodes.
+type Section struct {
+	Heading string `json:"heading,omitempty"`
+
+	// LabelWidth aligns the values of the items in text output.
+	// Zero means that each value directly follows its label.
+	LabelWidth int `json:"-"`
+
+	Items []Item  `json:"items,omitempty"`
+	Nodes []*Node `json:"nodes,omitempty"`
+}
+
+// Item markers used by the text renderer.
+const (
+	// MarkerDetail introduces a detail of a command or a result ("+").
+	MarkerDetail = "+"
+	// MarkerNote introduces an outcome or a remark ("-").
+	MarkerNote = "-"
+	// MarkerContinuation indents a line under the previous item (no marker).
+	MarkerContinuation = ""
+)
+
+// Item is a single labeled line of a section.
+type Item struct {
+	Marker string `json:"-"`
+	Label  string `json:"label,omitempty"`
+	Value  string `json:"value"`
+
+	// Width overrides the LabelWidth of the section for this item. Zero keeps it.
+	Width int `json:"-"`
+}
+
+// Node is a template (a struct of data objects) with its fields and nested templates.
+type Node struct {
+	Name     string  `json:"name"`
+	Fields   []Field `json:"fields,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Field is a single data object of a template.
+type Field struct {
+	// Name is the Go field name ("ApplicationLabel"), or "Unknown" for unmapped tags.
+	Name string `json:"name"`
+	Tag  string `json:"tag,omitempty"`
+	// Hex is the raw value, upper-case hexadecimal.
+	Hex string `json:"hex"`
+	// Text is the human-readable value (hex plus its interpretation).
+	Text    string `json:"text"`
+	Unknown bool   `json:"unknown,omitempty"`
+}
+
+// New creates an empty report.
+func New(title string) *Report {
+	return &Report{Title: title}
+}
+
+// AddSection appends a new section and returns it for chaining.
+func (r *Report) AddSection(heading string) *Section {
+	s := &Section{Heading: heading}
+	r.Sections = append(r.Sections, s)
+	return s
+}
+
+// Detail appends a "+" item.
+func (s *Section) Detail(label, value string) *Section {
+	return s.add(MarkerDetail, label, value)
+}
+
+// Note appends a "-" item. The label may be empty for free text.
+func (s *Section) Note(label, value string) *Section {
+	return s.add(MarkerNote, label, value)
+}
+
+// Continuation appends an indented item attached to the previous one.
+func (s *Section) Continuation(label, value string) *Section {
+	return s.add(MarkerContinuation, label, value)
+}
+
+// AlignLast aligns the value of the last item at width in text output, whatever the
+// LabelWidth of the section.
+func (s *Section) AlignLast(width int) *Section {
+	if len(s.Items) > 0 {
+		s.Items[len(s.Items)-1].Width = width
+	}
+	return s
+}
+
+// AddNode appends a template. Nil nodes are ignored.
+func (s *Section) AddNode(n *Node) *Section {
+	if n != nil {
+		s.Nodes = append(s.Nodes, n)
+	}
+	return s
+}
+
+func (s *Section) add(marker, label, value string) *Section {
+	s.Items = append(s.Items, Item{Marker: marker, Label: label, Value: value})
+	return s
+}
+
+// IsEmpty reports whether the node carries neither fields nor children.
+func (n *Node) IsEmpty() bool {
+	return len(n.Fields) == 0 && len(n.Children) == 0
+}
+
+// Label returns the display name of the field: "Name (Tag)" or "Unknown Tag XX".
+func (f Field) Label() string {
+	switch {
+	case f.Unknown:
+		return "Unknown Tag " + f.Tag
+	case f.Tag != "":
+		return f.Name + " (" + f.Tag + ")"
+	default:
+		return f.Name
+	}
+}
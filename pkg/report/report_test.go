@@ -0,0 +1,32 @@
+package report
+
+import "testing"
+
+func TestFieldLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    Field
+		expected string
+	}{
+		{"Tagged", Field{Name: "AID", Tag: "4F"}, "AID (4F)"},
+		{"Untagged", Field{Name: "RawData"}, "RawData"},
+		{"Unknown", Field{Name: "Unknown", Tag: "9F01", Unknown: true}, "Unknown Tag 9F01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.field.Label(); got != tt.expected {
+				t.Errorf("Label() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNodeIsEmpty(t *testing.T) {
+	if !(&Node{Name: "X"}).IsEmpty() {
+		t.Error("node without fields nor children should be empty")
+	}
+	if (&Node{Name: "X", Children: []*Node{{Name: "Y"}}}).IsEmpty() {
+		t.Error("node with children should not be empty")
+	}
+}
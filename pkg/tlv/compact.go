@@ -0,0 +1,78 @@
+package tlv
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/moov-io/bertlv"
+)
+
+// COMPACT-TLV Logic according to ISO/IEC 7816-4.
+//
+// A COMPACT-TLV data object starts with a single byte:
+//   - Bits 8-5: Tag number (N).
+//   - Bits 4-1: Length of the value (0 to 15).
+//
+// It is mainly found in the ATR historical bytes. A COMPACT-TLV object with tag
+// number N carries the interindustry data element whose BER-TLV tag is '4N'
+// (e.g. '31' is Card Service Data, BER tag '43'; '73' is Card Capabilities, '47').
+// Decoded objects are therefore exposed with their BER-TLV equivalent tag.
+
+// DecodeCompact parses a sequence of COMPACT-TLV data objects.
+func DecodeCompact(data []byte) ([]bertlv.TLV, error) {
+	var packets []bertlv.TLV
+
+	for offset := 0; offset < len(data); {
+		header := data[offset]
+		length := int(header & 0x0F)
+		offset++
+
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("insufficient data for compact tag %X: expected %d bytes, %d left", header>>4, length, len(data)-offset)
+		}
+
+		packets = append(packets, bertlv.TLV{
+			Tag:   fmt.Sprintf("4%X", header>>4),
+			Value: data[offset : offset+length],
+		})
+		offset += length
+	}
+
+	return packets, nil
+}
+
+// EncodeCompact serializes data objects using the COMPACT-TLV encoding.
+// Each tag must be an interindustry '4N' tag and each value at most 15 bytes long.
+func EncodeCompact(packets []bertlv.TLV) ([]byte, error) {
+	var out []byte
+
+	for _, p := range packets {
+		tag := strings.ToUpper(p.Tag)
+		if len(tag) != 2 || tag[0] != '4' {
+			return nil, fmt.Errorf("tag %q has no COMPACT-TLV equivalent (expected '4N')", p.Tag)
+		}
+		number, err := hex.DecodeString("0" + tag[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %q: %w", p.Tag, err)
+		}
+		if len(p.Value) > 0x0F {
+			return nil, fmt.Errorf("tag %s: value too long for COMPACT-TLV (%d bytes)", p.Tag, len(p.Value))
+		}
+
+		out = append(out, number[0]<<4|byte(len(p.Value)))
+		out = append(out, p.Value...)
+	}
+
+	return out, nil
+}
+
+// UnmarshalCompact parses COMPACT-TLV data and maps it into a target Go struct.
+// Fields are declared with the BER-TLV equivalent tag, e.g. `tlv:"47"` for Card Capabilities.
+func UnmarshalCompact(data []byte, target interface{}) error {
+	packets, err := DecodeCompact(data)
+	if err != nil {
+		return fmt.Errorf("compact-tlv decode failed: %w", err)
+	}
+	return UnmarshalFromPackets(packets, target)
+}
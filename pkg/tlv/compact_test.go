@@ -0,0 +1,92 @@
+package tlv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/moov-io/bertlv"
+)
+
+func TestDecodeCompact(t *testing.T) {
+	t.Run("Historical Bytes Objects", func(t *testing.T) {
+		// Card service data (31), card capabilities (73)
+		got, err := DecodeCompact(Hex("31 C0", "73 BE 21 80", "F0"))
+		if err != nil {
+			t.Fatalf("DecodeCompact failed: %v", err)
+		}
+
+		want := []bertlv.TLV{
+			{Tag: "43", Value: Hex("C0")},
+			{Tag: "47", Value: Hex("BE2180")},
+			{Tag: "4F", Value: []byte{}},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Truncated Value", func(t *testing.T) {
+		if _, err := DecodeCompact(Hex("73 BE 21")); err == nil {
+			t.Error("Expected error")
+		}
+	})
+}
+
+func TestEncodeCompact(t *testing.T) {
+	input := Hex("31 C0", "73 BE 21 80")
+
+	packets, err := DecodeCompact(input)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	got, err := EncodeCompact(packets)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(got, input) {
+		t.Errorf("Round trip mismatch: got %X, want %X", got, input)
+	}
+
+	bad := []bertlv.TLV{
+		{Tag: "5A", Value: Hex("01")},
+		{Tag: "4G", Value: Hex("01")},
+		{Tag: "43", Value: make([]byte, 16)},
+	}
+	for _, p := range bad {
+		if _, err := EncodeCompact([]bertlv.TLV{p}); err == nil {
+			t.Errorf("Expected error for tag %s", p.Tag)
+		}
+	}
+}
+
+func TestUnmarshalCompact(t *testing.T) {
+	type historical struct {
+		CardServiceData  []byte       `tlv:"43,len=1"`
+		CardCapabilities []byte       `tlv:"47,len=1-3"`
+		Unknown          []bertlv.TLV `tlv:",unknown"`
+	}
+
+	var h historical
+	if err := UnmarshalCompact(Hex("31 C0", "73 BE 21 80", "52 1234"), &h); err != nil {
+		t.Fatalf("UnmarshalCompact failed: %v", err)
+	}
+
+	var sb strings.Builder
+	WriteStructFields(&sb, "ATR", h)
+
+	want := []string{
+		"    - ATR.CardServiceData (43): C0",
+		"    - ATR.CardCapabilities (47): BE2180",
+		"    - ATR.Unknown Tag 45: 1234",
+	}
+	if diff := cmp.Diff(want, strings.Split(sb.String(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := UnmarshalCompact(Hex("35 00"), &h); err == nil {
+		t.Error("Expected decode error")
+	}
+}
@@ -6,44 +6,100 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/moov-io/bertlv"
 )
 
-// WriteStructFields inspects a struct and writes its fields to the strings.Builder.
-// It joins lines with newlines but DOES NOT add a trailing newline, preventing artifacts in strings.Split.
-// If the builder is not empty, it prepends a newline to separate this block from previous content.
-func WriteStructFields(sb *strings.Builder, prefix string, s interface{}) {
+// REPORT BUILDING:
+// BuildNode walks a tagged struct by reflection and produces its report.Node:
+//
+//   - []byte fields become Fields, displayed according to their `fmt` tag.
+//   - []bertlv.TLV fields (unmapped tags) become "Unknown" Fields.
+//   - Nested structs, pointers to structs and slices of structs become Children.
+//     A slice element is named "Name[i]" (1-based).
+//
+// The `report` struct tag renames a child template (`report:"Proprietary"`),
+// or excludes a field from the report (`report:"-"`).
+// Empty values and empty templates are omitted.
+
+// BuildNode produces the report node of a tagged struct (or pointer to struct).
+// It returns nil for a nil pointer.
+func BuildNode(name string, s interface{}) *report.Node {
 	val := reflect.ValueOf(s)
 
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
-			return
+			return nil
 		}
 		val = val.Elem()
 	}
 
+	return buildNode(name, val)
+}
+
+func buildNode(name string, val reflect.Value) *report.Node {
+	node := &report.Node{Name: name}
 	typ := val.Type()
-	var lines []string
 
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Field(i)
 		fieldType := typ.Field(i)
 
-		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8 {
-			if line := formatByteSliceField(prefix, field, fieldType); line != "" {
-				lines = append(lines, line)
+		childName := fieldType.Name
+		if label := fieldType.Tag.Get("report"); label != "" {
+			if label == "-" {
+				continue
 			}
-			continue
+			childName = label
 		}
 
-		if field.Type() == reflect.TypeOf([]bertlv.TLV{}) {
-			if unknownLines := formatUnknownField(prefix, field); len(unknownLines) > 0 {
-				lines = append(lines, unknownLines...)
+		switch {
+		case isByteSlice(field):
+			if f, ok := byteSliceField(field, fieldType); ok {
+				node.Fields = append(node.Fields, f)
 			}
+		case field.Type() == reflect.TypeOf([]bertlv.TLV{}):
+			node.Fields = append(node.Fields, unknownFields(field)...)
+		case field.Type() == reflect.TypeOf([]Element{}):
 			continue
+		case isStructOrPtrToStruct(field):
+			node.Children = appendChild(node.Children, childName, field)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < field.Len(); j++ {
+				node.Children = appendChild(node.Children, fmt.Sprintf("%s[%d]", childName, j+1), field.Index(j))
+			}
+		}
+	}
+
+	return node
+}
+
+func appendChild(children []*report.Node, name string, field reflect.Value) []*report.Node {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return children
 		}
+		field = field.Elem()
 	}
 
+	if child := buildNode(name, field); !child.IsEmpty() {
+		children = append(children, child)
+	}
+	return children
+}
+
+// WriteStructFields inspects a struct and writes its fields to the strings.Builder.
+// Nested templates are not written (see BuildNode for the recursive report).
+// It joins lines with newlines but DOES NOT add a trailing newline, preventing artifacts in strings.Split.
+// If the builder is not empty, it prepends a newline to separate this block from previous content.
+func WriteStructFields(sb *strings.Builder, prefix string, s interface{}) {
+	node := BuildNode(prefix, s)
+	if node == nil {
+		return
+	}
+
+	lines := report.FieldLines(prefix, node.Fields)
+
 	if len(lines) > 0 {
 		if sb.Len() > 0 {
 			sb.WriteString("\n")
@@ -52,41 +108,45 @@ func WriteStructFields(sb *strings.Builder, prefix string, s interface{}) {
 	}
 }
 
-func formatByteSliceField(prefix string, field reflect.Value, fieldType reflect.StructField) string {
+func byteSliceField(field reflect.Value, fieldType reflect.StructField) (report.Field, bool) {
 	if field.IsNil() || field.Len() == 0 {
-		return ""
+		return report.Field{}, false
 	}
 
 	bytesVal := field.Bytes()
-	formatTag := fieldType.Tag.Get("fmt")
-	tlvTag := fieldType.Tag.Get("tlv")
-
-	name := fieldType.Name
-	if tlvTag != "" {
-		name = fmt.Sprintf("%s (%s)", name, tlvTag)
-	}
-
-	displayVal := formatByteValue(bytesVal, formatTag)
-	return fmt.Sprintf("    - %s.%s: %s", prefix, name, displayVal)
+	tlvTag, _, _ := strings.Cut(fieldType.Tag.Get("tlv"), ",")
+
+	return report.Field{
+		Name: fieldType.Name,
+		Tag:  strings.ToUpper(tlvTag),
+		Hex:  strings.ToUpper(hex.EncodeToString(bytesVal)),
+		Text: formatByteValue(bytesVal, fieldType.Tag.Get("fmt")),
+	}, true
 }
 
-func formatUnknownField(prefix string, field reflect.Value) []string {
+func unknownFields(field reflect.Value) []report.Field {
 	if field.IsNil() || field.Len() == 0 {
 		return nil
 	}
 
-	var lines []string
-	tlvs := field.Interface().([]bertlv.TLV)
-	for _, t := range tlvs {
-		valStr := strings.ToUpper(hex.EncodeToString(t.Value))
-		lines = append(lines, fmt.Sprintf("    - %s.Unknown Tag %s: %s", prefix, t.Tag, valStr))
+	var fields []report.Field
+	for _, t := range field.Interface().([]bertlv.TLV) {
+		value := getPacketRawData(t)
+		valStr := strings.ToUpper(hex.EncodeToString(value))
+		fields = append(fields, report.Field{
+			Name:    "Unknown",
+			Tag:     t.Tag,
+			Hex:     valStr,
+			Text:    valStr,
+			Unknown: true,
+		})
 	}
-	return lines
+	return fields
 }
 
 func formatByteValue(data []byte, format string) string {
 	switch format {
-	case "ascii":
+	case "ascii", "an", "ans":
 		return fmt.Sprintf("%X (%q)", data, MakeSafeASCII(data))
 	case "int":
 		var integer int
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/gregLibert/smart-card/pkg/report"
 	"github.com/moov-io/bertlv"
 )
 
@@ -79,6 +80,43 @@ func TestWriteStructFields(t *testing.T) {
 	}
 }
 
+type mockEntry struct {
+	AID []byte `tlv:"4F,mandatory"`
+}
+
+type mockRecord struct {
+	Entries []mockEntry `tlv:"61" report:"Entry"`
+	Inner   *MockTemplate
+	Hidden  *MockTemplate `report:"-"`
+	Empty   *MockTemplate
+	Raw     []Element `tlv:",raw"`
+}
+
+func TestBuildNode(t *testing.T) {
+	rec := mockRecord{
+		Entries: []mockEntry{{AID: []byte{0xA0, 0x01}}, {AID: []byte{0xA0, 0x02}}},
+		Inner:   &MockTemplate{Label: []byte("OK")},
+		Hidden:  &MockTemplate{FileID: []byte{0x01}},
+		Empty:   &MockTemplate{},
+		Raw:     []Element{{Tag: "61"}},
+	}
+
+	expected := []string{
+		"    - Entry[1].AID (4F): A001",
+		"    - Entry[2].AID (4F): A002",
+		`    - Inner.Label (50): 4F4B ("OK")`,
+	}
+
+	got := report.NodeLines(BuildNode("Record", &rec))
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if BuildNode("Nil", (*mockRecord)(nil)) != nil {
+		t.Error("BuildNode(nil) should return nil")
+	}
+}
+
 func TestMakeSafeASCII(t *testing.T) {
 	input := []byte{0x41, 0x42, 0x00, 0x1F, 0x7F, 0x43} // AB, null, US, DEL, C
 	want := "AB...C"                                    // 0x7F (127) is > 126, so it becomes dot
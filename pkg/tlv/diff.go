@@ -0,0 +1,229 @@
+package tlv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// STRUCTURAL DIFF:
+// Diff compares two BER-TLV blobs object by object instead of byte by byte.
+// Data objects are matched by tag and by occurrence (the second '61' of A is
+// compared to the second '61' of B), then each pair is compared recursively.
+//
+// Paths use the same notation as the validation report: tags separated by '/',
+// with a 1-based occurrence index when a tag appears more than once in a template
+// (e.g. "70/61[2]/4F").
+
+// DiffKind classifies a difference between two TLV blobs.
+type DiffKind int
+
+const (
+	// DiffAdded marks a data object present only in B.
+	DiffAdded DiffKind = iota
+	// DiffRemoved marks a data object present only in A.
+	DiffRemoved
+	// DiffChanged marks a data object whose value differs.
+	DiffChanged
+	// DiffReordered marks a template whose data objects appear in a different order.
+	DiffReordered
+	// DiffEncoding marks identical content with a different encoding (length form, padding).
+	DiffEncoding
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "ADDED"
+	case DiffRemoved:
+		return "REMOVED"
+	case DiffChanged:
+		return "CHANGED"
+	case DiffReordered:
+		return "REORDERED"
+	case DiffEncoding:
+		return "ENCODING"
+	default:
+		return fmt.Sprintf("DiffKind(%d)", int(k))
+	}
+}
+
+// Difference describes one structural difference.
+type Difference struct {
+	Kind DiffKind
+	Path string
+	// A and B hold the encoded data object (or the order of tags for DiffReordered) on each side.
+	A, B   []byte
+	Detail string
+}
+
+// DiffReport lists all the differences found between two TLV blobs.
+type DiffReport struct {
+	Differences []Difference
+}
+
+// Equal reports whether both blobs are structurally and byte-wise identical.
+func (r *DiffReport) Equal() bool {
+	return len(r.Differences) == 0
+}
+
+// Report builds the structured report of the differences.
+func (r *DiffReport) Report() *report.Report {
+	rep := report.New("TLV DIFF REPORT")
+	section := rep.AddSection("")
+
+	if r.Equal() {
+		section.Note("", "No differences.")
+		return rep
+	}
+
+	for _, d := range r.Differences {
+		section.Note(fmt.Sprintf("[%s] %s", d.Kind, d.Path), d.Detail)
+	}
+	return rep
+}
+
+// Describe generates a human-readable list of the differences.
+func (r *DiffReport) Describe() string {
+	return report.Text(r.Report())
+}
+
+// Diff compares two BER-TLV blobs structurally.
+func Diff(a, b []byte) (*DiffReport, error) {
+	elemsA, err := DecodeElements(a)
+	if err != nil {
+		return nil, fmt.Errorf("decoding A: %w", err)
+	}
+	elemsB, err := DecodeElements(b)
+	if err != nil {
+		return nil, fmt.Errorf("decoding B: %w", err)
+	}
+
+	return DiffElements(elemsA, elemsB), nil
+}
+
+// DiffElements compares two sets of byte-exact elements (see DecodeElements).
+func DiffElements(a, b []Element) *DiffReport {
+	report := &DiffReport{}
+	report.diffLevel("", a, b)
+	return report
+}
+
+// keyedElement is an element identified by its tag and occurrence in a template.
+type keyedElement struct {
+	key  string
+	elem Element
+}
+
+func (r *DiffReport) diffLevel(path string, a, b []Element) {
+	repeated := repeatedTags(a, b)
+	keysA, padA := keyElements(a, repeated)
+	keysB, padB := keyElements(b, repeated)
+
+	if padA != padB {
+		r.add(DiffEncoding, displayPath(path), nil, nil, fmt.Sprintf("padding bytes %d -> %d", padA, padB))
+	}
+
+	indexB := make(map[string]Element, len(keysB))
+	for _, k := range keysB {
+		indexB[k.key] = k.elem
+	}
+	indexA := make(map[string]bool, len(keysA))
+
+	var commonA []string
+	for _, k := range keysA {
+		indexA[k.key] = true
+		childPath := joinPath(path, k.key, 0)
+
+		other, found := indexB[k.key]
+		if !found {
+			r.add(DiffRemoved, childPath, k.elem.Bytes(), nil, fmt.Sprintf("%X", k.elem.Value))
+			continue
+		}
+		commonA = append(commonA, k.key)
+		r.diffPair(childPath, k.elem, other)
+	}
+
+	var commonB []string
+	for _, k := range keysB {
+		if !indexA[k.key] {
+			r.add(DiffAdded, joinPath(path, k.key, 0), nil, k.elem.Bytes(), fmt.Sprintf("%X", k.elem.Value))
+			continue
+		}
+		commonB = append(commonB, k.key)
+	}
+
+	orderA, orderB := strings.Join(commonA, ","), strings.Join(commonB, ",")
+	if orderA != orderB {
+		r.add(DiffReordered, displayPath(path), []byte(orderA), []byte(orderB), fmt.Sprintf("%s -> %s", orderA, orderB))
+	}
+}
+
+func (r *DiffReport) diffPair(path string, a, b Element) {
+	if a.Constructed && b.Constructed {
+		if len(a.Length) != len(b.Length) {
+			r.add(DiffEncoding, path, a.Bytes(), b.Bytes(), fmt.Sprintf("length field %X -> %X", a.Length, b.Length))
+		}
+		r.diffLevel(path, a.Children, b.Children)
+		return
+	}
+
+	if a.Constructed != b.Constructed || string(a.Value) != string(b.Value) {
+		r.add(DiffChanged, path, a.Bytes(), b.Bytes(), fmt.Sprintf("%X -> %X", a.Value, b.Value))
+		return
+	}
+
+	if string(a.Length) != string(b.Length) {
+		r.add(DiffEncoding, path, a.Bytes(), b.Bytes(), fmt.Sprintf("length field %X -> %X", a.Length, b.Length))
+	}
+}
+
+func (r *DiffReport) add(kind DiffKind, path string, a, b []byte, detail string) {
+	r.Differences = append(r.Differences, Difference{Kind: kind, Path: path, A: a, B: b, Detail: detail})
+}
+
+// repeatedTags returns the tags occurring more than once in either template.
+// Their occurrences are numbered on both sides so that they can be paired.
+func repeatedTags(a, b []Element) map[string]bool {
+	repeated := make(map[string]bool)
+	for _, elems := range [][]Element{a, b} {
+		counts := make(map[string]int)
+		for _, e := range elems {
+			counts[e.Tag]++
+			if counts[e.Tag] > 1 {
+				repeated[e.Tag] = true
+			}
+		}
+	}
+	return repeated
+}
+
+// keyElements assigns a path key to each element ("4F", or "61[2]" for repeated tags)
+// and counts the padding bytes of the template.
+func keyElements(elems []Element, repeated map[string]bool) ([]keyedElement, int) {
+	seen := make(map[string]int)
+	padding := 0
+
+	var keyed []keyedElement
+	for _, e := range elems {
+		if e.IsPadding() {
+			padding++
+			continue
+		}
+		seen[e.Tag]++
+		key := e.Tag
+		if repeated[e.Tag] {
+			key = fmt.Sprintf("%s[%d]", e.Tag, seen[e.Tag])
+		}
+		keyed = append(keyed, keyedElement{key: key, elem: e})
+	}
+	return keyed, padding
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
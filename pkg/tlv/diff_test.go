@@ -0,0 +1,86 @@
+package tlv
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	t.Run("Identical", func(t *testing.T) {
+		data := Hex("6F 0C", "84 02 1122", "A5 06 50 04 56495341")
+
+		report, err := Diff(data, data)
+		if err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+		if !report.Equal() {
+			t.Errorf("Expected no differences, got:\n%s", report.Describe())
+		}
+		if got := report.Describe(); got != "=== TLV DIFF REPORT ===\n    - No differences." {
+			t.Errorf("Unexpected report: %q", got)
+		}
+	})
+
+	t.Run("All Kinds", func(t *testing.T) {
+		a := Hex(
+			"70 17",
+			"61 09 4F 03 A00001 50 02 4142", // First application
+			"61 05 4F 03 A00002",            // Second application
+			"5F20 02 4142",                  // Cardholder name
+		)
+		b := Hex(
+			"70 81 1F",
+			"5F20 02 4142",
+			"61 09 4F 03 A00001 50 02 4143", // Label changed
+			"61 05 4F 03 A00002",
+			"61 05 4F 03 A00003", // Third application added
+			"00",
+		)
+
+		report, err := Diff(a, b)
+		if err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+
+		var got []string
+		for _, d := range report.Differences {
+			got = append(got, "["+d.Kind.String()+"] "+d.Path+": "+d.Detail)
+		}
+
+		want := []string{
+			"[ENCODING] 70: length field 17 -> 811F",
+			"[ENCODING] 70: padding bytes 0 -> 1",
+			"[CHANGED] 70/61[1]/50: 4142 -> 4143",
+			"[ADDED] 70/61[3]: 4F03A00003",
+			"[REORDERED] 70: 61[1],61[2],5F20 -> 5F20,61[1],61[2]",
+		}
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Differences mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Removed And Type Change", func(t *testing.T) {
+		report, err := Diff(Hex("84 02 1122", "5A 01 12"), Hex("84 81 02 1122"))
+		if err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+
+		want := "=== TLV DIFF REPORT ===\n" +
+			"    - [ENCODING] 84: length field 02 -> 8102\n" +
+			"    - [REMOVED] 5A: 12"
+		if got := report.Describe(); got != want {
+			t.Errorf("Report mismatch:\nGot:\n%s\nWant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		if _, err := Diff(Hex("84 05"), Hex("84 00")); err == nil {
+			t.Error("Expected error for A")
+		}
+		if _, err := Diff(Hex("84 00"), Hex("84 05")); err == nil {
+			t.Error("Expected error for B")
+		}
+	})
+}
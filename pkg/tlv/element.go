@@ -0,0 +1,216 @@
+package tlv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/moov-io/bertlv"
+)
+
+// BYTE-EXACT BER-TLV:
+// bertlv.Decode normalizes what it reads: '00' padding bytes are dropped and the
+// original length encoding is forgotten (a card may legally send '81 05' instead
+// of '05'). Re-encoding the result therefore does not always reproduce the card
+// response.
+//
+// Element keeps every detail of the original encoding (padding, length field,
+// element order and duplicates) so that EncodeElements(DecodeElements(x)) == x.
+//
+// A struct field tagged `tlv:",raw"` of type []Element receives the elements of
+// its template, in their original order, when the data is mapped with Unmarshal
+// or UnmarshalElements.
+
+// Element is a BER-TLV data object decoded with its exact original encoding.
+type Element struct {
+	// Tag is the upper-case hex tag ("9F38"). Padding bytes use the tag "00".
+	Tag string
+	// Length is the length field as found in the data (e.g. 81 05).
+	Length []byte
+	// Value is the raw value field. For constructed objects it holds the encoded children.
+	Value []byte
+	// Children contains the decoded children of a constructed object.
+	Children []Element
+	// Constructed reports whether bit 6 of the first tag byte is set.
+	Constructed bool
+}
+
+// IsPadding reports whether the element is a '00' filler byte found between data objects.
+func (e Element) IsPadding() bool {
+	return e.Tag == "00"
+}
+
+// IsMinimalLength reports whether the length field uses the shortest possible encoding.
+func (e Element) IsMinimalLength() bool {
+	if e.IsPadding() || len(e.Length) == 0 {
+		return true
+	}
+	return bytes.Equal(e.Length, encodeLength(len(e.content()), 0))
+}
+
+// Bytes re-encodes the element, reproducing its original encoding.
+func (e Element) Bytes() []byte {
+	if e.IsPadding() {
+		return []byte{0x00}
+	}
+
+	tag, _ := hex.DecodeString(e.Tag)
+	content := e.content()
+
+	out := append([]byte{}, tag...)
+	out = append(out, encodeLength(len(content), len(e.Length))...)
+	return append(out, content...)
+}
+
+// Packet converts the element into the normalized bertlv representation.
+func (e Element) Packet() bertlv.TLV {
+	if e.Constructed {
+		return bertlv.TLV{Tag: e.Tag, TLVs: ElementsToPackets(e.Children)}
+	}
+	return bertlv.TLV{Tag: e.Tag, Value: e.Value}
+}
+
+// content returns the value field, re-encoding the children of constructed objects
+// so that modifications made to Children are taken into account.
+func (e Element) content() []byte {
+	if e.Constructed && len(e.Children) > 0 {
+		return EncodeElements(e.Children)
+	}
+	return e.Value
+}
+
+// DecodeElements parses BER-TLV data while preserving its exact encoding.
+func DecodeElements(data []byte) ([]Element, error) {
+	var elements []Element
+
+	for offset := 0; offset < len(data); {
+		if data[offset] == 0x00 {
+			elements = append(elements, Element{Tag: "00"})
+			offset++
+			continue
+		}
+
+		elem, read, err := decodeElement(data[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("offset %d: %w", offset, err)
+		}
+		elements = append(elements, elem)
+		offset += read
+	}
+
+	return elements, nil
+}
+
+func decodeElement(data []byte) (Element, int, error) {
+	tagLen := 1
+	if data[0]&0x1F == 0x1F {
+		for tagLen < len(data) && data[tagLen]&0x80 != 0 {
+			tagLen++
+		}
+		tagLen++
+		if tagLen > len(data) {
+			return Element{}, 0, fmt.Errorf("tag is incomplete")
+		}
+	}
+	tag := data[:tagLen]
+
+	length, lenLen, err := decodeLength(data[tagLen:])
+	if err != nil {
+		return Element{}, 0, fmt.Errorf("reading length for tag %X: %w", tag, err)
+	}
+
+	start := tagLen + lenLen
+	if len(data)-start < length {
+		return Element{}, 0, fmt.Errorf("insufficient data for tag %X: expected %d bytes, %d left", tag, length, len(data)-start)
+	}
+
+	elem := Element{
+		Tag:         strings.ToUpper(hex.EncodeToString(tag)),
+		Length:      data[tagLen:start],
+		Value:       data[start : start+length],
+		Constructed: tag[0]&0x20 != 0,
+	}
+
+	if elem.Constructed {
+		children, err := DecodeElements(elem.Value)
+		if err != nil {
+			return Element{}, 0, fmt.Errorf("decoding composite %s: %w", elem.Tag, err)
+		}
+		elem.Children = children
+	}
+
+	return elem, start + length, nil
+}
+
+// decodeLength reads a BER length field and returns the length and the number of bytes used.
+func decodeLength(data []byte) (int, int, error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("length is empty")
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+
+	count := int(data[0] & 0x7F)
+	if count == 0 {
+		return 0, 0, fmt.Errorf("indefinite length form is not supported")
+	}
+	if count > 3 || len(data) < count+1 {
+		return 0, 0, fmt.Errorf("length is incomplete or too large")
+	}
+
+	length := 0
+	for _, b := range data[1 : count+1] {
+		length = length<<8 | int(b)
+	}
+	return length, count + 1, nil
+}
+
+// encodeLength encodes a BER length field. When form is greater than the minimal size,
+// the long form with (form - 1) length bytes is kept, reproducing non-minimal encodings.
+func encodeLength(length, form int) []byte {
+	var digits []byte
+	for l := length; l > 0; l >>= 8 {
+		digits = append([]byte{byte(l)}, digits...)
+	}
+
+	if form <= 1 && length < 0x80 {
+		return []byte{byte(length)}
+	}
+
+	for len(digits) < form-1 {
+		digits = append([]byte{0x00}, digits...)
+	}
+	return append([]byte{0x80 | byte(len(digits))}, digits...)
+}
+
+// EncodeElements re-encodes elements, reproducing the original bytes of decoded data.
+func EncodeElements(elements []Element) []byte {
+	var out []byte
+	for _, e := range elements {
+		out = append(out, e.Bytes()...)
+	}
+	return out
+}
+
+// ElementsToPackets converts elements to the normalized bertlv representation, dropping padding.
+func ElementsToPackets(elements []Element) []bertlv.TLV {
+	var packets []bertlv.TLV
+	for _, e := range elements {
+		if !e.IsPadding() {
+			packets = append(packets, e.Packet())
+		}
+	}
+	return packets
+}
+
+// FindElement returns the first element carrying the given tag at the current level.
+func FindElement(elements []Element, tag string) (Element, bool) {
+	for _, e := range elements {
+		if strings.EqualFold(e.Tag, tag) {
+			return e, true
+		}
+	}
+	return Element{}, false
+}
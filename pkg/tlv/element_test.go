@@ -0,0 +1,124 @@
+package tlv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/moov-io/bertlv"
+)
+
+func TestDecodeElements_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"Minimal Encoding", Hex("6F 0C", "84 02 1122", "A5 06 50 04 56495341")},
+		{"Non Minimal Lengths", Hex("6F 81 0F", "84 81 02 1122", "A5 82 0006 50 04 56495341")},
+		{"Padding Between Objects", Hex("00 70 08", "5A 02 1234", "00 00", "8C 00", "00")},
+		{"Duplicates Kept In Order", Hex("61 03 4F 01 01", "50 01 41", "61 03 4F 01 02")},
+		{"Long Value", append(Hex("9F46 81 90"), bytes.Repeat([]byte{0xAA}, 0x90)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elems, err := DecodeElements(tt.input)
+			if err != nil {
+				t.Fatalf("DecodeElements failed: %v", err)
+			}
+
+			if got := EncodeElements(elems); !bytes.Equal(got, tt.input) {
+				t.Errorf("Round trip mismatch:\nGot:  %X\nWant: %X", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestDecodeElements_Errors(t *testing.T) {
+	bad := [][]byte{
+		Hex("9F"),          // Incomplete tag
+		Hex("84"),          // Missing length
+		Hex("84 80"),       // Indefinite length
+		Hex("84 82 01"),    // Incomplete length
+		Hex("84 03 1122"),  // Truncated value
+		Hex("6F 02 84 05"), // Broken child
+	}
+
+	for _, data := range bad {
+		if _, err := DecodeElements(data); err == nil {
+			t.Errorf("Expected error for %X", data)
+		}
+	}
+}
+
+func TestElement_Details(t *testing.T) {
+	elems, err := DecodeElements(Hex("6F 81 07", "84 02 1122", "A5 01 00"))
+	if err != nil {
+		t.Fatalf("DecodeElements failed: %v", err)
+	}
+
+	fci := elems[0]
+	if fci.IsMinimalLength() {
+		t.Error("81 07 should be reported as non-minimal")
+	}
+	if !fci.Children[0].IsMinimalLength() {
+		t.Error("02 should be reported as minimal")
+	}
+
+	// Modifying a child keeps the length form of its parent
+	fci.Children[0].Value = Hex("112233")
+	if got, want := fci.Bytes(), Hex("6F 81 08", "84 03 112233", "A5 01 00"); !bytes.Equal(got, want) {
+		t.Errorf("Re-encoding mismatch: got %X, want %X", got, want)
+	}
+
+	aid, found := FindElement(fci.Children, "84")
+	if !found || !bytes.Equal(aid.Value, Hex("112233")) {
+		t.Errorf("FindElement failed: %v", aid)
+	}
+	if _, found := FindElement(fci.Children, "50"); found {
+		t.Error("Tag 50 should not be found")
+	}
+}
+
+func TestUnmarshal_RawField(t *testing.T) {
+	type proprietary struct {
+		Label []byte    `tlv:"50"`
+		Raw   []Element `tlv:",raw"`
+	}
+	type fci struct {
+		DFName      []byte       `tlv:"84"`
+		Proprietary proprietary  `tlv:"A5"`
+		Raw         []Element    `tlv:",raw"`
+		Unknown     []bertlv.TLV `tlv:",unknown"`
+	}
+
+	input := Hex("84 81 02 1122", "00", "A5 06 50 04 56495341", "DF01 01 FF")
+
+	var res fci
+	if err := Unmarshal(input, &res); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if got := EncodeElements(res.Raw); !bytes.Equal(got, input) {
+		t.Errorf("Raw field does not reproduce input:\nGot:  %X\nWant: %X", got, input)
+	}
+	if got := EncodeElements(res.Proprietary.Raw); !bytes.Equal(got, Hex("50 04 56495341")) {
+		t.Errorf("Nested raw field mismatch: %X", got)
+	}
+	if diff := cmp.Diff(Hex("1122"), res.DFName); diff != "" {
+		t.Errorf("DFName mismatch: %s", diff)
+	}
+	if len(res.Unknown) != 1 || res.Unknown[0].Tag != "DF01" {
+		t.Errorf("Unknown mismatch: %v", res.Unknown)
+	}
+
+	// Raw fields stay empty when the byte-exact encoding is not available
+	var fromPackets fci
+	packets, _ := bertlv.Decode(input)
+	if err := UnmarshalFromPackets(packets, &fromPackets); err != nil {
+		t.Fatalf("UnmarshalFromPackets failed: %v", err)
+	}
+	if fromPackets.Raw != nil {
+		t.Error("Raw should be nil without byte-exact elements")
+	}
+}
@@ -1,5 +1,9 @@
 // Package tlv provides high-level utilities for parsing and mapping BER-TLV
 // (Basic Encoding Rules - Tag-Length-Value) data into Go structures using struct tags.
+//
+// The SIMPLE-TLV and COMPACT-TLV encodings of ISO/IEC 7816-4 are supported as well:
+// they are decoded into the same bertlv.TLV representation and mapped with the same
+// struct tags (see UnmarshalSimple and UnmarshalCompact).
 package tlv
 
 import (
@@ -17,17 +21,47 @@ type Unmarshaler interface {
 }
 
 // Unmarshal parses raw BER-TLV data and maps it into a target Go struct.
+// Constraint violations declared in the struct tags are reported through a
+// *ValidationError, returned only after the target has been fully populated.
 func Unmarshal(data []byte, target interface{}) error {
-	packets, err := bertlv.Decode(data)
+	elements, err := DecodeElements(data)
 	if err != nil {
 		return fmt.Errorf("bertlv decode failed: %w", err)
 	}
-	return UnmarshalFromPackets(packets, target)
+	return UnmarshalElements(elements, target)
+}
+
+// UnmarshalElements maps byte-exact elements (see DecodeElements) to a target struct.
+// It behaves like UnmarshalFromPackets and additionally fills the `tlv:",raw"` fields.
+func UnmarshalElements(elements []Element, target interface{}) error {
+	return unmarshalWithReport(ElementsToPackets(elements), elements, target)
 }
 
 // UnmarshalFromPackets maps a slice of pre-decoded bertlv.TLV objects to a target struct.
 // It supports multiple occurrences of the same tag if the target field is a slice.
+//
+// Structural problems (bad target, undecodable nested template) abort the mapping.
+// Violations of the declarative constraints (mandatory, len=, fmt) do not: every
+// violation is collected and returned as a *ValidationError.
 func UnmarshalFromPackets(packets []bertlv.TLV, target interface{}) error {
+	return unmarshalWithReport(packets, nil, target)
+}
+
+func unmarshalWithReport(packets []bertlv.TLV, level []Element, target interface{}) error {
+	report := &ValidationError{}
+	if err := unmarshalPackets(packets, level, target, "", report); err != nil {
+		return err
+	}
+	if len(report.Errors) > 0 {
+		return report
+	}
+	return nil
+}
+
+// unmarshalPackets is the recursive worker behind UnmarshalFromPackets.
+// The level holds the byte-exact elements of the template when they are known (nil otherwise),
+// and the path identifies the template for validation messages.
+func unmarshalPackets(packets []bertlv.TLV, level []Element, target interface{}, path string, report *ValidationError) error {
 	v := reflect.ValueOf(target)
 	if v.Kind() != reflect.Ptr || v.IsNil() {
 		return fmt.Errorf("target must be a non-nil pointer")
@@ -35,6 +69,14 @@ func UnmarshalFromPackets(packets []bertlv.TLV, target interface{}) error {
 	v = v.Elem()
 	t := v.Type()
 
+	// Elements parallel to packets (same indexes), padding excluded.
+	var elements []Element
+	for _, e := range level {
+		if !e.IsPadding() {
+			elements = append(elements, e)
+		}
+	}
+
 	consumedIndices := make(map[int]bool)
 
 	for i := 0; i < v.NumField(); i++ {
@@ -42,43 +84,88 @@ func UnmarshalFromPackets(packets []bertlv.TLV, target interface{}) error {
 		fieldType := t.Field(i)
 		tagConfig := fieldType.Tag.Get("tlv")
 
-		if tagConfig == "" || tagConfig == ",unknown" || fieldType.Name == "Unknown" {
+		if tagConfig == "" || fieldType.Name == "Unknown" {
 			continue
 		}
 
-		tagHex := strings.ToUpper(strings.Split(tagConfig, ",")[0])
+		opts, err := parseFieldOptions(tagConfig)
+		if err != nil {
+			return err
+		}
+		if opts.unknown {
+			continue
+		}
+		if opts.raw {
+			if level != nil && field.Type() == reflect.TypeOf([]Element{}) {
+				field.Set(reflect.ValueOf(level))
+			}
+			continue
+		}
+
+		occurrences := 0
 
 		// Find all packets matching this tag
 		for idx, packet := range packets {
-			if strings.ToUpper(packet.Tag) == tagHex {
-				if err := mapPacketToField(packet, field); err != nil {
-					return err
-				}
-				consumedIndices[idx] = true
+			if strings.ToUpper(packet.Tag) != opts.tag {
+				continue
+			}
+			occurrences++
+			consumedIndices[idx] = true
+
+			fieldPath := joinPath(path, opts.tag, 0)
+			if field.Kind() == reflect.Slice && !isByteSlice(field) {
+				fieldPath = joinPath(path, opts.tag, occurrences)
 			}
+
+			checkConstraints(packet, fieldType, opts, fieldPath, report)
+
+			var elem *Element
+			if idx < len(elements) {
+				elem = &elements[idx]
+			}
+
+			if err := mapPacketToField(packet, elem, field, fieldPath, report); err != nil {
+				return err
+			}
+		}
+
+		if opts.mandatory && occurrences == 0 {
+			report.add(joinPath(path, opts.tag, 0), fieldType.Name, "mandatory tag missing")
 		}
 	}
 
 	return handleUnknownFields(v, t, packets, consumedIndices)
 }
 
+// checkConstraints evaluates the len= option and the fmt data format of a packet.
+func checkConstraints(packet bertlv.TLV, fieldType reflect.StructField, opts fieldOptions, path string, report *ValidationError) {
+	value := getPacketRawData(packet)
+
+	if msg := opts.checkLength(value); msg != "" {
+		report.add(path, fieldType.Name, "%s", msg)
+	}
+	if msg := checkFormat(value, fieldType.Tag.Get("fmt")); msg != "" {
+		report.add(path, fieldType.Name, "%s", msg)
+	}
+}
+
 // mapPacketToField dispatches the TLV data to the appropriate reflection logic.
-func mapPacketToField(packet bertlv.TLV, field reflect.Value) error {
+func mapPacketToField(packet bertlv.TLV, elem *Element, field reflect.Value, path string, report *ValidationError) error {
 	// If it's a slice of structs (but not []byte), we grow the slice and use the last element
 	if field.Kind() == reflect.Slice && !isByteSlice(field) {
 		newElem := reflect.New(field.Type().Elem()).Elem()
-		if err := decodeToValue(packet, newElem); err != nil {
+		if err := decodeToValue(packet, elem, newElem, path, report); err != nil {
 			return err
 		}
 		field.Set(reflect.Append(field, newElem))
 		return nil
 	}
 
-	return decodeToValue(packet, field)
+	return decodeToValue(packet, elem, field, path, report)
 }
 
 // decodeToValue handles the leaf-node decoding logic (Custom Unmarshaler, ByteSlice, Struct, etc.)
-func decodeToValue(packet bertlv.TLV, field reflect.Value) error {
+func decodeToValue(packet bertlv.TLV, elem *Element, field reflect.Value, path string, report *ValidationError) error {
 	// 1. Custom Unmarshaler
 	if field.CanAddr() {
 		if u, ok := field.Addr().Interface().(Unmarshaler); ok {
@@ -101,15 +188,42 @@ func decodeToValue(packet bertlv.TLV, field reflect.Value) error {
 	// 4. Nested Structures
 	if isStructOrPtrToStruct(field) {
 		targetField := getTargetField(field)
-		if len(packet.TLVs) > 0 {
-			return UnmarshalFromPackets(packet.TLVs, targetField.Interface())
+		children, level, err := nestedContent(packet, elem)
+		if err != nil {
+			return err
 		}
-		return Unmarshal(packet.Value, targetField.Interface())
+		return unmarshalPackets(children, level, targetField.Interface(), path, report)
 	}
 
 	return nil
 }
 
+// nestedContent returns the children of a template, decoding the value of primitive
+// packets mapped to a struct. The byte-exact children are returned when known.
+func nestedContent(packet bertlv.TLV, elem *Element) ([]bertlv.TLV, []Element, error) {
+	if elem != nil {
+		level := elem.Children
+		if !elem.Constructed {
+			decoded, err := DecodeElements(elem.Value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("bertlv decode failed: %w", err)
+			}
+			level = decoded
+		}
+		return ElementsToPackets(level), level, nil
+	}
+
+	if len(packet.TLVs) > 0 {
+		return packet.TLVs, nil, nil
+	}
+
+	decoded, err := bertlv.Decode(packet.Value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bertlv decode failed: %w", err)
+	}
+	return decoded, nil, nil
+}
+
 func handleUnknownFields(v reflect.Value, t reflect.Type, packets []bertlv.TLV, consumed map[int]bool) error {
 	unknownField, found := findUnknownField(v, t)
 	if !found {
@@ -132,7 +246,7 @@ func handleUnknownFields(v reflect.Value, t reflect.Type, packets []bertlv.TLV,
 func findUnknownField(v reflect.Value, t reflect.Type) (reflect.Value, bool) {
 	for i := 0; i < v.NumField(); i++ {
 		tag := t.Field(i).Tag.Get("tlv")
-		if tag == ",unknown" || t.Field(i).Name == "Unknown" {
+		if strings.HasPrefix(tag, ",unknown") || t.Field(i).Name == "Unknown" {
 			return v.Field(i), true
 		}
 	}
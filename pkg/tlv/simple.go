@@ -0,0 +1,97 @@
+package tlv
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/moov-io/bertlv"
+)
+
+// SIMPLE-TLV Logic according to ISO/IEC 7816-4.
+//
+// A SIMPLE-TLV data object is made of:
+//   - Tag:    1 byte, from '01' to 'FE' ('00' and 'FF' are invalid).
+//   - Length: 1 byte from '00' to 'FE', or 3 bytes 'FF' followed by a 16-bit value (0 to 65535).
+//   - Value:  Length bytes, without any nested structure.
+//
+// Decoded objects are exposed as bertlv.TLV values (Tag is a 2-digit hex string,
+// TLVs is always empty), so they can be mapped with the usual struct tags.
+
+// DecodeSimple parses a sequence of SIMPLE-TLV data objects.
+func DecodeSimple(data []byte) ([]bertlv.TLV, error) {
+	var packets []bertlv.TLV
+
+	for offset := 0; offset < len(data); {
+		tag := data[offset]
+		if tag == 0x00 || tag == 0xFF {
+			return nil, fmt.Errorf("invalid SIMPLE-TLV tag %02X at offset %d", tag, offset)
+		}
+		offset++
+
+		if offset >= len(data) {
+			return nil, fmt.Errorf("missing length for tag %02X", tag)
+		}
+
+		length := int(data[offset])
+		offset++
+
+		if length == 0xFF {
+			if offset+2 > len(data) {
+				return nil, fmt.Errorf("incomplete 3-byte length for tag %02X", tag)
+			}
+			length = int(data[offset])<<8 | int(data[offset+1])
+			offset += 2
+		}
+
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("insufficient data for tag %02X: expected %d bytes, %d left", tag, length, len(data)-offset)
+		}
+
+		packets = append(packets, bertlv.TLV{
+			Tag:   fmt.Sprintf("%02X", tag),
+			Value: data[offset : offset+length],
+		})
+		offset += length
+	}
+
+	return packets, nil
+}
+
+// EncodeSimple serializes data objects using the SIMPLE-TLV encoding.
+// The shortest length form is always used.
+func EncodeSimple(packets []bertlv.TLV) ([]byte, error) {
+	var out []byte
+
+	for _, p := range packets {
+		tag, err := hex.DecodeString(p.Tag)
+		if err != nil || len(tag) != 1 || tag[0] == 0x00 || tag[0] == 0xFF {
+			return nil, fmt.Errorf("invalid SIMPLE-TLV tag %q", p.Tag)
+		}
+		if len(p.TLVs) > 0 {
+			return nil, fmt.Errorf("tag %s: SIMPLE-TLV objects cannot be constructed", p.Tag)
+		}
+
+		length := len(p.Value)
+		switch {
+		case length < 0xFF:
+			out = append(out, tag[0], byte(length))
+		case length <= 0xFFFF:
+			out = append(out, tag[0], 0xFF, byte(length>>8), byte(length))
+		default:
+			return nil, fmt.Errorf("tag %s: value too long for SIMPLE-TLV (%d bytes)", p.Tag, length)
+		}
+		out = append(out, p.Value...)
+	}
+
+	return out, nil
+}
+
+// UnmarshalSimple parses SIMPLE-TLV data and maps it into a target Go struct.
+// The struct tags follow the same rules as Unmarshal, e.g. `tlv:"01,mandatory"`.
+func UnmarshalSimple(data []byte, target interface{}) error {
+	packets, err := DecodeSimple(data)
+	if err != nil {
+		return fmt.Errorf("simple-tlv decode failed: %w", err)
+	}
+	return UnmarshalFromPackets(packets, target)
+}
@@ -0,0 +1,117 @@
+package tlv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/moov-io/bertlv"
+)
+
+func TestDecodeSimple(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAB}, 300)
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []bertlv.TLV
+		wantErr bool
+	}{
+		{
+			name:  "Short Lengths",
+			input: Hex("01 02 1122", "80 00", "FE 01 33"),
+			want: []bertlv.TLV{
+				{Tag: "01", Value: Hex("1122")},
+				{Tag: "80", Value: []byte{}},
+				{Tag: "FE", Value: Hex("33")},
+			},
+		},
+		{
+			name:  "Three Byte Length",
+			input: append(Hex("A5 FF 012C"), long...),
+			want:  []bertlv.TLV{{Tag: "A5", Value: long}},
+		},
+		{name: "Invalid Tag 00", input: Hex("00 01 11"), wantErr: true},
+		{name: "Invalid Tag FF", input: Hex("FF 01 11"), wantErr: true},
+		{name: "Missing Length", input: Hex("01"), wantErr: true},
+		{name: "Truncated Long Length", input: Hex("01 FF 01"), wantErr: true},
+		{name: "Truncated Value", input: Hex("01 03 1122"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeSimple(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeSimple() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEncodeSimple(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAB}, 255)
+
+	t.Run("Round Trip", func(t *testing.T) {
+		input := append(Hex("01 02 1122", "02 00", "03 FF 00FF"), long...)
+
+		packets, err := DecodeSimple(input)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+
+		got, err := EncodeSimple(packets)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Errorf("Round trip mismatch:\nGot:  %X\nWant: %X", got, input)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		bad := [][]bertlv.TLV{
+			{{Tag: "9F02", Value: Hex("01")}},
+			{{Tag: "FF", Value: Hex("01")}},
+			{{Tag: "70", TLVs: []bertlv.TLV{{Tag: "01"}}}},
+			{{Tag: "01", Value: make([]byte, 0x10000)}},
+		}
+		for _, packets := range bad {
+			if _, err := EncodeSimple(packets); err == nil {
+				t.Errorf("Expected error for %v", packets[0].Tag)
+			}
+		}
+	})
+}
+
+func TestUnmarshalSimple(t *testing.T) {
+	type simpleFile struct {
+		Identifier []byte       `tlv:"01,mandatory,len=2"`
+		Name       []byte       `tlv:"02" fmt:"ascii"`
+		Unknown    []bertlv.TLV `tlv:",unknown"`
+	}
+
+	var file simpleFile
+	if err := UnmarshalSimple(Hex("01 02 3F00", "02 03 414243", "7F 01 00"), &file); err != nil {
+		t.Fatalf("UnmarshalSimple failed: %v", err)
+	}
+
+	var sb strings.Builder
+	WriteStructFields(&sb, "File", file)
+
+	want := []string{
+		"    - File.Identifier (01): 3F00",
+		`    - File.Name (02): 414243 ("ABC")`,
+		"    - File.Unknown Tag 7F: 00",
+	}
+	if diff := cmp.Diff(want, strings.Split(sb.String(), "\n")); diff != "" {
+		t.Errorf("Describe mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := UnmarshalSimple(Hex("01"), &file); err == nil {
+		t.Error("Expected decode error")
+	}
+}
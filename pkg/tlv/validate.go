@@ -0,0 +1,224 @@
+package tlv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gregLibert/smart-card/pkg/report"
+)
+
+// DECLARATIVE CONSTRAINTS:
+// The `tlv` struct tag accepts options after the tag number, separated by commas:
+//
+//   - mandatory: the data object must be present at least once.
+//   - len=N or len=MIN-MAX: the length of the value field (in bytes) must match.
+//
+// The `fmt` struct tag, besides driving the display, also declares the EMV data
+// format of the value. The following formats are checked:
+//
+//   - n:   Numeric, BCD encoded, every nibble in the range 0-9.
+//   - cn:  Compressed numeric, BCD digits left justified and padded with trailing 'F'.
+//   - an:  Alphanumeric, ASCII letters and digits only.
+//   - ans: Alphanumeric special, printable ASCII characters (0x20-0x7E).
+//
+// Violations do not stop the mapping: the target struct is fully populated and
+// all violations are returned together in a *ValidationError, so callers can
+// decide whether to treat them as warnings or as hard errors.
+
+// unbounded is the maximum length of a field without a len= option.
+const unbounded = -1
+
+// fieldOptions holds the parsed content of a `tlv` struct tag.
+type fieldOptions struct {
+	tag       string
+	unknown   bool
+	raw       bool
+	mandatory bool
+	minLen    int
+	maxLen    int // unbounded (-1) without a len= option
+}
+
+// parseFieldOptions interprets a `tlv` struct tag such as "4F,mandatory,len=5-16".
+func parseFieldOptions(config string) (fieldOptions, error) {
+	parts := strings.Split(config, ",")
+	opts := fieldOptions{tag: strings.ToUpper(strings.TrimSpace(parts[0])), maxLen: unbounded}
+
+	for _, raw := range parts[1:] {
+		opt := strings.TrimSpace(raw)
+		switch {
+		case opt == "unknown":
+			opts.unknown = true
+		case opt == "raw":
+			opts.raw = true
+		case opt == "mandatory":
+			opts.mandatory = true
+		case strings.HasPrefix(opt, "len="):
+			minLen, maxLen, err := parseLengthRange(strings.TrimPrefix(opt, "len="))
+			if err != nil {
+				return opts, fmt.Errorf("invalid tlv tag %q: %w", config, err)
+			}
+			opts.minLen, opts.maxLen = minLen, maxLen
+		case opt == "":
+			continue
+		default:
+			return opts, fmt.Errorf("invalid tlv tag %q: unknown option %q", config, opt)
+		}
+	}
+
+	return opts, nil
+}
+
+// parseLengthRange parses "8" (exact length) or "5-16" (inclusive range).
+func parseLengthRange(spec string) (int, int, error) {
+	low, high, isRange := strings.Cut(spec, "-")
+
+	minLen, err := strconv.Atoi(low)
+	if err != nil || minLen < 0 {
+		return 0, 0, fmt.Errorf("bad length %q", spec)
+	}
+	if !isRange {
+		return minLen, minLen, nil
+	}
+
+	maxLen, err := strconv.Atoi(high)
+	if err != nil || maxLen < minLen {
+		return 0, 0, fmt.Errorf("bad length range %q", spec)
+	}
+	return minLen, maxLen, nil
+}
+
+// checkLength verifies the value length against the len= option.
+func (o fieldOptions) checkLength(value []byte) string {
+	if o.maxLen == unbounded {
+		return ""
+	}
+	if len(value) >= o.minLen && len(value) <= o.maxLen {
+		return ""
+	}
+	if o.minLen == o.maxLen {
+		return fmt.Sprintf("length %d, expected %d", len(value), o.minLen)
+	}
+	return fmt.Sprintf("length %d, expected %d-%d", len(value), o.minLen, o.maxLen)
+}
+
+// checkFormat verifies the value content against an EMV data format.
+// Formats that are purely informative (ascii, int, b...) are never rejected.
+func checkFormat(value []byte, format string) string {
+	switch format {
+	case "n":
+		for _, b := range value {
+			if b>>4 > 9 || b&0x0F > 9 {
+				return fmt.Sprintf("value %X is not numeric (n)", value)
+			}
+		}
+	case "cn":
+		if !isCompressedNumeric(value) {
+			return fmt.Sprintf("value %X is not compressed numeric (cn)", value)
+		}
+	case "an":
+		for _, b := range value {
+			if !isAlphaNumeric(b) {
+				return fmt.Sprintf("value %X is not alphanumeric (an)", value)
+			}
+		}
+	case "ans":
+		for _, b := range value {
+			if b < 0x20 || b > 0x7E {
+				return fmt.Sprintf("value %X is not alphanumeric special (ans)", value)
+			}
+		}
+	}
+	return ""
+}
+
+func isCompressedNumeric(value []byte) bool {
+	padding := false
+	for _, b := range value {
+		for _, nibble := range []byte{b >> 4, b & 0x0F} {
+			switch {
+			case nibble == 0x0F:
+				padding = true
+			case padding || nibble > 9:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isAlphaNumeric(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
+
+// FieldError describes a single constraint violation.
+type FieldError struct {
+	// Path locates the data object, e.g. "61[2]/4F" for the AID of the second
+	// application template. Occurrence indexes start at 1.
+	Path    string
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", e.Path, e.Field, e.Message)
+}
+
+// ValidationError gathers every constraint violation found during a mapping.
+// It is returned by Unmarshal and UnmarshalFromPackets once the target has been
+// completely populated.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("%d constraint violation(s): %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap exposes the individual violations to errors.Is and errors.As.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		errs = append(errs, fe)
+	}
+	return errs
+}
+
+// Report builds the structured report of all violations.
+func (e *ValidationError) Report() *report.Report {
+	r := report.New("TLV VALIDATION REPORT")
+	section := r.AddSection("")
+	for _, fe := range e.Errors {
+		section.Note(fmt.Sprintf("%s (%s)", fe.Path, fe.Field), fe.Message)
+	}
+	return r
+}
+
+// Describe generates a human-readable list of all violations.
+func (e *ValidationError) Describe() string {
+	return report.Text(e.Report())
+}
+
+func (e *ValidationError) add(path, field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &FieldError{
+		Path:    path,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// joinPath appends a tag (and its 1-based occurrence when index > 0) to a parent path.
+func joinPath(parent, tag string, index int) string {
+	elem := tag
+	if index > 0 {
+		elem = fmt.Sprintf("%s[%d]", tag, index)
+	}
+	if parent == "" {
+		return elem
+	}
+	return parent + "/" + elem
+}
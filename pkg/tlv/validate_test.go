@@ -0,0 +1,130 @@
+package tlv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type constrainedApp struct {
+	AID      []byte `tlv:"4F,mandatory,len=5-16"`
+	Label    []byte `tlv:"50,mandatory,len=1-16" fmt:"ans"`
+	Priority []byte `tlv:"87,len=1"`
+	Language []byte `tlv:"5F2D" fmt:"an"`
+}
+
+type constrainedDirectory struct {
+	Apps []constrainedApp `tlv:"61,mandatory"`
+	PAN  []byte           `tlv:"5A" fmt:"n"`
+	Name []byte           `tlv:"9F0B" fmt:"cn"`
+}
+
+func TestUnmarshal_Constraints(t *testing.T) {
+	t.Run("Valid Data", func(t *testing.T) {
+		data := Hex(
+			"61 10",
+			"4F 05 A000000003",
+			"50 04 56495341", // "VISA"
+			"87 01 01",
+			"5A 02 1234",
+		)
+
+		var dir constrainedDirectory
+		if err := Unmarshal(data, &dir); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("All Violations Reported", func(t *testing.T) {
+		data := Hex(
+			"61 10",
+			"4F 05 A000000003",
+			"50 04 56495341",
+			"87 01 01",
+			"61 0D", // Second app: AID too short, label missing
+			"4F 02 A000",
+			"87 02 0102",   // Priority is one byte
+			"5F2D 02 652D", // "e-" is not alphanumeric
+			"5A 02 12A4",   // Not BCD
+			"9F0B 02 12F3", // Digit after padding
+		)
+
+		var dir constrainedDirectory
+		err := Unmarshal(data, &dir)
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("Expected *ValidationError, got %v", err)
+		}
+
+		// The structure is still fully populated
+		if len(dir.Apps) != 2 || len(dir.Apps[1].AID) != 2 {
+			t.Errorf("Target not populated: %+v", dir)
+		}
+
+		var got []string
+		for _, fe := range verr.Errors {
+			got = append(got, fe.Path+": "+fe.Message)
+		}
+
+		want := []string{
+			"61[2]/4F: length 2, expected 5-16",
+			"61[2]/50: mandatory tag missing",
+			"61[2]/87: length 2, expected 1",
+			"61[2]/5F2D: value 652D is not alphanumeric (an)",
+			"5A: value 12A4 is not numeric (n)",
+			"9F0B: value 12F3 is not compressed numeric (cn)",
+		}
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Violations mismatch (-want +got):\n%s", diff)
+		}
+
+		if !strings.HasPrefix(verr.Describe(), "=== TLV VALIDATION REPORT ===\n    - 61[2]/4F (AID): ") {
+			t.Errorf("Unexpected report:\n%s", verr.Describe())
+		}
+	})
+
+	t.Run("Missing Mandatory Template", func(t *testing.T) {
+		var dir constrainedDirectory
+		err := Unmarshal(Hex("5A 01 12"), &dir)
+
+		var fe *FieldError
+		if !errors.As(err, &fe) || fe.Path != "61" || fe.Field != "Apps" {
+			t.Errorf("Expected violation on 61, got %v", err)
+		}
+	})
+
+	t.Run("Zero Length", func(t *testing.T) {
+		var exact struct {
+			RFU []byte `tlv:"DF01,len=0"`
+		}
+		var zeroRange struct {
+			RFU []byte `tlv:"DF01,len=0-0"`
+		}
+
+		for _, target := range []interface{}{&exact, &zeroRange} {
+			var fe *FieldError
+			if err := Unmarshal(Hex("DF01 01 00"), target); !errors.As(err, &fe) || fe.Path != "DF01" {
+				t.Errorf("%T: expected a length violation on DF01, got %v", target, err)
+			}
+			if err := Unmarshal(Hex("DF01 00"), target); err != nil {
+				t.Errorf("%T: expected an empty value to be accepted, got %v", target, err)
+			}
+		}
+	})
+
+	t.Run("Invalid Tag Options", func(t *testing.T) {
+		var target struct {
+			AID []byte `tlv:"4F,len=abc"`
+		}
+		err := Unmarshal(Hex("4F 01 00"), &target)
+
+		var verr *ValidationError
+		if err == nil || errors.As(err, &verr) {
+			t.Errorf("Expected a configuration error, got %v", err)
+		}
+	})
+}